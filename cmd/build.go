@@ -1,13 +1,19 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"github.com/johanfylling/odm/printer"
 	"github.com/johanfylling/odm/proj"
 	"github.com/johanfylling/odm/utils"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 )
 
 var (
@@ -17,28 +23,81 @@ var (
 
 func init() {
 	var noUpdate bool
+	var strict bool
+	var frozen bool
+	var fromArtifact string
+	var configMap string
+	var secret string
+	var k8sPolicies bool
 
 	var buildCmd = &cobra.Command{
 		Use:   "build",
 		Short: "Build OPA bundle",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if k8sPolicies && configMap == "" && secret == "" {
+				return fmt.Errorf("--k8s-policies requires --k8s-configmap or --k8s-secret")
+			}
+			for _, value := range []string{configMap, secret} {
+				if value != "" {
+					if _, err := parseK8sResource("", value); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			projPath := "."
+			projPath := projectPath()
+
+			writeManifests := func(bundle string) {
+				for _, r := range []struct{ kind, value string }{{"ConfigMap", configMap}, {"Secret", secret}} {
+					if r.value == "" {
+						continue
+					}
+					resource, _ := parseK8sResource(r.kind, r.value)
+					if err := writeK8sManifest(bundle, resource, k8sPolicies); err != nil {
+						exit(err)
+					}
+				}
+			}
+
+			if fromArtifact != "" {
+				bundle, err := doBuildFromArtifact(fromArtifact, args)
+				if err != nil {
+					exit(err)
+				}
+				writeManifests(bundle)
+				return
+			}
 
 			if !noUpdate {
-				if err := doUpdate(projPath); err != nil {
-					_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-					os.Exit(1)
+				if _, err := updateProject(projPath, proj.UpdateOptions{Strict: strict, Frozen: frozen}, false); err != nil {
+					exit(err)
 				}
+			} else if err := verifyDependencies(projPath, strict); err != nil {
+				exit(err)
 			}
 
 			if err := doBuild(projPath, args); err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-				os.Exit(1)
+				exit(err)
+			}
+			if configMap != "" || secret != "" {
+				project, err := proj.ReadAndLoadProject(projPath, false)
+				if err != nil {
+					exit(err)
+				}
+				writeManifests(bundlePath(project))
 			}
 		},
 	}
 
 	addNoUpdateFlag(buildCmd, &noUpdate)
+	addStrictFlag(buildCmd, &strict)
+	addFrozenFlag(buildCmd, &frozen)
+	buildCmd.Flags().StringVar(&fromArtifact, "from-artifact", "", "build the project artifact, exported by 'odm export artifact', at the given path or oci:// reference, without fetching anything")
+	buildCmd.Flags().StringVar(&configMap, "k8s-configmap", "", "also write a Kubernetes ConfigMap manifest embedding the bundle; given as name=<name>[,namespace=<namespace>], or just the name")
+	buildCmd.Flags().StringVar(&secret, "k8s-secret", "", "also write a Kubernetes Secret manifest embedding the bundle; given as name=<name>[,namespace=<namespace>], or just the name")
+	buildCmd.Flags().BoolVar(&k8sPolicies, "k8s-policies", false, "embed the bundle's policies in the Kubernetes manifests, for discovery by kube-mgmt, instead of the bundle itself")
 	RootCommand.AddCommand(buildCmd)
 }
 
@@ -51,6 +110,166 @@ func doBuild(projPath string, args []string) error {
 		return err
 	}
 
+	outputPath := bundlePath(project)
+	if err := utils.MakeDir(filepath.Dir(outputPath)); err != nil {
+		return fmt.Errorf("error creating build directory: %s", err)
+	}
+
+	return buildBundle(project, outputPath, args)
+}
+
+// doBuildFromArtifact builds the project artifact at location offline, from its vendored dependencies; which must match
+// the sums recorded when the artifact was exported. The bundle is written to the artifact's build output, relative to
+// the working directory; and its path returned.
+func doBuildFromArtifact(location string, args []string) (string, error) {
+	printer.Trace("--- Build from artifact start ---")
+	defer printer.Trace("--- Build from artifact end ---")
+
+	tmpDir, err := os.MkdirTemp("", "odm-artifact-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	if err := extractArtifact(location, tmpDir); err != nil {
+		return "", err
+	}
+
+	if err := verifyDependencies(tmpDir, true); err != nil {
+		return "", err
+	}
+
+	project, err := proj.ReadAndLoadProject(tmpDir, false)
+	if err != nil {
+		return "", err
+	}
+
+	relOutputPath, err := filepath.Rel(project.Dir(), bundlePath(project))
+	if err != nil {
+		return "", err
+	}
+	outputPath, err := filepath.Abs(relOutputPath)
+	if err != nil {
+		return "", err
+	}
+	if err := utils.MakeDir(filepath.Dir(outputPath)); err != nil {
+		return "", fmt.Errorf("error creating build directory: %s", err)
+	}
+
+	// Built from within the artifact, for the bundle to be identical to one built from the project itself
+	printer.Info("Building artifact %s into %s", location, outputPath)
+	return outputPath, buildBundleIn(project, project.Dir(), outputPath, args)
+}
+
+// buildBundle builds the project's source and dependencies into a bundle at outputPath.
+func buildBundle(project *proj.Project, outputPath string, args []string) error {
+	return buildBundleIn(project, "", outputPath, args)
+}
+
+// buildBundleIn builds the project like buildBundle, running OPA in dir, if set, with the data and bundle locations
+// given relative to it; so that the paths of files in the bundle don't depend on where the project is located.
+func buildBundleIn(project *proj.Project, dir string, outputPath string, args []string) error {
+	dataLocations, err := project.DataLocations()
+	if err != nil {
+		return fmt.Errorf("error getting data locations: %s", err)
+	}
+
+	bundleLocations, err := project.BundleLocations(false)
+	if err != nil {
+		return fmt.Errorf("error getting bundle locations: %s", err)
+	}
+
+	if dir != "" {
+		if dataLocations, err = relativePaths(dir, dataLocations); err != nil {
+			return err
+		}
+		if bundleLocations, err = relativePaths(dir, bundleLocations); err != nil {
+			return err
+		}
+	}
+
+	opa := utils.NewOpa(dataLocations...).
+		WithDir(dir).
+		WithBundles(bundleLocations).
+		WithEntrypoints(project.Entrypoints()).
+		WithTarget(project.Build.Target)
+	if output, err := opa.Build(outputPath, args...); err != nil {
+		return fmt.Errorf("error running opa eval:\n %s", err)
+	} else {
+		printer.Info(output)
+	}
+
+	if err := addWasmModules(project, outputPath); err != nil {
+		return fmt.Errorf("error adding dependency wasm modules to bundle: %w", err)
+	}
+
+	if err := addLicenses(project, outputPath); err != nil {
+		return fmt.Errorf("error adding dependency licenses to bundle: %w", err)
+	}
+
+	return nil
+}
+
+// relativePaths returns the paths relative to dir.
+func relativePaths(dir string, paths []string) ([]string, error) {
+	relPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		relPaths = append(relPaths, relPath)
+	}
+	return relPaths, nil
+}
+
+// addWasmModules adds the wasm modules of all wasm module dependencies to the bundle at outputPath, under
+// wasm/<dependency path>/, exposing their entrypoints through the bundle manifest alongside those built from source.
+func addWasmModules(project *proj.Project, outputPath string) error {
+	bundles, err := project.WasmBundles()
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(bundles))
+	for path := range bundles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		printer.Debug("Adding wasm modules of dependency %s to %s", path, outputPath)
+		if err := utils.MergeWasmModules(outputPath, bundles[path], "wasm/"+path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLicenses adds the license and notice files of all dependencies to the bundle at outputPath, under
+// licenses/<dependency path>/, as several licenses require for redistribution. OPA ignores these files when loading the
+// bundle.
+func addLicenses(project *proj.Project, outputPath string) error {
+	licenses, err := project.LicenseFiles()
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string]string)
+	for path, licenseFiles := range licenses {
+		for _, file := range licenseFiles {
+			files[fmt.Sprintf("/licenses/%s/%s", path, filepath.Base(file))] = file
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	printer.Debug("Adding %d dependency license files to %s", len(files), outputPath)
+	return utils.AddToTarGz(outputPath, files)
+}
+
+// bundlePath returns the location of the bundle built for the project, as configured by 'build.output'.
+func bundlePath(project *proj.Project) string {
 	outputDir, outputFile := filepath.Split(project.Build.Output)
 	if outputFile == "" {
 		outputFile = defaultTargetFile
@@ -61,28 +280,152 @@ func doBuild(projPath string, args []string) error {
 
 	if outputDir != "" {
 		outputDir = filepath.Join(project.Dir(), outputDir)
-		if err := utils.MakeDir(outputDir); err != nil {
-			return fmt.Errorf("error creating build directory: %s", err)
-		}
 	} else {
 		outputDir = project.Dir()
 	}
 
-	outputPath := filepath.Join(filepath.Clean(outputDir), outputFile)
+	return filepath.Join(filepath.Clean(outputDir), outputFile)
+}
 
-	dataLocations, err := project.DataLocations()
+// k8sResource identifies the Kubernetes resource a manifest is written for.
+type k8sResource struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// maxK8sResourceSize is the size limit Kubernetes imposes on the data of a ConfigMap or Secret.
+const maxK8sResourceSize = 1024 * 1024
+
+var k8sNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// parseK8sResource parses the resource of the given kind from value, given as name=<name>[,namespace=<namespace>], or
+// as just the name.
+func parseK8sResource(kind string, value string) (k8sResource, error) {
+	resource := k8sResource{Kind: kind}
+	if !strings.Contains(value, "=") {
+		resource.Name = value
+	} else {
+		for _, attr := range strings.Split(value, ",") {
+			key, val, _ := strings.Cut(attr, "=")
+			switch strings.TrimSpace(key) {
+			case "name":
+				resource.Name = strings.TrimSpace(val)
+			case "namespace":
+				resource.Namespace = strings.TrimSpace(val)
+			default:
+				return resource, fmt.Errorf("invalid Kubernetes resource '%s'; unknown attribute '%s', expected name or namespace",
+					value, key)
+			}
+		}
+	}
+
+	for _, name := range []string{resource.Name, resource.Namespace} {
+		if name != "" && (len(name) > 253 || !k8sNamePattern.MatchString(name)) {
+			return resource, fmt.Errorf("invalid Kubernetes resource '%s'; '%s' is not a valid name", value, name)
+		}
+	}
+	if resource.Name == "" {
+		return resource, fmt.Errorf("invalid Kubernetes resource '%s'; expected a name", value)
+	}
+	return resource, nil
+}
+
+// writeK8sManifest writes a manifest of the resource, embedding the bundle at bundle, or its policies, next to the
+// bundle; as <name>.<kind>.yaml.
+func writeK8sManifest(bundle string, resource k8sResource, policies bool) error {
+	manifest, err := renderK8sManifest(bundle, resource, policies)
 	if err != nil {
-		return fmt.Errorf("error getting data locations: %s", err)
+		return err
+	}
+	path := filepath.Join(filepath.Dir(bundle), fmt.Sprintf("%s.%s.yaml", resource.Name, strings.ToLower(resource.Kind)))
+	if err := os.WriteFile(path, manifest, 0644); err != nil {
+		return fmt.Errorf("failed to write %s manifest %s: %w", resource.Kind, path, err)
 	}
+	printer.Info("Wrote %s %s to %s", resource.Kind, resource.Name, path)
+	return nil
+}
 
-	opa := utils.NewOpa(dataLocations...).
-		WithEntrypoints(project.Build.Entrypoints).
-		WithTarget(project.Build.Target)
-	if output, err := opa.Build(outputPath, args...); err != nil {
-		return fmt.Errorf("error running opa eval:\n %s", err)
+// renderK8sManifest returns a manifest of the resource, a ConfigMap or Secret, embedding the bundle at bundle as
+// bundle.tar.gz; or, if policies is true, the bundle's Rego files, keyed by their path with '/' replaced by '_', and
+// labelled for discovery by kube-mgmt.
+func renderK8sManifest(bundle string, resource k8sResource, policies bool) ([]byte, error) {
+	type metadata struct {
+		Name      string            `yaml:"name"`
+		Namespace string            `yaml:"namespace,omitempty"`
+		Labels    map[string]string `yaml:"labels,omitempty"`
+	}
+	type manifest struct {
+		APIVersion string            `yaml:"apiVersion"`
+		Kind       string            `yaml:"kind"`
+		Metadata   metadata          `yaml:"metadata"`
+		Type       string            `yaml:"type,omitempty"`
+		Data       map[string]string `yaml:"data,omitempty"`
+		BinaryData map[string]string `yaml:"binaryData,omitempty"`
+		StringData map[string]string `yaml:"stringData,omitempty"`
+	}
+
+	m := manifest{
+		APIVersion: "v1",
+		Kind:       resource.Kind,
+		Metadata:   metadata{Name: resource.Name, Namespace: resource.Namespace},
+	}
+	if resource.Kind == "Secret" {
+		m.Type = "Opaque"
+	}
+
+	data := make(map[string]string)
+	size := 0
+	if policies {
+		files, err := utils.ReadBundlePolicies(bundle)
+		if err != nil {
+			return nil, err
+		}
+		paths := make(map[string]string)
+		for path, content := range files {
+			key := strings.ReplaceAll(path, "/", "_")
+			if other, ok := paths[key]; ok {
+				return nil, fmt.Errorf("policies %s and %s of bundle %s both map to the %s key %s", other, path, bundle,
+					resource.Kind, key)
+			}
+			paths[key] = path
+			data[key] = content
+			size += len(content)
+		}
+		m.Metadata.Labels = map[string]string{"openpolicyagent.org/policy": "rego"}
+		if resource.Kind == "Secret" {
+			m.StringData = data
+		} else {
+			m.Data = data
+		}
 	} else {
-		printer.Info(output)
+		bs, err := os.ReadFile(bundle)
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(bs)
+		data[defaultTargetFile] = encoded
+		size = len(bs)
+		if resource.Kind == "Secret" {
+			m.Data = data
+		} else {
+			m.BinaryData = data
+		}
 	}
 
-	return nil
+	if size > maxK8sResourceSize {
+		printer.Warn("The content of %s %s, %d bytes, exceeds the 1 MiB limit imposed by Kubernetes", resource.Kind,
+			resource.Name, size)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(m); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
@@ -4,6 +4,8 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"encoding/base64"
+	"github.com/johanfylling/odm/errs"
 	"github.com/johanfylling/odm/printer"
 	"github.com/johanfylling/odm/proj"
 	"github.com/johanfylling/odm/utils"
@@ -77,6 +79,42 @@ func TestBuildProjects(t *testing.T) {
 				filepath.Join(proj.DepId("no_deps", "file:/../no-dependencies"), "src", "policy.rego"),
 			},
 		},
+		{
+			name:           "Project with licensed dependency",
+			projectDir:     filepath.Join(rootDir, "testdata", "projects", "licensed-dependencies"),
+			bundleLocation: filepath.Join(rootDir, "testdata", "projects", "licensed-dependencies", "build", "bundle.tar.gz"),
+			cleanup:        "build",
+			bundleContent: []string{
+				"/data.json",
+				filepath.Join(proj.DepId("lib", "file:/../licensed-library"), "src", "policy.rego"),
+				"/licenses/lib/LICENSE",
+				"/licenses/lib/NOTICE",
+			},
+		},
+		{
+			name:           "Project with compiled bundle dependency",
+			projectDir:     filepath.Join(rootDir, "testdata", "projects", "bundle-dependencies"),
+			bundleLocation: filepath.Join(rootDir, "testdata", "projects", "bundle-dependencies", "build", "bundle.tar.gz"),
+			cleanup:        "build",
+			bundleContent: []string{
+				"/.manifest",
+				"/data.json",
+				"/src/policy.rego",
+				filepath.Join(proj.DepId("lib", "file:/../compiled-library"), "lib", "policy.rego"),
+			},
+		},
+		{
+			name:           "Project with wasm module dependency",
+			projectDir:     filepath.Join(rootDir, "testdata", "projects", "wasm-dependencies"),
+			bundleLocation: filepath.Join(rootDir, "testdata", "projects", "wasm-dependencies", "build", "bundle.tar.gz"),
+			cleanup:        "build",
+			bundleContent: []string{
+				"/.manifest",
+				"/data.json",
+				"/src/policy.rego",
+				"/wasm/authz/policy.wasm",
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -136,3 +174,187 @@ func TestBuildProjects(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildFromArtifact(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	projectDir := filepath.Join(filepath.Dir(file), "testdata", "projects", "local-dependencies")
+	defer cleanup(projectDir, "build", "opa.project.lock")
+
+	printer.PrintWriter = &bytes.Buffer{}
+	if err := doUpdate(projectDir); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpDir := t.TempDir()
+	artifactPath := filepath.Join(tmpDir, "artifact.tar.gz")
+	if err := doExportArtifact(projectDir, artifactPath); err != nil {
+		t.Fatal(err)
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildDir := filepath.Join(tmpDir, "release")
+	if err := os.Mkdir(buildDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(buildDir); err != nil {
+		t.Fatal(err)
+	}
+	_, err = doBuildFromArtifact(artifactPath, nil)
+	_ = os.Chdir(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes, err := utils.TarGzSizes(filepath.Join(buildDir, "build", "bundle.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := filepath.Join(".opa", "dependencies", proj.DepId("no_deps", "file:/../no-dependencies"), "src", "policy.rego")
+	if _, ok := sizes["/"+expected]; !ok {
+		t.Fatalf("expected %s to be in bundle, got %v", expected, sizes)
+	}
+
+	// Modified dependencies are refused
+	depFile := filepath.Join(projectDir, expected)
+	if err := os.WriteFile(depFile, []byte("package no_deps\n\nallow := false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := doExportArtifact(projectDir, artifactPath); !errs.Is(err, errs.ContentMismatch) {
+		t.Fatalf("expected modified dependency to be refused, got %v", err)
+	}
+}
+
+func TestParseK8sResource(t *testing.T) {
+	tests := []struct {
+		note     string
+		value    string
+		expected k8sResource
+		err      string
+	}{
+		{
+			note:     "name only",
+			value:    "opa-policies",
+			expected: k8sResource{Kind: "ConfigMap", Name: "opa-policies"},
+		},
+		{
+			note:     "attributes",
+			value:    "name=opa-policies,namespace=opa",
+			expected: k8sResource{Kind: "ConfigMap", Name: "opa-policies", Namespace: "opa"},
+		},
+		{
+			note:  "invalid name",
+			value: "name=OPA_policies",
+			err:   "'OPA_policies' is not a valid name",
+		},
+		{
+			note:  "missing name",
+			value: "namespace=opa",
+			err:   "expected a name",
+		},
+		{
+			note:  "unknown attribute",
+			value: "name=opa-policies,labels=x",
+			err:   "unknown attribute 'labels'",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			actual, err := parseK8sResource("ConfigMap", tc.value)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRenderK8sManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "policy.rego"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := utils.CreateTarGz(&buf, dir, []string{filepath.Join(dir, "src"), filepath.Join(dir, "data.json")}); err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join(dir, "bundle.tar.gz")
+	if err := os.WriteFile(bundle, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	tests := []struct {
+		note     string
+		resource k8sResource
+		policies bool
+		expected string
+	}{
+		{
+			note:     "bundle configmap",
+			resource: k8sResource{Kind: "ConfigMap", Name: "opa-policies", Namespace: "opa"},
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: opa-policies
+  namespace: opa
+binaryData:
+  bundle.tar.gz: ` + encoded + "\n",
+		},
+		{
+			note:     "bundle secret",
+			resource: k8sResource{Kind: "Secret", Name: "opa-bundle"},
+			expected: `apiVersion: v1
+kind: Secret
+metadata:
+  name: opa-bundle
+type: Opaque
+data:
+  bundle.tar.gz: ` + encoded + "\n",
+		},
+		{
+			note:     "policies configmap",
+			resource: k8sResource{Kind: "ConfigMap", Name: "opa-policies"},
+			policies: true,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: opa-policies
+  labels:
+    openpolicyagent.org/policy: rego
+data:
+  src_policy.rego: |
+    package main
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			actual, err := renderK8sManifest(bundle, tc.resource, tc.policies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual) != tc.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tc.expected, actual)
+			}
+		})
+	}
+}
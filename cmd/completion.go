@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"sort"
+	"strings"
+)
+
+// completeDependencies completes the dot-separated paths of the dependencies of the current project; of the entire
+// dependency tree, as far as it was fetched.
+func completeDependencies(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	project, err := proj.ReadProjectFromFile(projectPath(), false)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	// Dependencies not yet fetched have no known transitive dependencies, which doesn't fail loading
+	if err := project.Load(); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return withPrefix(project.DependencyPaths(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeDirectDependencies completes the names of the direct dependencies of the current project.
+func completeDirectDependencies(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	project, err := proj.ReadProjectFromFile(projectPath(), false)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	names := make([]string, 0, len(project.Dependencies))
+	for name := range project.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return withPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeEntrypoints completes the entrypoints of the current project, as slash-separated decision paths.
+func completeEntrypoints(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	project, err := proj.ReadProjectFromFile(projectPath(), false)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	if err := project.Load(); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return withPrefix(project.EntrypointPaths(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeToolchainVersions completes the installed OPA versions.
+func completeToolchainVersions(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	toolchains, err := utils.NewToolchains()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	versions, err := toolchains.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return withPrefix(versions, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeValues completes the given fixed values of a flag.
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return withPrefix(values, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func withPrefix(candidates []string, prefix string) []string {
+	var matching []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matching = append(matching, candidate)
+		}
+	}
+	return matching
+}
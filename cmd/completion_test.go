@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	projectDir := filepath.Join(filepath.Dir(file), "testdata", "projects", "transitive-dependencies")
+	defer func() {
+		projectFile = ""
+		RootCommand.SetArgs(nil)
+		RootCommand.SetOut(nil)
+	}()
+
+	tests := []struct {
+		note     string
+		args     []string
+		expected []string
+	}{
+		{
+			note:     "dependencies",
+			args:     []string{"info", ""},
+			expected: []string{"bar", "baz", "foo"},
+		},
+		{
+			note:     "dependencies, prefix",
+			args:     []string{"docs", "ba"},
+			expected: []string{"bar", "baz"},
+		},
+		{
+			note: "dependencies, single argument",
+			args: []string{"info", "foo", ""},
+		},
+		{
+			note:     "flag values",
+			args:     []string{"inspect", "--format", ""},
+			expected: []string{"pretty", "json"},
+		},
+		{
+			note:     "arguments",
+			args:     []string{"inspect", "p"},
+			expected: []string{"project"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			output := bytes.Buffer{}
+			RootCommand.SetOut(&output)
+			RootCommand.SetArgs(append([]string{"__complete", "-f", projectDir}, tc.args...))
+			if err := RootCommand.Execute(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Candidates are followed by the completion directive
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			var candidates []string
+			for _, line := range lines[:len(lines)-1] {
+				candidates = append(candidates, line)
+			}
+			if !reflect.DeepEqual(candidates, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, candidates)
+			}
+		})
+	}
+}
@@ -5,12 +5,13 @@ import (
 	"github.com/johanfylling/odm/printer"
 	"github.com/johanfylling/odm/proj"
 	"github.com/spf13/cobra"
-	"os"
+	"strings"
 )
 
 func init() {
 	var namespace string
 	var noNamespace bool
+	var sha256 string
 
 	var depCommand = &cobra.Command{
 		Use:   "depend <name> <location> [flags]",
@@ -19,20 +20,29 @@ func init() {
 
 Supported location types:
 - Git repository: git+http://..., git+https://..., git+ssh://...
-- Local file/directory: file://path/to/dir, file:/../path/to/dir
+- Local file/directory: file://path/to/dir, file:/../path/to/dir, or bundle archive: file:/../path/to/bundle.tar.gz
+- OCI artifact: oci://registry/repository[:tag][@sha256:digest]
+- Gzipped tarball: https://host/path/bundle.tar.gz, azblob://account/container/path/bundle.tar.gz or the GitHub release
+  asset github-release://owner/repo@tag/bundle.tar.gz, optionally pinned to its digest with --sha256
+- OPA bundle service: bundle+https://service/resource, authenticated with the bearer token of the service host
+- Short name: prefix/name[@version], expanded through the 'registries' section of the ODM config file
 
 Example:`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) < 2 {
 				return fmt.Errorf("expected exactly one dependency name and one location")
 			}
+			if sha256 != "" && !strings.HasPrefix(args[1], "https://") && !strings.HasPrefix(args[1], "http://") &&
+				!strings.HasPrefix(args[1], "azblob://") && !strings.HasPrefix(args[1], "github-release://") {
+				return fmt.Errorf("--sha256 only applies to HTTP(S), azblob:// and github-release:// tarball locations")
+			}
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			name := args[0]
 			location := args[1]
 
-			projPath := "."
+			projPath := projectPath()
 
 			if noNamespace {
 				namespace = ""
@@ -40,20 +50,20 @@ Example:`,
 				namespace = name
 			}
 
-			if err := doAddDependency(name, location, namespace, projPath); err != nil {
-				_, _ = cmd.OutOrStderr().Write([]byte(err.Error()))
-				os.Exit(1)
+			if err := doAddDependency(name, location, namespace, sha256, projPath); err != nil {
+				exit(err)
 			}
 		},
 	}
 
 	depCommand.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace of the dependency. Ignored if --no-namespace is set")
 	depCommand.Flags().BoolVar(&noNamespace, "no-namespace", false, "")
+	depCommand.Flags().StringVar(&sha256, "sha256", "", "sha256 digest the tarball of an HTTP(S), azblob:// or github-release:// location must match")
 
 	RootCommand.AddCommand(depCommand)
 }
 
-func doAddDependency(name string, location string, namespace string, projectPath string) error {
+func doAddDependency(name string, location string, namespace string, sha256 string, projectPath string) error {
 	printer.Trace("--- Dep start ---")
 	defer printer.Trace("--- Dep end ---")
 
@@ -73,6 +83,7 @@ func doAddDependency(name string, location string, namespace string, projectPath
 	dependency := proj.DependencyInfo{
 		Namespace: namespace,
 		Location:  location,
+		Sha256:    sha256,
 	}
 
 	project.SetDependency(name, dependency)
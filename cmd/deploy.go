@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// deployBuiltBundle is the value of --bundle, when given without a path, for deploying the project's bundle as built
+const deployBuiltBundle = "build"
+
+type deployOptions struct {
+	url    string
+	token  string
+	prefix string
+	prune  bool
+	// bundle is the path of a bundle to deploy the policies and data of, instead of the project's source; if set
+	bundle string
+}
+
+func init() {
+	var noUpdate bool
+	var opts deployOptions
+
+	var deployCommand = &cobra.Command{
+		Use:   "deploy [flags]",
+		Short: "Deploy project policies to a running OPA",
+		Long: `Deploy project policies to a running OPA
+
+Uploads the project's policies and data, including all dependencies, to an OPA instance through its REST API.
+Policies are created through the Policy API, with ids prefixed by --prefix; data documents through the Data API.
+Previously deployed policies with the same prefix that are no longer part of the project are removed, unless --prune=false is set.
+
+With --bundle, the project is built, as by 'odm build', and the policies and data of the built bundle are deployed
+instead; or, with --bundle=<path>, those of an existing bundle. Compiled bundles, holding a plan or wasm modules, can't
+be deployed through the REST API.
+
+Intended for quick iteration against development clusters; production deployments should use bundles.
+
+Example:
+'odm deploy --url http://localhost:8181'
+'odm deploy --bundle'
+'odm deploy --bundle=build/bundle.tar.gz'
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if opts.bundle != "" && opts.bundle != deployBuiltBundle {
+				if err := doDeployBundle(opts); err != nil {
+					exit(err)
+				}
+				return
+			}
+
+			if !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if opts.bundle == deployBuiltBundle {
+				if err := doBuild(projPath, nil); err != nil {
+					exit(err)
+				}
+				project, err := proj.ReadAndLoadProject(projPath, false)
+				if err != nil {
+					exit(err)
+				}
+				opts.bundle = bundlePath(project)
+				if err := doDeployBundle(opts); err != nil {
+					exit(err)
+				}
+				return
+			}
+
+			if err := doDeploy(projPath, opts); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	deployCommand.Flags().StringVar(&opts.url, "url", "http://localhost:8181", "base URL of the OPA instance")
+	deployCommand.Flags().StringVar(&opts.token, "token", os.Getenv("OPA_TOKEN"), "bearer token for authenticating with OPA. Defaults to the OPA_TOKEN environment variable")
+	deployCommand.Flags().StringVar(&opts.prefix, "prefix", "odm", "prefix for the ids of deployed policies")
+	deployCommand.Flags().BoolVar(&opts.prune, "prune", true, "remove previously deployed policies that are no longer part of the project")
+	deployCommand.Flags().StringVar(&opts.bundle, "bundle", "", "deploy the policies and data of the project's built bundle; or, given as --bundle=<path>, of an existing bundle")
+	deployCommand.Flags().Lookup("bundle").NoOptDefVal = deployBuiltBundle
+	addNoUpdateFlag(deployCommand, &noUpdate)
+	RootCommand.AddCommand(deployCommand)
+}
+
+func doDeploy(projPath string, opts deployOptions) error {
+	printer.Trace("--- Deploy start ---")
+	defer printer.Trace("--- Deploy end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, true)
+	if err != nil {
+		return err
+	}
+
+	dataLocations, err := project.DataLocations()
+	if err != nil {
+		return fmt.Errorf("error getting data locations: %s", err)
+	}
+
+	client := utils.NewOpaClient(opts.url, opts.token)
+	printer.Info("Deploying project '%s' to %s", project.Name, opts.url)
+
+	deployed := make(map[string]bool)
+	for _, location := range dataLocations {
+		err := filepath.WalkDir(location, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			switch name := d.Name(); {
+			case strings.HasSuffix(name, ".rego"):
+				id, err := policyId(opts.prefix, project.Dir(), file)
+				if err != nil {
+					return err
+				}
+				module, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("failed to read policy %s: %w", file, err)
+				}
+				printer.Debug("Deploying policy %s as '%s'", file, id)
+				if err := client.PutPolicy(id, module); err != nil {
+					return fmt.Errorf("failed to deploy policy %s: %w", file, err)
+				}
+				deployed[id] = true
+			case name == "data.json" || name == "data.yaml" || name == "data.yml":
+				if err := deployData(client, location, file); err != nil {
+					return fmt.Errorf("failed to deploy data %s: %w", file, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.prune {
+		if err := pruneStalePolicies(client, opts.prefix, deployed); err != nil {
+			return err
+		}
+	}
+
+	printer.Info("Deployed %d policies", len(deployed))
+
+	return nil
+}
+
+// doDeployBundle deploys the policies and data of the bundle at opts.bundle; policies with ids prefixed by opts.prefix
+// and their path in the bundle.
+func doDeployBundle(opts deployOptions) error {
+	printer.Trace("--- Deploy bundle start ---")
+	defer printer.Trace("--- Deploy bundle end ---")
+
+	if compiled, err := utils.IsCompiledBundle(opts.bundle); err != nil {
+		return fmt.Errorf("failed to read bundle %s: %w", opts.bundle, err)
+	} else if compiled {
+		return fmt.Errorf("bundle %s is compiled, and can't be deployed through the REST API", opts.bundle)
+	}
+
+	policies, err := utils.ReadBundlePolicies(opts.bundle)
+	if err != nil {
+		return fmt.Errorf("failed to read policies of bundle %s: %w", opts.bundle, err)
+	}
+	data, err := utils.ReadBundleData(opts.bundle)
+	if err != nil {
+		return fmt.Errorf("failed to read data of bundle %s: %w", opts.bundle, err)
+	}
+
+	client := utils.NewOpaClient(opts.url, opts.token)
+	printer.Info("Deploying bundle %s to %s", opts.bundle, opts.url)
+
+	deployed := make(map[string]bool)
+	for file, module := range policies {
+		id := path.Join(opts.prefix, file)
+		printer.Debug("Deploying policy %s as '%s'", file, id)
+		if err := client.PutPolicy(id, []byte(module)); err != nil {
+			return fmt.Errorf("failed to deploy policy %s: %w", file, err)
+		}
+		deployed[id] = true
+	}
+	for file, bs := range data {
+		if err := putData(client, path.Dir(file), file, bs); err != nil {
+			return fmt.Errorf("failed to deploy data %s: %w", file, err)
+		}
+	}
+
+	if opts.prune {
+		if err := pruneStalePolicies(client, opts.prefix, deployed); err != nil {
+			return err
+		}
+	}
+
+	printer.Info("Deployed %d policies", len(deployed))
+
+	return nil
+}
+
+// pruneStalePolicies removes the deployed policies with ids under prefix that weren't deployed this time.
+func pruneStalePolicies(client *utils.OpaClient, prefix string, deployed map[string]bool) error {
+	ids, err := client.ListPolicies()
+	if err != nil {
+		return fmt.Errorf("failed to list deployed policies: %w", err)
+	}
+	for _, id := range ids {
+		if strings.HasPrefix(id, prefix+"/") && !deployed[id] {
+			printer.Debug("Removing stale policy '%s'", id)
+			if err := client.DeletePolicy(id); err != nil {
+				return fmt.Errorf("failed to remove stale policy '%s': %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+func policyId(prefix string, projDir string, file string) (string, error) {
+	rel, err := filepath.Rel(projDir, file)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(prefix, filepath.ToSlash(rel)), nil
+}
+
+// deployData uploads a data file to the path corresponding to its directory relative to the data location root,
+// following the same placement rules as 'opa run -d'.
+func deployData(client *utils.OpaClient, root string, file string) error {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(root, filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	return putData(client, filepath.ToSlash(rel), file, bs)
+}
+
+// putData uploads the data document of a data file, named file, to the slash-separated path rel; or, if rel is ".",
+// merges it into the root document.
+func putData(client *utils.OpaClient, rel string, file string, bs []byte) error {
+	var value interface{}
+	var err error
+	if strings.HasSuffix(file, ".json") {
+		err = json.Unmarshal(bs, &value)
+	} else {
+		err = yaml.Unmarshal(bs, &value)
+	}
+	if err != nil {
+		return err
+	}
+
+	if rel != "." {
+		return client.PutData(rel, value)
+	}
+
+	// Data at the root is merged key by key, so as not to overwrite unrelated documents
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("root data document must be an object")
+	}
+	for k, v := range obj {
+		if err := client.PutData(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"github.com/johanfylling/odm/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestDeployProject(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	rootDir := filepath.Dir(file)
+
+	var mtx sync.Mutex
+	var requests []string
+	bodies := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		b, _ := io.ReadAll(r.Body)
+		bodies[r.URL.Path] = string(b)
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/policies" {
+			_, _ = w.Write([]byte(`{"result": [{"id": "odm/src/policy.rego"}, {"id": "odm/src/stale.rego"}, {"id": "other/policy.rego"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	projectDir := filepath.Join(rootDir, "testdata", "projects", "source-list")
+	if err := doDeploy(projectDir, deployOptions{url: server.URL, prefix: "odm", prune: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"DELETE /v1/policies/odm/src/stale.rego",
+		"GET /v1/policies",
+		"PUT /v1/data/do",
+		"PUT /v1/data/foo",
+		"PUT /v1/policies/odm/src/policy.rego",
+	}
+	sort.Strings(requests)
+	if !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("expected requests:\n\n%v\n\ngot:\n\n%v", expected, requests)
+	}
+
+	if bodies["/v1/data/foo"] != `{"bar":"baz"}` {
+		t.Fatalf("unexpected data body: %s", bodies["/v1/data/foo"])
+	}
+	if bodies["/v1/data/do"] != `{"re":{"mi":"fa"}}` {
+		t.Fatalf("unexpected data body: %s", bodies["/v1/data/do"])
+	}
+}
+
+func TestDeployBundle(t *testing.T) {
+	var mtx sync.Mutex
+	var requests []string
+	bodies := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		b, _ := io.ReadAll(r.Body)
+		bodies[r.URL.Path] = string(b)
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/policies" {
+			_, _ = w.Write([]byte(`{"result": [{"id": "odm/policy.rego"}, {"id": "odm/stale.rego"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	contentDir := t.TempDir()
+	files := map[string]string{
+		"policy.rego":    "package policy",
+		"foo/data.json":  `{"bar": "baz"}`,
+		"data.yaml":      "do: re",
+		".manifest":      `{"roots": [""]}`,
+		"foo/notes.text": "ignored",
+	}
+	for name, content := range files {
+		file := filepath.Join(contentDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.CreateTarGz(f, contentDir, []string{contentDir}); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if err := doDeployBundle(deployOptions{url: server.URL, prefix: "odm", prune: true, bundle: bundle}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"DELETE /v1/policies/odm/stale.rego",
+		"GET /v1/policies",
+		"PUT /v1/data/do",
+		"PUT /v1/data/foo",
+		"PUT /v1/policies/odm/policy.rego",
+	}
+	sort.Strings(requests)
+	if !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("expected requests:\n\n%v\n\ngot:\n\n%v", expected, requests)
+	}
+	if bodies["/v1/data/foo"] != `{"bar":"baz"}` {
+		t.Fatalf("unexpected data body: %s", bodies["/v1/data/foo"])
+	}
+	if bodies["/v1/data/do"] != `"re"` {
+		t.Fatalf("unexpected data body: %s", bodies["/v1/data/do"])
+	}
+}
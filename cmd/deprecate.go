@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var deprecation oci.Deprecation
+	var undo bool
+
+	var deprecateCommand = &cobra.Command{
+		Use:   "deprecate <location> [flags]",
+		Short: "Mark an OCI dependency, or one of its versions, as deprecated",
+		Long: `Mark an OCI dependency, or one of its versions, as deprecated
+
+Deprecations are recorded in the repository's ODM metadata artifact, tagged '` + oci.MetadataTag + `'.
+A location with a tag deprecates that version; a location without a tag deprecates all versions.
+Deprecated dependencies are still resolved, but ODM prints the deprecation notice when updating, and in
+'odm list dependencies'.
+
+Libraries can also declare themselves deprecated through the 'deprecated' attribute of their opa.project file.
+
+Example:
+'odm deprecate oci://ghcr.io/my-org/policy-lib --message "no longer maintained" --replacement oci://ghcr.io/my-org/authz-lib'
+'odm deprecate oci://ghcr.io/my-org/policy-lib:1.2.0 --undo'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one location")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doDeprecate(args[0], deprecation, undo); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	deprecateCommand.Flags().StringVar(&deprecation.Message, "message", "", "deprecation notice, shown to users of the dependency")
+	deprecateCommand.Flags().StringVar(&deprecation.Replacement, "replacement", "", "location of a suggested replacement")
+	deprecateCommand.Flags().BoolVar(&undo, "undo", false, "remove the deprecation")
+	RootCommand.AddCommand(deprecateCommand)
+}
+
+func doDeprecate(location string, deprecation oci.Deprecation, undo bool) error {
+	printer.Trace("--- Deprecate start ---")
+	defer printer.Trace("--- Deprecate end ---")
+
+	ref, versioned, err := parseRepositoryLocation(location)
+	if err != nil {
+		return err
+	}
+	repository := ref.Registry + "/" + ref.Repository
+
+	client := oci.NewClient()
+	metadata, err := client.Metadata(ref)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case undo && versioned:
+		if _, ok := metadata.DeprecatedVersions[ref.Tag]; !ok {
+			return fmt.Errorf("version %s of %s is not deprecated", ref.Tag, repository)
+		}
+		delete(metadata.DeprecatedVersions, ref.Tag)
+		printer.Info("Removing deprecation of version %s of %s", ref.Tag, repository)
+	case undo:
+		if metadata.Deprecated == nil {
+			return fmt.Errorf("%s is not deprecated", repository)
+		}
+		metadata.Deprecated = nil
+		printer.Info("Removing deprecation of %s", repository)
+	case versioned:
+		if _, err := client.Resolve(ref); err != nil {
+			return fmt.Errorf("cannot deprecate %s: %w", location, err)
+		}
+		if metadata.DeprecatedVersions == nil {
+			metadata.DeprecatedVersions = make(map[string]oci.Deprecation)
+		}
+		metadata.DeprecatedVersions[ref.Tag] = deprecation
+		printer.Info("Deprecating version %s of %s", ref.Tag, repository)
+	default:
+		metadata.Deprecated = &deprecation
+		printer.Info("Deprecating %s", repository)
+	}
+
+	return client.PushMetadata(ref, metadata)
+}
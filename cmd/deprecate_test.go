@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"testing"
+)
+
+func TestDeprecate(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1"})
+	repository := "oci://" + registry.Host() + "/org/policy"
+	ref, _ := oci.ParseReference(registry.Host() + "/org/policy:1.0.0")
+
+	deprecation := oci.Deprecation{Message: "insecure", Replacement: "oci://example.com/org/new"}
+	if err := doDeprecate(repository+":1.0.0", deprecation, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := doDeprecate(repository, oci.Deprecation{Message: "unmaintained"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := oci.NewClient().Metadata(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := metadata.DeprecationOf("1.0.0"); actual == nil || *actual != deprecation {
+		t.Fatalf("expected deprecation %v, got %v", deprecation, actual)
+	}
+	if actual := metadata.DeprecationOf("2.0.0"); actual == nil || actual.Message != "unmaintained" {
+		t.Fatalf("expected repository deprecation, got %v", actual)
+	}
+
+	if err := doDeprecate(repository+":1.0.0", oci.Deprecation{}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := doDeprecate(repository, oci.Deprecation{}, true); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err = oci.NewClient().Metadata(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := metadata.DeprecationOf("1.0.0"); actual != nil {
+		t.Fatalf("expected no deprecation, got %v", actual)
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func init() {
+	var noUpdate bool
+	var interval time.Duration
+
+	var devCommand = &cobra.Command{
+		Use:   "dev [flags] -- [opa run flags]",
+		Short: "Run OPA with live reload of the project",
+		Long: `Run OPA with live reload of the project
+
+Launches 'opa run --server --watch' preloaded with the project sources and all dependencies.
+Changes to project sources are hot-reloaded by OPA. Changes to the project file or to local (file:) dependencies
+trigger a dependency update, after which OPA is restarted with the updated dependency set.
+
+Example:
+'odm dev -- --addr :8181 --log-level debug'
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if err := doDev(projPath, interval, noUpdate, args); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	devCommand.Flags().DurationVar(&interval, "watch-interval", time.Second, "interval between checks for changed dependencies")
+	addNoUpdateFlag(devCommand, &noUpdate)
+	RootCommand.AddCommand(devCommand)
+}
+
+func doDev(projPath string, interval time.Duration, noUpdate bool, args []string) error {
+	printer.Trace("--- Dev start ---")
+	defer printer.Trace("--- Dev end ---")
+
+	start := func() (*exec.Cmd, *utils.Watcher, error) {
+		if !noUpdate {
+			if err := doUpdate(projPath); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		project, err := proj.ReadAndLoadProject(projPath, true)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dataLocations, err := project.DataLocations()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting data locations: %s", err)
+		}
+
+		depLocations, err := project.LocalDependencyLocations()
+		if err != nil {
+			return nil, nil, err
+		}
+		watcher := utils.NewWatcher(depLocations, []string{".opa", ".git"})
+
+		opa := utils.NewOpa(dataLocations...)
+		process, err := opa.Run(append([]string{"--server", "--watch"}, args...)...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return process, watcher, nil
+	}
+
+	process, watcher, err := start()
+	if err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func(p *exec.Cmd) { exited <- p.Wait() }(process)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-signals:
+			if process != nil {
+				_ = process.Process.Signal(os.Interrupt)
+				<-exited
+			}
+			return nil
+		case err := <-exited:
+			return fmt.Errorf("opa exited: %v", err)
+		case <-ticker.C:
+			if !watcher.Changed() {
+				continue
+			}
+			printer.Output("Dependency change detected, restarting OPA")
+
+			if process != nil {
+				_ = process.Process.Signal(os.Interrupt)
+				<-exited
+			}
+
+			// A broken dependency shouldn't end the session; keep watching until it's fixed
+			p, w, err := start()
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+				process = nil
+				continue
+			}
+			process, watcher = p, w
+			go func(p *exec.Cmd) { exited <- p.Wait() }(process)
+		}
+	}
+}
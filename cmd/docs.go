@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/docs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	var noUpdate bool
+	var format string
+	var output string
+
+	var docsCommand = &cobra.Command{
+		Use:   "docs <name> [flags]",
+		Short: "Render the documentation of a dependency",
+		Long: `Render the documentation of a dependency
+
+Extracts the README and the METADATA annotations of all packages and rules of the resolved dependency,
+and renders a summary in the terminal or as an HTML page.
+
+Transitive dependencies are referenced by their dot-separated path in the dependency tree; e.g. 'foo.bar' for the
+dependency 'bar' declared by the dependency 'foo'.
+
+Example:
+'odm docs http'
+'odm docs http --format html -o http.html'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one dependency name")
+			}
+			if format != "text" && format != "html" {
+				return fmt.Errorf("unsupported format '%s'; expected text or html", format)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doDocs(projPath, args[0], format, output); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	docsCommand.Flags().StringVar(&format, "format", "text", "output format: text or html")
+	docsCommand.Flags().StringVarP(&output, "output", "o", "", "file to write the documentation to, instead of stdout")
+	docsCommand.ValidArgsFunction = completeDependencies
+	_ = docsCommand.RegisterFlagCompletionFunc("format", completeValues("text", "html"))
+	addNoUpdateFlag(docsCommand, &noUpdate)
+	RootCommand.AddCommand(docsCommand)
+
+	var siteFormat string
+	var outDir string
+	var generateNoUpdate bool
+
+	var generateCommand = &cobra.Command{
+		Use:   "generate [flags]",
+		Short: "Generate a static documentation site of the project and its dependencies",
+		Long: `Generate a static documentation site of the project and its dependencies
+
+Extracts the README and the METADATA annotations, packages and rule signatures of the project's source and of all
+resolved dependencies, and writes a static reference site, of HTML pages or Markdown documents, to the output directory:
+an index page documenting the project, linking to a page per dependency, in deps/<dependency path>.
+
+Example:
+'odm docs generate --out site/'
+'odm docs generate --format markdown --out docs/reference'
+`,
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if siteFormat != "html" && siteFormat != "markdown" {
+				return fmt.Errorf("unsupported format '%s'; expected html or markdown", siteFormat)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if !generateNoUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doDocsGenerate(projPath, siteFormat, outDir); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	generateCommand.Flags().StringVar(&siteFormat, "format", "html", "output format: html or markdown")
+	generateCommand.Flags().StringVar(&outDir, "out", "site", "directory to write the site to")
+	_ = generateCommand.RegisterFlagCompletionFunc("format", completeValues("html", "markdown"))
+	addNoUpdateFlag(generateCommand, &generateNoUpdate)
+	docsCommand.AddCommand(generateCommand)
+}
+
+func doDocs(projPath string, name string, format string, output string) error {
+	printer.Trace("--- Docs start ---")
+	defer printer.Trace("--- Docs end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	d, err := dependencyDocs(project, name)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = printer.PrintWriter
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+
+	if format == "html" {
+		return docs.RenderHTML(w, d)
+	}
+	return docs.RenderText(w, d)
+}
+
+func doDocsGenerate(projPath string, format string, outDir string) error {
+	printer.Trace("--- Docs generate start ---")
+	defer printer.Trace("--- Docs generate end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	sourceDirs := []string{project.Dir()}
+	if len(project.SourceDirs) > 0 {
+		sourceDirs = nil
+		for _, dir := range project.SourceDirs {
+			sourceDirs = append(sourceDirs, filepath.Join(project.Dir(), dir))
+		}
+	}
+	title := project.Name
+	if title == "" {
+		dir, err := filepath.Abs(project.Dir())
+		if err != nil {
+			return err
+		}
+		title = filepath.Base(dir)
+	}
+	if project.Version != "" {
+		title += " " + project.Version
+	}
+	projectDocs, err := docs.Extract(title, project.Dir(), utils.FilterExistingFiles(sourceDirs))
+	if err != nil {
+		return fmt.Errorf("failed to extract documentation of project: %w", err)
+	}
+
+	depDocs := make(map[string]*docs.Docs)
+	for _, path := range project.DependencyPaths() {
+		if depDocs[path], err = dependencyDocs(project, path); err != nil {
+			return err
+		}
+	}
+
+	if err := docs.WriteSite(outDir, format, projectDocs, depDocs); err != nil {
+		return fmt.Errorf("failed to write documentation site: %w", err)
+	}
+	printer.Info("Documentation of the project and %d dependencies written to %s", len(depDocs), outDir)
+	return nil
+}
+
+// dependencyDocs extracts the documentation of the dependency at the given dot-separated path in the dependency tree.
+func dependencyDocs(project *proj.Project, path string) (*docs.Docs, error) {
+	details, err := project.DependencyDetails(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceDirs := []string{details.Dir}
+	title := details.Path
+	if p := details.Project; p != nil {
+		if len(p.SourceDirs) > 0 {
+			sourceDirs = nil
+			for _, dir := range p.SourceDirs {
+				sourceDirs = append(sourceDirs, filepath.Join(details.Dir, dir))
+			}
+		}
+		if p.Name != "" {
+			title = fmt.Sprintf("%s (%s)", details.Path, strings.TrimSpace(p.Name+" "+p.Version))
+		}
+	}
+
+	d, err := docs.Extract(title, details.Dir, sourceDirs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract documentation of %s: %w", path, err)
+	}
+	return d, nil
+}
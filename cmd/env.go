@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"strings"
+)
+
+// projectEnv is ODM's view of the project, as needed to run OPA directly.
+type projectEnv struct {
+	DataLocations []string `json:"dataLocations"`
+	TestLocations []string `json:"testLocations"`
+	Schema        string   `json:"schema,omitempty"`
+	Bundles       []string `json:"bundles"`
+}
+
+func init() {
+	var format string
+	var noUpdate bool
+	var includeDeps bool
+
+	var envCommand = &cobra.Command{
+		Use:   "env [flags]",
+		Short: "Print the project's data locations, test locations, schemas and bundles, for running OPA directly",
+		Long: `Print the project's data locations, test locations, schemas and bundles, for running OPA directly
+
+Prints the locations ODM passes to OPA for the project, with dependencies resolved: the data locations of the project's
+source and namespaced dependencies (ODM_DATA_LOCATIONS), the test locations (ODM_TEST_LOCATIONS), the schemas declared
+by the project (ODM_SCHEMA), and compiled bundle dependencies, loaded as bundles (ODM_BUNDLES). Paths are absolute.
+
+In shell format, the locations are printed as export statements of space-separated lists, to be evaluated by a shell;
+in json format, as an object, e.g. for editor plugins and build rules, and for paths containing whitespace.
+
+With --include-deps, the test locations include the tests of dependencies.
+
+Example:
+'eval "$(odm env)" && opa test $ODM_DATA_LOCATIONS $ODM_TEST_LOCATIONS'
+'odm env --format json --no-update'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if format != "shell" && format != "json" {
+				return fmt.Errorf("unsupported format '%s'; expected shell or json", format)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doEnv(projPath, format, includeDeps); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	envCommand.Flags().StringVar(&format, "format", "shell", "output format: shell or json")
+	_ = envCommand.RegisterFlagCompletionFunc("format", completeValues("shell", "json"))
+	envCommand.Flags().BoolVar(&includeDeps, "include-deps", false, "include the tests of dependencies")
+	addNoUpdateFlag(envCommand, &noUpdate)
+	RootCommand.AddCommand(envCommand)
+}
+
+func doEnv(projPath string, format string, includeDeps bool) error {
+	printer.Trace("--- Env start ---")
+	defer printer.Trace("--- Env end ---")
+
+	env, err := resolveProjectEnv(projPath, includeDeps)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		bs, err := json.MarshalIndent(env, "", "  ")
+		if err != nil {
+			return err
+		}
+		printer.Output(string(bs))
+		return nil
+	}
+
+	printer.Output(strings.Join([]string{
+		shellExport("ODM_DATA_LOCATIONS", env.DataLocations...),
+		shellExport("ODM_TEST_LOCATIONS", env.TestLocations...),
+		shellExport("ODM_SCHEMA", env.Schema),
+		shellExport("ODM_BUNDLES", env.Bundles...),
+	}, "\n"))
+	return nil
+}
+
+// resolveProjectEnv returns the absolute locations ODM passes to OPA for the project at projPath, as currently resolved.
+// Test locations include the tests of dependencies if includeDeps is true.
+func resolveProjectEnv(projPath string, includeDeps bool) (projectEnv, error) {
+	env := projectEnv{}
+	project, err := proj.ReadAndLoadProject(projPath, true)
+	if err != nil {
+		return env, err
+	}
+
+	if env.DataLocations, err = project.DataLocations(); err != nil {
+		return env, fmt.Errorf("error getting data locations: %s", err)
+	}
+	if env.TestLocations, err = project.TestLocations(includeDeps); err != nil {
+		return env, fmt.Errorf("error getting test locations: %s", err)
+	}
+	if env.Schema, err = project.SchemaLocation(); err != nil {
+		return env, err
+	}
+	if env.Bundles, err = project.BundleLocations(true); err != nil {
+		return env, fmt.Errorf("error getting bundle locations: %s", err)
+	}
+
+	for _, list := range []*[]string{&env.DataLocations, &env.TestLocations, &env.Bundles} {
+		if *list, err = absPaths(*list); err != nil {
+			return env, err
+		}
+	}
+	if env.Schema != "" {
+		if env.Schema, err = filepath.Abs(env.Schema); err != nil {
+			return env, err
+		}
+	}
+	return env, nil
+}
+
+// absPaths returns the absolute forms of paths; never nil.
+func absPaths(paths []string) ([]string, error) {
+	abs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		p, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		abs = append(abs, p)
+	}
+	return abs, nil
+}
+
+// shellExport returns a shell statement exporting the values as a quoted, space-separated list. Values are expected to
+// be paths without whitespace, for the list to be split into words.
+func shellExport(name string, values ...string) string {
+	var list []string
+	for _, value := range values {
+		if value != "" {
+			list = append(list, value)
+		}
+	}
+	return fmt.Sprintf("export %s='%s'", name, strings.ReplaceAll(strings.Join(list, " "), "'", `'\''`))
+}
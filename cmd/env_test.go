@@ -0,0 +1,39 @@
+package cmd
+
+import "testing"
+
+func TestShellExport(t *testing.T) {
+	tests := []struct {
+		note     string
+		values   []string
+		expected string
+	}{
+		{
+			note:     "none",
+			expected: "export ODM_LOCATIONS=''",
+		},
+		{
+			note:     "empty",
+			values:   []string{""},
+			expected: "export ODM_LOCATIONS=''",
+		},
+		{
+			note:     "multiple",
+			values:   []string{"/proj/src", "/proj/.opa/dependencies/123"},
+			expected: "export ODM_LOCATIONS='/proj/src /proj/.opa/dependencies/123'",
+		},
+		{
+			note:     "quote",
+			values:   []string{"/proj/it's"},
+			expected: `export ODM_LOCATIONS='/proj/it'\''s'`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			if actual := shellExport("ODM_LOCATIONS", tc.values...); actual != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -6,11 +6,20 @@ import (
 	"github.com/johanfylling/odm/proj"
 	"github.com/johanfylling/odm/utils"
 	"github.com/spf13/cobra"
-	"os"
 )
 
+type evalOptions struct {
+	profile      bool
+	profileSort  string
+	profileLimit int
+	metrics      bool
+}
+
 func init() {
 	var noUpdate bool
+	var strict bool
+	var frozen bool
+	var opts evalOptions
 
 	var evalCommand = &cobra.Command{
 		Use:   "eval [flags] -- [opa eval flags]",
@@ -22,29 +31,38 @@ Convenience command for running 'opa eval' with project dependencies.
 Example:
 'odm eval -- -d policy.rego "data.main.allow"' is equivalent to running:
 'opa eval -d ./opa/dependencies -d policy.rego "data.main.allow"'
+
+Performance can be investigated against the full set of dependencies with --profile and --metrics:
+'odm eval --profile --profile-sort num_eval -- "data.main.allow"'
 `,
 		Run: func(cmd *cobra.Command, args []string) {
-			projPath := "."
+			projPath := projectPath()
 
 			if !noUpdate {
-				if err := doUpdate(projPath); err != nil {
-					_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-					os.Exit(1)
+				if _, err := updateProject(projPath, proj.UpdateOptions{Strict: strict, Frozen: frozen}, false); err != nil {
+					exit(err)
 				}
+			} else if err := verifyDependencies(projPath, strict); err != nil {
+				exit(err)
 			}
 
-			if err := doEval(projPath, args); err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-				os.Exit(1)
+			if err := doEval(projPath, opts, args); err != nil {
+				exit(err)
 			}
 		},
 	}
 
+	evalCommand.Flags().BoolVar(&opts.profile, "profile", false, "profile the query evaluation, rendering the profile as a table")
+	evalCommand.Flags().StringVar(&opts.profileSort, "profile-sort", "total_time_ns", "sort criteria for the profile. Only used with --profile")
+	evalCommand.Flags().IntVar(&opts.profileLimit, "profile-limit", 10, "number of profile entries to show. Only used with --profile")
+	evalCommand.Flags().BoolVar(&opts.metrics, "metrics", false, "report query performance metrics")
 	addNoUpdateFlag(evalCommand, &noUpdate)
+	addStrictFlag(evalCommand, &strict)
+	addFrozenFlag(evalCommand, &frozen)
 	RootCommand.AddCommand(evalCommand)
 }
 
-func doEval(projPath string, args []string) error {
+func doEval(projPath string, opts evalOptions, args []string) error {
 	printer.Trace("--- Eval start ---")
 	defer printer.Trace("--- Eval end ---")
 
@@ -63,7 +81,23 @@ func doEval(projPath string, args []string) error {
 		return fmt.Errorf("error getting data locations: %s", err)
 	}
 
-	opa := utils.NewOpa(dataLocations...)
+	bundleLocations, err := project.BundleLocations(true)
+	if err != nil {
+		return fmt.Errorf("error getting bundle locations: %s", err)
+	}
+
+	schema, err := project.SchemaLocation()
+	if err != nil {
+		return err
+	}
+
+	opa := utils.NewOpa(dataLocations...).WithBundles(bundleLocations).WithSchema(schema)
+	if opts.profile {
+		opa = opa.WithProfile(opts.profileSort, opts.profileLimit)
+	}
+	if opts.metrics {
+		opa = opa.WithMetrics()
+	}
 	if output, err := opa.Eval(args...); err != nil {
 		return fmt.Errorf("error running opa eval:\n %s", err)
 	} else {
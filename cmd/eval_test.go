@@ -120,7 +120,7 @@ func TestEvalProjects(t *testing.T) {
 			if err := doUpdate(tc.projectDir); err != nil {
 				t.Fatal(err)
 			}
-			if err := doEval(tc.projectDir, args); err != nil {
+			if err := doEval(tc.projectDir, evalOptions{}, args); err != nil {
 				t.Fatal(err)
 			}
 			if !strings.Contains(output.String(), tc.expectedOutput) {
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	var noUpdate bool
+	var decision string
+
+	var execCommand = &cobra.Command{
+		Use:   "exec --decision <path> <input files...> [-- opa exec flags]",
+		Short: "Execute a decision against input files using OPA",
+		Long: `Execute a decision against input files using OPA
+
+Convenience command for running 'opa exec' with the project and its dependencies.
+The project is built into a temporary bundle, which is loaded by OPA for evaluating the decision
+against each input file.
+
+Example:
+'odm exec --decision main/allow inputs/*.json'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if decision == "" {
+				return fmt.Errorf("a decision path is required")
+			}
+			if len(inputFiles(cmd, args)) == 0 {
+				return fmt.Errorf("expected at least one input file")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doExec(projPath, decision, inputFiles(cmd, args), passThroughArgs(cmd, args)); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	execCommand.Flags().StringVar(&decision, "decision", "", "path of the decision to evaluate, e.g. 'main/allow'")
+	_ = execCommand.RegisterFlagCompletionFunc("decision", completeEntrypoints)
+	addNoUpdateFlag(execCommand, &noUpdate)
+	RootCommand.AddCommand(execCommand)
+}
+
+// inputFiles returns the positional arguments preceding any '--' separator.
+func inputFiles(cmd *cobra.Command, args []string) []string {
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		return args[:dash]
+	}
+	return args
+}
+
+// passThroughArgs returns the arguments following a '--' separator.
+func passThroughArgs(cmd *cobra.Command, args []string) []string {
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		return args[dash:]
+	}
+	return nil
+}
+
+func doExec(projPath string, decision string, inputs []string, args []string) error {
+	printer.Trace("--- Exec start ---")
+	defer printer.Trace("--- Exec end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, true)
+	if err != nil {
+		return err
+	}
+
+	dataLocations, err := project.DataLocations()
+	if err != nil {
+		return fmt.Errorf("error getting data locations: %s", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "odm-exec-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	bundle := filepath.Join(tmpDir, defaultTargetFile)
+	opa := utils.NewOpa(dataLocations...)
+	if _, err := opa.Build(bundle); err != nil {
+		return fmt.Errorf("error running opa build:\n %s", err)
+	}
+
+	if output, err := opa.Exec(bundle, decision, inputs, args...); err != nil {
+		return fmt.Errorf("error running opa exec:\n %s", err)
+	} else {
+		printer.Output(output)
+	}
+
+	return nil
+}
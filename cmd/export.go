@@ -0,0 +1,408 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultArtifactFile = "artifact.tar.gz"
+
+func init() {
+	var exportCommand = &cobra.Command{
+		Use:   "export",
+		Short: "Export the project for use outside of ODM",
+	}
+
+	RootCommand.AddCommand(exportCommand)
+
+	var noUpdate bool
+
+	var artifactCommand = &cobra.Command{
+		Use:   "artifact [destination] [flags]",
+		Short: "Package the project, its lock file and vendored dependencies into a single artifact",
+		Long: `Package the project, its lock file and vendored dependencies into a single artifact
+
+Archives the project file, the project's source and test directories, the lock file, and all vendored dependencies,
+with the sums recorded by the last update, into a gzipped tarball; or pushes it to an OCI registry, as an ORAS artifact
+of type '` + oci.ArtifactTypeProject + `'. The artifact can later be built offline, with 'odm build --from-artifact',
+without fetching anything; e.g. for regulated release processes.
+
+The destination defaults to ` + defaultArtifactFile + ` in the build output directory.
+Dependencies modified since the last update are refused.
+
+Example:
+'odm export artifact'
+'odm export artifact release.tar.gz --no-update'
+'odm export artifact oci://ghcr.io/my-org/policy-release:1.2.0'
+`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+			destination := ""
+			if len(args) == 1 {
+				destination = args[0]
+			}
+
+			if !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doExportArtifact(projPath, destination); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	addNoUpdateFlag(artifactCommand, &noUpdate)
+	exportCommand.AddCommand(artifactCommand)
+
+	var flagsFormat string
+	var relativeTo string
+	var flagsNoUpdate bool
+
+	var flagsCommand = &cobra.Command{
+		Use:   "flags [destination] [flags]",
+		Short: "Write the OPA flags loading the resolved project, for build systems calling OPA themselves",
+		Long: `Write the OPA flags loading the resolved project, for build systems calling OPA themselves
+
+Writes the -d, --schema and -b flags loading the project's source and namespaced dependencies, the schemas declared by
+the project, and compiled bundle dependencies, as ODM passes them to OPA; e.g. for Bazel or Make rules that must call
+OPA themselves, for hermeticity. In args-file format, every argument is written on a line of its own, as read by
+Bazel's param files and xargs.
+
+Paths are absolute, unless --relative-to is set. The flags are written to the destination file, or standard output if
+none is given.
+
+Example:
+'odm export flags opa.args'
+'odm export flags --relative-to . --no-update'
+'xargs -a opa.args -d '\n' opa eval data.main.allow'
+`,
+		Args: cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if flagsFormat != "args-file" {
+				return fmt.Errorf("unsupported format '%s'; expected args-file", flagsFormat)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+			destination := ""
+			if len(args) == 1 {
+				destination = args[0]
+			}
+
+			if !flagsNoUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doExportFlags(projPath, destination, relativeTo); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	flagsCommand.Flags().StringVar(&flagsFormat, "format", "args-file", "output format: args-file")
+	_ = flagsCommand.RegisterFlagCompletionFunc("format", completeValues("args-file"))
+	flagsCommand.Flags().StringVar(&relativeTo, "relative-to", "", "write paths relative to the given directory")
+	addNoUpdateFlag(flagsCommand, &flagsNoUpdate)
+	exportCommand.AddCommand(flagsCommand)
+
+	var output string
+	var gatekeeperNoUpdate bool
+
+	var gatekeeperCommand = &cobra.Command{
+		Use:   "gatekeeper <package>[=<kind>]... [flags]",
+		Short: "Export entrypoint policies as Gatekeeper ConstraintTemplates, with their library code inlined",
+		Long: `Export entrypoint policies as Gatekeeper ConstraintTemplates, with their library code inlined
+
+Writes a ConstraintTemplate for every given package, with the package's policy as the template's Rego, and all modules
+it references, directly or transitively, of the project's source and namespaced dependencies, inlined as libs.
+The policy of a package must be a single module; test modules are left out. As Gatekeeper requires, referenced modules
+must be placed under data.lib; dependencies may be namespaced there.
+
+The kind of the template's constraints is given after '=', or else derived from the last segment of the package, with
+its first letter upper-cased. The templates are written as a multi-document YAML stream.
+
+Example:
+'odm export gatekeeper k8srequiredlabels=K8sRequiredLabels -o templates.yaml'
+'odm export gatekeeper policies.k8sallowedrepos policies.k8spspprivileged --no-update | kubectl apply -f -'
+`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if !gatekeeperNoUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doExportGatekeeper(projPath, args, output); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	gatekeeperCommand.Flags().StringVarP(&output, "output", "o", "", "file to write the templates to, instead of stdout")
+	addNoUpdateFlag(gatekeeperCommand, &gatekeeperNoUpdate)
+	exportCommand.AddCommand(gatekeeperCommand)
+}
+
+func doExportGatekeeper(projPath string, packages []string, output string) error {
+	printer.Trace("--- Export gatekeeper start ---")
+	defer printer.Trace("--- Export gatekeeper end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, true)
+	if err != nil {
+		return err
+	}
+
+	var templates []*proj.ConstraintTemplate
+	for _, arg := range packages {
+		pkg, kind, _ := strings.Cut(arg, "=")
+		template, err := project.GatekeeperTemplate(pkg, kind)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, template)
+	}
+
+	content, err := renderConstraintTemplates(templates)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		printer.Output("%s", strings.TrimSuffix(content, "\n"))
+		return nil
+	}
+	if err := os.WriteFile(output, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write templates %s: %w", output, err)
+	}
+	return nil
+}
+
+// renderConstraintTemplates returns the templates as Gatekeeper ConstraintTemplate resources, in a multi-document YAML
+// stream.
+func renderConstraintTemplates(templates []*proj.ConstraintTemplate) (string, error) {
+	type names struct {
+		Kind string `yaml:"kind"`
+	}
+	type crdSpec struct {
+		Names names `yaml:"names"`
+	}
+	type crd struct {
+		Spec crdSpec `yaml:"spec"`
+	}
+	type target struct {
+		Target string   `yaml:"target"`
+		Rego   string   `yaml:"rego"`
+		Libs   []string `yaml:"libs,omitempty"`
+	}
+	type spec struct {
+		CRD     crd      `yaml:"crd"`
+		Targets []target `yaml:"targets"`
+	}
+	type metadata struct {
+		Name string `yaml:"name"`
+	}
+	type resource struct {
+		APIVersion string   `yaml:"apiVersion"`
+		Kind       string   `yaml:"kind"`
+		Metadata   metadata `yaml:"metadata"`
+		Spec       spec     `yaml:"spec"`
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, t := range templates {
+		err := encoder.Encode(resource{
+			APIVersion: "templates.gatekeeper.sh/v1",
+			Kind:       "ConstraintTemplate",
+			Metadata:   metadata{Name: t.Name},
+			Spec: spec{
+				CRD: crd{Spec: crdSpec{Names: names{Kind: t.Kind}}},
+				Targets: []target{{
+					Target: "admission.k8s.gatekeeper.sh",
+					Rego:   t.Rego,
+					Libs:   t.Libs,
+				}},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func doExportFlags(projPath string, destination string, relativeTo string) error {
+	printer.Trace("--- Export flags start ---")
+	defer printer.Trace("--- Export flags end ---")
+
+	env, err := resolveProjectEnv(projPath, false)
+	if err != nil {
+		return err
+	}
+	args, err := opaFlags(env, relativeTo)
+	if err != nil {
+		return err
+	}
+
+	content := strings.Join(args, "\n")
+	if destination == "" {
+		printer.Output("%s", content)
+		return nil
+	}
+	if err := utils.MakeDir(filepath.Dir(destination)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(destination, []byte(content+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write flags %s: %w", destination, err)
+	}
+	return nil
+}
+
+// opaFlags returns the OPA flags loading the locations of env; with paths relative to the relativeTo directory, unless
+// empty.
+func opaFlags(env projectEnv, relativeTo string) ([]string, error) {
+	path := func(p string) (string, error) {
+		if relativeTo == "" {
+			return p, nil
+		}
+		base, err := filepath.Abs(relativeTo)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Rel(base, p)
+	}
+
+	var args []string
+	add := func(flag string, paths ...string) error {
+		for _, p := range paths {
+			p, err := path(p)
+			if err != nil {
+				return err
+			}
+			args = append(args, flag, p)
+		}
+		return nil
+	}
+	if err := add("-d", env.DataLocations...); err != nil {
+		return nil, err
+	}
+	if env.Schema != "" {
+		if err := add("--schema", env.Schema); err != nil {
+			return nil, err
+		}
+	}
+	if err := add("-b", env.Bundles...); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+func doExportArtifact(projPath string, destination string) error {
+	printer.Trace("--- Export artifact start ---")
+	defer printer.Trace("--- Export artifact end ---")
+
+	if err := verifyDependencies(projPath, true); err != nil {
+		return err
+	}
+
+	project, err := proj.ReadAndLoadProject(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	locations, err := sourceLocations(project)
+	if err != nil {
+		return err
+	}
+	locations = append(locations, project.VendoredLocations()...)
+
+	tmpDir, err := os.MkdirTemp("", "odm-export-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	archivePath := filepath.Join(tmpDir, defaultArtifactFile)
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	err = utils.CreateTarGz(f, project.Dir(), locations)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to archive project: %w", err)
+	}
+
+	if strings.HasPrefix(destination, "oci://") {
+		printer.Info("Pushing artifact of %s to %s", project.Dir(), destination)
+		return pushOci(destination, archivePath, oci.MediaTypeProjectLayer, oci.ArtifactTypeProject)
+	}
+
+	if destination == "" {
+		destination = filepath.Join(filepath.Dir(bundlePath(project)), defaultArtifactFile)
+	}
+	if err := utils.MakeDir(filepath.Dir(destination)); err != nil {
+		return err
+	}
+	bs, err := os.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(destination, bs, 0644); err != nil {
+		return fmt.Errorf("failed to write artifact %s: %w", destination, err)
+	}
+	printer.Output("%s", destination)
+	return nil
+}
+
+// extractArtifact extracts the project artifact at location, a gzipped tarball or an oci:// reference, into dstDir.
+func extractArtifact(location string, dstDir string) error {
+	if strings.HasPrefix(location, "oci://") {
+		ref, err := oci.ParseReference(strings.TrimPrefix(location, "oci://"))
+		if err != nil {
+			return fmt.Errorf("invalid artifact %s: %w", location, err)
+		}
+		digest, err := oci.NewClient().Pull(ref, dstDir)
+		if err != nil {
+			return err
+		}
+		printer.Info("Pulled artifact %s@%s", ref, digest)
+	} else {
+		f, err := os.Open(location)
+		if err != nil {
+			return err
+		}
+		err = utils.ExtractTarGz(f, dstDir)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to extract artifact %s: %w", location, err)
+		}
+	}
+
+	if !utils.FileExists(proj.ProjectFilePath(dstDir)) {
+		return fmt.Errorf("%s is not a project artifact; no opa.project found", location)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOpaFlags(t *testing.T) {
+	env := projectEnv{
+		DataLocations: []string{"/proj/src", "/proj/.opa/dependencies/123"},
+		TestLocations: []string{"/proj/tests"},
+		Schema:        "/proj/schemas",
+		Bundles:       []string{"/proj/.opa/bundles/456.tar.gz"},
+	}
+
+	tests := []struct {
+		note       string
+		env        projectEnv
+		relativeTo string
+		expected   []string
+	}{
+		{
+			note: "empty",
+		},
+		{
+			note: "absolute",
+			env:  env,
+			expected: []string{
+				"-d", "/proj/src",
+				"-d", "/proj/.opa/dependencies/123",
+				"--schema", "/proj/schemas",
+				"-b", "/proj/.opa/bundles/456.tar.gz",
+			},
+		},
+		{
+			note:       "relative",
+			env:        env,
+			relativeTo: "/proj/build",
+			expected: []string{
+				"-d", "../src",
+				"-d", "../.opa/dependencies/123",
+				"--schema", "../schemas",
+				"-b", "../.opa/bundles/456.tar.gz",
+			},
+		},
+		{
+			note:       "no schema",
+			env:        projectEnv{DataLocations: []string{"/proj/src"}},
+			relativeTo: "/proj",
+			expected:   []string{"-d", "src"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			actual, err := opaFlags(tc.env, tc.relativeTo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ideEditors are the editors 'ide setup' can configure.
+var ideEditors = []string{"vscode", "regal"}
+
+// ideMarkerFile, in the .opa directory, lists the editors configured by 'ide setup', to be kept up to date by updates.
+const ideMarkerFile = "ide"
+
+func init() {
+	var editors []string
+	var noUpdate bool
+
+	var ideCommand = &cobra.Command{
+		Use:   "ide",
+		Short: "Configure editors for the project",
+	}
+	RootCommand.AddCommand(ideCommand)
+
+	var ideSetupCommand = &cobra.Command{
+		Use:   "setup [flags]",
+		Short: "Point editors and language servers at the project's source and dependencies",
+		Long: `Point editors and language servers at the project's source and dependencies
+
+Writes the project's data and test locations, including the resolved dependencies under .opa/dependencies, as the roots
+of the editor configuration; so that go-to-definition, completion and linting work across dependencies. Paths are
+relative to the project directory, which is expected to be the root of the editor's workspace.
+
+Supported editors:
+* vscode: 'opa.roots' and 'opa.schema' in .vscode/settings.json, for the OPA extension
+* regal: 'project.roots' in .regal/config.yaml, for the Regal language server
+
+Other settings in these files are kept. Once set up, the configuration is rewritten by every update of the
+dependencies, as they're added and removed.
+
+Example:
+'odm ide setup'
+'odm ide setup --editor regal'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			for _, editor := range editors {
+				if !utils.Contains(ideEditors, editor) {
+					return fmt.Errorf("unsupported editor '%s'; expected %s", editor, strings.Join(ideEditors, " or "))
+				}
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doIdeSetup(projPath, editors); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	ideSetupCommand.Flags().StringSliceVar(&editors, "editor", ideEditors,
+		"editor to configure: vscode or regal; may be repeated")
+	_ = ideSetupCommand.RegisterFlagCompletionFunc("editor", completeValues(ideEditors...))
+	addNoUpdateFlag(ideSetupCommand, &noUpdate)
+	ideCommand.AddCommand(ideSetupCommand)
+}
+
+func doIdeSetup(projPath string, editors []string) error {
+	printer.Trace("--- IDE setup start ---")
+	defer printer.Trace("--- IDE setup end ---")
+
+	project, err := proj.ReadProjectFromFile(projPath, false)
+	if err != nil {
+		return err
+	}
+	if err := writeEditorConfig(projPath, project.Dir(), editors); err != nil {
+		return err
+	}
+
+	dotOpaDir := filepath.Join(project.Dir(), ".opa")
+	if err := os.MkdirAll(dotOpaDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dotOpaDir, ideMarkerFile), []byte(strings.Join(editors, "\n")+"\n"), 0644)
+}
+
+// refreshEditorConfig rewrites the configuration of the editors set up for the project in dir, if any.
+func refreshEditorConfig(projPath string, dir string) error {
+	bs, err := os.ReadFile(filepath.Join(dir, ".opa", ideMarkerFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return writeEditorConfig(projPath, dir, strings.Fields(string(bs)))
+}
+
+func writeEditorConfig(projPath string, dir string, editors []string) error {
+	env, err := resolveProjectEnv(projPath, false)
+	if err != nil {
+		return err
+	}
+	roots, err := editorRoots(dir, append(env.DataLocations, env.TestLocations...))
+	if err != nil {
+		return err
+	}
+	schema := ""
+	if env.Schema != "" {
+		if schema, err = filepath.Rel(dir, env.Schema); err != nil {
+			return err
+		}
+	}
+
+	for _, editor := range editors {
+		var path string
+		switch editor {
+		case "vscode":
+			path = filepath.Join(dir, ".vscode", "settings.json")
+			err = writeVSCodeSettings(path, roots, schema)
+		case "regal":
+			path = filepath.Join(dir, ".regal", "config.yaml")
+			err = writeRegalConfig(path, roots)
+		default:
+			err = fmt.Errorf("unsupported editor '%s'", editor)
+		}
+		if err != nil {
+			return err
+		}
+		printer.Info("Configured %s in %s", editor, path)
+	}
+	return nil
+}
+
+// editorRoots returns the directories of the absolute locations, relative to dir, in order and without duplicates.
+// Files directly in dir, listed individually when it holds compiled bundle dependencies, are covered by dir itself.
+func editorRoots(dir string, locations []string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	for _, location := range locations {
+		if info, err := os.Stat(location); err == nil && !info.IsDir() {
+			location = filepath.Dir(location)
+		}
+		root, err := filepath.Rel(dir, location)
+		if err != nil {
+			return nil, err
+		}
+		root = filepath.ToSlash(root)
+		if !utils.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	return roots, nil
+}
+
+// writeVSCodeSettings sets the roots and schema of the OPA extension in the VS Code settings file at path, relative to
+// the workspace folder. Comments in an existing settings file aren't kept.
+func writeVSCodeSettings(path string, roots []string, schema string) error {
+	settings := map[string]any{}
+	if bs, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(stripJSONComments(bs), &settings); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	workspaceRoots := make([]string, 0, len(roots))
+	for _, root := range roots {
+		workspaceRoots = append(workspaceRoots, workspacePath(root))
+	}
+	settings["opa.roots"] = workspaceRoots
+	if schema != "" {
+		settings["opa.schema"] = workspacePath(filepath.ToSlash(schema))
+	} else {
+		delete(settings, "opa.schema")
+	}
+
+	bs, err := json.MarshalIndent(settings, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(bs, '\n'), 0644)
+}
+
+func workspacePath(path string) string {
+	if path == "." {
+		return "${workspaceFolder}"
+	}
+	return "${workspaceFolder}/" + path
+}
+
+// stripJSONComments removes the line and block comments, and trailing commas, VS Code allows in its settings files.
+func stripJSONComments(bs []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(bs); i++ {
+		c := bs[i]
+		switch {
+		case inString:
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(bs) {
+				i++
+				out.WriteByte(bs[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '/' && i+1 < len(bs) && bs[i+1] == '/':
+			for i < len(bs) && bs[i] != '\n' {
+				i++
+			}
+			out.WriteByte('\n')
+		case c == '/' && i+1 < len(bs) && bs[i+1] == '*':
+			end := bytes.Index(bs[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(bs)
+			} else {
+				i += end + 3
+			}
+		case c == ',':
+			if j := skipJSONSpace(bs, i+1); j < len(bs) && (bs[j] == '}' || bs[j] == ']') {
+				continue
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+// skipJSONSpace returns the index of the first character from i that is neither whitespace nor part of a comment.
+func skipJSONSpace(bs []byte, i int) int {
+	for i < len(bs) {
+		switch {
+		case bs[i] == ' ' || bs[i] == '\t' || bs[i] == '\r' || bs[i] == '\n':
+			i++
+		case bytes.HasPrefix(bs[i:], []byte("//")):
+			for i < len(bs) && bs[i] != '\n' {
+				i++
+			}
+		case bytes.HasPrefix(bs[i:], []byte("/*")):
+			end := bytes.Index(bs[i+2:], []byte("*/"))
+			if end < 0 {
+				return len(bs)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// writeRegalConfig sets the project roots of the Regal config file at path, relative to the project directory. Other
+// settings, and comments, are kept.
+func writeRegalConfig(path string, roots []string) error {
+	var doc yaml.Node
+	if bs, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(bs, &doc); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	config := doc.Content[0]
+	if config.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse %s: expected a mapping", path)
+	}
+
+	project := mappingValue(config, "project")
+	if project.Kind != yaml.MappingNode {
+		*project = yaml.Node{Kind: yaml.MappingNode}
+	}
+	rootsNode := mappingValue(project, "roots")
+	*rootsNode = yaml.Node{Kind: yaml.SequenceNode}
+	for _, root := range roots {
+		rootsNode.Content = append(rootsNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: root})
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// mappingValue returns the value of key in the YAML mapping, appending an empty value if the key is missing.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	value := &yaml.Node{}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
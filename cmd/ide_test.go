@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteVSCodeSettings(t *testing.T) {
+	tests := []struct {
+		note     string
+		existing string
+		schema   string
+		expected string
+	}{
+		{
+			note: "new",
+			expected: `{
+    "opa.roots": [
+        "${workspaceFolder}/src",
+        "${workspaceFolder}/.opa/dependencies/123"
+    ]
+}
+`,
+		},
+		{
+			note: "existing, with comments and trailing commas",
+			existing: `{
+    // Format on save
+    "editor.formatOnSave": true, /* keep */
+    "opa.roots": ["${workspaceFolder}/old",],
+    "opa.schema": "${workspaceFolder}/old-schemas",
+    "url": "https://example.com//path",
+}`,
+			schema: "schemas",
+			expected: `{
+    "editor.formatOnSave": true,
+    "opa.roots": [
+        "${workspaceFolder}/src",
+        "${workspaceFolder}/.opa/dependencies/123"
+    ],
+    "opa.schema": "${workspaceFolder}/schemas",
+    "url": "https://example.com//path"
+}
+`,
+		},
+		{
+			note:     "schema removed",
+			existing: `{"opa.schema": "${workspaceFolder}/old-schemas"}`,
+			expected: `{
+    "opa.roots": [
+        "${workspaceFolder}/src",
+        "${workspaceFolder}/.opa/dependencies/123"
+    ]
+}
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".vscode", "settings.json")
+			if tc.existing != "" {
+				_ = os.MkdirAll(filepath.Dir(path), 0755)
+				if err := os.WriteFile(path, []byte(tc.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := writeVSCodeSettings(path, []string{"src", ".opa/dependencies/123"}, tc.schema); err != nil {
+				t.Fatal(err)
+			}
+
+			bs, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(bs) != tc.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tc.expected, bs)
+			}
+		})
+	}
+}
+
+func TestWriteRegalConfig(t *testing.T) {
+	tests := []struct {
+		note     string
+		existing string
+		expected string
+	}{
+		{
+			note: "new",
+			expected: `project:
+  roots:
+    - .
+    - .opa/dependencies/123
+`,
+		},
+		{
+			note: "existing",
+			existing: `# Lint rules
+rules:
+  style:
+    line-length:
+      level: ignore
+project:
+  roots:
+    - old
+`,
+			expected: `# Lint rules
+rules:
+  style:
+    line-length:
+      level: ignore
+project:
+  roots:
+    - .
+    - .opa/dependencies/123
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".regal", "config.yaml")
+			if tc.existing != "" {
+				_ = os.MkdirAll(filepath.Dir(path), 0755)
+				if err := os.WriteFile(path, []byte(tc.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := writeRegalConfig(path, []string{".", ".opa/dependencies/123"}); err != nil {
+				t.Fatal(err)
+			}
+
+			bs, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(bs) != tc.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tc.expected, bs)
+			}
+		})
+	}
+}
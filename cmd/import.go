@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+func init() {
+	var sourceDir string
+	var dependency string
+
+	var importCommand = &cobra.Command{
+		Use:   "import",
+		Short: "Scaffold the project from existing artifacts",
+	}
+
+	RootCommand.AddCommand(importCommand)
+
+	var bundleCommand = &cobra.Command{
+		Use:   "bundle <bundle.tar.gz> [flags]",
+		Short: "Scaffold the project from an existing bundle",
+		Long: `Scaffold the project from an existing bundle
+
+Reads the manifest and content of a bundle, e.g. built by an established bundle pipeline, and scaffolds the project
+after it. The bundle's Rego and data files are extracted into the source directory, keeping their paths within the
+bundle, and the source directory is added to the project.
+With --dependency, the bundle is instead copied into bundles/<name>/, and declared as a compiled bundle dependency;
+as compiled bundles, with a plan or wasm modules, must be.
+
+The project file is created, if it doesn't exist.
+
+Example:
+'odm import bundle bundle.tar.gz'
+'odm import bundle authz.tar.gz --dependency authz'
+`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if err := doImportBundle(projPath, args[0], sourceDir, dependency); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	bundleCommand.Flags().StringVarP(&sourceDir, "source", "s", "src", "source directory to extract the bundle into")
+	bundleCommand.Flags().StringVarP(&dependency, "dependency", "d", "", "import the bundle as a dependency of the given name")
+	importCommand.AddCommand(bundleCommand)
+}
+
+func doImportBundle(projPath string, bundlePath string, sourceDir string, dependency string) error {
+	printer.Trace("--- Import bundle start ---")
+	defer printer.Trace("--- Import bundle end ---")
+
+	project, err := proj.ReadProjectFromFile(projPath, true)
+	if err != nil {
+		return err
+	}
+
+	imported, err := project.ImportBundle(bundlePath, sourceDir, dependency)
+	if err != nil {
+		return fmt.Errorf("failed to import bundle %s: %w", bundlePath, err)
+	}
+	if err := project.WriteToFile(projPath, true); err != nil {
+		return err
+	}
+
+	if imported.Dependency != "" {
+		printer.Output("Imported bundle as dependency %s", imported.Dependency)
+	} else {
+		printer.Output("Imported %d files into %s", len(imported.Files), sourceDir)
+	}
+	for _, file := range imported.Files {
+		printer.Info("  %s", file)
+	}
+	if imported.Roots != nil {
+		printer.Output("Bundle roots: %s", strings.Join(imported.Roots, ", "))
+	}
+	return nil
+}
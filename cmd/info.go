@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/spf13/cobra"
+	"sort"
+	"strings"
+)
+
+func init() {
+	var noUpdate bool
+
+	var infoCommand = &cobra.Command{
+		Use:   "info <name> [flags]",
+		Short: "Show details of a dependency",
+		Long: `Show details of a dependency
+
+Shows what was actually fetched for the dependency: its declared and resolved location, the fetched git commit or
+OCI digest, its namespace and directory, its own dependencies, and the contents of its project file.
+
+Transitive dependencies are referenced by their dot-separated path in the dependency tree; e.g. 'foo.bar' for the
+dependency 'bar' declared by the dependency 'foo'.
+
+Example:
+'odm info http'
+'odm info http.jwt'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one dependency name")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doInfo(projPath, args[0]); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	infoCommand.ValidArgsFunction = completeDependencies
+	addNoUpdateFlag(infoCommand, &noUpdate)
+	RootCommand.AddCommand(infoCommand)
+}
+
+func doInfo(projPath string, name string) error {
+	printer.Trace("--- Info start ---")
+	defer printer.Trace("--- Info end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	details, err := project.DependencyDetails(name)
+	if err != nil {
+		return err
+	}
+
+	printer.Output(formatDependencyDetails(details))
+	return nil
+}
+
+func formatDependencyDetails(details *proj.DependencyDetails) string {
+	var sb strings.Builder
+	field := func(name string, value string) {
+		if value == "" {
+			value = "-"
+		}
+		_, _ = fmt.Fprintf(&sb, "%-19s %s\n", name+":", value)
+	}
+
+	field("Name", details.Name)
+	if details.Path != details.Name {
+		field("Path", details.Path)
+	}
+	field("Location", details.Location)
+	if details.ResolvedLocation != details.Location {
+		field("Resolved location", details.ResolvedLocation)
+	}
+	field("Revision", details.Revision)
+	if details.Namespace != "" {
+		field("Namespace", "data."+details.Namespace)
+	} else {
+		field("Namespace", "none")
+	}
+	field("Directory", details.Dir)
+	if len(details.WasmEntrypoints) > 0 {
+		field("Wasm entrypoints", strings.Join(details.WasmEntrypoints, ", "))
+	}
+	if details.Deprecation != nil {
+		field("Deprecated", details.Deprecation.String())
+	}
+
+	if p := details.Project; p != nil {
+		field("Project", strings.TrimSpace(p.Name+" "+p.Version))
+		field("Source", strings.Join(p.SourceDirs, ", "))
+		field("Tests", strings.Join(p.TestDirs, ", "))
+		field("Entrypoints", strings.Join(p.Build.Entrypoints, ", "))
+	}
+
+	if len(details.Dependencies) == 0 {
+		field("Dependencies", "")
+	} else {
+		sb.WriteString("Dependencies:\n")
+		names := make([]string, 0, len(details.Dependencies))
+		for name := range details.Dependencies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			_, _ = fmt.Fprintf(&sb, "  %s: %s\n", name, details.Dependencies[name])
+		}
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
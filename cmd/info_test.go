@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/johanfylling/odm/proj"
+	"testing"
+)
+
+func TestFormatDependencyDetails(t *testing.T) {
+	details := &proj.DependencyDetails{
+		Name:             "http",
+		Path:             "http",
+		Location:         "lib/http@1.2.0",
+		ResolvedLocation: "oci://ghcr.io/acme-policies/http:1.2.0",
+		Revision:         "sha256:abc",
+		Namespace:        "http",
+		Dir:              "/proj/.opa/dependencies/123",
+		Dependencies: map[string]string{
+			"jwt":  "lib/jwt@2.0.0",
+			"util": "file:/../util",
+		},
+		Project: &proj.Project{
+			Name:       "policy-lib-http",
+			Version:    "1.2.0",
+			SourceDirs: []string{"src"},
+		},
+	}
+
+	expected := `Name:               http
+Location:           lib/http@1.2.0
+Resolved location:  oci://ghcr.io/acme-policies/http:1.2.0
+Revision:           sha256:abc
+Namespace:          data.http
+Directory:          /proj/.opa/dependencies/123
+Project:            policy-lib-http 1.2.0
+Source:             src
+Tests:              -
+Entrypoints:        -
+Dependencies:
+  jwt: lib/jwt@2.0.0
+  util: file:/../util`
+
+	if actual := formatDependencyDetails(details); actual != expected {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", expected, actual)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/johanfylling/odm/utils"
 	"github.com/spf13/cobra"
 	"os"
+	"path/filepath"
 )
 
 func init() {
@@ -17,7 +18,7 @@ func init() {
 		Use:   "init [name]",
 		Short: "Initialize a new OPA project",
 		Run: func(cmd *cobra.Command, args []string) {
-			path := "."
+			path := newProjectPath()
 			var name string
 			if len(args) == 1 {
 				name = args[0]
@@ -27,8 +28,7 @@ func init() {
 				sourceDir = ""
 			}
 			if err := doInit(path, name, sourceDir); err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-				os.Exit(1)
+				exit(err)
 			}
 		},
 	}
@@ -53,20 +53,22 @@ func doInit(path string, name string, sourceDir string) error {
 		project.SourceDirs = []string{sourceDir}
 	}
 
-	if !utils.FileExists(path) {
-		if err := os.MkdirAll(path, 0755); err != nil {
+	filePath := proj.ProjectFilePath(path)
+	dir := filepath.Dir(filePath)
+	if !utils.FileExists(dir) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
 			return fmt.Errorf("error creating project directory: %s", err)
 		}
 	} else {
-		printer.Debug("directory %s already exists, not creating new\n", path)
+		printer.Debug("directory %s already exists, not creating new\n", dir)
 	}
 
-	err := project.WriteToFile(path, false)
+	err := project.WriteToFile(filePath, false)
 	if err != nil {
 		return err
 	}
 
-	err = createDotOpaDirectory(path)
+	err = createDotOpaDirectory(dir)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const projectContentLabel = "(project)"
+
+type inspectOptions struct {
+	annotations bool
+	format      string
+}
+
+// contentSize is the contribution of the project itself, or one of its dependencies, to the size of a bundle.
+type contentSize struct {
+	Dependency string `json:"dependency,omitempty"`
+	Files      int    `json:"files"`
+	Size       int64  `json:"size"`
+}
+
+func init() {
+	var noUpdate bool
+	var opts inspectOptions
+
+	var inspectCommand = &cobra.Command{
+		Use:   "inspect [bundle|project] [flags]",
+		Short: "Inspect the built bundle, or the resolved project",
+		Long: `Inspect the built bundle, or the resolved project
+
+Runs 'opa inspect', listing packages, entrypoints and optionally annotations, followed by the size contributed by the
+project and each of its dependencies; to understand what makes up a bundle.
+
+'bundle' (the default) inspects the bundle built by 'odm build', as configured by 'build.output'.
+'project' inspects the resolved dependency tree, as it would be built.
+
+Example:
+'odm inspect'
+'odm inspect project --annotations'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 || (len(args) == 1 && args[0] != "bundle" && args[0] != "project") {
+				return fmt.Errorf("expected 'bundle' or 'project'")
+			}
+			if opts.format != "pretty" && opts.format != "json" {
+				return fmt.Errorf("unsupported format '%s'; expected pretty or json", opts.format)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+			inspectProject := len(args) == 1 && args[0] == "project"
+
+			if inspectProject && !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doInspect(projPath, inspectProject, opts); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	inspectCommand.Flags().BoolVarP(&opts.annotations, "annotations", "a", false, "list annotations")
+	inspectCommand.Flags().StringVar(&opts.format, "format", "pretty", "output format: pretty or json")
+	inspectCommand.ValidArgs = []string{"bundle", "project"}
+	_ = inspectCommand.RegisterFlagCompletionFunc("format", completeValues("pretty", "json"))
+	addNoUpdateFlag(inspectCommand, &noUpdate)
+	RootCommand.AddCommand(inspectCommand)
+}
+
+func doInspect(projPath string, inspectProject bool, opts inspectOptions) error {
+	printer.Trace("--- Inspect start ---")
+	defer printer.Trace("--- Inspect end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, true)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	if inspectProject {
+		tmpDir, err := os.MkdirTemp("", "odm-inspect-")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.RemoveAll(tmpDir) }()
+		path = filepath.Join(tmpDir, defaultTargetFile)
+		if err := buildBundle(project, path, nil); err != nil {
+			return err
+		}
+	} else {
+		path = bundlePath(project)
+		if !utils.FileExists(path) {
+			return fmt.Errorf("bundle %s not found; run 'odm build' first", path)
+		}
+	}
+
+	flags := []string{"--format", opts.format}
+	if opts.annotations {
+		flags = append(flags, "--annotations")
+	}
+	output, err := utils.NewOpa().Inspect(path, flags...)
+	if err != nil {
+		return fmt.Errorf("error running opa inspect:\n %s", err)
+	}
+
+	entries, err := utils.TarGzSizes(path)
+	if err != nil {
+		return err
+	}
+	dirs, err := project.DependencyDirs()
+	if err != nil {
+		return err
+	}
+	sizes := contentSizes(entries, dirs)
+
+	if opts.format == "json" {
+		bs, err := json.MarshalIndent(struct {
+			Inspect json.RawMessage `json:"inspect"`
+			Sizes   []contentSize   `json:"sizes"`
+		}{json.RawMessage(output), sizes}, "", "  ")
+		if err != nil {
+			return err
+		}
+		printer.Output(string(bs))
+		return nil
+	}
+
+	printer.Output(strings.TrimSuffix(output, "\n") + "\n" + formatContentSizes(sizes))
+	return nil
+}
+
+// contentSizes attributes the bundle entries to the dependencies whose directory they were built from, or whose
+// licenses or wasm modules they are; other entries are attributed to the project. Sizes are ordered largest first.
+func contentSizes(entries map[string]int64, dirs map[string]string) []contentSize {
+	paths := make([]string, 0, len(dirs))
+	for path := range dirs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	byDependency := make(map[string]*contentSize)
+	for name, size := range entries {
+		name = "/" + strings.TrimPrefix(filepath.ToSlash(name), "/")
+		owner := ""
+		for _, path := range paths {
+			if strings.Contains(name, "/"+filepath.Base(dirs[path])+"/") ||
+				strings.HasPrefix(name, "/licenses/"+path+"/") || strings.HasPrefix(name, "/wasm/"+path+"/") {
+				owner = path
+				break
+			}
+		}
+		if byDependency[owner] == nil {
+			byDependency[owner] = &contentSize{Dependency: owner}
+		}
+		byDependency[owner].Files++
+		byDependency[owner].Size += size
+	}
+
+	sizes := make([]contentSize, 0, len(byDependency))
+	for _, size := range byDependency {
+		sizes = append(sizes, *size)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].Size != sizes[j].Size {
+			return sizes[i].Size > sizes[j].Size
+		}
+		return sizes[i].Dependency < sizes[j].Dependency
+	})
+	return sizes
+}
+
+func formatContentSizes(sizes []contentSize) string {
+	width := len("DEPENDENCY")
+	for _, size := range sizes {
+		if len(size.Dependency) > width {
+			width = len(size.Dependency)
+		}
+	}
+	if len(projectContentLabel) > width {
+		width = len(projectContentLabel)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SIZES:\n")
+	_, _ = fmt.Fprintf(&sb, "%-*s  %5s  %10s\n", width, "DEPENDENCY", "FILES", "SIZE")
+	for _, size := range sizes {
+		name := size.Dependency
+		if name == "" {
+			name = projectContentLabel
+		}
+		_, _ = fmt.Fprintf(&sb, "%-*s  %5d  %10s\n", width, name, size.Files, formatBytes(size.Size))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// formatBytes returns the byte count in a human-readable form, with binary prefixes.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContentSizes(t *testing.T) {
+	entries := map[string]int64{
+		"/data.json":                         10,
+		"/.manifest":                         20,
+		"/src/policy.rego":                   100,
+		"/proj/.opa/dependencies/abc/x.rego": 300,
+		"/proj/.opa/dependencies/abc/y.rego": 200,
+		"/proj/.opa/dependencies/def/z.rego": 50,
+		"/licenses/http/LICENSE":             1000,
+		"/wasm/authz/policy.wasm":            4000,
+	}
+	dirs := map[string]string{
+		"http":     "/proj/.opa/dependencies/abc",
+		"http.jwt": "/proj/.opa/dependencies/def",
+		"authz":    "/proj/.opa/dependencies/ghi",
+	}
+
+	expected := []contentSize{
+		{Dependency: "authz", Files: 1, Size: 4000},
+		{Dependency: "http", Files: 3, Size: 1500},
+		{Dependency: "", Files: 3, Size: 130},
+		{Dependency: "http.jwt", Files: 1, Size: 50},
+	}
+	if actual := contentSizes(entries, dirs); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 * 1024 * 1024, "5.0 MiB"},
+	}
+
+	for _, tc := range tests {
+		if actual := formatBytes(tc.n); actual != tc.expected {
+			t.Errorf("expected %d bytes as %q, got %q", tc.n, tc.expected, actual)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/johanfylling/odm/printer"
 	"github.com/johanfylling/odm/proj"
 	"github.com/spf13/cobra"
-	"os"
 	"strings"
 )
 
@@ -26,18 +25,16 @@ func init() {
 		Use:   "source",
 		Short: "List OPA project source folders",
 		Run: func(cmd *cobra.Command, args []string) {
-			projPath := "."
+			projPath := projectPath()
 
 			if !noUpdate {
 				if err := doUpdate(projPath); err != nil {
-					_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-					os.Exit(1)
+					exit(err)
 				}
 			}
 
 			if err := doListSource(projPath, includeTestDirs, includeDepTests); err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-				os.Exit(1)
+				exit(err)
 			}
 		},
 	}
@@ -45,6 +42,26 @@ func init() {
 	listSourceCommand.Flags().BoolVarP(&includeTestDirs, "include-test-dirs", "t", false, "Include test directories in the list")
 	listSourceCommand.Flags().BoolVar(&includeDepTests, "include-dep-tests", false, "Include dependency tests")
 	listCommand.AddCommand(listSourceCommand)
+
+	var listDependenciesCommand = &cobra.Command{
+		Use:   "dependencies",
+		Short: "List all dependencies in the dependency tree, with deprecation notices",
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if !noUpdate {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doListDependencies(projPath); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	listCommand.AddCommand(listDependenciesCommand)
 }
 
 func doListSource(projPath string, includeTestDirs, includeDepTests bool) error {
@@ -74,3 +91,29 @@ func doListSource(projPath string, includeTestDirs, includeDepTests bool) error
 
 	return nil
 }
+
+func doListDependencies(projPath string) error {
+	printer.Trace("--- List dependencies start ---")
+	defer printer.Trace("--- List dependencies end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, true)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, path := range project.DependencyPaths() {
+		details, err := project.DependencyDetails(path)
+		if err != nil {
+			return err
+		}
+		line := fmt.Sprintf("%s %s", path, details.Location)
+		if details.Deprecation != nil {
+			line += fmt.Sprintf(" (deprecated: %s)", details.Deprecation)
+		}
+		lines = append(lines, line)
+	}
+
+	printer.Output(strings.Join(lines, "\n"))
+	return nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/johanfylling/odm/keyring"
+	"github.com/johanfylling/odm/printer"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+)
+
+func init() {
+	var username string
+
+	var loginCommand = &cobra.Command{
+		Use:   "login <host> [flags]",
+		Short: "Store a token for an OCI registry or git host",
+		Long: `Store a token for an OCI registry or git host
+
+The token, or password, is prompted for, or read from stdin when it isn't a terminal. It's stored in the OS keychain
+where one is available: the macOS Keychain, the Windows Credential Manager, or the Secret Service of the session on
+Linux (through libsecret's 'secret-tool'). Elsewhere, it's stored in a file encrypted at rest, beside the ODM config
+file; keyed by the ODM_KEYRING_PASSPHRASE environment variable if set, and otherwise by a generated key file readable
+only by the user. Set ODM_KEYRING to 'os' or 'file' to choose the store explicitly.
+
+Stored tokens authenticate pulls and pushes of OCI dependencies from the registry, and fetches of git dependencies
+over HTTP(S) from the host.
+
+The host may be given as a dependency location, or URL, it's taken from.
+
+Example:
+'echo $GITHUB_TOKEN | odm login ghcr.io --username my-user'
+'odm login git+https://github.com/my-org/policy-lib.git'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one host")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doLogin(args[0], username, os.Stdin); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	loginCommand.Flags().StringVarP(&username, "username", "u", "odm",
+		"username to authenticate as; ignored by most hosts when authenticating with a token")
+	RootCommand.AddCommand(loginCommand)
+
+	var logoutCommand = &cobra.Command{
+		Use:   "logout <host>",
+		Short: "Remove the token stored for an OCI registry or git host",
+		Long: `Remove the token stored for an OCI registry or git host
+
+Example:
+'odm logout ghcr.io'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one host")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doLogout(args[0]); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	RootCommand.AddCommand(logoutCommand)
+}
+
+func doLogin(location string, username string, in *os.File) error {
+	printer.Trace("--- Login start ---")
+	defer printer.Trace("--- Login end ---")
+
+	host := keyring.Host(location)
+	if host == "" {
+		return fmt.Errorf("invalid host '%s'", location)
+	}
+
+	secret, err := readSecret(in, host)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return fmt.Errorf("no token given for %s", host)
+	}
+
+	store, err := keyring.Open()
+	if err != nil {
+		return err
+	}
+	if err := store.Set(host, keyring.Credential{Username: username, Secret: secret}); err != nil {
+		return fmt.Errorf("failed to store credential for %s in %s: %w", host, store.Name(), err)
+	}
+	printer.Info("Stored credential for %s in %s", host, store.Name())
+	return nil
+}
+
+// readSecret prompts for the secret, without echoing it, if in is a terminal; and otherwise reads all of in.
+func readSecret(in *os.File, host string) (string, error) {
+	if term.IsTerminal(int(in.Fd())) {
+		_, _ = fmt.Fprintf(os.Stderr, "Token for %s: ", host)
+		bs, err := term.ReadPassword(int(in.Fd()))
+		_, _ = fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		return strings.TrimSpace(string(bs)), nil
+	}
+	bs, err := io.ReadAll(in)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
+
+func doLogout(location string) error {
+	printer.Trace("--- Logout start ---")
+	defer printer.Trace("--- Logout end ---")
+
+	host := keyring.Host(location)
+	store, err := keyring.Open()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(host); errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("no credential stored for %s in %s", host, store.Name())
+	} else if err != nil {
+		return fmt.Errorf("failed to remove credential for %s from %s: %w", host, store.Name(), err)
+	}
+	printer.Info("Removed credential for %s from %s", host, store.Name())
+	return nil
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestMain isolates the ODM cache of the commands under test in a temporary directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "odm-cache-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Setenv("ODM_CACHE_DIR", dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+)
+
+func init() {
+	var dryRun bool
+	var force bool
+
+	var migrateCommand = &cobra.Command{
+		Use:   "migrate [flags]",
+		Short: "Generate an opa.project from an existing setup",
+		Long: `Generate an opa.project from an existing setup
+
+Inspects a project managing its dependencies without ODM, and generates an equivalent opa.project, with dependencies
+and source and test directories filled in. Recognized are:
+
+- git submodules, declared in .gitmodules; pinned to the tag of their checked out commit, if any
+- fetch scripts; shell scripts and Makefiles running 'opa_fetch', 'git clone', 'conftest pull' or 'oras pull'
+- conftest layouts; the policy directory configured in conftest.toml, or policy/
+
+Dependencies aren't namespaced, as their packages were loaded as-is. Vendored dependency directories are kept out of
+the source where possible; otherwise, they're listed for removal once 'odm update' fetches them.
+
+Example:
+'odm migrate --dry-run'
+'odm migrate && odm update'
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := newProjectPath()
+
+			if err := doMigrate(projPath, dryRun, force); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	migrateCommand.Flags().BoolVar(&dryRun, "dry-run", false, "print the generated project file instead of writing it")
+	migrateCommand.Flags().BoolVar(&force, "force", false, "overwrite an existing project file")
+	RootCommand.AddCommand(migrateCommand)
+}
+
+func doMigrate(projPath string, dryRun bool, force bool) error {
+	printer.Trace("--- Migrate start ---")
+	defer printer.Trace("--- Migrate end ---")
+
+	migration, err := proj.Migrate(filepath.Dir(proj.ProjectFilePath(projPath)))
+	if err != nil {
+		return err
+	}
+	for _, note := range migration.Notes {
+		printer.Info("%s", note)
+	}
+	for _, warning := range migration.Warnings {
+		printer.Warn("%s", warning)
+	}
+
+	if dryRun {
+		bs, err := yaml.Marshal(migration.Project)
+		if err != nil {
+			return fmt.Errorf("failed to marshal project file: %w", err)
+		}
+		printer.Output("%s", bs)
+		return nil
+	}
+
+	if err := migration.Project.WriteToFile(projPath, force); err != nil {
+		return err
+	}
+	return createDotOpaDirectory(filepath.Dir(proj.ProjectFilePath(projPath)))
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var pinCommand = &cobra.Command{
+		Use:   "pin [<dependency>] [flags]",
+		Short: "Pin floating dependencies to their locked commits and digests",
+		Long: `Pin floating dependencies to their locked commits and digests
+
+Rewrites the locations of floating dependencies in opa.project to the exact revisions recorded in the lock file by
+the last 'odm update': git dependencies tracking a branch or HEAD are pinned to their commit, and OCI dependencies
+referenced by version range or tag, without digest, to their digest. Useful for freezing dependencies before a
+release. Only direct dependencies are pinned; all of them, unless a dependency is named.
+
+Example:
+'odm update && odm pin'
+'odm pin http'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("expected at most one dependency")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if err := doPin(projPath, args); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	pinCommand.ValidArgsFunction = completeDirectDependencies
+	RootCommand.AddCommand(pinCommand)
+}
+
+func doPin(projPath string, names []string) error {
+	printer.Trace("--- Pin start ---")
+	defer printer.Trace("--- Pin end ---")
+
+	project, err := proj.ReadProjectFromFile(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	pinned, err := project.Pin(names)
+	if err != nil {
+		return err
+	}
+	if len(pinned) == 0 {
+		printer.Output("No floating dependencies to pin")
+		return nil
+	}
+
+	if err := project.WriteToFile(projPath, true); err != nil {
+		return err
+	}
+	for _, dep := range pinned {
+		printer.Output("%s: %s → %s", dep.Name, dep.Location, dep.Pinned)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+func init() {
+	var format string
+
+	var planCommand = &cobra.Command{
+		Use:   "plan [flags]",
+		Short: "Show how dependencies would be resolved by an update, without fetching them",
+		Long: `Show how dependencies would be resolved by an update, without fetching them
+
+Resolves each dependency by querying metadata only: git references, OCI tags and manifests, and the configured
+remote cache; and prints the source, revision, cache hit and download size (where known) of each. Transitive
+dependencies are planned as declared by the dependencies fetched by the last update.
+
+A plan in JSON can be executed exactly with 'odm update --from-plan', e.g. after review in CI.
+
+Example:
+'odm plan'
+'odm plan --format json > plan.json && odm update --from-plan plan.json'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unsupported format '%s'; expected text or json", format)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if err := doPlan(projPath, format); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	planCommand.Flags().StringVar(&format, "format", "text", "output format: text or json")
+	_ = planCommand.RegisterFlagCompletionFunc("format", completeValues("text", "json"))
+	RootCommand.AddCommand(planCommand)
+}
+
+func doPlan(projPath string, format string) error {
+	printer.Trace("--- Plan start ---")
+	defer printer.Trace("--- Plan end ---")
+
+	project, err := proj.ReadAndLoadProject(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	plan, err := project.Plan()
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		bs, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		printer.Output(string(bs))
+		return nil
+	}
+
+	if len(plan.Dependencies) == 0 {
+		printer.Output("No dependencies")
+		return nil
+	}
+	lines := make([]string, 0, len(plan.Dependencies))
+	for _, dep := range plan.Dependencies {
+		lines = append(lines, formatPlannedDependency(dep))
+	}
+	printer.Output(strings.Join(lines, "\n"))
+	return nil
+}
+
+func formatPlannedDependency(dep proj.PlannedDependency) string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "%s: %s", dep.Path, dep.Source)
+	if dep.Tag != "" {
+		_, _ = fmt.Fprintf(&sb, "\n  tag:      %s", dep.Tag)
+	}
+	if dep.Revision != "" {
+		_, _ = fmt.Fprintf(&sb, "\n  revision: %s", dep.Revision)
+	}
+	if dep.Size > 0 {
+		_, _ = fmt.Fprintf(&sb, "\n  size:     %s", formatBytes(dep.Size))
+	}
+
+	var notes []string
+	if dep.Locked {
+		notes = append(notes, "locked")
+	}
+	if dep.Cached {
+		notes = append(notes, "cached")
+	}
+	if len(notes) > 0 {
+		_, _ = fmt.Fprintf(&sb, "\n  %s", strings.Join(notes, ", "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proxy"
+	"github.com/spf13/cobra"
+	"net/http"
+	"os"
+)
+
+func init() {
+	var listen string
+	var cacheDir string
+
+	var proxyCommand = &cobra.Command{
+		Use:   "proxy [flags]",
+		Short: "Run a caching pull-through proxy for OCI and git dependencies",
+		Long: `Run a caching pull-through proxy for OCI and git dependencies
+
+Serves OCI and git HTTP(S) dependencies to ODM clients, caching fetched content, so that fleets of CI runners don't
+each fetch from upstream hosts. OCI blobs and manifests are cached by digest; tags and git refs are refreshed from
+upstream on every request, falling back to the cache when upstream is unavailable.
+
+Clients fetch through the proxy by setting its URL as 'proxy' in their user-level ODM config:
+
+proxy: http://odm-proxy.internal:9000
+
+The proxy serves anonymous upstream content only; upstream credentials are not forwarded.
+
+Example:
+'odm proxy --listen :9000 --cache-dir /var/cache/odm'
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doProxy(listen, cacheDir); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	proxyCommand.Flags().StringVar(&listen, "listen", ":9000", "address to listen on")
+	proxyCommand.Flags().StringVar(&cacheDir, "cache-dir", "", "directory to cache fetched content in (default: odm/proxy in the user cache directory)")
+	RootCommand.AddCommand(proxyCommand)
+}
+
+func doProxy(listen string, cacheDir string) error {
+	printer.Trace("--- Proxy start ---")
+	defer printer.Trace("--- Proxy end ---")
+
+	if cacheDir == "" {
+		var err error
+		if cacheDir, err = proxy.DefaultCacheDir(); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory %s: %w", cacheDir, err)
+	}
+
+	printer.Output("Proxying dependencies on %s, caching in %s", listen, cacheDir)
+	return http.ListenAndServe(listen, proxy.NewServer(cacheDir))
+}
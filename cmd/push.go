@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	var noUpdate bool
+	var noBuild bool
+	var source bool
+	var meta utils.ObjectMetadata
+
+	var pushCommand = &cobra.Command{
+		Use:   "push <destination> [flags]",
+		Short: "Push the built OPA bundle to object storage or an OCI registry",
+		Long: `Push the built OPA bundle to object storage or an OCI registry
+
+Builds the project bundle and uploads it to an S3 or GCS bucket, or an OCI registry, from where OPA agents can poll it.
+If an object storage destination ends with '/', the bundle file name is appended.
+
+Object storage uploads are delegated to the 'aws', 'gsutil' and 'az' CLIs, which must be installed and authenticated.
+Their locations can be overridden through the AWS_CLI_PATH, GSUTIL_PATH and AZ_CLI_PATH environment variables.
+
+With --source, the project's Rego source tree (the project file, and source and test directories) is pushed to an
+OCI registry instead of the built bundle, as an ORAS artifact of type '` + oci.ArtifactTypeRego + `'.
+Source artifacts can be used as 'oci://' dependencies by other projects.
+
+Supported destinations:
+- Amazon S3: s3://bucket/path/bundle.tar.gz
+- Google Cloud Storage: gs://bucket/path/bundle.tar.gz
+- Azure Blob Storage: azblob://account/container/path/bundle.tar.gz
+- OCI registry: oci://registry/repository:tag
+
+Example:
+'odm push s3://my-bucket/bundles/ --cache-control "max-age=60" --metadata revision=abc123'
+'odm push oci://ghcr.io/my-org/policy-lib:1.2.0 --source'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one destination")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if source {
+				if err := doPushSource(projPath, args[0]); err != nil {
+					exit(err)
+				}
+				return
+			}
+
+			if !noUpdate && !noBuild {
+				if err := doUpdate(projPath); err != nil {
+					exit(err)
+				}
+			}
+
+			if !noBuild {
+				if err := doBuild(projPath, nil); err != nil {
+					exit(err)
+				}
+			}
+
+			if err := doPush(projPath, args[0], meta); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	pushCommand.Flags().BoolVar(&noBuild, "no-build", false, "push the previously built bundle, without building it first")
+	pushCommand.Flags().BoolVar(&source, "source", false, "push the project's Rego source tree to an OCI registry, instead of the built bundle")
+	pushCommand.Flags().StringVar(&meta.CacheControl, "cache-control", "", "Cache-Control header to set on the uploaded bundle")
+	pushCommand.Flags().StringVar(&meta.ContentType, "content-type", "application/gzip", "Content-Type header to set on the uploaded bundle")
+	pushCommand.Flags().StringToStringVar(&meta.Metadata, "metadata", nil, "custom metadata to set on the uploaded bundle, as key=value pairs")
+	addNoUpdateFlag(pushCommand, &noUpdate)
+	RootCommand.AddCommand(pushCommand)
+}
+
+func doPush(projPath string, destination string, meta utils.ObjectMetadata) error {
+	printer.Trace("--- Push start ---")
+	defer printer.Trace("--- Push end ---")
+
+	project, err := proj.ReadProjectFromFile(projPath, true)
+	if err != nil {
+		return err
+	}
+
+	source := bundlePath(project)
+	if !utils.FileExists(source) {
+		return fmt.Errorf("bundle %s does not exist; run 'odm build' first", source)
+	}
+
+	if strings.HasPrefix(destination, "oci://") {
+		printer.Info("Pushing bundle %s to %s", source, destination)
+		return pushOci(destination, source, oci.MediaTypeLayerTarGzip, "")
+	}
+
+	if strings.HasSuffix(destination, "/") {
+		destination += filepath.Base(source)
+	}
+
+	printer.Info("Pushing bundle %s to %s", source, destination)
+
+	return utils.UploadObject(source, destination, meta)
+}
+
+func doPushSource(projPath string, destination string) error {
+	printer.Trace("--- Push source start ---")
+	defer printer.Trace("--- Push source end ---")
+
+	if !strings.HasPrefix(destination, "oci://") {
+		return fmt.Errorf("source trees can only be pushed to OCI registries; expected oci:// destination")
+	}
+
+	project, err := proj.ReadProjectFromFile(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	locations, err := sourceLocations(project)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "odm-push-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	archivePath := filepath.Join(tmpDir, "source.tar.gz")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	err = utils.CreateTarGz(f, project.Dir(), locations)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to archive source tree: %w", err)
+	}
+
+	printer.Info("Pushing source tree of %s to %s", project.Dir(), destination)
+	return pushOci(destination, archivePath, oci.MediaTypeRegoLayer, oci.ArtifactTypeRego)
+}
+
+// sourceLocations returns the project file and the project's own source and test locations. A project without
+// declared source directories contributes all non-hidden entries of the project directory, except the bundle output.
+func sourceLocations(project *proj.Project) ([]string, error) {
+	dataLocations, err := project.DataLocations()
+	if err != nil {
+		return nil, err
+	}
+	testLocations, err := project.TestLocations(false)
+	if err != nil {
+		return nil, err
+	}
+
+	outputEntry := strings.Split(strings.TrimPrefix(bundlePath(project), project.Dir()+string(os.PathSeparator)),
+		string(os.PathSeparator))[0]
+
+	locations := []string{project.FilePath()}
+	for _, location := range append(dataLocations, testLocations...) {
+		if location != project.Dir() {
+			if !strings.HasPrefix(location, filepath.Join(project.Dir(), ".opa")) {
+				locations = append(locations, location)
+			}
+			continue
+		}
+
+		entries, err := os.ReadDir(project.Dir())
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || name == filepath.Base(project.FilePath()) || name == outputEntry {
+				continue
+			}
+			locations = append(locations, filepath.Join(project.Dir(), name))
+		}
+	}
+
+	// Drop locations nested in other locations, so no file is archived twice
+	sort.Strings(locations)
+	var distinct []string
+	for _, location := range locations {
+		if n := len(distinct); n > 0 && (location == distinct[n-1] ||
+			strings.HasPrefix(location, distinct[n-1]+string(os.PathSeparator))) {
+			continue
+		}
+		distinct = append(distinct, location)
+	}
+
+	return utils.FilterExistingFiles(distinct), nil
+}
+
+func pushOci(destination string, layerPath string, layerMediaType string, artifactType string) error {
+	ref, err := oci.ParseReference(strings.TrimPrefix(destination, "oci://"))
+	if err != nil {
+		return fmt.Errorf("invalid destination %s: %w", destination, err)
+	}
+
+	digest, err := oci.NewClient().Push(ref, layerPath, layerMediaType, artifactType)
+	if err != nil {
+		return err
+	}
+
+	printer.Output("%s@%s", ref, digest)
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestPushSource(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	if err := doPushSource("testdata/projects/source-list", "oci://"+registry.Host()+"/org/source-list:1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := oci.ParseReference(registry.Host() + "/org/source-list:1.0.0")
+	dir := t.TempDir()
+	if _, err := oci.NewClient().Pull(ref, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+
+	expected := []string{"data/do/data.yml", "data/foo/data.json", "opa.project", "src/policy.rego", "test/test.rego"}
+	if len(files) != len(expected) {
+		t.Fatalf("expected files %v, got %v", expected, files)
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Fatalf("expected files %v, got %v", expected, files)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/spf13/cobra"
+	"sort"
+	"strings"
+)
+
+func init() {
+	var dryRun bool
+
+	var repairCommand = &cobra.Command{
+		Use:   "repair [flags]",
+		Short: "Re-fetch missing, partially fetched or modified dependencies",
+		Long: `Re-fetch missing, partially fetched or modified dependencies
+
+Compares the dependencies directory to the content recorded by the last 'odm update', and re-fetches only the
+dependencies whose directories are missing, empty, or don't match; leaving intact dependencies untouched. Locked
+dependencies are fetched as locked. Useful after an interrupted update, or disk issues.
+
+Example:
+'odm repair'
+'odm repair --dry-run'
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if err := doRepair(projPath, dryRun); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	repairCommand.Flags().BoolVar(&dryRun, "dry-run", false, "only list the dependencies in need of repair")
+	RootCommand.AddCommand(repairCommand)
+}
+
+func doRepair(projPath string, dryRun bool) error {
+	printer.Trace("--- Repair start ---")
+	defer printer.Trace("--- Repair end ---")
+
+	project, err := proj.ReadProjectFromFile(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	problems, err := project.Repair(dryRun)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		printer.Output("All dependencies are intact")
+		return nil
+	}
+
+	lines := make([]string, 0, len(problems))
+	for path, problem := range problems {
+		lines = append(lines, fmt.Sprintf("  %s: %s", path, problem))
+	}
+	sort.Strings(lines)
+	heading := "Repaired:"
+	if dryRun {
+		heading = "In need of repair:"
+	}
+	printer.Output("%s\n%s", heading, strings.Join(lines, "\n"))
+	return nil
+}
@@ -1,22 +1,131 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/errs"
 	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/timing"
 	"github.com/spf13/cobra"
+	"io"
 	"os"
 	"path"
+	"time"
 )
 
 var RootCommand = &cobra.Command{
 	Use:   path.Base(os.Args[0]),
 	Short: "OPA Dependency Manager (ODM)",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if timingsFormat == "" {
+			return nil
+		}
+		if timingsFormat != "table" && timingsFormat != "json" {
+			return fmt.Errorf("unsupported timings format '%s'; expected table or json", timingsFormat)
+		}
+		timing.Enable()
+		commandStart = time.Now()
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		reportTimings()
+	},
 }
 
+// errorFormat is the format errors are reported in: text or json
+var errorFormat string
+
+// timingsFormat is the format the durations of the command's phases are reported in, selected with --timings: table
+// or json; empty if not reported
+var timingsFormat string
+
+// commandStart is when the command started, for reporting its total duration
+var commandStart time.Time
+
+// projectFile is the project file, or project directory, selected with --project-file; empty for the working directory
+var projectFile string
+
 func init() {
 	// Add verbose flag to all commands
 	RootCommand.PersistentFlags().CountVarP(&printer.LogLevel, "verbose", "v", "verbose output")
+	RootCommand.PersistentFlags().StringVar(&errorFormat, "error-format", "text", "format of reported errors: text or json")
+	RootCommand.PersistentFlags().StringVarP(&projectFile, "project-file", "f", "", "project file to use, e.g. opa.project.yaml, or its directory; defaults to the opa.project of the working directory")
+	RootCommand.PersistentFlags().IntVar(&config.FetchOverrides.Parallel, "parallel-fetches", 0, "maximum number of dependencies fetched concurrently; overrides 'fetch.parallel' of the config file")
+	RootCommand.PersistentFlags().StringVar(&config.FetchOverrides.Bandwidth, "bandwidth-limit", "", "maximum combined download rate per second, e.g. 10MB; overrides 'fetch.bandwidth' of the config file")
+	RootCommand.PersistentFlags().StringVar(&timingsFormat, "timings", "", "report the durations of resolution, fetches, copies, refactorings and OPA invocations on stderr, as a table or json")
+	RootCommand.PersistentFlags().Lookup("timings").NoOptDefVal = "table"
+	_ = RootCommand.RegisterFlagCompletionFunc("error-format", completeValues("text", "json"))
+	_ = RootCommand.RegisterFlagCompletionFunc("timings", completeValues("table", "json"))
+}
+
+// projectPath returns the path of the project selected with --project-file; or else the nearest directory containing a
+// project file, starting at the working directory and walking up its parents; or the working directory, if there is none.
+func projectPath() string {
+	if projectFile != "" {
+		return projectFile
+	}
+	if dir, ok := proj.FindProjectDir("."); ok {
+		return dir
+	}
+	return "."
+}
+
+// newProjectPath returns the path of the project selected with --project-file; or the working directory, if none is.
+// Used by commands creating projects, which mustn't pick up a project in a parent directory.
+func newProjectPath() string {
+	if projectFile != "" {
+		return projectFile
+	}
+	return "."
 }
 
 func addNoUpdateFlag(cmd *cobra.Command, v *bool) {
 	cmd.Flags().BoolVar(v, "no-update", false, "do not sync dependencies before executing this command")
 }
+
+func addStrictFlag(cmd *cobra.Command, v *bool) {
+	cmd.Flags().BoolVar(v, "strict", false, "fail, instead of warn, if dependencies were modified since the last update")
+}
+
+func addFrozenFlag(cmd *cobra.Command, v *bool) {
+	cmd.Flags().BoolVar(v, "frozen", false,
+		"fail, without updating the lock file, if dependencies don't resolve exactly as locked")
+}
+
+// exit reports err on stderr and exits with the exit code of the error's code.
+func exit(err error) {
+	reportTimings()
+	reportError(os.Stderr, err, errorFormat)
+	os.Exit(errs.CodeOf(err).ExitCode)
+}
+
+// reportTimings reports the durations of the command's phases on stderr, if requested.
+func reportTimings() {
+	if !timing.Enabled() {
+		return
+	}
+	if err := timing.Report(printer.LogWriter, timingsFormat, time.Since(commandStart)); err != nil {
+		printer.Warn("failed to report timings: %s", err)
+	}
+}
+
+// reportError writes err to w in the given format. In text format, coded errors are prefixed by their code; in json
+// format, errors are written as an object with the code, title, message and exit code.
+func reportError(w io.Writer, err error, format string) {
+	code := errs.CodeOf(err)
+	if format == "json" {
+		_ = json.NewEncoder(w).Encode(struct {
+			errs.Code
+			Message string `json:"message"`
+		}{code, err.Error()})
+		return
+	}
+
+	if code == errs.Unknown {
+		_, _ = fmt.Fprintf(w, "%s\n", err)
+	} else {
+		_, _ = fmt.Fprintf(w, "%s: %s\n", code.ID, err)
+	}
+}
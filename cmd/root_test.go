@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"github.com/johanfylling/odm/errs"
+	"testing"
+)
+
+func TestReportError(t *testing.T) {
+	tests := []struct {
+		note     string
+		err      error
+		format   string
+		expected string
+	}{
+		{
+			note:     "text, uncoded",
+			err:      errors.New("something failed"),
+			format:   "text",
+			expected: "something failed\n",
+		},
+		{
+			note:     "text, coded",
+			err:      errs.New(errs.FetchFailed, "failed to pull %s", "policy"),
+			format:   "text",
+			expected: "ODM0020: failed to pull policy\n",
+		},
+		{
+			note:     "json",
+			err:      errs.New(errs.ContentMismatch, "content mismatch for %s", "policy"),
+			format:   "json",
+			expected: `{"code":"ODM0030","title":"dependency content mismatch","exit_code":4,"message":"content mismatch for policy"}` + "\n",
+		},
+		{
+			note:     "json, uncoded",
+			err:      errors.New("something failed"),
+			format:   "json",
+			expected: `{"code":"ODM0001","title":"error","exit_code":1,"message":"something failed"}` + "\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			var buf bytes.Buffer
+			reportError(&buf, tc.err, tc.format)
+			if buf.String() != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+func init() {
+	var noUpdate bool
+	var port int
+	var interval time.Duration
+
+	var serveCommand = &cobra.Command{
+		Use:   "serve [flags] -- [opa build flags]",
+		Short: "Serve the project bundle for local development",
+		Long: `Serve the project bundle for local development
+
+Builds the project bundle and serves it over HTTP, rebuilding it whenever project sources, tests, or local
+dependencies change. The bundle is served for every request path, with an ETag allowing OPA to skip unchanged bundles.
+While the last rebuild has failed, requests fail with its error, until a later change rebuilds the bundle successfully.
+
+Example OPA configuration for polling the served bundle:
+
+services:
+  odm:
+    url: http://localhost:8282
+bundles:
+  odm:
+    service: odm
+    resource: bundle.tar.gz
+    polling:
+      min_delay_seconds: 1
+      max_delay_seconds: 2
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if err := doServe(projPath, port, interval, noUpdate, args); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	serveCommand.Flags().IntVarP(&port, "port", "p", 8282, "port to serve the bundle on")
+	serveCommand.Flags().DurationVar(&interval, "watch-interval", time.Second, "interval between checks for changed files")
+	addNoUpdateFlag(serveCommand, &noUpdate)
+	RootCommand.AddCommand(serveCommand)
+}
+
+// bundleServer serves the last successfully built bundle; or, while the last rebuild has failed, its error, for clients
+// to not silently keep polling a stale bundle.
+type bundleServer struct {
+	mtx    sync.RWMutex
+	bundle []byte
+	etag   string
+	err    error
+}
+
+func (s *bundleServer) load(path string) error {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle %s: %w", path, err)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.bundle = bs
+	s.etag = fmt.Sprintf("\"%x\"", sha256.Sum256(bs))
+	s.err = nil
+	return nil
+}
+
+// fail records the error of a failed rebuild, served in place of the bundle until the next successful load.
+func (s *bundleServer) fail(err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.err = err
+}
+
+func (s *bundleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	printer.Debug("%s %s", r.Method, r.URL.Path)
+
+	if s.err != nil {
+		http.Error(w, fmt.Sprintf("bundle rebuild failed: %s", s.err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", s.etag)
+	if r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	http.ServeContent(w, r, "bundle.tar.gz", time.Time{}, bytes.NewReader(s.bundle))
+}
+
+func doServe(projPath string, port int, interval time.Duration, noUpdate bool, args []string) error {
+	printer.Trace("--- Serve start ---")
+	defer printer.Trace("--- Serve end ---")
+
+	server := &bundleServer{}
+
+	rebuild := func() (*utils.Watcher, error) {
+		if !noUpdate {
+			if err := doUpdate(projPath); err != nil {
+				return nil, err
+			}
+		}
+		if err := doBuild(projPath, args); err != nil {
+			return nil, err
+		}
+
+		project, err := proj.ReadAndLoadProject(projPath, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := server.load(bundlePath(project)); err != nil {
+			return nil, err
+		}
+		return newProjectWatcher(project)
+	}
+
+	watcher, err := rebuild()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			if !watcher.Changed() {
+				continue
+			}
+			printer.Output("Change detected, rebuilding bundle")
+			if w, err := rebuild(); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+				server.fail(err)
+			} else {
+				watcher = w
+				printer.Output("Bundle rebuilt")
+			}
+		}
+	}()
+
+	addr := fmt.Sprintf(":%d", port)
+	printer.Output("Serving bundle on http://localhost%s", addr)
+	return http.ListenAndServe(addr, server)
+}
+
+// newProjectWatcher creates a watcher for all local locations affecting the project; ignoring the .opa directory and
+// the build output.
+func newProjectWatcher(project *proj.Project) (*utils.Watcher, error) {
+	locations, err := project.LocalLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	output := bundlePath(project)
+	exclude := []string{".opa", ".git", filepath.Base(output)}
+	if outputDir := filepath.Dir(output); outputDir != project.Dir() {
+		exclude = append(exclude, filepath.Base(outputDir))
+	}
+
+	return utils.NewWatcher(locations, exclude), nil
+}
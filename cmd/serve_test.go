@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundleServerETag(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(bundle, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &bundleServer{}
+	if err := server.load(bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/bundles/bundle.tar.gz", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	resp := get("")
+	if resp.Code != http.StatusOK || resp.Body.String() != "v1" {
+		t.Fatalf("expected 200 with bundle, got %d: %s", resp.Code, resp.Body.String())
+	}
+	etag := resp.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+
+	if resp := get(etag); resp.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for unchanged bundle, got %d", resp.Code)
+	}
+
+	if err := os.WriteFile(bundle, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.load(bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = get(etag)
+	if resp.Code != http.StatusOK || resp.Body.String() != "v2" {
+		t.Fatalf("expected 200 with rebuilt bundle, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("ETag") == etag {
+		t.Fatal("expected ETag to change for rebuilt bundle")
+	}
+
+	// Failed rebuilds are reported instead of the stale bundle, until the next successful rebuild
+	server.fail(errors.New("rego_parse_error"))
+	resp = get("")
+	if resp.Code != http.StatusInternalServerError || !strings.Contains(resp.Body.String(), "rego_parse_error") {
+		t.Fatalf("expected 500 with rebuild error, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if err := server.load(bundle); err != nil {
+		t.Fatal(err)
+	}
+	if resp := get(""); resp.Code != http.StatusOK || resp.Body.String() != "v2" {
+		t.Fatalf("expected 200 with bundle after successful rebuild, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
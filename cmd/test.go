@@ -6,36 +6,48 @@ import (
 	"github.com/johanfylling/odm/proj"
 	"github.com/johanfylling/odm/utils"
 	"github.com/spf13/cobra"
-	"os"
 )
 
 func init() {
 	var noUpdate bool
+	var strict bool
+	var frozen bool
 	var includeDeps bool
 
 	var testCommand = &cobra.Command{
 		Use:   "test [flags] -- [opa test flags]",
 		Short: "Run OPA tests",
-		Long:  `Run OPA tests`,
+		Long: `Run OPA tests
+
+With --include-deps, the tests of dependencies are run too. Dependencies declared with 'isolatedTests: true' are
+tested separately, in a sandbox where they aren't namespaced, for test suites referencing their own packages by
+their original paths.
+
+Example:
+'odm test'
+'odm test --include-deps'
+`,
 		Run: func(cmd *cobra.Command, args []string) {
-			projPath := "."
+			projPath := projectPath()
 
 			if !noUpdate {
-				if err := doUpdate(projPath); err != nil {
-					_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-					os.Exit(1)
+				if _, err := updateProject(projPath, proj.UpdateOptions{Strict: strict, Frozen: frozen}, false); err != nil {
+					exit(err)
 				}
+			} else if err := verifyDependencies(projPath, strict); err != nil {
+				exit(err)
 			}
 
 			if err := doTest(projPath, includeDeps, args); err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-				os.Exit(1)
+				exit(err)
 			}
 		},
 	}
 
 	testCommand.Flags().BoolVar(&includeDeps, "include-deps", false, "Include dependency tests")
 	addNoUpdateFlag(testCommand, &noUpdate)
+	addStrictFlag(testCommand, &strict)
+	addFrozenFlag(testCommand, &frozen)
 	RootCommand.AddCommand(testCommand)
 }
 
@@ -60,12 +72,34 @@ func doTest(projPath string, includeDependencies bool, args []string) error {
 
 	dataLocations = append(dataLocations, testLocations...)
 
-	opa := utils.NewOpa(dataLocations...)
+	schema, err := project.SchemaLocation()
+	if err != nil {
+		return err
+	}
+
+	opa := utils.NewOpa(dataLocations...).WithSchema(schema)
 	if output, err := opa.Test(args...); err != nil {
 		return fmt.Errorf("error running opa test:\n %s", err)
 	} else {
 		printer.Output(output)
 	}
 
+	if !includeDependencies {
+		return nil
+	}
+	sandboxes, err := project.TestSandboxes()
+	if err != nil {
+		return err
+	}
+	for _, sandbox := range sandboxes {
+		printer.Output("Testing dependency %s in isolation:", sandbox.Dependency)
+		opa := utils.NewOpa(sandbox.Locations...)
+		if output, err := opa.Test(args...); err != nil {
+			return fmt.Errorf("error running opa test for dependency %s:\n %s", sandbox.Dependency, err)
+		} else {
+			printer.Output(output)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,5 @@
+package main
+
+allow {
+    data.lib.policy.allow
+}
@@ -0,0 +1,5 @@
+package lib.policy
+
+allow {
+    1 + 1 == 2
+}
@@ -0,0 +1,3 @@
+package licensed
+
+allow := true
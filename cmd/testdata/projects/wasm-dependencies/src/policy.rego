@@ -0,0 +1,5 @@
+package main
+
+allow {
+    1 + 1 == 2
+}
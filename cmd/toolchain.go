@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var use bool
+
+	var toolchainCommand = &cobra.Command{
+		Use:   "toolchain",
+		Short: "Manage locally cached OPA binaries",
+		Long: `Manage locally cached OPA binaries
+
+OPA binaries are downloaded to the ODM cache directory, and verified against their published checksums.
+The binary selected with 'odm toolchain use' is used by all ODM commands, unless overridden by the
+OPA_PATH environment variable.
+`,
+	}
+
+	RootCommand.AddCommand(toolchainCommand)
+
+	toolchainCommand.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List installed OPA versions",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doToolchainList(); err != nil {
+				exit(err)
+			}
+		},
+	})
+
+	var installCommand = &cobra.Command{
+		Use:   "install <version>",
+		Short: "Install an OPA version",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doToolchainInstall(args[0], use); err != nil {
+				exit(err)
+			}
+		},
+	}
+	installCommand.Flags().BoolVar(&use, "use", false, "use the installed version as default")
+	toolchainCommand.AddCommand(installCommand)
+
+	toolchainCommand.AddCommand(&cobra.Command{
+		Use:               "remove <version>",
+		Short:             "Remove an installed OPA version",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeToolchainVersions,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doToolchainRemove(args[0]); err != nil {
+				exit(err)
+			}
+		},
+	})
+
+	toolchainCommand.AddCommand(&cobra.Command{
+		Use:               "use <version>",
+		Short:             "Use an installed OPA version as default",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeToolchainVersions,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doToolchainUse(args[0]); err != nil {
+				exit(err)
+			}
+		},
+	})
+}
+
+func doToolchainList() error {
+	toolchains, err := utils.NewToolchains()
+	if err != nil {
+		return err
+	}
+
+	versions, err := toolchains.List()
+	if err != nil {
+		return err
+	}
+	current, err := toolchains.Current()
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if version == current {
+			printer.Output("* %s", version)
+		} else {
+			printer.Output("  %s", version)
+		}
+	}
+	return nil
+}
+
+func doToolchainInstall(version string, use bool) error {
+	toolchains, err := utils.NewToolchains()
+	if err != nil {
+		return err
+	}
+
+	if err := toolchains.Install(version); err != nil {
+		return err
+	}
+
+	if use {
+		return toolchains.Use(version)
+	}
+	return nil
+}
+
+func doToolchainRemove(version string) error {
+	toolchains, err := utils.NewToolchains()
+	if err != nil {
+		return err
+	}
+	return toolchains.Remove(version)
+}
+
+func doToolchainUse(version string) error {
+	toolchains, err := utils.NewToolchains()
+	if err != nil {
+		return err
+	}
+	return toolchains.Use(version)
+}
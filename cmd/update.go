@@ -1,63 +1,350 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/johanfylling/odm/errs"
 	"github.com/johanfylling/odm/printer"
 	"github.com/johanfylling/odm/proj"
 	"github.com/johanfylling/odm/utils"
 	"github.com/spf13/cobra"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 func init() {
+	var summaryOnly bool
+	var planFile string
+	var strict bool
+	var frozen bool
+	var check bool
+	var recordFile string
+	var replayFile string
+	var explainFormat string
+
 	var updateCommand = &cobra.Command{
-		Use:   "update",
+		Use:   "update [<dependency>] [flags]",
 		Short: "Update OPA project dependencies",
+		Long: `Update OPA project dependencies
+
+Fetches all dependencies of the project into the .opa/dependencies directory, and prints a summary of the
+dependencies added, removed and updated since the last update; with their old and new versions, the number of
+changed files, and namespace changes. Colors are disabled when output isn't a terminal, or NO_COLOR is set.
+
+If a dependency is named, only it, and its transitive dependencies, are updated; the fetched directories and lock
+entries of all other dependencies are left untouched.
+
+The commit each git tag points at is recorded in the lock file. If a tag has since been moved, the locked commit is
+fetched with a warning; or, with --strict, the update fails. Git dependencies tracking a branch, or HEAD, are
+updated to the head of the branch, and pinned to its commit in the lock file; other commands updating dependencies
+fetch the pinned commit.
+
+With --from-plan, dependencies are fetched exactly as planned by 'odm plan --format json'; the update fails if the
+project's dependencies no longer match the plan.
+
+With --check, nothing is fetched: dependencies are resolved by querying metadata only, and a JSON report lists those
+an update would resolve to newer revisions than locked; git dependencies tracking a branch that has moved on, and OCI
+dependencies whose version range or tag resolves to another manifest. If any are listed, the command exits with
+code 5 (ODM0050), e.g. for a scheduled CI job to open an update pull request.
+
+With --frozen, for CI, the update fails with ODM0034 if any dependency doesn't resolve exactly as locked, or the lock
+file holds dependencies no longer declared; nothing is fetched from locations not in the lock file, floating git
+dependencies aren't moved from their pinned commits, and the lock file is left untouched.
+
+With --record-resolution, the remote responses resolution depends on (git references, OCI tags, metadata and manifest
+digests), and the lock the update started from, are recorded to a JSON file; also if the update fails. With
+--replay-resolution, an update resolves exactly as recorded, without querying remotes for resolution; so that
+intermittent resolution bugs can be reported and debugged deterministically. Dependency content is still fetched, at
+the recorded revisions.
+
+With --explain, the resolution decision trail is reported on stderr once the update is done, or has failed: the
+versions listed for each dependency, the version or revision each dependency in the tree requires, the version
+selected for dependencies constrained in multiple places and why, conflicts, and overridden locations; as text, or,
+with --explain=json, as JSON.
+
+Example:
+'odm update'
+'odm update http'
+'odm update --summary-only'
+'odm update --from-plan plan.json'
+'odm update --check'
+'odm update --frozen'
+'odm update --record-resolution resolution.json'
+'odm update --explain=json'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("expected at most one dependency")
+			}
+			if len(args) > 0 && (check || planFile != "") {
+				return fmt.Errorf("a dependency can't be named with --check or --from-plan")
+			}
+			if check && (planFile != "" || summaryOnly || recordFile != "" || replayFile != "") {
+				return fmt.Errorf("--check can't be combined with --from-plan, --summary-only or resolution recordings")
+			}
+			if frozen && (check || planFile != "") {
+				return fmt.Errorf("--frozen can't be combined with --check or --from-plan")
+			}
+			if replayFile != "" && (recordFile != "" || planFile != "") {
+				return fmt.Errorf("--replay-resolution can't be combined with --record-resolution or --from-plan")
+			}
+			if explainFormat != "" {
+				if explainFormat != "text" && explainFormat != "json" {
+					return fmt.Errorf("unsupported explain format '%s'; expected text or json", explainFormat)
+				}
+				if check {
+					return fmt.Errorf("--explain can't be combined with --check")
+				}
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			projPath := "."
+			projPath := projectPath()
 
-			if err := doUpdate(projPath); err != nil {
-				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
-				os.Exit(1)
+			if check {
+				if err := doCheckUpdates(projPath); err != nil {
+					exit(err)
+				}
+				return
 			}
+
+			var plan *proj.Plan
+			if planFile != "" {
+				var err error
+				if plan, err = proj.ReadPlanFile(planFile); err != nil {
+					exit(err)
+				}
+			}
+
+			opts := proj.UpdateOptions{Plan: plan, Strict: strict, Refresh: true, Frozen: frozen, Dependencies: args}
+			if recordFile != "" {
+				opts.Recording = proj.NewResolutionRecording()
+			} else if replayFile != "" {
+				var err error
+				if opts.Recording, err = proj.ReadResolutionRecording(replayFile); err != nil {
+					exit(err)
+				}
+			}
+
+			if explainFormat != "" {
+				opts.Trace = proj.NewResolutionTrace()
+			}
+
+			changes, err := updateProject(projPath, opts, true)
+			if explainFormat != "" {
+				// Failed updates are explained too, as explaining conflicts is what the trail is mostly for
+				if explainErr := opts.Trace.Report(printer.LogWriter, explainFormat); explainErr != nil {
+					printer.Warn("failed to report resolution: %s", explainErr)
+				}
+			}
+			if recordFile != "" {
+				// Failed updates are recorded too, as their resolution is the one to debug
+				if recordErr := opts.Recording.WriteToFile(recordFile); recordErr != nil && err == nil {
+					err = recordErr
+				}
+			}
+			if err != nil {
+				exit(err)
+			}
+			printUpdateSummary(changes, summaryOnly)
 		},
 	}
 
+	updateCommand.Flags().BoolVar(&summaryOnly, "summary-only", false,
+		"only print the number of added, removed and updated dependencies")
+	updateCommand.Flags().StringVar(&planFile, "from-plan", "", "fetch dependencies as planned in the given plan file")
+	updateCommand.Flags().BoolVar(&check, "check", false,
+		"only report dependencies with newer revisions available, as JSON, exiting non-zero if any")
+	updateCommand.Flags().StringVar(&recordFile, "record-resolution", "",
+		"record the remote responses resolution depends on to the given JSON file")
+	updateCommand.Flags().StringVar(&replayFile, "replay-resolution", "",
+		"resolve dependencies as recorded in the given file, by --record-resolution")
+	updateCommand.Flags().StringVar(&explainFormat, "explain", "",
+		"report the resolution decision trail on stderr, as text or json")
+	updateCommand.Flags().Lookup("explain").NoOptDefVal = "text"
+	_ = updateCommand.RegisterFlagCompletionFunc("explain", completeValues("text", "json"))
+	addStrictFlag(updateCommand, &strict)
+	addFrozenFlag(updateCommand, &frozen)
+	updateCommand.ValidArgsFunction = completeDirectDependencies
 	RootCommand.AddCommand(updateCommand)
 }
 
+// doCheckUpdates prints a JSON report of the dependencies of the project with newer revisions available, failing with
+// UpdatesAvailable if there are any.
+func doCheckUpdates(projectPath string) error {
+	project, err := proj.ReadAndLoadProject(projectPath, false)
+	if err != nil {
+		return err
+	}
+	updates, err := project.CheckUpdates()
+	if err != nil {
+		return err
+	}
+
+	report := struct {
+		Updates []proj.AvailableUpdate `json:"updates"`
+	}{Updates: updates}
+	if report.Updates == nil {
+		report.Updates = []proj.AvailableUpdate{}
+	}
+	bs, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	printer.Output(string(bs))
+
+	if len(updates) > 0 {
+		paths := make([]string, 0, len(updates))
+		for _, update := range updates {
+			paths = append(paths, update.Path)
+		}
+		return errs.New(errs.UpdatesAvailable, "newer revisions available for %s", strings.Join(paths, ", "))
+	}
+	return nil
+}
+
 func doUpdate(projectPath string) error {
+	_, err := updateProject(projectPath, proj.UpdateOptions{}, false)
+	return err
+}
+
+// updateProject updates the dependencies of the project, as configured by opts; returning how they changed, if summarize
+// is true.
+func updateProject(projectPath string, opts proj.UpdateOptions, summarize bool) ([]proj.DependencyChange, error) {
 	printer.Trace("--- Project update start ---")
 	defer printer.Trace("--- Project update end ---")
 
 	project, err := proj.ReadProjectFromFile(projectPath, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	printer.Info("Updating project '%s'", project.Name)
 
+	var before proj.Snapshot
+	if summarize {
+		before = snapshotDependencies(projectPath)
+	}
+
 	dotOpaDir := filepath.Join(project.Dir(), ".opa")
 	depRootDir := fmt.Sprintf("%s/dependencies", dotOpaDir)
 
 	if !utils.FileExists(dotOpaDir) {
 		if err := os.Mkdir(dotOpaDir, 0755); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	if err := os.RemoveAll(depRootDir); err != nil {
-		return err
+	// Updates of named dependencies leave the directories of the others untouched
+	if len(opts.Dependencies) == 0 {
+		if err := os.RemoveAll(depRootDir); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := os.Mkdir(depRootDir, 0755); err != nil {
-		return err
+	if err := os.MkdirAll(depRootDir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := project.UpdateWithOptions(opts); err != nil {
+		return nil, err
+	}
+	if err := refreshEditorConfig(projectPath, project.Dir()); err != nil {
+		printer.Warn("failed to update editor configuration: %s", err)
+	}
+
+	if !summarize {
+		return nil, nil
+	}
+	after := snapshotDependencies(projectPath)
+	return proj.DiffSnapshots(before, after), nil
+}
+
+// snapshotDependencies returns a snapshot of the currently fetched dependencies of the project; empty if they can't be
+// loaded, e.g. before the first update.
+func snapshotDependencies(projectPath string) proj.Snapshot {
+	project, err := proj.ReadAndLoadProject(projectPath, false)
+	if err != nil {
+		printer.Debug("No snapshot of dependencies: %v", err)
+		return proj.Snapshot{}
+	}
+	snapshot, err := project.Snapshot()
+	if err != nil {
+		printer.Debug("No snapshot of dependencies: %v", err)
+		return proj.Snapshot{}
+	}
+	return snapshot
+}
+
+func printUpdateSummary(changes []proj.DependencyChange, summaryOnly bool) {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Kind]++
+		if summaryOnly {
+			continue
+		}
+
+		switch change.Kind {
+		case "added":
+			printer.Output("%s", printer.Colorize(printer.Green, strings.TrimSpace("+ "+change.Path+" "+change.NewVersion)))
+		case "removed":
+			printer.Output("%s", printer.Colorize(printer.Red, strings.TrimSpace("- "+change.Path+" "+change.OldVersion)))
+		case "updated":
+			line := "~ " + change.Path
+			if change.OldVersion != change.NewVersion {
+				line += fmt.Sprintf(" %s → %s", versionOrNone(change.OldVersion), versionOrNone(change.NewVersion))
+			}
+			var notes []string
+			if change.ChangedFiles > 0 {
+				notes = append(notes, fmt.Sprintf("%d files changed", change.ChangedFiles))
+			}
+			if change.OldNamespace != change.NewNamespace {
+				notes = append(notes, fmt.Sprintf("namespace %s → %s",
+					versionOrNone(change.OldNamespace), versionOrNone(change.NewNamespace)))
+			}
+			if len(notes) > 0 {
+				line += " (" + strings.Join(notes, ", ") + ")"
+			}
+			printer.Output("%s", printer.Colorize(printer.Yellow, line))
+		}
 	}
 
-	if err := project.Update(); err != nil {
+	if len(changes) == 0 {
+		printer.Output("Dependencies up to date")
+		return
+	}
+	printer.Output("%d added, %d removed, %d updated", counts["added"], counts["removed"], counts["updated"])
+}
+
+func versionOrNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// verifyDependencies warns if the resolved dependencies of the project were modified since the last update, e.g. by
+// hand-editing vendored files; or fails, if strict.
+func verifyDependencies(projectPath string, strict bool) error {
+	project, err := proj.ReadAndLoadProject(projectPath, true)
+	if err != nil {
 		return err
 	}
 
+	changes, err := project.ModifiedDependencyFiles()
+	if err != nil {
+		return fmt.Errorf("failed to verify dependencies: %w", err)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("dependencies modified since the last update; run 'odm update' to restore them:\n  %s",
+		strings.Join(changes, "\n  "))
+	if strict {
+		return errs.New(errs.ContentMismatch, "%s", msg)
+	}
+	printer.Warn("%s", msg)
 	return nil
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var major bool
+	var minor bool
+	var patch bool
+
+	var upgradeCommand = &cobra.Command{
+		Use:   "upgrade [<dependency>] [flags]",
+		Short: "Upgrade dependencies to their newest compatible versions",
+		Long: `Upgrade dependencies to their newest compatible versions
+
+Bumps git and OCI dependencies declared with an exact semver tag, e.g. '#v1.2.0' or ':1.2.0', to the newest tag of
+the same major version, rewriting their locations in opa.project; and updates the project, and its lock file. With
+--patch, dependencies are only upgraded to newer versions of the same major and minor version; with --major, to
+any newer version. Dependencies declared with a version range, tag pattern or 'latest' keep their locations, and are
+re-resolved to their newest matching versions. Only direct dependencies are upgraded; all of them, unless a
+dependency is named.
+
+Example:
+'odm upgrade'
+'odm upgrade --patch'
+'odm upgrade http --major'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("expected at most one dependency")
+			}
+			if (major && minor) || (major && patch) || (minor && patch) {
+				return fmt.Errorf("only one of --major, --minor and --patch can be set")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			scope := proj.UpgradeMinor
+			if major {
+				scope = proj.UpgradeMajor
+			} else if patch {
+				scope = proj.UpgradePatch
+			}
+			if err := doUpgrade(projPath, args, scope); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	upgradeCommand.Flags().BoolVar(&major, "major", false, "upgrade to any newer version")
+	upgradeCommand.Flags().BoolVar(&minor, "minor", false,
+		"upgrade to newer versions of the same major version (default)")
+	upgradeCommand.Flags().BoolVar(&patch, "patch", false,
+		"upgrade to newer versions of the same major and minor version")
+	upgradeCommand.ValidArgsFunction = completeDirectDependencies
+	RootCommand.AddCommand(upgradeCommand)
+}
+
+func doUpgrade(projPath string, names []string, scope string) error {
+	printer.Trace("--- Upgrade start ---")
+	defer printer.Trace("--- Upgrade end ---")
+
+	project, err := proj.ReadProjectFromFile(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	upgraded, err := project.Upgrade(names, scope)
+	if err != nil {
+		return err
+	}
+	if len(upgraded) > 0 {
+		if err := project.WriteToFile(projPath, true); err != nil {
+			return err
+		}
+	}
+	for _, dep := range upgraded {
+		printer.Output("%s: %s → %s", dep.Name, dep.Location, dep.Upgraded)
+	}
+
+	changes, err := updateProject(projPath, proj.UpdateOptions{}, true)
+	if err != nil {
+		return err
+	}
+	printUpdateSummary(changes, false)
+	return nil
+}
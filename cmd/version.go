@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/proj"
+	"github.com/johanfylling/odm/utils"
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	var tag bool
+
+	var versionCommand = &cobra.Command{
+		Use:   "version [major|minor|patch|<version>] [flags]",
+		Short: "Print or bump the version of the project",
+		Long: `Print or bump the version of the project
+
+Without arguments, prints the version of the project. Otherwise, updates the version in opa.project: to the next major,
+minor or patch version, or to the given semantic version. A project without a version is bumped from 0.0.0.
+
+With --tag, the updated project file is committed, and tagged with an annotated git tag of the version, prefixed with
+'v' (e.g. v1.2.0); so that versions are managed consistently across policy repositories. The tag must not exist.
+
+Example:
+'odm version'
+'odm version minor --tag'
+'odm version 2.0.0-rc.1'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("expected at most one version")
+			}
+			if tag && len(args) == 0 {
+				return fmt.Errorf("--tag requires a version to bump to")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projPath := projectPath()
+
+			if err := doVersion(projPath, args, tag); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	versionCommand.Flags().BoolVar(&tag, "tag", false, "commit the project file and create a git tag of the new version")
+	versionCommand.ValidArgsFunction = completeValues("major", "minor", "patch")
+	RootCommand.AddCommand(versionCommand)
+}
+
+func doVersion(projPath string, args []string, tag bool) error {
+	printer.Trace("--- Version start ---")
+	defer printer.Trace("--- Version end ---")
+
+	project, err := proj.ReadProjectFromFile(projPath, false)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		if project.Version == "" {
+			return fmt.Errorf("project %s has no version", project.FilePath())
+		}
+		printer.Output("%s", project.Version)
+		return nil
+	}
+
+	previous := project.Version
+	version, err := project.BumpVersion(args[0])
+	if err != nil {
+		return err
+	}
+
+	tagName := "v" + strings.TrimPrefix(version, "v")
+	if tag {
+		if !utils.GitAvailable() {
+			return fmt.Errorf("git not found; required to tag version %s", version)
+		}
+		if _, err := utils.RunGit(project.Dir(), "rev-parse", "--quiet", "--verify", "refs/tags/"+tagName); err == nil {
+			return fmt.Errorf("git tag %s already exists", tagName)
+		}
+	}
+
+	if err := project.WriteToFile(project.FilePath(), true); err != nil {
+		return err
+	}
+	if previous == "" {
+		printer.Output("%s", version)
+	} else {
+		printer.Output("%s → %s", previous, version)
+	}
+
+	if tag {
+		if err := tagVersion(project, version, tagName); err != nil {
+			return err
+		}
+		printer.Output("Tagged %s", tagName)
+	}
+	return nil
+}
+
+// tagVersion commits the project file, and tags the commit with an annotated tag of the version.
+func tagVersion(project *proj.Project, version string, tagName string) error {
+	dir := project.Dir()
+	file := filepath.Base(project.FilePath())
+	message := "Release " + version
+	if _, err := utils.RunGit(dir, "add", "--", file); err != nil {
+		return fmt.Errorf("failed to commit version %s: %w", version, err)
+	}
+	if _, err := utils.RunGit(dir, "commit", "--quiet", "--message", message, "--", file); err != nil {
+		return fmt.Errorf("failed to commit version %s: %w", version, err)
+	}
+	if _, err := utils.RunGit(dir, "tag", "--annotate", "--message", message, tagName); err != nil {
+		return fmt.Errorf("failed to tag version %s: %w", version, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+func init() {
+	var reason string
+	var undo bool
+
+	var yankCommand = &cobra.Command{
+		Use:   "yank <location> [flags]",
+		Short: "Mark a published version of an OCI dependency as yanked",
+		Long: `Mark a published version of an OCI dependency as yanked
+
+Yanked versions are recorded in the repository's ODM metadata artifact, tagged '` + oci.MetadataTag + `'.
+They are skipped when resolving version ranges, and refused when depended on by tag, but projects that have already
+locked a yanked version keep pulling it, with a warning.
+The version is not deleted from the registry.
+
+Example:
+'odm yank oci://ghcr.io/my-org/policy-lib:1.2.0 --reason "broken authz rule"'
+'odm yank oci://ghcr.io/my-org/policy-lib:1.2.0 --undo'
+`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one location")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := doYank(args[0], reason, undo); err != nil {
+				exit(err)
+			}
+		},
+	}
+
+	yankCommand.Flags().StringVar(&reason, "reason", "", "reason the version is yanked, shown to its users")
+	yankCommand.Flags().BoolVar(&undo, "undo", false, "un-yank the version")
+	RootCommand.AddCommand(yankCommand)
+}
+
+func doYank(location string, reason string, undo bool) error {
+	printer.Trace("--- Yank start ---")
+	defer printer.Trace("--- Yank end ---")
+
+	ref, versioned, err := parseRepositoryLocation(location)
+	if err != nil {
+		return err
+	}
+	if !versioned {
+		return fmt.Errorf("cannot yank %s: expected location with version tag", location)
+	}
+
+	client := oci.NewClient()
+	metadata, err := client.Metadata(ref)
+	if err != nil {
+		return err
+	}
+
+	if undo {
+		if _, ok := metadata.YankReason(ref.Tag); !ok {
+			return fmt.Errorf("version %s of %s/%s is not yanked", ref.Tag, ref.Registry, ref.Repository)
+		}
+		delete(metadata.Yanked, ref.Tag)
+		printer.Info("Un-yanking version %s of %s/%s", ref.Tag, ref.Registry, ref.Repository)
+	} else {
+		if _, err := client.Resolve(ref); err != nil {
+			return fmt.Errorf("cannot yank %s: %w", location, err)
+		}
+		if metadata.Yanked == nil {
+			metadata.Yanked = make(map[string]string)
+		}
+		metadata.Yanked[ref.Tag] = reason
+		printer.Info("Yanking version %s of %s/%s", ref.Tag, ref.Registry, ref.Repository)
+	}
+
+	return client.PushMetadata(ref, metadata)
+}
+
+// parseRepositoryLocation parses an OCI location, possibly a short name, for publishing repository metadata.
+// Returns whether the location names a version tag, rather than the whole repository.
+func parseRepositoryLocation(location string) (oci.Reference, bool, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return oci.Reference{}, false, err
+	}
+	if location, err = cfg.ExpandShortName(location); err != nil {
+		return oci.Reference{}, false, err
+	}
+	if !strings.HasPrefix(location, "oci://") {
+		return oci.Reference{}, false, fmt.Errorf("unsupported location %s: expected OCI location", location)
+	}
+
+	ref, err := oci.ParseReference(strings.TrimPrefix(location, "oci://"))
+	if err != nil {
+		return oci.Reference{}, false, fmt.Errorf("invalid location %s: %w", location, err)
+	}
+	if ref.Digest != "" {
+		return oci.Reference{}, false, fmt.Errorf("unsupported location %s: expected tag, not digest", location)
+	}
+
+	// References without a tag default to 'latest'
+	versioned := strings.Contains(strings.TrimPrefix(location, "oci://"+ref.Registry), ":")
+	return ref, versioned, nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"testing"
+)
+
+func TestYank(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1"})
+	location := "oci://" + registry.Host() + "/org/policy:1.0.0"
+	ref, _ := oci.ParseReference(registry.Host() + "/org/policy:1.0.0")
+
+	if err := doYank(location, "broken", false); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := oci.NewClient().Metadata(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason, yanked := metadata.YankReason("1.0.0"); !yanked || reason != "broken" {
+		t.Fatalf("expected 1.0.0 to be yanked as broken, got %v", metadata.Yanked)
+	}
+
+	if err := doYank(location, "", true); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err = oci.NewClient().Metadata(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, yanked := metadata.YankReason("1.0.0"); yanked {
+		t.Fatalf("expected 1.0.0 to be un-yanked, got %v", metadata.Yanked)
+	}
+
+	if err := doYank("oci://"+registry.Host()+"/org/policy:2.0.0", "", false); err == nil {
+		t.Fatal("expected error yanking unknown version")
+	}
+}
@@ -0,0 +1,405 @@
+// Package config reads the user-level ODM configuration, shared by all projects on the machine.
+package config
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the user-level ODM configuration.
+type Config struct {
+	// Registries maps location short-name prefixes to the locations they expand to; e.g. 'lib/' to
+	// 'oci://ghcr.io/acme-policies/'.
+	Registries map[string]string `yaml:"registries,omitempty"`
+	// NamespacePrefix is prepended to the top-level namespace of every dependency; e.g. 'ext_' places a dependency
+	// namespaced 'http' under 'data.ext_http'.
+	NamespacePrefix string `yaml:"namespacePrefix,omitempty"`
+	// Policy lists Rego files or directories evaluated against the resolved dependency graph of every project, before
+	// accepting an update. Relative paths are relative to the config file.
+	Policy []string `yaml:"policy,omitempty"`
+	// Proxy is the URL of an 'odm proxy' server, through which OCI and git HTTP(S) dependencies are fetched; e.g.
+	// 'http://odm-proxy.internal:9000'.
+	Proxy string `yaml:"proxy,omitempty"`
+	// Cache is the location of a remote cache of fetched dependencies, shared by ephemeral CI runners; e.g.
+	// 's3://bucket/odm-cache/', 'gs://bucket/odm-cache/', or 'https://cache.internal/odm/'.
+	Cache string `yaml:"cache,omitempty"`
+	// Fetch limits the network usage of dependency updates.
+	Fetch FetchLimits `yaml:"fetch,omitempty"`
+	// GitKeyring is an armored PGP keyring; if set, the checked-out tag or commit of every git dependency must be signed
+	// by one of its keys. A relative path is relative to the config file.
+	GitKeyring string `yaml:"gitKeyring,omitempty"`
+	// ShallowClone clones git dependencies without history, unless a dependency opts out of it.
+	ShallowClone bool `yaml:"shallowClone,omitempty"`
+	// SSH configures the authentication of git dependencies fetched over SSH.
+	SSH SSH `yaml:"ssh,omitempty"`
+	// GitTokens maps git hosts to the token git dependencies fetched from them over HTTPS authenticate with; e.g.
+	// 'git.example.com: {env: GHE_TOKEN}'.
+	GitTokens map[string]GitToken `yaml:"gitTokens,omitempty"`
+	// CredentialHelpers maps hosts to the command of an external credential helper, speaking the git credential helper
+	// protocol, that provides the credential for the host at fetch time; e.g. 'git.example.com: vault-git-credential'.
+	// Hosts may be wildcards of subdomains, e.g. '*.example.com'.
+	CredentialHelpers map[string]string `yaml:"credentialHelpers,omitempty"`
+	// URL maps location prefixes to rewrite rules of the locations they replace, like the url.<base>.insteadOf settings
+	// of git; e.g. 'git+https://git.internal/mirrors/github/: {insteadOf: [git+https://github.com/]}'.
+	URL map[string]URLRewrite `yaml:"url,omitempty"`
+	// TLS configures the verification of the certificates of HTTPS hosts dependencies are fetched from.
+	TLS TLS `yaml:"tls,omitempty"`
+}
+
+// TLS configures the verification of the certificates of HTTPS hosts; e.g. of internal git servers with certificates
+// issued by a private certificate authority.
+type TLS struct {
+	// CAFile is a PEM file of certificate authorities trusted in addition to those of the system. A relative path is
+	// relative to the config file.
+	CAFile string `yaml:"caFile,omitempty"`
+	// Hosts configures the TLS of individual hosts, by host name
+	Hosts map[string]TLSHost `yaml:"hosts,omitempty"`
+}
+
+// TLSHost configures the TLS of a single host.
+type TLSHost struct {
+	// Insecure skips the verification of the host's certificate; an escape hatch for hosts whose certificate can't be
+	// verified otherwise, leaving their connections open to interception
+	Insecure bool `yaml:"insecure,omitempty"`
+}
+
+// InsecureHosts returns the hosts configured to skip the verification of their certificate.
+func (t TLS) InsecureHosts() []string {
+	var hosts []string
+	for host, settings := range t.Hosts {
+		if settings.Insecure {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// URLRewrite lists the location prefixes replaced by the prefix it's configured for.
+type URLRewrite struct {
+	// InsteadOf are the prefixes of locations to rewrite
+	InsteadOf []string `yaml:"insteadOf"`
+}
+
+// GitToken is the token a git host is authenticated with, as the password of basic authentication. The token itself is
+// read from the environment, and never stored in the config file.
+type GitToken struct {
+	// Env is the environment variable holding the token
+	Env string `yaml:"env"`
+	// Username is sent along with the token; defaults to one accepted by the host, e.g. 'oauth2' for GitLab
+	Username string `yaml:"username,omitempty"`
+}
+
+// SSH configures how git dependencies with ssh:// locations authenticate. Without an identity file, keys are taken from
+// the SSH agent, if running, and otherwise from the default identity files of ~/.ssh.
+type SSH struct {
+	// IdentityFile is the private key to authenticate with; e.g. '~/.ssh/deploy_ed25519'. A relative path is relative
+	// to the file declaring it. Encrypted keys are decrypted with the ODM_SSH_PASSPHRASE environment variable.
+	IdentityFile string `yaml:"identityFile,omitempty"`
+	// KnownHosts is the known_hosts file host keys are verified against; defaults to ~/.ssh/known_hosts, and the
+	// system-wide known_hosts file. A relative path is relative to the file declaring it.
+	KnownHosts string `yaml:"knownHosts,omitempty"`
+}
+
+// DefaultParallelFetches is the number of dependencies fetched concurrently, unless configured otherwise.
+const DefaultParallelFetches = 4
+
+// DefaultFetchRetries is the number of times a fetch failing with a transient error is retried, unless configured
+// otherwise.
+const DefaultFetchRetries = 2
+
+// DefaultFetchBackoff is the delay before the first retry of a failed fetch, unless configured otherwise.
+const DefaultFetchBackoff = time.Second
+
+// FetchLimits limits how many dependencies are fetched at once, and how fast; so that large dependency graphs don't
+// saturate shared network links, or trip registry rate limits.
+type FetchLimits struct {
+	// Parallel is the maximum number of dependencies fetched concurrently; defaults to DefaultParallelFetches
+	Parallel int `yaml:"parallel,omitempty"`
+	// Bandwidth is the maximum combined download rate of all HTTP(S) fetches, per second; e.g. '10MB' or '512KiB'.
+	// Unlimited if empty.
+	Bandwidth string `yaml:"bandwidth,omitempty"`
+	// Retries is the number of times a fetch failing with a transient network error is retried; defaults to
+	// DefaultFetchRetries
+	Retries *int `yaml:"retries,omitempty"`
+	// Backoff is the delay before the first retry of a failed fetch, doubling with every further retry; e.g. '2s'.
+	// Defaults to DefaultFetchBackoff.
+	Backoff string `yaml:"backoff,omitempty"`
+	// Timeout limits the duration of every network operation of a fetch, such as a download or a git clone; e.g. '5m'.
+	// Unlimited if empty.
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// Validate returns an error if any of the fetch limits is invalid.
+func (f FetchLimits) Validate() error {
+	if _, err := ParseBandwidth(f.Bandwidth); err != nil {
+		return err
+	}
+	if f.Parallel < 0 {
+		return fmt.Errorf("negative parallel fetches")
+	}
+	if f.Retries != nil && *f.Retries < 0 {
+		return fmt.Errorf("negative retries")
+	}
+	for name, duration := range map[string]string{"backoff": f.Backoff, "timeout": f.Timeout} {
+		if d, err := time.ParseDuration(duration); duration != "" && (err != nil || d <= 0) {
+			return fmt.Errorf("invalid %s '%s': expected a positive duration, e.g. '30s'", name, duration)
+		}
+	}
+	return nil
+}
+
+// Merge returns the fetch limits, overridden by the limits set in overrides.
+func (f FetchLimits) Merge(overrides FetchLimits) FetchLimits {
+	if overrides.Parallel > 0 {
+		f.Parallel = overrides.Parallel
+	}
+	if overrides.Bandwidth != "" {
+		f.Bandwidth = overrides.Bandwidth
+	}
+	if overrides.Retries != nil {
+		f.Retries = overrides.Retries
+	}
+	if overrides.Backoff != "" {
+		f.Backoff = overrides.Backoff
+	}
+	if overrides.Timeout != "" {
+		f.Timeout = overrides.Timeout
+	}
+	return f
+}
+
+// FetchOverrides overrides the fetch limits of the config file, where set; e.g. by command line flags.
+var FetchOverrides FetchLimits
+
+// ParallelFetches returns the configured maximum number of concurrent fetches.
+func (c *Config) ParallelFetches() int {
+	if c.Fetch.Parallel > 0 {
+		return c.Fetch.Parallel
+	}
+	return DefaultParallelFetches
+}
+
+// FetchRetries returns the configured number of retries of failed fetches.
+func (c *Config) FetchRetries() int {
+	if c.Fetch.Retries != nil {
+		return *c.Fetch.Retries
+	}
+	return DefaultFetchRetries
+}
+
+// FetchBackoff returns the configured delay before the first retry of a failed fetch.
+func (c *Config) FetchBackoff() time.Duration {
+	if d, err := time.ParseDuration(c.Fetch.Backoff); err == nil && d > 0 {
+		return d
+	}
+	return DefaultFetchBackoff
+}
+
+// FetchTimeout returns the configured time limit of network operations; 0 if unlimited.
+func (c *Config) FetchTimeout() time.Duration {
+	d, _ := time.ParseDuration(c.Fetch.Timeout)
+	return d
+}
+
+// BandwidthLimit returns the configured maximum download rate, in bytes per second; 0 if unlimited.
+func (c *Config) BandwidthLimit() int64 {
+	limit, _ := ParseBandwidth(c.Fetch.Bandwidth)
+	return limit
+}
+
+var bandwidthUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1000,
+	"KB":  1000,
+	"KIB": 1 << 10,
+	"M":   1000 * 1000,
+	"MB":  1000 * 1000,
+	"MIB": 1 << 20,
+	"G":   1000 * 1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"GIB": 1 << 30,
+}
+
+// ParseBandwidth parses a download rate in bytes per second, with an optional decimal (KB, MB, GB) or binary (KiB, MiB,
+// GiB) unit; e.g. '10MB'. An empty rate is unlimited, and yields 0.
+func ParseBandwidth(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := bandwidthUnits[strings.ToUpper(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid bandwidth '%s': unknown unit '%s'", s, s[i:])
+	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth '%s': expected a positive number, with an optional unit", s)
+	}
+	return int64(value * float64(unit)), nil
+}
+
+// FilePath returns the location of the config file.
+// Defaults to 'odm/config.yaml' in the user config directory, and can be overridden through the ODM_CONFIG environment
+// variable.
+func FilePath() (string, error) {
+	if path, ok := os.LookupEnv("ODM_CONFIG"); ok {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine config directory: %w", err)
+	}
+	return filepath.Join(dir, "odm", "config.yaml"), nil
+}
+
+// Load reads the config file. A missing config file yields an empty config.
+func Load() (*Config, error) {
+	path, err := FilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return applyFetchOverrides(&Config{})
+	} else if err != nil {
+		return nil, errs.New(errs.InvalidConfig, "failed to read config file %s: %w", path, err)
+	}
+
+	printer.Debug("Loading config from %s", path)
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errs.New(errs.InvalidConfig, "failed to unmarshal config file %s: %w", path, err)
+	}
+	if err := config.Fetch.Validate(); err != nil {
+		return nil, errs.New(errs.InvalidConfig, "invalid fetch limits in config file %s: %w", path, err)
+	}
+	if config.Proxy != "" {
+		if u, err := url.Parse(config.Proxy); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errs.New(errs.InvalidConfig, "invalid proxy '%s' in config file %s: expected http(s)://host[:port]",
+				config.Proxy, path)
+		}
+	}
+	return applyFetchOverrides(&config)
+}
+
+func applyFetchOverrides(config *Config) (*Config, error) {
+	if FetchOverrides.Parallel < 0 {
+		return nil, errs.New(errs.InvalidUsage, "invalid number of parallel fetches %d", FetchOverrides.Parallel)
+	}
+	if _, err := ParseBandwidth(FetchOverrides.Bandwidth); err != nil {
+		return nil, errs.New(errs.InvalidUsage, "%w", err)
+	}
+	config.Fetch = config.Fetch.Merge(FetchOverrides)
+	return config, nil
+}
+
+// IsShortName reports whether a dependency location lacks a scheme, and so must be expanded through the registry
+// mapping.
+func IsShortName(location string) bool {
+	return !strings.Contains(location, "://") && !strings.HasPrefix(location, "file:")
+}
+
+// ExpandShortName expands a short-name location, such as 'lib/http@1.2.0', through the longest matching registry
+// prefix. The version following '@' is appended using the version separator of the expanded location's type:
+// ':' for OCI tags, '@' for OCI digests, and '#' for git refs. Locations that aren't short names are returned as-is.
+func (c *Config) ExpandShortName(location string) (string, error) {
+	if !IsShortName(location) {
+		return location, nil
+	}
+
+	var prefix string
+	for p := range c.Registries {
+		if strings.HasPrefix(location, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	if prefix == "" {
+		return "", errs.New(errs.InvalidLocation, "no registry configured for location '%s'", location)
+	}
+
+	name, version, hasVersion := strings.Cut(strings.TrimPrefix(location, prefix), "@")
+	expanded := c.Registries[prefix] + name
+	if !hasVersion {
+		return expanded, nil
+	}
+
+	switch {
+	case strings.HasPrefix(expanded, "oci://") && strings.HasPrefix(version, "sha256:"):
+		return expanded + "@" + version, nil
+	case strings.HasPrefix(expanded, "oci://"):
+		return expanded + ":" + version, nil
+	case strings.HasPrefix(expanded, "git+"):
+		return expanded + "#" + version, nil
+	default:
+		return expanded + "@" + version, nil
+	}
+}
+
+// RewriteLocation rewrites an expanded location starting with any insteadOf prefix of the configured URL rewrites to
+// start with the prefix of the rewrite instead; e.g. 'git+https://github.com/org/policy.git#v1' to
+// 'git+https://git.internal/mirrors/github/org/policy.git#v1'. As with git, the longest matching prefix wins. Locations
+// not matching any prefix are returned as-is.
+func (c *Config) RewriteLocation(location string) string {
+	var base, prefix string
+	for b, rewrite := range c.URL {
+		for _, p := range rewrite.InsteadOf {
+			if strings.HasPrefix(location, p) && len(p) > len(prefix) {
+				base, prefix = b, p
+			}
+		}
+	}
+	if prefix == "" {
+		return location
+	}
+	return base + strings.TrimPrefix(location, prefix)
+}
+
+// ProxyLocation rewrites an expanded OCI or git HTTP(S) location to be fetched through the configured proxy; e.g.
+// 'oci://ghcr.io/org/policy:1.0.0' to 'oci://proxy:9000/ghcr.io/org/policy:1.0.0', and
+// 'git+https://github.com/org/policy.git#v1' to 'git+http://proxy:9000/git/github.com/org/policy.git#v1'.
+// Other locations, and all locations when no proxy is configured, are returned as-is.
+func (c *Config) ProxyLocation(location string) string {
+	if c.Proxy == "" {
+		return location
+	}
+	u, err := url.Parse(c.Proxy)
+	if err != nil {
+		return location
+	}
+
+	switch {
+	case strings.HasPrefix(location, "oci://"):
+		return "oci://" + u.Host + "/" + strings.TrimPrefix(location, "oci://")
+	case strings.HasPrefix(location, "git+https://"):
+		return "git+" + u.Scheme + "://" + u.Host + "/git/" + strings.TrimPrefix(location, "git+https://")
+	case strings.HasPrefix(location, "git+http://"):
+		return "git+" + u.Scheme + "://" + u.Host + "/git/" + strings.TrimPrefix(location, "git+http://")
+	}
+	return location
+}
+
+// ProxyHost returns the host[:port] of the configured proxy, and whether it's served over plain HTTP.
+func (c *Config) ProxyHost() (string, bool) {
+	u, err := url.Parse(c.Proxy)
+	if c.Proxy == "" || err != nil {
+		return "", false
+	}
+	return u.Host, u.Scheme == "http"
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandShortName(t *testing.T) {
+	config := &Config{
+		Registries: map[string]string{
+			"lib/":      "oci://ghcr.io/acme-policies/",
+			"lib/core/": "oci://registry.acme.com/core/",
+			"gh/":       "git+https://github.com/acme/",
+		},
+	}
+
+	tests := []struct {
+		location    string
+		expected    string
+		expectedErr bool
+	}{
+		{
+			location: "lib/http@1.2.0",
+			expected: "oci://ghcr.io/acme-policies/http:1.2.0",
+		},
+		{
+			location: "lib/http",
+			expected: "oci://ghcr.io/acme-policies/http",
+		},
+		{
+			location: "lib/http@^1.2",
+			expected: "oci://ghcr.io/acme-policies/http:^1.2",
+		},
+		{
+			location: "lib/http@sha256:abc",
+			expected: "oci://ghcr.io/acme-policies/http@sha256:abc",
+		},
+		{
+			location: "lib/core/jwt@2.0.0",
+			expected: "oci://registry.acme.com/core/jwt:2.0.0",
+		},
+		{
+			location: "gh/policy-lib.git@v1.0",
+			expected: "git+https://github.com/acme/policy-lib.git#v1.0",
+		},
+		{
+			location: "git+https://github.com/acme/policy-lib.git#v1.0",
+			expected: "git+https://github.com/acme/policy-lib.git#v1.0",
+		},
+		{
+			location: "file:/../policy-lib",
+			expected: "file:/../policy-lib",
+		},
+		{
+			location:    "unknown/http@1.2.0",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.location, func(t *testing.T) {
+			actual, err := config.ExpandShortName(tc.location)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("ODM_CONFIG", path)
+
+	config, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Registries) != 0 {
+		t.Fatalf("expected empty config for missing file, got %v", config)
+	}
+
+	if err := os.WriteFile(path, []byte(`registries:
+  lib/: oci://ghcr.io/acme-policies/
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "oci://ghcr.io/acme-policies/"; config.Registries["lib/"] != expected {
+		t.Fatalf("expected registry %s, got %v", expected, config.Registries)
+	}
+}
+
+func TestProxyLocation(t *testing.T) {
+	config := &Config{Proxy: "http://odm-proxy:9000"}
+
+	tests := map[string]string{
+		"oci://ghcr.io/org/policy:1.0.0":               "oci://odm-proxy:9000/ghcr.io/org/policy:1.0.0",
+		"git+https://github.com/org/policy.git#v1.0":   "git+http://odm-proxy:9000/git/github.com/org/policy.git#v1.0",
+		"git+http://git.internal/org/policy.git":       "git+http://odm-proxy:9000/git/git.internal/org/policy.git",
+		"git+ssh://git@github.com/org/policy.git#v1.0": "git+ssh://git@github.com/org/policy.git#v1.0",
+		"file:/../policy-lib":                          "file:/../policy-lib",
+	}
+
+	for location, expected := range tests {
+		if actual := config.ProxyLocation(location); actual != expected {
+			t.Fatalf("expected %s, got %s", expected, actual)
+		}
+	}
+
+	if actual := (&Config{}).ProxyLocation("oci://ghcr.io/org/policy:1.0.0"); actual != "oci://ghcr.io/org/policy:1.0.0" {
+		t.Fatalf("expected location unchanged without proxy, got %s", actual)
+	}
+}
+
+func TestRewriteLocation(t *testing.T) {
+	config := &Config{
+		URL: map[string]URLRewrite{
+			"git+https://git.internal/mirrors/github/": {InsteadOf: []string{"git+https://github.com/", "git+ssh://git@github.com/"}},
+			"git+https://git.internal/acme/":           {InsteadOf: []string{"git+https://github.com/acme/"}},
+			"oci://registry.internal/":                 {InsteadOf: []string{"oci://ghcr.io/"}},
+		},
+	}
+
+	tests := map[string]string{
+		"git+https://github.com/org/policy.git#v1.0":   "git+https://git.internal/mirrors/github/org/policy.git#v1.0",
+		"git+ssh://git@github.com/org/policy.git#v1.0": "git+https://git.internal/mirrors/github/org/policy.git#v1.0",
+		"git+https://github.com/acme/policy.git#v1.0":  "git+https://git.internal/acme/policy.git#v1.0",
+		"oci://ghcr.io/org/policy:1.0.0":               "oci://registry.internal/org/policy:1.0.0",
+		"git+https://gitlab.com/org/policy.git":        "git+https://gitlab.com/org/policy.git",
+		"file:/../policy-lib":                          "file:/../policy-lib",
+	}
+
+	for location, expected := range tests {
+		if actual := config.RewriteLocation(location); actual != expected {
+			t.Fatalf("expected %s, got %s", expected, actual)
+		}
+	}
+}
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		bandwidth   string
+		expected    int64
+		expectedErr bool
+	}{
+		{bandwidth: "", expected: 0},
+		{bandwidth: "1024", expected: 1024},
+		{bandwidth: "10MB", expected: 10 * 1000 * 1000},
+		{bandwidth: "512KiB", expected: 512 * 1024},
+		{bandwidth: "1.5 mib", expected: 3 << 19},
+		{bandwidth: "10mbit", expectedErr: true},
+		{bandwidth: "0", expectedErr: true},
+		{bandwidth: "MB", expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.bandwidth, func(t *testing.T) {
+			actual, err := ParseBandwidth(tc.bandwidth)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Fatalf("expected %d, got %d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadFetchOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("ODM_CONFIG", path)
+	if err := os.WriteFile(path, []byte(`fetch:
+  parallel: 8
+  bandwidth: 10MB
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ParallelFetches() != 8 || config.BandwidthLimit() != 10*1000*1000 {
+		t.Fatalf("expected configured fetch limits, got %v", config.Fetch)
+	}
+
+	FetchOverrides = FetchLimits{Parallel: 2}
+	defer func() { FetchOverrides = FetchLimits{} }()
+	config, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ParallelFetches() != 2 || config.BandwidthLimit() != 10*1000*1000 {
+		t.Fatalf("expected overridden parallel fetches, got %v", config.Fetch)
+	}
+}
+
+func TestFetchLimitsValidate(t *testing.T) {
+	negative := -1
+	tests := []struct {
+		note   string
+		limits FetchLimits
+		valid  bool
+	}{
+		{
+			note:  "empty",
+			valid: true,
+		},
+		{
+			note:   "retries, backoff and timeout",
+			limits: FetchLimits{Retries: new(int), Backoff: "500ms", Timeout: "5m"},
+			valid:  true,
+		},
+		{
+			note:   "negative retries",
+			limits: FetchLimits{Retries: &negative},
+		},
+		{
+			note:   "invalid backoff",
+			limits: FetchLimits{Backoff: "soon"},
+		},
+		{
+			note:   "negative timeout",
+			limits: FetchLimits{Timeout: "-1s"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			err := tc.limits.Validate()
+			if tc.valid && err != nil {
+				t.Fatal(err)
+			} else if !tc.valid && err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestFetchRetriesDefaults(t *testing.T) {
+	config := &Config{}
+	if config.FetchRetries() != DefaultFetchRetries || config.FetchBackoff() != DefaultFetchBackoff ||
+		config.FetchTimeout() != 0 {
+		t.Fatalf("expected default retries, backoff and no timeout, got %v", config.Fetch)
+	}
+
+	config.Fetch = config.Fetch.Merge(FetchLimits{Retries: new(int), Backoff: "2s", Timeout: "1m"})
+	if config.FetchRetries() != 0 || config.FetchBackoff() != 2*time.Second || config.FetchTimeout() != time.Minute {
+		t.Fatalf("expected merged retries, backoff and timeout, got %v", config.Fetch)
+	}
+}
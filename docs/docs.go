@@ -0,0 +1,200 @@
+// Package docs extracts documentation from Rego source trees, from package and rule METADATA annotations.
+package docs
+
+import (
+	"bufio"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Docs is the documentation of a Rego source tree.
+type Docs struct {
+	Title string
+	// Readme is the content of the tree's README file, if any
+	Readme   string
+	Packages []*Package
+	// Dependencies link to the documentation of dependencies, as rendered in HTML and Markdown
+	Dependencies []Reference
+}
+
+// Reference is a link to other documentation.
+type Reference struct {
+	Title string
+	// Href is the URL of the documentation, relative to the page linking to it
+	Href string
+}
+
+type Package struct {
+	// Path is the package path, e.g. 'data.http.headers'
+	Path        string
+	Title       string
+	Description string
+	Rules       []*Rule
+}
+
+type Rule struct {
+	Name string
+	// Signature is the rule's name, followed by the arguments of a function, or the key of a partial rule, as first
+	// declared; e.g. 'has_header(name)' or 'deny[msg]'
+	Signature   string
+	Title       string
+	Description string
+	Entrypoint  bool
+}
+
+// annotations holds the subset of METADATA annotation attributes rendered as documentation.
+type annotations struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Scope       string `yaml:"scope"`
+	Entrypoint  bool   `yaml:"entrypoint"`
+}
+
+var readmeNames = []string{"README.md", "README", "readme.md", "README.txt"}
+
+var (
+	packagePattern = regexp.MustCompile(`^package\s+([A-Za-z_][\w.\[\]"]*)`)
+	rulePattern    = regexp.MustCompile(`^(?:default\s+)?([A-Za-z_]\w*)(\([^)]*\)|\[[^\]]*\])?`)
+	keywords       = map[string]bool{"package": true, "import": true, "default": true, "else": true}
+)
+
+// Extract reads the documentation of all Rego modules, except tests, in the given source directories.
+// The README is read from rootDir.
+func Extract(title string, rootDir string, sourceDirs []string) (*Docs, error) {
+	docs := &Docs{Title: title}
+
+	for _, name := range readmeNames {
+		if bs, err := os.ReadFile(filepath.Join(rootDir, name)); err == nil {
+			docs.Readme = strings.TrimSpace(string(bs))
+			break
+		}
+	}
+
+	packages := make(map[string]*Package)
+	for _, dir := range sourceDirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if info.Name() == ".git" || info.Name() == ".opa" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".rego" || strings.HasSuffix(path, "_test.rego") {
+				return nil
+			}
+			return parseModule(path, packages)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pkg := range packages {
+		docs.Packages = append(docs.Packages, pkg)
+	}
+	sort.Slice(docs.Packages, func(i, j int) bool {
+		return docs.Packages[i].Path < docs.Packages[j].Path
+	})
+
+	return docs, nil
+}
+
+// parseModule reads the package, rules and METADATA annotations of a Rego module.
+func parseModule(path string, packages map[string]*Package) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var pkg *Package
+	var pending *annotations
+	var metadata []string
+	inMetadata := false
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+
+		if inMetadata {
+			if strings.HasPrefix(line, "#") {
+				metadata = append(metadata, strings.TrimPrefix(strings.TrimPrefix(line, "#"), " "))
+				continue
+			}
+			inMetadata = false
+			pending = &annotations{}
+			if err := yaml.Unmarshal([]byte(strings.Join(metadata, "\n")), pending); err != nil {
+				return fmt.Errorf("invalid METADATA annotation in %s:%d: %w", path, lineNo, err)
+			}
+			pending.Title = strings.TrimSpace(pending.Title)
+			pending.Description = strings.TrimSpace(pending.Description)
+		}
+
+		if strings.TrimSpace(line) == "# METADATA" {
+			inMetadata = true
+			metadata = nil
+			continue
+		}
+
+		if m := packagePattern.FindStringSubmatch(line); m != nil {
+			pkgPath := "data." + m[1]
+			if pkg = packages[pkgPath]; pkg == nil {
+				pkg = &Package{Path: pkgPath}
+				packages[pkgPath] = pkg
+			}
+			if pending != nil && (pending.Scope == "" || pending.Scope == "package" || pending.Scope == "subpackages") {
+				pkg.Title = firstNonEmpty(pkg.Title, pending.Title)
+				pkg.Description = firstNonEmpty(pkg.Description, pending.Description)
+			}
+			pending = nil
+			continue
+		}
+
+		if pkg == nil {
+			continue
+		}
+
+		if m := rulePattern.FindStringSubmatch(line); m != nil && !keywords[m[1]] {
+			rule := pkg.rule(m[1])
+			if rule.Signature == rule.Name {
+				rule.Signature += m[2]
+			}
+			if pending != nil {
+				rule.Title = firstNonEmpty(rule.Title, pending.Title)
+				rule.Description = firstNonEmpty(rule.Description, pending.Description)
+				rule.Entrypoint = rule.Entrypoint || pending.Entrypoint
+			}
+			pending = nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (p *Package) rule(name string) *Rule {
+	for _, rule := range p.Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	rule := &Rule{Name: name, Signature: name}
+	p.Rules = append(p.Rules, rule)
+	return rule
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
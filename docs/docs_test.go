@@ -0,0 +1,166 @@
+package docs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"README.md": "# HTTP helpers\n",
+		"src/headers.rego": `# METADATA
+# title: Header helpers
+# description: |
+#   Functions for inspecting
+#   HTTP headers.
+package http.headers
+
+import future.keywords.if
+
+# METADATA
+# title: Content type
+# description: The request's content type.
+# entrypoint: true
+content_type := input.headers["Content-Type"]
+
+default allow := false
+
+allow if {
+	content_type == "application/json"
+}
+
+allow if input.internal
+
+# METADATA
+# title: Has header
+has_header(name) if input.headers[name]
+`,
+		"src/headers_test.rego": `package http.headers_test
+
+test_allow if {
+	true
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs, err := Extract("http", root, []string{filepath.Join(root, "src")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Docs{
+		Title:  "http",
+		Readme: "# HTTP helpers",
+		Packages: []*Package{
+			{
+				Path:        "data.http.headers",
+				Title:       "Header helpers",
+				Description: "Functions for inspecting\nHTTP headers.",
+				Rules: []*Rule{
+					{Name: "content_type", Signature: "content_type", Title: "Content type", Description: "The request's content type.", Entrypoint: true},
+					{Name: "allow", Signature: "allow"},
+					{Name: "has_header", Signature: "has_header(name)", Title: "Has header"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, docs)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderText(&buf, docs); err != nil {
+		t.Fatal(err)
+	}
+	expectedText := `http
+====
+
+# HTTP helpers
+
+data.http.headers
+-----------------
+Header helpers
+Functions for inspecting
+HTTP headers.
+  content_type (entrypoint) - Content type
+      The request's content type.
+  allow
+  has_header(name) - Has header
+`
+	if buf.String() != expectedText {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expectedText, buf.String())
+	}
+
+	buf.Reset()
+	if err := RenderHTML(&buf, docs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<code>content_type</code> <span class=\"entrypoint\">entrypoint</span>") {
+		t.Fatalf("expected rendered rule in HTML, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := RenderMarkdown(&buf, docs); err != nil {
+		t.Fatal(err)
+	}
+	expectedMarkdown := "# http\n\n# HTTP helpers\n\n## `data.http.headers`\n\n**Header helpers**\n\nFunctions for inspecting\nHTTP headers.\n\n" +
+		"* `content_type` _(entrypoint)_ &mdash; Content type\n\n  The request's content type.\n\n" +
+		"* `allow`\n" +
+		"* `has_header(name)` &mdash; Has header\n"
+	if buf.String() != expectedMarkdown {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expectedMarkdown, buf.String())
+	}
+}
+
+func TestWriteSite(t *testing.T) {
+	project := &Docs{Title: "proj", Packages: []*Package{{Path: "data.main"}}}
+	deps := map[string]*Docs{
+		"http":     {Title: "http (http-lib 1.0.0)"},
+		"http.jwt": {Title: "http.jwt"},
+	}
+
+	for _, format := range []string{"html", "markdown"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := WriteSite(dir, format, project, deps); err != nil {
+				t.Fatal(err)
+			}
+
+			ext := ".html"
+			if format == "markdown" {
+				ext = ".md"
+			}
+			for _, page := range []string{"deps/http" + ext, "deps/http.jwt" + ext} {
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(page))); err != nil {
+					t.Fatalf("expected page %s: %v", page, err)
+				}
+			}
+			bs, err := os.ReadFile(filepath.Join(dir, "index"+ext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			index := string(bs)
+			if !strings.Contains(index, "data.main") || !strings.Contains(index, "deps/http.jwt"+ext) ||
+				strings.Index(index, "deps/http"+ext) > strings.Index(index, "deps/http.jwt"+ext) {
+				t.Fatalf("expected index linking to dependencies in order, got:\n%s", index)
+			}
+			if project.Dependencies != nil {
+				t.Fatal("expected project documentation to be left unchanged")
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package docs
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// RenderText writes the documentation as plain text, for reading in the terminal.
+func RenderText(w io.Writer, docs *Docs) error {
+	var sb strings.Builder
+
+	sb.WriteString(docs.Title + "\n")
+	sb.WriteString(strings.Repeat("=", len(docs.Title)) + "\n")
+
+	if docs.Readme != "" {
+		sb.WriteString("\n" + docs.Readme + "\n")
+	}
+
+	for _, pkg := range docs.Packages {
+		sb.WriteString("\n" + pkg.Path + "\n")
+		sb.WriteString(strings.Repeat("-", len(pkg.Path)) + "\n")
+		if pkg.Title != "" {
+			sb.WriteString(pkg.Title + "\n")
+		}
+		if pkg.Description != "" {
+			sb.WriteString(indent(pkg.Description, "") + "\n")
+		}
+		for _, rule := range pkg.Rules {
+			heading := "  " + rule.Signature
+			if rule.Entrypoint {
+				heading += " (entrypoint)"
+			}
+			if rule.Title != "" {
+				heading += " - " + rule.Title
+			}
+			sb.WriteString(heading + "\n")
+			if rule.Description != "" {
+				sb.WriteString(indent(rule.Description, "      ") + "\n")
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func indent(s string, prefix string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+var htmlTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; }
+code, pre { background: #f4f4f4; }
+pre { padding: 1em; white-space: pre-wrap; }
+.entrypoint { font-size: small; color: #666; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{- if .Readme }}
+<pre>{{ .Readme }}</pre>
+{{- end }}
+{{- if .Dependencies }}
+<h2>Dependencies</h2>
+<ul>
+{{- range .Dependencies }}
+<li><a href="{{ .Href }}">{{ .Title }}</a></li>
+{{- end }}
+</ul>
+{{- end }}
+{{- range .Packages }}
+<h2 id="{{ .Path }}"><code>{{ .Path }}</code></h2>
+{{- if .Title }}
+<p><strong>{{ .Title }}</strong></p>
+{{- end }}
+{{- if .Description }}
+<p>{{ .Description }}</p>
+{{- end }}
+{{- if .Rules }}
+<dl>
+{{- range .Rules }}
+<dt><code>{{ .Signature }}</code>{{ if .Entrypoint }} <span class="entrypoint">entrypoint</span>{{ end }}{{ if .Title }} &mdash; {{ .Title }}{{ end }}</dt>
+<dd>{{ .Description }}</dd>
+{{- end }}
+</dl>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
+
+// RenderHTML writes the documentation as a standalone HTML page.
+func RenderHTML(w io.Writer, docs *Docs) error {
+	if err := htmlTemplate.Execute(w, docs); err != nil {
+		return fmt.Errorf("failed to render HTML: %w", err)
+	}
+	return nil
+}
+
+// RenderMarkdown writes the documentation as a Markdown document.
+func RenderMarkdown(w io.Writer, docs *Docs) error {
+	var sb strings.Builder
+
+	sb.WriteString("# " + docs.Title + "\n")
+
+	if docs.Readme != "" {
+		sb.WriteString("\n" + docs.Readme + "\n")
+	}
+
+	if len(docs.Dependencies) > 0 {
+		sb.WriteString("\n## Dependencies\n\n")
+		for _, dep := range docs.Dependencies {
+			sb.WriteString(fmt.Sprintf("* [%s](%s)\n", dep.Title, dep.Href))
+		}
+	}
+
+	for _, pkg := range docs.Packages {
+		sb.WriteString("\n## `" + pkg.Path + "`\n")
+		if pkg.Title != "" {
+			sb.WriteString("\n**" + pkg.Title + "**\n")
+		}
+		if pkg.Description != "" {
+			sb.WriteString("\n" + strings.TrimSpace(pkg.Description) + "\n")
+		}
+		if len(pkg.Rules) > 0 {
+			sb.WriteString("\n")
+		}
+		for _, rule := range pkg.Rules {
+			item := "* `" + rule.Signature + "`"
+			if rule.Entrypoint {
+				item += " _(entrypoint)_"
+			}
+			if rule.Title != "" {
+				item += " &mdash; " + rule.Title
+			}
+			sb.WriteString(item + "\n")
+			if rule.Description != "" {
+				sb.WriteString("\n" + indent(rule.Description, "  ") + "\n\n")
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,57 @@
+package docs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// WriteSite writes the documentation of a project, and of its dependencies by their dot-separated path in the
+// dependency tree, to dir as a static site: of HTML pages, or Markdown documents for the format 'markdown'. The
+// project's documentation is written to the index page, linking to the page of every dependency, in deps/.
+func WriteSite(dir string, format string, project *Docs, deps map[string]*Docs) error {
+	ext := ".html"
+	render := RenderHTML
+	if format == "markdown" {
+		ext = ".md"
+		render = RenderMarkdown
+	}
+
+	paths := make([]string, 0, len(deps))
+	for p := range deps {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	index := *project
+	index.Dependencies = nil
+	for _, p := range paths {
+		href := path.Join("deps", p+ext)
+		if err := writePage(filepath.Join(dir, filepath.FromSlash(href)), render, deps[p]); err != nil {
+			return err
+		}
+		index.Dependencies = append(index.Dependencies, Reference{Title: deps[p].Title, Href: href})
+	}
+	return writePage(filepath.Join(dir, "index"+ext), render, &index)
+}
+
+func writePage(file string, render func(io.Writer, *Docs) error, docs *Docs) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := render(f, docs); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
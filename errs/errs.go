@@ -0,0 +1,101 @@
+// Package errs defines coded ODM errors. Codes and their exit codes are stable across releases, so that CI systems and
+// wrappers can react to failures without matching error messages.
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code identifies a class of errors.
+type Code struct {
+	// ID is the stable identifier of the code, e.g. ODM0030
+	ID string `json:"code"`
+	// Title is a short description of the class of errors
+	Title string `json:"title"`
+	// ExitCode is the exit code of commands failing with errors of this class
+	ExitCode int `json:"exit_code"`
+}
+
+func (c Code) String() string {
+	return fmt.Sprintf("%s: %s", c.ID, c.Title)
+}
+
+// Exit codes are shared by related codes: 1 for unclassified failures, 2 for invalid input, 3 for failures to fetch
+// dependencies, 4 for dependencies rejected for integrity or policy reasons, and 5 for checks finding dependencies out
+// of date.
+var (
+	Unknown      = Code{"ODM0001", "error", 1}
+	InvalidUsage = Code{"ODM0002", "invalid command usage", 2}
+
+	InvalidProject  = Code{"ODM0010", "invalid project file", 2}
+	InvalidConfig   = Code{"ODM0011", "invalid configuration", 2}
+	InvalidLocation = Code{"ODM0012", "invalid dependency location", 2}
+
+	FetchFailed      = Code{"ODM0020", "failed to fetch dependency", 3}
+	ResolutionFailed = Code{"ODM0021", "failed to resolve dependency version", 3}
+	VersionYanked    = Code{"ODM0022", "dependency version yanked", 3}
+
+	ContentMismatch  = Code{"ODM0030", "dependency content mismatch", 4}
+	PolicyViolation  = Code{"ODM0031", "dependency policy violation", 4}
+	TagMoved         = Code{"ODM0032", "locked git tag moved", 4}
+	SignatureInvalid = Code{"ODM0033", "dependency signature verification failed", 4}
+	LockMismatch     = Code{"ODM0034", "dependencies do not match the lock file", 4}
+
+	RefactorFailed     = Code{"ODM0040", "namespace refactoring failed", 1}
+	NamespaceCollision = Code{"ODM0042", "namespace collision", 2}
+
+	UpdatesAvailable = Code{"ODM0050", "dependency updates available", 5}
+)
+
+// Codes lists all codes, ordered by ID.
+var Codes = []Code{
+	Unknown, InvalidUsage,
+	InvalidProject, InvalidConfig, InvalidLocation,
+	FetchFailed, ResolutionFailed, VersionYanked,
+	ContentMismatch, PolicyViolation, TagMoved, SignatureInvalid, LockMismatch,
+	RefactorFailed, NamespaceCollision,
+	UpdatesAvailable,
+}
+
+// Error is an error with a code.
+type Error struct {
+	Code Code
+	err  error
+}
+
+// New returns an error with the given code, and a message formatted as by fmt.Errorf; i.e. wrapping any %w argument.
+func New(code Code, format string, args ...any) error {
+	return &Error{Code: code, err: fmt.Errorf(format, args...)}
+}
+
+// Wrap assigns a code to err, unless nil or already coded.
+func Wrap(code Code, err error) error {
+	var coded *Error
+	if err == nil || errors.As(err, &coded) {
+		return err
+	}
+	return &Error{Code: code, err: err}
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// CodeOf returns the code of the first coded error in err's chain, or Unknown if there is none.
+func CodeOf(err error) Code {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return Unknown
+}
+
+// Is reports whether err has the given code.
+func Is(err error, code Code) bool {
+	return CodeOf(err) == code
+}
@@ -0,0 +1,69 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCodesUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, code := range Codes {
+		if seen[code.ID] {
+			t.Fatalf("duplicate code %s", code.ID)
+		}
+		seen[code.ID] = true
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	cause := errors.New("connection refused")
+	fetchErr := New(FetchFailed, "failed to pull %s: %w", "ghcr.io/org/policy:1.0.0", cause)
+
+	tests := []struct {
+		note     string
+		err      error
+		expected Code
+	}{
+		{
+			note:     "uncoded",
+			err:      cause,
+			expected: Unknown,
+		},
+		{
+			note:     "coded",
+			err:      fetchErr,
+			expected: FetchFailed,
+		},
+		{
+			note:     "wrapped",
+			err:      fmt.Errorf("failed to update dependency policy: %w", fetchErr),
+			expected: FetchFailed,
+		},
+		{
+			note:     "wrap keeps existing code",
+			err:      Wrap(InvalidProject, fetchErr),
+			expected: FetchFailed,
+		},
+		{
+			note:     "wrap uncoded",
+			err:      Wrap(InvalidProject, cause),
+			expected: InvalidProject,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			if code := CodeOf(tc.err); code != tc.expected {
+				t.Fatalf("expected code %s, got %s", tc.expected, code)
+			}
+		})
+	}
+
+	if !errors.Is(fetchErr, cause) {
+		t.Fatalf("expected coded error to wrap its cause")
+	}
+	if Wrap(FetchFailed, nil) != nil {
+		t.Fatalf("expected wrapping nil to yield nil")
+	}
+}
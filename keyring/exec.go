@@ -0,0 +1,29 @@
+//go:build darwin || linux
+
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// run runs the keychain CLI with stdin as its input, returning its output and exit code. Secrets are passed on stdin
+// where the CLI allows it, so that they aren't visible in the process list.
+func run(stdin string, name string, args ...string) (string, int, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return outb.String(), exitErr.ExitCode(), fmt.Errorf("%s %s: %s", name, args[0], strings.TrimSpace(errb.String()))
+	} else if err != nil {
+		return "", -1, fmt.Errorf("%s %s: %w", name, args[0], err)
+	}
+	return outb.String(), 0, nil
+}
@@ -0,0 +1,189 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/config"
+	"golang.org/x/crypto/scrypt"
+	"os"
+	"path/filepath"
+)
+
+// fileMagic prefixes the encrypted credentials file, identifying its format.
+var fileMagic = []byte("ODMKEYRING1")
+
+const (
+	saltSize = 16
+	keySize  = 32
+)
+
+// fileStore stores credentials in a single AES-GCM encrypted file, 'credentials' in the directory of the config file.
+// The key is derived, through scrypt, from the ODM_KEYRING_PASSPHRASE environment variable if set, and otherwise from
+// a random key generated into 'credentials.key' beside it, readable only by the user.
+type fileStore struct {
+	path    string
+	keyPath string
+}
+
+func newFileStore() (*fileStore, error) {
+	configPath, err := config.FilePath()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(configPath)
+	return &fileStore{path: filepath.Join(dir, "credentials"), keyPath: filepath.Join(dir, "credentials.key")}, nil
+}
+
+func (s *fileStore) Name() string {
+	return "encrypted file " + s.path
+}
+
+func (s *fileStore) Get(host string) (Credential, error) {
+	credentials, err := s.read()
+	if err != nil {
+		return Credential{}, err
+	}
+	credential, ok := credentials[host]
+	if !ok {
+		return Credential{}, ErrNotFound
+	}
+	return credential, nil
+}
+
+func (s *fileStore) Set(host string, credential Credential) error {
+	credentials, err := s.read()
+	if err != nil {
+		return err
+	}
+	credentials[host] = credential
+	return s.write(credentials)
+}
+
+func (s *fileStore) Delete(host string) error {
+	credentials, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := credentials[host]; !ok {
+		return ErrNotFound
+	}
+	delete(credentials, host)
+	return s.write(credentials)
+}
+
+// read decrypts the stored credentials; none if the file doesn't exist.
+func (s *fileStore) read() (map[string]Credential, error) {
+	credentials := map[string]Credential{}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return credentials, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file %s: %w", s.path, err)
+	}
+
+	if !bytes.HasPrefix(data, fileMagic) || len(data) < len(fileMagic)+saltSize {
+		return nil, fmt.Errorf("credentials file %s is malformed", s.path)
+	}
+	data = data[len(fileMagic):]
+	salt, data := data[:saltSize], data[saltSize:]
+
+	aead, err := s.cipher(salt, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("credentials file %s is malformed", s.path)
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], fileMagic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials file %s; has ODM_KEYRING_PASSPHRASE changed?", s.path)
+	}
+	if err := json.Unmarshal(plaintext, &credentials); err != nil {
+		return nil, fmt.Errorf("credentials file %s is malformed: %w", s.path, err)
+	}
+	return credentials, nil
+}
+
+// write encrypts the credentials with a fresh salt and nonce, replacing the file.
+func (s *fileStore) write(credentials map[string]Credential) error {
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	aead, err := s.cipher(salt, true)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data := append(append(append([]byte{}, fileMagic...), salt...), nonce...)
+	data = aead.Seal(data, nonce, plaintext, fileMagic)
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials file %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write credentials file %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// cipher returns the AES-GCM cipher keyed by the passphrase and salt; generating the key file if create is true, and
+// there's neither a passphrase nor a key file.
+func (s *fileStore) cipher(salt []byte, create bool) (cipher.AEAD, error) {
+	passphrase, err := s.passphrase(create)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *fileStore) passphrase(create bool) ([]byte, error) {
+	if passphrase := os.Getenv("ODM_KEYRING_PASSPHRASE"); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	key, err := os.ReadFile(s.keyPath)
+	if err == nil {
+		return key, nil
+	} else if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read credentials key %s: %w", s.keyPath, err)
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.keyPath), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.keyPath, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write credentials key %s: %w", s.keyPath, err)
+	}
+	return key, nil
+}
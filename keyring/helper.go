@@ -0,0 +1,113 @@
+package keyring
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/printer"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// helperTimeout bounds the time a credential helper may take; e.g. to complete an interactive SSO flow.
+const helperTimeout = 2 * time.Minute
+
+var (
+	helperMu    sync.Mutex
+	helperCache = map[string]*Credential{}
+)
+
+// HelperCredential returns the credential provided by the credential helper configured for host in the user-level
+// config, if any. The helper is run as '<command> get', with the protocol and host of the request on stdin, and answers
+// with the username and password on stdout; as git credential helpers do. Its answer, or failure, is cached for the
+// rest of the process. Failing helpers are logged, as anonymous access may still succeed.
+func HelperCredential(host string) (Credential, bool) {
+	host = Host(host)
+
+	helperMu.Lock()
+	defer helperMu.Unlock()
+	if credential, ok := helperCache[host]; ok {
+		if credential == nil {
+			return Credential{}, false
+		}
+		return *credential, true
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		printer.Debug("Not using credential helpers for %s: %s", host, err)
+		return Credential{}, false
+	}
+	command := helperCommand(cfg.CredentialHelpers, host)
+	if command == "" {
+		helperCache[host] = nil
+		return Credential{}, false
+	}
+
+	credential, err := runHelper(command, host)
+	if err != nil {
+		printer.Warn("Credential helper for %s failed: %s", host, err)
+		helperCache[host] = nil
+		return Credential{}, false
+	}
+	printer.Debug("Using credential for %s from credential helper '%s'", host, command)
+	helperCache[host] = &credential
+	return credential, true
+}
+
+// helperCommand returns the command of the credential helper configured for host: exactly, or else by the most
+// specific wildcard of its subdomains.
+func helperCommand(helpers map[string]string, host string) string {
+	command, matched := "", ""
+	for pattern, c := range helpers {
+		pattern = Host(pattern)
+		if pattern == host {
+			return c
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") &&
+			strings.HasSuffix(host, suffix) && len(suffix) > len(matched) {
+			command, matched = c, suffix
+		}
+	}
+	return command
+}
+
+func runHelper(command string, host string) (Credential, error) {
+	args := strings.Fields(command)
+	ctx, cancel := context.WithTimeout(context.Background(), helperTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], "get")...)
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", host))
+	// Helpers may prompt, e.g. for an SSO login, on stderr
+	cmd.Stderr = os.Stderr
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return Credential{}, err
+	}
+
+	var credential Credential
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "username":
+			credential.Username = value
+		case "password":
+			credential.Secret = value
+		}
+	}
+	if credential.Secret == "" {
+		return Credential{}, fmt.Errorf("no password returned")
+	}
+	return credential, nil
+}
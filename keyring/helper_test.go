@@ -0,0 +1,71 @@
+package keyring
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestHelperCredential(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper is a shell script")
+	}
+
+	dir := t.TempDir()
+	helper := filepath.Join(dir, "helper")
+	// Answers with the requested host as the password, after checking the protocol of the request
+	script := `#!/bin/sh
+[ "$2" = "get" ] || exit 1
+while read -r line && [ -n "$line" ]; do
+  case "$line" in
+    host=*) host="${line#host=}" ;;
+  esac
+done
+echo "username=$1"
+echo "password=token-for-$host"
+`
+	if err := os.WriteFile(helper, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(`credentialHelpers:
+  git.example.com: %[1]s exact
+  "*.example.com": %[1]s wildcard
+  "*.internal.example.com": %[1]s specific
+  broken.example.com: %[2]s
+`, helper, filepath.Join(dir, "missing"))
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ODM_CONFIG", configPath)
+
+	tests := []struct {
+		host     string
+		expected *Credential
+	}{
+		{host: "git.example.com", expected: &Credential{Username: "exact", Secret: "token-for-git.example.com"}},
+		{host: "https://registry.example.com/v2/", expected: &Credential{Username: "wildcard",
+			Secret: "token-for-registry.example.com"}},
+		{host: "git.internal.example.com", expected: &Credential{Username: "specific",
+			Secret: "token-for-git.internal.example.com"}},
+		{host: "example.com"},
+		{host: "broken.example.com"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.host, func(t *testing.T) {
+			credential, ok := HelperCredential(tc.host)
+			if tc.expected == nil {
+				if ok {
+					t.Fatalf("expected no credential, got %v", credential)
+				}
+				return
+			}
+			if !ok || credential != *tc.expected {
+				t.Fatalf("expected %v, got %v", *tc.expected, credential)
+			}
+		})
+	}
+}
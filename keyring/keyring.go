@@ -0,0 +1,117 @@
+// Package keyring stores the credentials collected by 'odm login': in the OS keychain where one is available (macOS
+// Keychain, Windows Credential Manager, or a libsecret Secret Service on Linux), and otherwise in a file encrypted at
+// rest, in the user config directory.
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// service is the name credentials are stored under in OS keychains.
+const service = "odm"
+
+// ErrNotFound is returned when no credential is stored for a host.
+var ErrNotFound = errors.New("no credential stored")
+
+// Credential is the username and token, or password, used to authenticate with a registry or git host.
+type Credential struct {
+	Username string `json:"username"`
+	Secret   string `json:"secret"`
+}
+
+// Store is a store of credentials, keyed by host.
+type Store interface {
+	// Name is a human-readable name of the store, e.g. 'macOS Keychain'
+	Name() string
+	// Get returns the credential stored for host; ErrNotFound if there is none
+	Get(host string) (Credential, error)
+	// Set stores the credential for host, replacing any previously stored
+	Set(host string, credential Credential) error
+	// Delete removes the credential stored for host; ErrNotFound if there is none
+	Delete(host string) error
+}
+
+// Open returns the credential store selected by the ODM_KEYRING environment variable: 'os' for the OS keychain, or
+// 'file' for the encrypted file. Defaults to the OS keychain, if one is available, and to the encrypted file otherwise.
+func Open() (Store, error) {
+	switch backend := os.Getenv("ODM_KEYRING"); backend {
+	case "":
+		if store, ok := osStore(); ok {
+			return store, nil
+		}
+		return newFileStore()
+	case "os":
+		if store, ok := osStore(); ok {
+			return store, nil
+		}
+		return nil, errs.New(errs.InvalidConfig, "no OS keychain available; set ODM_KEYRING=file to use an encrypted file")
+	case "file":
+		return newFileStore()
+	default:
+		return nil, errs.New(errs.InvalidConfig, "unsupported ODM_KEYRING '%s'; expected os or file", backend)
+	}
+}
+
+// Lookup returns the credential of the credential helper configured for host, or else the credential stored for host,
+// if any. Failing to read the store isn't an error, as anonymous access may still succeed; the failure is logged
+// instead.
+func Lookup(host string) (Credential, bool) {
+	if credential, ok := HelperCredential(host); ok {
+		return credential, true
+	}
+	store, err := Open()
+	if err != nil {
+		printer.Debug("Not using stored credentials for %s: %s", host, err)
+		return Credential{}, false
+	}
+	credential, err := store.Get(Host(host))
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			printer.Warn("Failed to read credential for %s from %s: %s", host, store.Name(), err)
+		}
+		return Credential{}, false
+	}
+	printer.Debug("Using credential for %s from %s", host, store.Name())
+	return credential, true
+}
+
+// Host returns the lower-cased host[:port] credentials are stored under for a host, URL, or dependency location; e.g.
+// 'ghcr.io' for 'oci://ghcr.io/org/policy:1.0.0', and 'github.com' for 'git+https://github.com/org/policy.git#v1'.
+func Host(location string) string {
+	location = strings.TrimPrefix(location, "git+")
+	if strings.Contains(location, "://") {
+		if u, err := url.Parse(location); err == nil && u.Host != "" {
+			return strings.ToLower(u.Host)
+		}
+		location = location[strings.Index(location, "://")+3:]
+	}
+	host, _, _ := strings.Cut(location, "/")
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	return strings.ToLower(host)
+}
+
+// encode and decode serialize a credential as the single secret OS keychains store.
+func encode(credential Credential) (string, error) {
+	bs, err := json.Marshal(credential)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func decode(secret string) (Credential, error) {
+	var credential Credential
+	if err := json.Unmarshal([]byte(secret), &credential); err != nil {
+		return credential, fmt.Errorf("malformed stored credential: %w", err)
+	}
+	return credential, nil
+}
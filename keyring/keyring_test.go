@@ -0,0 +1,119 @@
+package keyring
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	tests := []struct {
+		location string
+		expected string
+	}{
+		{"ghcr.io", "ghcr.io"},
+		{"GHCR.io", "ghcr.io"},
+		{"localhost:5000", "localhost:5000"},
+		{"ghcr.io/org/policy:1.0.0", "ghcr.io"},
+		{"oci://ghcr.io/org/policy:1.0.0", "ghcr.io"},
+		{"git+https://github.com/org/policy.git#v1", "github.com"},
+		{"https://user@git.example.com:8443/org/policy.git", "git.example.com:8443"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.location, func(t *testing.T) {
+			if actual := Host(tc.location); actual != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ODM_CONFIG", filepath.Join(dir, "config.yaml"))
+	t.Setenv("ODM_KEYRING", "file")
+
+	tests := []struct {
+		note       string
+		passphrase string
+		keyFile    bool
+	}{
+		{
+			note:    "generated key file",
+			keyFile: true,
+		},
+		{
+			note:       "passphrase",
+			passphrase: "correct horse battery staple",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			t.Setenv("ODM_KEYRING_PASSPHRASE", tc.passphrase)
+			defer func() {
+				_ = os.Remove(filepath.Join(dir, "credentials"))
+				_ = os.Remove(filepath.Join(dir, "credentials.key"))
+			}()
+
+			store, err := Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get("ghcr.io"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected not found, got %v", err)
+			}
+
+			credential := Credential{Username: "user", Secret: "token"}
+			if err := store.Set("ghcr.io", credential); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Set("github.com", Credential{Username: "odm", Secret: "other"}); err != nil {
+				t.Fatal(err)
+			}
+			if actual, ok := Lookup("oci://ghcr.io/org/policy:1.0.0"); !ok || actual != credential {
+				t.Fatalf("expected %v, got %v", credential, actual)
+			}
+
+			bs, err := os.ReadFile(filepath.Join(dir, "credentials"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(bs), "token") || strings.Contains(string(bs), "user") {
+				t.Fatalf("expected credentials to be encrypted, got %s", bs)
+			}
+			info, err := os.Stat(filepath.Join(dir, "credentials"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Fatalf("expected credentials file mode 0600, got %s", info.Mode().Perm())
+			}
+			if _, err := os.Stat(filepath.Join(dir, "credentials.key")); (err == nil) != tc.keyFile {
+				t.Fatalf("expected key file to exist: %v, got %v", tc.keyFile, err)
+			}
+
+			t.Setenv("ODM_KEYRING_PASSPHRASE", "wrong")
+			if _, err := store.Get("ghcr.io"); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+				t.Fatalf("expected decryption error, got %v", err)
+			}
+			t.Setenv("ODM_KEYRING_PASSPHRASE", tc.passphrase)
+
+			if err := store.Delete("ghcr.io"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get("ghcr.io"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected not found, got %v", err)
+			}
+			if _, err := store.Get("github.com"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete("ghcr.io"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected not found, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package keyring
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// errItemNotFound is the exit code of the 'security' CLI when no keychain item matches.
+const errItemNotFound = 44
+
+// keychainStore stores credentials as generic passwords in the user's default macOS keychain, through the 'security'
+// CLI.
+type keychainStore struct{}
+
+func osStore() (Store, bool) {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil, false
+	}
+	return keychainStore{}, true
+}
+
+func (keychainStore) Name() string {
+	return "macOS Keychain"
+}
+
+func (keychainStore) Get(host string) (Credential, error) {
+	out, code, err := run("", "security", "find-generic-password", "-s", service, "-a", host, "-w")
+	if code == errItemNotFound {
+		return Credential{}, ErrNotFound
+	} else if err != nil {
+		return Credential{}, err
+	}
+	return decode(strings.TrimSuffix(out, "\n"))
+}
+
+func (keychainStore) Set(host string, credential Credential) error {
+	secret, err := encode(credential)
+	if err != nil {
+		return err
+	}
+	// The 'security' CLI only reads passwords from its arguments, or an interactive prompt
+	_, _, err = run("", "security", "add-generic-password", "-U", "-s", service, "-a", host, "-l", "ODM: "+host,
+		"-w", secret)
+	return err
+}
+
+func (keychainStore) Delete(host string) error {
+	_, code, err := run("", "security", "delete-generic-password", "-s", service, "-a", host)
+	if code == errItemNotFound {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package keyring
+
+import (
+	"os"
+	"os/exec"
+)
+
+// secretServiceStore stores credentials in the Secret Service of the user's session, e.g. GNOME Keyring or KWallet,
+// through the libsecret 'secret-tool' CLI.
+type secretServiceStore struct{}
+
+func osStore() (Store, bool) {
+	if _, err := exec.LookPath("secret-tool"); err != nil || os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil, false
+	}
+	return secretServiceStore{}, true
+}
+
+func (secretServiceStore) Name() string {
+	return "Secret Service"
+}
+
+func (secretServiceStore) Get(host string) (Credential, error) {
+	out, code, err := run("", "secret-tool", "lookup", "service", service, "host", host)
+	// secret-tool exits with 1, without output, when no item matches
+	if code == 1 && out == "" {
+		return Credential{}, ErrNotFound
+	} else if err != nil {
+		return Credential{}, err
+	}
+	return decode(out)
+}
+
+func (secretServiceStore) Set(host string, credential Credential) error {
+	secret, err := encode(credential)
+	if err != nil {
+		return err
+	}
+	_, _, err = run(secret, "secret-tool", "store", "--label", "ODM: "+host, "service", service, "host", host)
+	return err
+}
+
+func (s secretServiceStore) Delete(host string) error {
+	if _, err := s.Get(host); err != nil {
+		return err
+	}
+	_, _, err := run("", "secret-tool", "clear", "service", service, "host", host)
+	return err
+}
@@ -0,0 +1,7 @@
+//go:build !darwin && !linux && !windows
+
+package keyring
+
+func osStore() (Store, bool) {
+	return nil, false
+}
@@ -0,0 +1,114 @@
+package keyring
+
+import (
+	"errors"
+	"golang.org/x/sys/windows"
+	"unsafe"
+)
+
+var (
+	advapi32       = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+// credential is the Win32 CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialManagerStore stores credentials as generic credentials of the Windows Credential Manager, persisted for the
+// user on the local machine.
+type credentialManagerStore struct{}
+
+func osStore() (Store, bool) {
+	if err := procCredReadW.Find(); err != nil {
+		return nil, false
+	}
+	return credentialManagerStore{}, true
+}
+
+func (credentialManagerStore) Name() string {
+	return "Windows Credential Manager"
+}
+
+func target(host string) (*uint16, error) {
+	return windows.UTF16PtrFromString(service + ":" + host)
+}
+
+func (credentialManagerStore) Get(host string) (Credential, error) {
+	name, err := target(host)
+	if err != nil {
+		return Credential{}, err
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return Credential{}, ErrNotFound
+		}
+		return Credential{}, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return decode(string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)))
+}
+
+func (credentialManagerStore) Set(host string, c Credential) error {
+	secret, err := encode(c)
+	if err != nil {
+		return err
+	}
+	name, err := target(host)
+	if err != nil {
+		return err
+	}
+	user, err := windows.UTF16PtrFromString(c.Username)
+	if err != nil {
+		return err
+	}
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         name,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (credentialManagerStore) Delete(host string) error {
+	name, err := target(host)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0); r == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
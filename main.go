@@ -2,11 +2,13 @@ package main
 
 import (
 	"github.com/johanfylling/odm/cmd"
+	"github.com/johanfylling/odm/errs"
 	"os"
 )
 
 func main() {
+	// Command failures exit from within the commands; errors returned here are usage errors, reported by cobra
 	if err := cmd.RootCommand.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(errs.InvalidUsage.ExitCode)
 	}
 }
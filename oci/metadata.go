@@ -0,0 +1,103 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Metadata is the ODM metadata of a repository, published as a JSON artifact tagged MetadataTag next to the repository's
+// versions.
+type Metadata struct {
+	// Yanked maps yanked versions to the reason they were yanked. Yanked versions are skipped when resolving version
+	// ranges, but are still pulled when pinned.
+	Yanked map[string]string `json:"yanked,omitempty"`
+	// Deprecated, when set, deprecates all versions of the repository
+	Deprecated *Deprecation `json:"deprecated,omitempty"`
+	// DeprecatedVersions maps deprecated versions to their deprecation
+	DeprecatedVersions map[string]Deprecation `json:"deprecatedVersions,omitempty"`
+}
+
+// Deprecation is a notice shown to users of a deprecated repository or version.
+type Deprecation struct {
+	Message string `json:"message,omitempty"`
+	// Replacement is the location of a suggested replacement, if any
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// YankReason returns the reason the given version was yanked, and whether it was yanked.
+func (m *Metadata) YankReason(version string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	reason, ok := m.Yanked[version]
+	return reason, ok
+}
+
+// DeprecationOf returns the deprecation of the given version, or of the whole repository; nil if not deprecated.
+func (m *Metadata) DeprecationOf(version string) *Deprecation {
+	if m == nil {
+		return nil
+	}
+	if deprecation, ok := m.DeprecatedVersions[version]; ok {
+		return &deprecation
+	}
+	return m.Deprecated
+}
+
+// Metadata returns the metadata of the reference's repository. A repository without metadata yields empty metadata.
+func (c *Client) Metadata(ref Reference) (*Metadata, error) {
+	ref = Reference{Registry: ref.Registry, Repository: ref.Repository, Tag: MetadataTag}
+
+	manifest, _, err := c.fetchManifest(ref, MetadataTag)
+	if errors.Is(err, errNotFound) {
+		return &Metadata{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata of %s/%s: %w", ref.Registry, ref.Repository, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != MediaTypeMetadata {
+			continue
+		}
+
+		resp, err := c.get(ref, fmt.Sprintf("/v2/%s/blobs/%s", ref.Repository, layer.Digest), "")
+		if err != nil {
+			return nil, err
+		}
+		verifier := newDigestVerifier(resp.Body)
+		data, err := io.ReadAll(verifier)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata of %s/%s: %w", ref.Registry, ref.Repository, err)
+		}
+		if digest := verifier.digest(); digest != layer.Digest {
+			return nil, fmt.Errorf("digest mismatch for metadata of %s/%s: expected %s, got %s", ref.Registry,
+				ref.Repository, layer.Digest, digest)
+		}
+
+		var metadata Metadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata of %s/%s: %w", ref.Registry, ref.Repository, err)
+		}
+		return &metadata, nil
+	}
+
+	return &Metadata{}, nil
+}
+
+// PushMetadata replaces the metadata of the reference's repository.
+func (c *Client) PushMetadata(ref Reference, metadata *Metadata) error {
+	ref = Reference{Registry: ref.Registry, Repository: ref.Repository, Tag: MetadataTag}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.push(ref, data, "metadata.json", MediaTypeMetadata, ArtifactTypeMetadata); err != nil {
+		return fmt.Errorf("failed to push metadata of %s/%s: %w", ref.Registry, ref.Repository, err)
+	}
+	return nil
+}
@@ -0,0 +1,680 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/johanfylling/odm/keyring"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	MediaTypeImageManifest  = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeImageConfig    = "application/vnd.oci.image.config.v1+json"
+	MediaTypeEmptyConfig    = "application/vnd.oci.empty.v1+json"
+	MediaTypeLayerTarGzip   = "application/vnd.oci.image.layer.v1.tar+gzip"
+	// MediaTypeRegoLayer is the media type of layers holding a gzipped tarball of a Rego source tree
+	MediaTypeRegoLayer = "application/vnd.odm.rego.layer.v1.tar+gzip"
+	// ArtifactTypeRego is the artifact type of ORAS artifacts holding a Rego source tree
+	ArtifactTypeRego = "application/vnd.odm.rego.v1"
+
+	// MediaTypeOpaPolicyLayer and MediaTypeOpaDataLayer are the media types of layers holding a single Rego or JSON
+	// file, as pushed by conftest; named by their title annotation
+	MediaTypeOpaPolicyLayer = "application/vnd.cncf.openpolicyagent.policy.layer.v1+rego"
+	MediaTypeOpaDataLayer   = "application/vnd.cncf.openpolicyagent.data.layer.v1+json"
+
+	// MediaTypeProjectLayer is the media type of layers holding a gzipped tarball of a self-contained project, with its
+	// lock file and vendored dependencies
+	MediaTypeProjectLayer = "application/vnd.odm.project.layer.v1.tar+gzip"
+	// ArtifactTypeProject is the artifact type of ORAS artifacts holding a self-contained project
+	ArtifactTypeProject = "application/vnd.odm.project.v1"
+
+	// MediaTypeMetadata is the media type of the layer holding the repository metadata, as JSON
+	MediaTypeMetadata = "application/vnd.odm.metadata.v1+json"
+	// ArtifactTypeMetadata is the artifact type of the repository metadata artifact
+	ArtifactTypeMetadata = "application/vnd.odm.metadata.v1"
+	// MetadataTag is the tag of the repository metadata artifact
+	MetadataTag = "odm.metadata"
+
+	// annotationTitle is the layer annotation holding the file name of a layer; or the path of a single-file layer
+	annotationTitle = "org.opencontainers.image.title"
+)
+
+// Reference is a reference to an artifact in an OCI registry, by tag and/or digest.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses references on the form registry/repository[:tag][@digest].
+// If neither tag nor digest is specified, the tag defaults to 'latest'.
+func ParseReference(s string) (Reference, error) {
+	var ref Reference
+
+	if i := strings.Index(s, "@"); i >= 0 {
+		ref.Digest = s[i+1:]
+		s = s[:i]
+		if !strings.HasPrefix(ref.Digest, "sha256:") || len(ref.Digest) != len("sha256:")+64 {
+			return ref, fmt.Errorf("invalid digest '%s'; expected sha256:<hex>", ref.Digest)
+		}
+	}
+
+	slash := strings.Index(s, "/")
+	if slash <= 0 {
+		return ref, fmt.Errorf("invalid reference '%s'; expected registry/repository[:tag][@digest]", s)
+	}
+	ref.Registry = s[:slash]
+	ref.Repository = s[slash+1:]
+
+	if i := strings.LastIndex(ref.Repository, ":"); i >= 0 && !strings.Contains(ref.Repository[i:], "/") {
+		ref.Tag = ref.Repository[i+1:]
+		ref.Repository = ref.Repository[:i]
+	}
+
+	if ref.Repository == "" {
+		return ref, fmt.Errorf("invalid reference '%s'; missing repository", s)
+	}
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+
+	return ref, nil
+}
+
+func (r Reference) String() string {
+	s := fmt.Sprintf("%s/%s", r.Registry, r.Repository)
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// WithDigest returns a copy of the reference pinned to the given digest.
+func (r Reference) WithDigest(digest string) Reference {
+	r.Digest = digest
+	return r
+}
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Client is a minimal client for the OCI distribution API, supporting token and basic authentication; with the
+// credentials stored by 'odm login', or anonymously.
+type Client struct {
+	client *http.Client
+	// auth maps repositories to the Authorization header of their requests
+	auth map[string]string
+	// credentials returns the credential stored for a registry, if any
+	credentials func(registry string) (keyring.Credential, bool)
+}
+
+func NewClient() *Client {
+	return &Client{
+		client:      http.DefaultClient,
+		auth:        make(map[string]string),
+		credentials: keyring.Lookup,
+	}
+}
+
+// NewAnonymousClient returns a client never authenticating with stored credentials.
+func NewAnonymousClient() *Client {
+	c := NewClient()
+	c.credentials = func(string) (keyring.Credential, bool) { return keyring.Credential{}, false }
+	return c
+}
+
+var errNotFound = errors.New("not found")
+
+// ErrDigestMismatch is returned when pulled content doesn't match its digest.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// IsNotFound reports whether the error is caused by the registry not finding the requested repository, manifest or blob.
+func IsNotFound(err error) bool {
+	return errors.Is(err, errNotFound)
+}
+
+// Resolve returns the digest of the manifest the reference points to.
+// If the reference is pinned by digest, the digest is returned as-is.
+func (c *Client) Resolve(ref Reference) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+
+	_, digest, err := c.fetchManifest(ref, ref.Tag)
+	return digest, err
+}
+
+// Tags lists all tags of the reference's repository.
+func (c *Client) Tags(ref Reference) ([]string, error) {
+	var tags []string
+
+	path := fmt.Sprintf("/v2/%s/tags/list", ref.Repository)
+	for path != "" {
+		resp, err := c.get(ref, path, "application/json")
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tag list of %s/%s: %w", ref.Registry, ref.Repository, err)
+		}
+		tags = append(tags, page.Tags...)
+
+		path = nextPage(resp.Header.Get("Link"))
+	}
+
+	return tags, nil
+}
+
+// nextPage returns the path of the next page from a paginated response's Link header; e.g.
+// '</v2/repo/tags/list?n=100&last=v1.2.0>; rel="next"'.
+func nextPage(link string) string {
+	if !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return link[start+1 : end]
+}
+
+// Pull downloads all gzipped tarball layers of the artifact the reference points to, extracting them into dstDir; and
+// all single-file OPA policy and data layers, writing them to their titled paths within dstDir. Returns the digest of
+// the pulled manifest. If the reference is pinned by digest, the manifest content is verified against it.
+func (c *Client) Pull(ref Reference, dstDir string) (string, error) {
+	manifestRef := ref.Tag
+	if ref.Digest != "" {
+		manifestRef = ref.Digest
+	}
+
+	manifest, digest, err := c.fetchManifest(ref, manifestRef)
+	if err != nil {
+		return "", err
+	}
+
+	layers := 0
+	for _, layer := range manifest.Layers {
+		switch {
+		case isTarGzipLayer(layer.MediaType):
+			err = c.pullLayer(ref, layer, dstDir)
+		case layer.MediaType == MediaTypeOpaPolicyLayer || layer.MediaType == MediaTypeOpaDataLayer:
+			err = c.pullFileLayer(ref, layer, dstDir)
+		default:
+			printer.Debug("Skipping layer %s with unsupported media type %s", layer.Digest, layer.MediaType)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		layers++
+	}
+
+	if layers == 0 {
+		return "", fmt.Errorf("%s contains no supported layers", ref)
+	}
+
+	return digest, nil
+}
+
+func isTarGzipLayer(mediaType string) bool {
+	return mediaType == MediaTypeLayerTarGzip ||
+		mediaType == MediaTypeRegoLayer ||
+		mediaType == MediaTypeProjectLayer ||
+		mediaType == "application/vnd.docker.image.rootfs.diff.tar.gzip"
+}
+
+// pullFileLayer writes a single-file layer to the path of its title within dstDir; or, if untitled, to a file named by
+// its digest.
+func (c *Client) pullFileLayer(ref Reference, layer Descriptor, dstDir string) error {
+	printer.Debug("Pulling file layer %s from %s", layer.Digest, ref)
+
+	name := filepath.FromSlash(strings.TrimPrefix(layer.Annotations[annotationTitle], "/"))
+	if name == "" {
+		ext := ".rego"
+		if layer.MediaType == MediaTypeOpaDataLayer {
+			ext = ".json"
+		}
+		name = strings.TrimPrefix(layer.Digest, "sha256:") + ext
+	}
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("layer %s of %s has invalid title '%s'", layer.Digest, ref, layer.Annotations[annotationTitle])
+	}
+
+	resp, err := c.get(ref, fmt.Sprintf("/v2/%s/blobs/%s", ref.Repository, layer.Digest), "")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	verifier := newDigestVerifier(resp.Body)
+	content, err := io.ReadAll(verifier)
+	if err != nil {
+		return err
+	}
+	if digest := verifier.digest(); digest != layer.Digest {
+		return fmt.Errorf("%w for layer of %s: expected %s, got %s", ErrDigestMismatch, ref, layer.Digest, digest)
+	}
+
+	path := filepath.Join(dstDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+func (c *Client) pullLayer(ref Reference, layer Descriptor, dstDir string) error {
+	printer.Debug("Pulling layer %s from %s", layer.Digest, ref)
+
+	resp, err := c.get(ref, fmt.Sprintf("/v2/%s/blobs/%s", ref.Repository, layer.Digest), "")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	verifier := newDigestVerifier(resp.Body)
+	if err := utils.ExtractTarGz(verifier, dstDir); err != nil {
+		return fmt.Errorf("failed to extract layer %s: %w", layer.Digest, err)
+	}
+	// Drain any trailing bytes not consumed by the tar reader, so the full blob is verified
+	if _, err := io.Copy(io.Discard, verifier); err != nil {
+		return err
+	}
+	if digest := verifier.digest(); digest != layer.Digest {
+		return fmt.Errorf("%w for layer of %s: expected %s, got %s", ErrDigestMismatch, ref, layer.Digest, digest)
+	}
+
+	return nil
+}
+
+// ResolveManifest returns the decoded manifest the reference points to, along with its digest.
+func (c *Client) ResolveManifest(ref Reference) (*Manifest, string, error) {
+	manifestRef := ref.Tag
+	if ref.Digest != "" {
+		manifestRef = ref.Digest
+	}
+	return c.fetchManifest(ref, manifestRef)
+}
+
+func (c *Client) fetchManifest(ref Reference, manifestRef string) (*Manifest, string, error) {
+	body, _, digest, err := c.fetchRawManifest(ref, manifestRef)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, "", fmt.Errorf("failed to decode manifest of %s: %w", ref, err)
+	}
+
+	return &manifest, digest, nil
+}
+
+// FetchManifest returns the manifest the reference points to as-is, along with its media type and digest.
+// If the reference is pinned by digest, the manifest content is verified against it.
+func (c *Client) FetchManifest(ref Reference) ([]byte, string, string, error) {
+	manifestRef := ref.Tag
+	if ref.Digest != "" {
+		manifestRef = ref.Digest
+	}
+	return c.fetchRawManifest(ref, manifestRef)
+}
+
+func (c *Client) fetchRawManifest(ref Reference, manifestRef string) ([]byte, string, string, error) {
+	accept := strings.Join([]string{MediaTypeImageManifest, MediaTypeDockerManifest}, ", ")
+	resp, err := c.get(ref, fmt.Sprintf("/v2/%s/manifests/%s", ref.Repository, manifestRef), accept)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read manifest of %s: %w", ref, err)
+	}
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	if ref.Digest != "" && digest != ref.Digest {
+		return nil, "", "", fmt.Errorf("%w for %s: got %s", ErrDigestMismatch, ref, digest)
+	}
+
+	return body, resp.Header.Get("Content-Type"), digest, nil
+}
+
+// FetchBlob returns the content of the blob with the given digest in the reference's repository, as-is.
+// The caller is responsible for closing the returned reader, and for verifying the content against the digest.
+func (c *Client) FetchBlob(ref Reference, digest string) (io.ReadCloser, error) {
+	resp, err := c.get(ref, fmt.Sprintf("/v2/%s/blobs/%s", ref.Repository, digest), "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Push uploads the gzipped tarball at layerPath as the single layer of an artifact, tagged as the reference's tag.
+// The artifact type is only set when non-empty; i.e. OPA bundles are pushed as plain images, for OPA to pull.
+// Returns the digest of the pushed manifest.
+func (c *Client) Push(ref Reference, layerPath string, layerMediaType string, artifactType string) (string, error) {
+	layer, err := os.ReadFile(layerPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", layerPath, err)
+	}
+
+	return c.push(ref, layer, filepath.Base(layerPath), layerMediaType, artifactType)
+}
+
+func (c *Client) push(ref Reference, layer []byte, title string, layerMediaType string, artifactType string) (string, error) {
+	if ref.Tag == "" {
+		return "", fmt.Errorf("cannot push %s: missing tag", ref)
+	}
+
+	var err error
+	config := Descriptor{MediaType: MediaTypeImageConfig}
+	configContent := []byte("{}")
+	if artifactType != "" {
+		config.MediaType = MediaTypeEmptyConfig
+	}
+	if config.Digest, err = c.uploadBlob(ref, configContent); err != nil {
+		return "", err
+	}
+	config.Size = int64(len(configContent))
+
+	layerDigest, err := c.uploadBlob(ref, layer)
+	if err != nil {
+		return "", err
+	}
+
+	manifest, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeImageManifest,
+		ArtifactType:  artifactType,
+		Config:        config,
+		Layers: []Descriptor{
+			{
+				MediaType:   layerMediaType,
+				Digest:      layerDigest,
+				Size:        int64(len(layer)),
+				Annotations: map[string]string{annotationTitle: title},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	printer.Debug("Pushing manifest for %s", ref)
+	resp, err := c.do(ref, http.MethodPut, fmt.Sprintf("/v2/%s/manifests/%s", ref.Repository, ref.Tag),
+		map[string]string{"Content-Type": MediaTypeImageManifest}, manifest, http.StatusCreated)
+	if err != nil {
+		return "", fmt.Errorf("failed to push manifest for %s: %w", ref, err)
+	}
+	_ = resp.Body.Close()
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(manifest)), nil
+}
+
+// uploadBlob uploads content to the reference's repository in a single request, returning its digest.
+func (c *Client) uploadBlob(ref Reference, content []byte) (string, error) {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+	printer.Debug("Uploading blob %s to %s", digest, ref)
+
+	resp, err := c.do(ref, http.MethodPost, fmt.Sprintf("/v2/%s/blobs/uploads/", ref.Repository), nil, nil,
+		http.StatusAccepted)
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate upload of blob %s: %w", digest, err)
+	}
+	_ = resp.Body.Close()
+
+	location, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate upload of blob %s: %w", digest, err)
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	resp, err = c.do(ref, http.MethodPut, location.RequestURI(),
+		map[string]string{"Content-Type": "application/octet-stream"}, content, http.StatusCreated)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload blob %s: %w", digest, err)
+	}
+	_ = resp.Body.Close()
+
+	return digest, nil
+}
+
+func (c *Client) get(ref Reference, path string, accept string) (*http.Response, error) {
+	var headers map[string]string
+	if accept != "" {
+		headers = map[string]string{"Accept": accept}
+	}
+	return c.do(ref, http.MethodGet, path, headers, nil, http.StatusOK)
+}
+
+// do sends a request to the reference's registry, authenticating and retrying once if challenged to.
+func (c *Client) do(ref Reference, method string, path string, headers map[string]string, body []byte,
+	expectedStatus int) (*http.Response, error) {
+	u := fmt.Sprintf("%s://%s%s", scheme(ref.Registry), ref.Registry, path)
+
+	resp, err := c.send(ref, method, u, headers, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+		if err := c.authenticate(ref, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = c.send(ref, method, u, headers, body); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != expectedStatus {
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s %s: %w: %w", method, u, utils.NewStatusError(resp), errNotFound)
+		}
+		return nil, fmt.Errorf("%s %s: %w", method, u, utils.NewStatusError(resp))
+	}
+
+	return resp, nil
+}
+
+func (c *Client) send(ref Reference, method string, u string, headers map[string]string, body []byte) (*http.Response, error) {
+	printer.Debug("%s %s", method, u)
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if auth, ok := c.auth[authKey(ref)]; ok {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, u, err)
+	}
+	return resp, nil
+}
+
+// authenticate obtains a bearer token for the reference's repository, as instructed by the registry's authentication
+// challenge; with the credential stored for the registry, if any, and anonymously otherwise. Registries challenging for
+// basic authentication are sent the stored credential as is.
+func (c *Client) authenticate(ref Reference, challenge string) error {
+	credential, hasCredential := c.credentials(ref.Registry)
+	if strings.HasPrefix(strings.ToLower(challenge), "basic") && hasCredential {
+		basic := base64.StdEncoding.EncodeToString([]byte(credential.Username + ":" + credential.Secret))
+		c.auth[authKey(ref)] = "Basic " + basic
+		return nil
+	}
+
+	params, ok := parseBearerChallenge(challenge)
+	if !ok {
+		if !hasCredential {
+			return fmt.Errorf("unauthorized to access %s; authenticate with 'odm login %s'", ref, ref.Registry)
+		}
+		return fmt.Errorf("unauthorized to access %s", ref)
+	}
+
+	query := url.Values{}
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	if scope, ok := params["scope"]; ok {
+		query.Set("scope", scope)
+	} else {
+		query.Set("scope", fmt.Sprintf("repository:%s:pull", ref.Repository))
+	}
+
+	tokenUrl := params["realm"] + "?" + query.Encode()
+	printer.Debug("Requesting registry token from %s", tokenUrl)
+
+	req, err := http.NewRequest(http.MethodGet, tokenUrl, nil)
+	if err != nil {
+		return err
+	}
+	if hasCredential {
+		req.SetBasicAuth(credential.Username, credential.Secret)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with %s: %w", ref.Registry, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusUnauthorized && hasCredential {
+		return fmt.Errorf("failed to authenticate with %s: stored credential rejected; update it with 'odm login %s'",
+			ref.Registry, ref.Registry)
+	} else if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("unauthorized to access %s; authenticate with 'odm login %s'", ref, ref.Registry)
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to authenticate with %s: unexpected status %s", ref.Registry, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to decode registry token: %w", err)
+	}
+
+	if token.Token != "" {
+		c.auth[authKey(ref)] = "Bearer " + token.Token
+	} else {
+		c.auth[authKey(ref)] = "Bearer " + token.AccessToken
+	}
+	return nil
+}
+
+func authKey(ref Reference) string {
+	return ref.Registry + "/" + ref.Repository
+}
+
+func parseBearerChallenge(challenge string) (map[string]string, bool) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(challenge[len("bearer "):], ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToLower(kv[0])] = strings.Trim(kv[1], "\"")
+		}
+	}
+
+	_, ok := params["realm"]
+	return params, ok
+}
+
+// plainHTTPRegistries are registries known to be served over plain HTTP, such as an ODM proxy
+var plainHTTPRegistries sync.Map
+
+// AllowPlainHTTP makes all clients connect to the given registry (host[:port]) over plain HTTP.
+func AllowPlainHTTP(registry string) {
+	plainHTTPRegistries.Store(registry, true)
+}
+
+// scheme returns the scheme to use for the registry; plain HTTP is only used for registries allowed by AllowPlainHTTP,
+// registries on the local host, or when explicitly allowed through the ODM_OCI_PLAIN_HTTP environment variable.
+func scheme(registry string) string {
+	if _, ok := plainHTTPRegistries.Load(registry); ok {
+		return "http"
+	}
+	host := registry
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	if host == "localhost" || host == "127.0.0.1" || os.Getenv("ODM_OCI_PLAIN_HTTP") == "true" {
+		return "http"
+	}
+	return "https"
+}
+
+type digestVerifier struct {
+	r io.Reader
+	h hash.Hash
+}
+
+func newDigestVerifier(r io.Reader) *digestVerifier {
+	h := sha256.New()
+	return &digestVerifier{r: io.TeeReader(r, h), h: h}
+}
+
+func (v *digestVerifier) Read(p []byte) (int, error) {
+	return v.r.Read(p)
+}
+
+func (v *digestVerifier) digest() string {
+	return fmt.Sprintf("sha256:%x", v.h.Sum(nil))
+}
@@ -0,0 +1,368 @@
+package oci
+
+import (
+	"encoding/json"
+	"github.com/johanfylling/odm/keyring"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	tests := []struct {
+		input       string
+		expected    Reference
+		expectedErr bool
+	}{
+		{
+			input:    "ghcr.io/org/policy:1.2.0",
+			expected: Reference{Registry: "ghcr.io", Repository: "org/policy", Tag: "1.2.0"},
+		},
+		{
+			input:    "ghcr.io/org/policy",
+			expected: Reference{Registry: "ghcr.io", Repository: "org/policy", Tag: "latest"},
+		},
+		{
+			input:    "localhost:5000/policy:v1",
+			expected: Reference{Registry: "localhost:5000", Repository: "policy", Tag: "v1"},
+		},
+		{
+			input:    "localhost:5000/policy@" + digest,
+			expected: Reference{Registry: "localhost:5000", Repository: "policy", Digest: digest},
+		},
+		{
+			input:    "ghcr.io/org/policy:1.2.0@" + digest,
+			expected: Reference{Registry: "ghcr.io", Repository: "org/policy", Tag: "1.2.0", Digest: digest},
+		},
+		{
+			input:       "ghcr.io/org/policy@sha256:abc",
+			expectedErr: true,
+		},
+		{
+			input:       "policy:1.2.0",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			ref, err := ParseReference(tc.input)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ref != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, ref)
+			}
+		})
+	}
+}
+
+func TestPull(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	registry.Token = "secret"
+
+	v1 := registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1"})
+	v2 := registry.Push("org/policy", "2.0.0", map[string]string{"/policy.rego": "package v2"})
+
+	tests := []struct {
+		note           string
+		ref            string
+		expectedDigest string
+		expectedPolicy string
+		expectedErr    string
+	}{
+		{
+			note:           "by tag",
+			ref:            registry.Host() + "/org/policy:1.0.0",
+			expectedDigest: v1,
+			expectedPolicy: "package v1",
+		},
+		{
+			note:           "by digest",
+			ref:            registry.Host() + "/org/policy@" + v2,
+			expectedDigest: v2,
+			expectedPolicy: "package v2",
+		},
+		{
+			note:           "by tag and digest, digest wins",
+			ref:            registry.Host() + "/org/policy:1.0.0@" + v2,
+			expectedDigest: v2,
+			expectedPolicy: "package v2",
+		},
+		{
+			note:        "unknown tag",
+			ref:         registry.Host() + "/org/policy:3.0.0",
+			expectedErr: "404",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			ref, err := ParseReference(tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dir := t.TempDir()
+			digest, err := NewClient().Pull(ref, dir)
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("expected error containing %s, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if digest != tc.expectedDigest {
+				t.Fatalf("expected digest %s, got %s", tc.expectedDigest, digest)
+			}
+
+			bs, err := os.ReadFile(filepath.Join(dir, "policy.rego"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(bs) != tc.expectedPolicy {
+				t.Fatalf("expected policy %s, got %s", tc.expectedPolicy, string(bs))
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	v1 := registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1"})
+
+	ref, _ := ParseReference(registry.Host() + "/org/policy:1.0.0")
+	digest, err := NewClient().Resolve(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != v1 {
+		t.Fatalf("expected digest %s, got %s", v1, digest)
+	}
+}
+
+func TestTags(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1"})
+	registry.Push("org/policy", "latest", map[string]string{"/policy.rego": "package v1"})
+
+	ref, _ := ParseReference(registry.Host() + "/org/policy:^1.0")
+	tags, err := NewClient().Tags(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(tags)
+	if expected := []string{"1.0.0", "latest"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected tags %v, got %v", expected, tags)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	tests := map[string]string{
+		"": "",
+		`</v2/org/policy/tags/list?n=2&last=b>; rel="next"`: "/v2/org/policy/tags/list?n=2&last=b",
+		`</v2/org/policy/tags/list?n=2&last=b>; rel="prev"`: "",
+	}
+
+	for link, expected := range tests {
+		if actual := nextPage(link); actual != expected {
+			t.Fatalf("expected %s for link %s, got %s", expected, link, actual)
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	registry.Token = "secret"
+
+	layerPath := filepath.Join(t.TempDir(), "source.tar.gz")
+	f, err := os.Create(layerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "policy.rego"), []byte("package pushed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.CreateTarGz(f, srcDir, []string{srcDir}); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	ref, _ := ParseReference(registry.Host() + "/org/policy:1.0.0")
+	client := NewClient()
+	digest, err := client.Push(ref, layerPath, MediaTypeRegoLayer, ArtifactTypeRego)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(registry.Manifest("org/policy", "1.0.0"), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ArtifactType != ArtifactTypeRego {
+		t.Fatalf("expected artifact type %s, got %s", ArtifactTypeRego, manifest.ArtifactType)
+	}
+	if manifest.Config.MediaType != MediaTypeEmptyConfig {
+		t.Fatalf("expected config media type %s, got %s", MediaTypeEmptyConfig, manifest.Config.MediaType)
+	}
+	if len(manifest.Layers) != 1 || manifest.Layers[0].MediaType != MediaTypeRegoLayer {
+		t.Fatalf("expected single %s layer, got %v", MediaTypeRegoLayer, manifest.Layers)
+	}
+
+	dir := t.TempDir()
+	pulledDigest, err := client.Pull(ref, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pulledDigest != digest {
+		t.Fatalf("expected digest %s, got %s", digest, pulledDigest)
+	}
+	bs, err := os.ReadFile(filepath.Join(dir, "policy.rego"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "package pushed" {
+		t.Fatalf("expected pushed policy, got %s", string(bs))
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1"})
+
+	ref, _ := ParseReference(registry.Host() + "/org/policy:1.0.0")
+	client := NewClient()
+
+	metadata, err := client.Metadata(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, yanked := metadata.YankReason("1.0.0"); yanked {
+		t.Fatalf("expected no yanked versions, got %v", metadata.Yanked)
+	}
+
+	metadata.Yanked = map[string]string{"1.0.0": "broken"}
+	if err := client.PushMetadata(ref, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err = client.Metadata(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason, yanked := metadata.YankReason("1.0.0"); !yanked || reason != "broken" {
+		t.Fatalf("expected 1.0.0 to be yanked as broken, got %v", metadata.Yanked)
+	}
+}
+
+func TestAuthenticateWithStoredCredential(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	registry.Push("org/private", "1.0.0", map[string]string{"/policy.rego": "package private"})
+	registry.Token = "secret"
+	registry.Username = "user"
+	registry.Password = "password"
+
+	ref, _ := ParseReference(registry.Host() + "/org/private:1.0.0")
+
+	tests := []struct {
+		note        string
+		credential  *keyring.Credential
+		expectedErr string
+	}{
+		{
+			note:        "no stored credential",
+			expectedErr: "unauthorized to access " + ref.String() + "; authenticate with 'odm login " + registry.Host() + "'",
+		},
+		{
+			note:        "rejected credential",
+			credential:  &keyring.Credential{Username: "user", Secret: "wrong"},
+			expectedErr: "stored credential rejected",
+		},
+		{
+			note:       "stored credential",
+			credential: &keyring.Credential{Username: "user", Secret: "password"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			client := NewClient()
+			client.credentials = func(registry string) (keyring.Credential, bool) {
+				if tc.credential == nil || registry != ref.Registry {
+					return keyring.Credential{}, false
+				}
+				return *tc.credential, true
+			}
+
+			_, err := client.Resolve(ref)
+			if tc.expectedErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tc.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErr)) {
+				t.Fatalf("expected error containing %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPullFileLayers(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	files := map[string]string{
+		"policy/main.rego": "package main",
+		"data.json":        `{"limit": 3}`,
+	}
+	expectedDigest := registry.PushFiles("org/conftest", "1.0.0", files)
+
+	ref, _ := ParseReference(registry.Host() + "/org/conftest:1.0.0")
+	dir := t.TempDir()
+	digest, err := NewClient().Pull(ref, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != expectedDigest {
+		t.Fatalf("expected digest %s, got %s", expectedDigest, digest)
+	}
+
+	for path, expected := range files {
+		bs, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != expected {
+			t.Fatalf("expected %s to be %s, got %s", path, expected, bs)
+		}
+	}
+
+	registry.PushFiles("org/conftest", "evil", map[string]string{"../escape.rego": "package evil"})
+	ref, _ = ParseReference(registry.Host() + "/org/conftest:evil")
+	if _, err := NewClient().Pull(ref, t.TempDir()); err == nil || !strings.Contains(err.Error(), "invalid title") {
+		t.Fatalf("expected invalid title error, got %v", err)
+	}
+}
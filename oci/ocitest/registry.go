@@ -0,0 +1,263 @@
+// Package ocitest provides an in-memory OCI registry for testing.
+package ocitest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry is an in-memory OCI registry.
+type Registry struct {
+	server    *httptest.Server
+	mtx       sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	tags      map[string]map[string]string
+	// Token, when set, is required as bearer token for all registry requests
+	Token string
+	// Username and Password, when set, are required as basic authentication to obtain the token
+	Username string
+	Password string
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		tags:      make(map[string]map[string]string),
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.handle))
+	return r
+}
+
+// Host returns the host:port of the registry, on the local host.
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.server.URL, "http://")
+}
+
+func (r *Registry) Close() {
+	r.server.Close()
+}
+
+// Push stores an artifact with a single gzipped tarball layer of the given files, tagging it in the given repository.
+// Returns the digest of the artifact manifest.
+func (r *Registry) Push(repository string, tag string, files map[string]string) string {
+	return r.PushArtifact(repository, tag, "application/vnd.oci.image.layer.v1.tar+gzip", "", nil, files)
+}
+
+// PushArtifact stores an artifact with a single gzipped tarball layer of the given files, with the given layer media
+// type, artifact type, and manifest annotations. Returns the digest of the artifact manifest.
+func (r *Registry) PushArtifact(repository string, tag string, layerMediaType string, artifactType string,
+	annotations map[string]string, files map[string]string) string {
+	return r.pushBlobArtifact(repository, tag, layerMediaType, artifactType, annotations, tarGz(files))
+}
+
+// PushFiles stores an artifact as pushed by conftest: every file in a layer of its own, with the OPA policy or data
+// media type and the file's path as title. Returns the digest of the artifact manifest.
+func (r *Registry) PushFiles(repository string, tag string, files map[string]string) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var layers []layer
+	for _, path := range paths {
+		mediaType := "application/vnd.cncf.openpolicyagent.policy.layer.v1+rego"
+		if strings.HasSuffix(path, ".json") {
+			mediaType = "application/vnd.cncf.openpolicyagent.data.layer.v1+json"
+		}
+		layers = append(layers, layer{mediaType: mediaType, content: []byte(files[path]),
+			annotations: map[string]string{"org.opencontainers.image.title": path}})
+	}
+	return r.pushLayers(repository, tag, "application/vnd.cncf.openpolicyagent.config.v1+json", "", nil, layers)
+}
+
+type layer struct {
+	mediaType   string
+	content     []byte
+	annotations map[string]string
+}
+
+func (r *Registry) pushBlobArtifact(repository string, tag string, layerMediaType string, artifactType string,
+	annotations map[string]string, content []byte) string {
+	return r.pushLayers(repository, tag, "application/vnd.oci.image.config.v1+json", artifactType, annotations,
+		[]layer{{mediaType: layerMediaType, content: content}})
+}
+
+func (r *Registry) pushLayers(repository string, tag string, configMediaType string, artifactType string,
+	annotations map[string]string, layers []layer) string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var descriptors []map[string]interface{}
+	for _, l := range layers {
+		layerDigest := digest(l.content)
+		r.blobs[layerDigest] = l.content
+		descriptors = append(descriptors, map[string]interface{}{
+			"mediaType":   l.mediaType,
+			"digest":      layerDigest,
+			"size":        len(l.content),
+			"annotations": l.annotations,
+		})
+	}
+
+	config := []byte("{}")
+	configDigest := digest(config)
+	r.blobs[configDigest] = config
+
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"artifactType":  artifactType,
+		"config": map[string]interface{}{
+			"mediaType": configMediaType,
+			"digest":    configDigest,
+			"size":      len(config),
+		},
+		"layers":      descriptors,
+		"annotations": annotations,
+	})
+	manifestDigest := digest(manifest)
+	r.manifests[manifestDigest] = manifest
+
+	if tag != "" {
+		if r.tags[repository] == nil {
+			r.tags[repository] = make(map[string]string)
+		}
+		r.tags[repository][tag] = manifestDigest
+	}
+
+	return manifestDigest
+}
+
+// Yank replaces the ODM metadata of the given repository with the given yanked versions and reasons.
+func (r *Registry) Yank(repository string, yanked map[string]string) {
+	r.SetMetadata(repository, map[string]interface{}{"yanked": yanked})
+}
+
+// SetMetadata replaces the ODM metadata of the given repository with the JSON encoding of metadata.
+func (r *Registry) SetMetadata(repository string, metadata interface{}) {
+	bs, _ := json.Marshal(metadata)
+	r.pushBlobArtifact(repository, "odm.metadata", "application/vnd.odm.metadata.v1+json",
+		"application/vnd.odm.metadata.v1", nil, bs)
+}
+
+// Manifest returns the manifest tagged in the given repository, if any.
+func (r *Registry) Manifest(repository string, tag string) []byte {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.manifests[r.tags[repository][tag]]
+}
+
+func (r *Registry) handle(w http.ResponseWriter, req *http.Request) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.Token != "" {
+		if req.URL.Path == "/token" {
+			if username, password, _ := req.BasicAuth(); username != r.Username || password != r.Password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": r.Token})
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer "+r.Token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/blobs/uploads/"):
+		w.Header().Set("Location", fmt.Sprintf("/v2/%supload-%d", path, len(r.blobs)))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case req.Method == http.MethodPut && strings.Contains(path, "/blobs/uploads/"):
+		blob, _ := io.ReadAll(req.Body)
+		if d := req.URL.Query().Get("digest"); d != digest(blob) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[digest(blob)] = blob
+		w.WriteHeader(http.StatusCreated)
+		return
+	case req.Method == http.MethodPut && strings.Contains(path, "/manifests/"):
+		parts := strings.SplitN(path, "/manifests/", 2)
+		manifest, _ := io.ReadAll(req.Body)
+		manifestDigest := digest(manifest)
+		r.manifests[manifestDigest] = manifest
+		if r.tags[parts[0]] == nil {
+			r.tags[parts[0]] = make(map[string]string)
+		}
+		r.tags[parts[0]][parts[1]] = manifestDigest
+		w.WriteHeader(http.StatusCreated)
+		return
+	case strings.Contains(path, "/manifests/"):
+		parts := strings.SplitN(path, "/manifests/", 2)
+		ref := parts[1]
+		if !strings.HasPrefix(ref, "sha256:") {
+			ref = r.tags[parts[0]][ref]
+		}
+		if manifest, ok := r.manifests[ref]; ok {
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", ref)
+			_, _ = w.Write(manifest)
+			return
+		}
+	case strings.Contains(path, "/blobs/"):
+		parts := strings.SplitN(path, "/blobs/", 2)
+		if blob, ok := r.blobs[parts[1]]; ok {
+			_, _ = w.Write(blob)
+			return
+		}
+	case strings.HasSuffix(path, "/tags/list"):
+		repository := strings.TrimSuffix(path, "/tags/list")
+		if tags, ok := r.tags[repository]; ok {
+			var names []string
+			for tag := range tags {
+				names = append(names, tag)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repository, "tags": names})
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func digest(bs []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(bs))
+}
+
+func tarGz(files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		_ = tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.Close()
+	_ = gw.Close()
+	return buf.Bytes()
+}
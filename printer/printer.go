@@ -28,6 +28,11 @@ func Output(format string, args ...any) {
 	out(PrintWriter, format, args...)
 }
 
+// Warn prints a warning, regardless of log level.
+func Warn(format string, args ...any) {
+	out(LogWriter, "warning: "+format, args...)
+}
+
 func Info(format string, args ...any) {
 	if LogLevel >= InfoLevel {
 		out(LogWriter, format, args...)
@@ -69,3 +74,31 @@ func TracePrinter() io.Writer {
 		return noOpWriter
 	}
 }
+
+// ANSI colors, for Colorize.
+const (
+	Red    = "31"
+	Green  = "32"
+	Yellow = "33"
+)
+
+// Colorize wraps s in the escape codes of the given ANSI color; unless output isn't a terminal, or the NO_COLOR
+// environment variable is set.
+func Colorize(color string, s string) string {
+	if !colorEnabled() {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+func colorEnabled() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := PrintWriter.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,140 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"path/filepath"
+	"strings"
+)
+
+// bundleLocation returns the location of a compiled bundle dependency: the single gzipped tarball at the top of its
+// directory, if any; otherwise, the directory itself.
+func (d Dependency) bundleLocation() string {
+	matches, _ := filepath.Glob(filepath.Join(d.dirPath, "*.tar.gz"))
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	return d.dirPath
+}
+
+// isWasm reports whether the dependency is a bundle of wasm modules; i.e. if its manifest declares wasm modules.
+func (d Dependency) isWasm() bool {
+	if d.dirPath == "" {
+		return false
+	}
+	resolvers, err := utils.ReadWasmResolvers(d.bundleLocation())
+	return err == nil && len(resolvers) > 0
+}
+
+// isBundle reports whether the dependency is a compiled bundle, used as-is. Bundles of wasm modules always are.
+func (d Dependency) isBundle() bool {
+	return d.Bundle || d.isWasm()
+}
+
+// BundleLocations returns the locations of all compiled bundle dependencies of the project, to be loaded by OPA as
+// bundles, as opposed to the data locations of other dependencies. Bundles of wasm modules are only included if
+// includeWasm is true.
+func (p *Project) BundleLocations(includeWasm bool) ([]string, error) {
+	var locations []string
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		if dep.isBundle() && (includeWasm || !dep.isWasm()) {
+			locations = append(locations, dep.bundleLocation())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// WasmBundles returns the locations of all wasm module dependencies in the loaded dependency tree, by the dot-separated
+// path of the dependency.
+func (p *Project) WasmBundles() (map[string]string, error) {
+	bundles := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, path := range p.DependencyPaths() {
+		dep, err := p.findDependency(path)
+		if err != nil {
+			return nil, err
+		}
+		if location := dep.bundleLocation(); dep.isWasm() && !seen[location] {
+			seen[location] = true
+			bundles[path] = location
+		}
+	}
+	return bundles, nil
+}
+
+// wasmEntrypoints returns the entrypoints of the wasm modules of the dependency; none if it isn't a wasm bundle.
+func (d Dependency) wasmEntrypoints() ([]string, error) {
+	if d.dirPath == "" {
+		return nil, nil
+	}
+	resolvers, err := utils.ReadWasmResolvers(d.bundleLocation())
+	if err != nil {
+		return nil, err
+	}
+	entrypoints := make([]string, 0, len(resolvers))
+	for _, resolver := range resolvers {
+		entrypoints = append(entrypoints, resolver.Entrypoint)
+	}
+	return entrypoints, nil
+}
+
+// bundleDirs returns the directories of all compiled bundle dependencies of the project.
+func (p *Project) bundleDirs() ([]string, error) {
+	var dirs []string
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		if dep.isBundle() {
+			dirs = append(dirs, dep.dirPath)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// checkBundleRoots verifies that the roots of compiled bundle dependencies don't overlap the packages of the project's
+// source and other dependencies, as OPA refuses to merge bundles claiming the same part of the data tree.
+func (p *Project) checkBundleRoots() error {
+	var bundleDeps []Dependency
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		if dep.isBundle() {
+			bundleDeps = append(bundleDeps, dep)
+		}
+		return nil
+	})
+	if err != nil || len(bundleDeps) == 0 {
+		return err
+	}
+
+	dataLocations, err := p.DataLocations()
+	if err != nil {
+		return err
+	}
+	var modules []regoModule
+	for _, location := range dataLocations {
+		found, err := scanModules(location)
+		if err != nil {
+			return err
+		}
+		modules = append(modules, found...)
+	}
+
+	for _, dep := range bundleDeps {
+		roots, err := dep.effectiveRoots(false)
+		if err != nil {
+			return err
+		}
+		for _, root := range roots {
+			for _, m := range modules {
+				path := strings.ReplaceAll(strings.TrimPrefix(m.pkg, "data."), ".", "/")
+				if utils.RootsOverlap(root, path) {
+					return errs.New(errs.NamespaceCollision, "bundle root %s of dependency %s (%s) overlaps package %s of %s",
+						displayRoot(root), dep.Name, dep.location(), strings.TrimPrefix(m.pkg, "data."), m.path)
+				}
+			}
+		}
+	}
+	return nil
+}
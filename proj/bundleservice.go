@@ -0,0 +1,37 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/keyring"
+	"github.com/johanfylling/odm/printer"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// bundleServiceScheme prefixes the HTTP(S) URL of a bundle served by an OPA bundle service, e.g.
+// bundle+https://control-plane.example.com/bundles/authz.
+const bundleServiceScheme = "bundle+"
+
+// bundleServiceUrl returns the URL of the bundle at the bundle service location.
+func bundleServiceUrl(location string) (string, error) {
+	rest := strings.TrimPrefix(location, bundleServiceScheme)
+	u, err := url.Parse(rest)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", errs.New(errs.InvalidLocation,
+			"invalid bundle service location %s: expected bundle+http(s)://<service>/<resource>", location)
+	}
+	return rest, nil
+}
+
+// bundleServiceHeader returns the header of requests for the bundle at url, authenticating with the token of its host,
+// if any, as a bearer token; the way OPA authenticates with bundle services configured with bearer credentials.
+func bundleServiceHeader(url string) http.Header {
+	header := http.Header{}
+	host := keyring.Host(url)
+	if credential, ok := gitCredential(host); ok {
+		printer.Debug("Authenticating with bearer token for bundle service %s", host)
+		header.Set("Authorization", "Bearer "+credential.Secret)
+	}
+	return header
+}
@@ -0,0 +1,126 @@
+package proj
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateBundleService(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "policy.rego"), []byte("package authz"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var tarball bytes.Buffer
+	if err := utils.CreateTarGz(&tarball, srcDir, []string{srcDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/bundles/authz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write(tarball.Bytes())
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		note            string
+		location        string
+		token           string
+		expectedErr     string
+		expectedErrCode errs.Code
+	}{
+		{
+			note:     "bearer token",
+			location: "bundle+" + server.URL + "/bundles/authz",
+			token:    "secret",
+		},
+		{
+			note:            "no token",
+			location:        "bundle+" + server.URL + "/bundles/authz",
+			expectedErr:     "unexpected status 401",
+			expectedErrCode: errs.FetchFailed,
+		},
+		{
+			note:            "missing bundle",
+			location:        "bundle+" + server.URL + "/bundles/other",
+			token:           "secret",
+			expectedErr:     "unexpected status 404",
+			expectedErrCode: errs.FetchFailed,
+		},
+		{
+			note:            "not an HTTP(S) service",
+			location:        "bundle+ftp://" + u.Host + "/bundles/authz",
+			token:           "secret",
+			expectedErr:     "invalid bundle service location",
+			expectedErrCode: errs.InvalidLocation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			config := fmt.Sprintf("gitTokens:\n  %s:\n    env: BUNDLE_TOKEN\n", u.Host)
+			if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("ODM_CONFIG", configPath)
+			t.Setenv("ODM_KEYRING", "file")
+			t.Setenv("NETRC", filepath.Join(t.TempDir(), "netrc"))
+			t.Setenv("ODM_GIT_TOKEN", "")
+			t.Setenv("BUNDLE_TOKEN", tc.token)
+
+			files := map[string]string{
+				"opa.project": fmt.Sprintf("dependencies:\n  authz:\n    location: %s\n    namespace: false\n", tc.location),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadAndLoadProject(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = project.UpdateWithOptions(UpdateOptions{})
+				if tc.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected error containing %q, got %v", tc.expectedErr, err)
+					}
+					if code := errs.CodeOf(err); code != tc.expectedErrCode {
+						t.Fatalf("expected error code %s, got %s", tc.expectedErrCode.ID, code.ID)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				bs, err := os.ReadFile(filepath.Join(project.Dependencies["authz"].dir(dependenciesDir(root)), "policy.rego"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(bs) != "package authz" {
+					t.Fatalf("expected extracted policy, got %s", bs)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
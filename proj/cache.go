@@ -0,0 +1,220 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// remoteCache is a shared cache of fetched dependencies, in object storage or on an HTTP server, keyed by the immutable
+// identity of their content: the manifest digest of OCI artifacts, and the commit or tag object of git references.
+// Dependencies are only stored after their content has been verified against its identity when fetched from origin.
+// Cache failures are never fatal; the dependency is fetched from origin instead.
+type remoteCache struct {
+	location string
+}
+
+func newRemoteCache(location string) *remoteCache {
+	if location == "" {
+		return nil
+	}
+	if !strings.HasSuffix(location, "/") {
+		location += "/"
+	}
+	return &remoteCache{location: location}
+}
+
+func (c *remoteCache) objectLocation(key string) string {
+	return c.location + key + ".tar.gz"
+}
+
+// restore extracts the cached content of the given key into targetDir. Returns false on a cache miss, or on any
+// failure, in which case targetDir is left empty.
+func (c *remoteCache) restore(key string, targetDir string) bool {
+	if c == nil {
+		return false
+	}
+
+	tmp, err := os.CreateTemp("", "odm-cache-*.tar.gz")
+	if err != nil {
+		return false
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := utils.DownloadObject(c.objectLocation(key), tmp.Name()); err != nil {
+		printer.Debug("Cache miss for %s: %s", key, err)
+		return false
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := utils.ExtractTarGz(f, targetDir); err != nil {
+		printer.Info("Ignoring corrupt cache entry %s: %s", key, err)
+		clearDir(targetDir)
+		return false
+	}
+
+	printer.Debug("Restored %s from cache", key)
+	return true
+}
+
+// has reports whether the cache holds content under the given key; false on any failure.
+func (c *remoteCache) has(key string) bool {
+	if c == nil {
+		return false
+	}
+	exists, err := utils.ObjectExists(c.objectLocation(key))
+	if err != nil {
+		printer.Debug("Failed to look up %s in cache: %s", key, err)
+	}
+	return exists
+}
+
+// store uploads the content of dir to the cache under the given key.
+func (c *remoteCache) store(key string, dir string) {
+	if c == nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp("", "odm-cache-*.tar.gz")
+	if err != nil {
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	err = utils.CreateTarGz(tmp, dir, []string{dir})
+	_ = tmp.Close()
+	if err != nil {
+		printer.Info("Failed to archive %s for the cache: %s", key, err)
+		return
+	}
+
+	if err := utils.UploadObject(tmp.Name(), c.objectLocation(key), utils.ObjectMetadata{ContentType: "application/gzip"}); err != nil {
+		printer.Info("Failed to populate cache with %s: %s", key, err)
+		return
+	}
+	printer.Debug("Stored %s in cache", key)
+}
+
+func clearDir(dir string) {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		_ = os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+}
+
+func ociCacheKey(digest string) string {
+	return "oci/" + strings.TrimPrefix(digest, "sha256:")
+}
+
+func gitCacheKey(hash plumbing.Hash) string {
+	return "git/" + hash.String()
+}
+
+// remoteGitHash returns the hash the given tag or branch, or HEAD if no reference is given, refers to in the remote
+// repository, without fetching any objects. Tags take precedence over branches of the same name, unless the reference
+// is explicitly a branch; full commit hashes refer to themselves. The references are replayed from, or recorded in, the
+// recording, if any.
+func remoteGitHash(recording *ResolutionRecording, url string, ref gitRef) (plumbing.Hash, error) {
+	if ref.isCommit() {
+		return plumbing.NewHash(ref.name), nil
+	}
+	refs, err := listRemoteGitRefs(recording, url)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if ref.name == "" {
+		return resolveRemoteRef(refs, url, plumbing.HEAD)
+	}
+	if tag := ref.tag(); tag != "" {
+		if hash, err := resolveRemoteRef(refs, url, plumbing.NewTagReferenceName(tag)); err == nil {
+			return hash, nil
+		}
+	}
+	return resolveRemoteRef(refs, url, plumbing.NewBranchReferenceName(ref.name))
+}
+
+// listRemoteGitRefs lists the references of the remote repository, as replayed from, or recorded in, the recording.
+func listRemoteGitRefs(recording *ResolutionRecording, url string) ([]*plumbing.Reference, error) {
+	refs, err := recording.remoteGitRefs(url, func() ([]*plumbing.Reference, error) {
+		remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{url}})
+		listCtx, cancel := utils.FetchContext()
+		defer cancel()
+		return remote.ListContext(listCtx, &git.ListOptions{Auth: gitAuth(url)})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list references of %s: %w", url, err)
+	}
+	return refs, nil
+}
+
+func resolveRemoteRef(refs []*plumbing.Reference, url string, name plumbing.ReferenceName) (plumbing.Hash, error) {
+	// HEAD is usually listed as a symbolic reference to the default branch
+	for i := 0; i <= len(refs); i++ {
+		var target plumbing.ReferenceName
+		for _, ref := range refs {
+			if ref.Name() != name {
+				continue
+			}
+			if ref.Type() == plumbing.HashReference {
+				return ref.Hash(), nil
+			}
+			target = ref.Target()
+		}
+		if target == "" {
+			break
+		}
+		name = target
+	}
+	return plumbing.ZeroHash, fmt.Errorf("reference %s not found in %s", name, url)
+}
+
+// localGitHash returns the hash the given tag or branch, or HEAD if no reference or a commit hash is given, refers to in
+// the repository in dir.
+func localGitHash(dir string, gitRef gitRef) (plumbing.Hash, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if gitRef.name == "" || gitRef.isCommit() {
+		head, err := repo.Head()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return head.Hash(), nil
+	}
+	if tag := gitRef.tag(); tag != "" {
+		if ref, err := repo.Reference(plumbing.NewTagReferenceName(tag), false); err == nil {
+			return ref.Hash(), nil
+		}
+	}
+	ref, err := repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, gitRef.name), true)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
+
+func isGitTag(repo *git.Repository, name string) bool {
+	_, err := repo.Tag(name)
+	return err == nil
+}
+
+// isLocalGitTag returns true if name is a tag of the repository in dir.
+func isLocalGitTag(dir string, name string) bool {
+	repo, err := git.PlainOpen(dir)
+	return err == nil && isGitTag(repo, name)
+}
@@ -0,0 +1,171 @@
+package proj
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"github.com/johanfylling/odm/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cacheServer is an in-memory HTTP object store.
+type cacheServer struct {
+	mtx     sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *cacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		if object, ok := s.objects[r.URL.Path]; ok {
+			_, _ = w.Write(object)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// tamper replaces the content of all cached objects with the given files.
+func (s *cacheServer) tamper(t *testing.T, files map[string]string) {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := utils.CreateTarGz(&buf, dir, []string{dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for path := range s.objects {
+		s.objects[path] = buf.Bytes()
+	}
+}
+
+func withRemoteCache(t *testing.T) *cacheServer {
+	cache := &cacheServer{objects: make(map[string][]byte)}
+	server := httptest.NewServer(cache)
+	t.Cleanup(server.Close)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf("cache: %s/odm-cache\n", server.URL)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ODM_CONFIG", configPath)
+	return cache
+}
+
+func TestUpdateOciDependencyRemoteCache(t *testing.T) {
+	cache := withRemoteCache(t)
+
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	digest := registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package origin"})
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: oci://%s/org/policy:1.0.0
+    namespace: false
+`, registry.Host()),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package origin" {
+			t.Fatalf("expected package origin, got %s", policy)
+		}
+		key := "/odm-cache/oci/" + strings.TrimPrefix(digest, "sha256:") + ".tar.gz"
+		if _, ok := cache.objects[key]; !ok {
+			t.Fatalf("expected cache to be populated with %s, got %v", key, cache.objects)
+		}
+
+		// Cached content not matching the content hash in the lock file is refused
+		cache.tamper(t, map[string]string{"policy.rego": "package cached"})
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err == nil || !strings.Contains(err.Error(), "quarantined") {
+			t.Fatalf("expected quarantine error, got %v", err)
+		}
+
+		// Cached content is preferred over the registry
+		if err := os.Remove(filepath.Join(root, "opa.project.lock")); err != nil {
+			t.Fatal(err)
+		}
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package cached" {
+			t.Fatalf("expected package cached, got %s", policy)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateGitDependencyRemoteCache(t *testing.T) {
+	cache := withRemoteCache(t)
+
+	upstream := newGitUpstream(t, "policy.rego")
+	commit := upstream.tag("v1", "package origin")
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: git+file://%s#v1
+    namespace: false
+`, upstream.dir),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package origin" {
+			t.Fatalf("expected package origin, got %s", policy)
+		}
+		key := "/odm-cache/git/" + commit.String() + ".tar.gz"
+		if _, ok := cache.objects[key]; !ok {
+			t.Fatalf("expected cache to be populated with %s", key)
+		}
+
+		// Cache entries not holding the expected commit are ignored
+		cache.tamper(t, map[string]string{"policy.rego": "package cached"})
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package origin" {
+			t.Fatalf("expected package origin, got %s", policy)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func updateAndRead(t *testing.T, root string, dependency string, file string) string {
+	t.Helper()
+	project, err := ReadProjectFromFile(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Update(); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(filepath.Join(project.Dependencies[dependency].dir(dependenciesDir(root)), file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bs)
+}
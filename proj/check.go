@@ -0,0 +1,63 @@
+package proj
+
+import "strings"
+
+// AvailableUpdate is a locked dependency an update would resolve to a newer revision.
+type AvailableUpdate struct {
+	// Path is the dot-separated path of the dependency in the dependency tree
+	Path string `json:"path"`
+	// Location is the location as declared, with variables expanded
+	Location string `json:"location"`
+	// Current is the locked git commit or OCI manifest digest
+	Current string `json:"current"`
+	// Latest is the git commit or OCI manifest digest an update would resolve to
+	Latest string `json:"latest"`
+	// CurrentTag is the locked tag a version range resolved to, if any
+	CurrentTag string `json:"currentTag,omitempty"`
+	// LatestTag is the tag a version range would resolve to, if any
+	LatestTag string `json:"latestTag,omitempty"`
+}
+
+// CheckUpdates returns the locked dependencies an update would resolve to newer revisions: git dependencies tracking a
+// branch that has moved on, and OCI dependencies whose version range, or tag, resolves to another manifest. As when
+// planning, only remote metadata is queried. Dependencies pinned to an exact revision, or not locked yet, are never
+// reported.
+func (p *Project) CheckUpdates() ([]AvailableUpdate, error) {
+	lock, err := ReadLockFile(lockFilePath(p.filePath))
+	if err != nil {
+		return nil, err
+	}
+	// Planning against an empty lock resolves every dependency as a refreshing update would
+	latest, err := p.plan(newLock(lock.filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []AvailableUpdate
+	for _, planned := range latest.Dependencies {
+		locked, ok := lock.Get(planned.Location)
+		if !ok {
+			continue
+		}
+		update := AvailableUpdate{Path: planned.Path, Location: planned.Location, Latest: planned.Revision}
+		switch {
+		case strings.HasPrefix(planned.Source, "git+"):
+			// Tags are locked to the commit they pointed at; a moved tag isn't a newer version
+			if locked.Branch == "" || locked.Commit == "" || locked.Commit == planned.Revision {
+				continue
+			}
+			update.Current = locked.Commit
+		case strings.HasPrefix(planned.Source, "oci://"):
+			if locked.Digest == "" || locked.Digest == planned.Revision {
+				continue
+			}
+			update.Current = locked.Digest
+			update.CurrentTag = locked.Tag
+			update.LatestTag = planned.Tag
+		default:
+			continue
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
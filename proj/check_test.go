@@ -0,0 +1,92 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckUpdates(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	v10 := registry.Push("org/ranged", "1.0.0", map[string]string{"/policy.rego": "package ranged"})
+	registry.Push("org/exact", "1.0.0", map[string]string{"/policy.rego": "package exact"})
+
+	upstream := newGitUpstream(t, "policy.rego")
+	first := upstream.tag("v1", "package floating").String()
+
+	ranged := fmt.Sprintf("oci://%s/org/ranged:^1.0", registry.Host())
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  ranged:
+    location: %s
+    namespace: false
+  exact:
+    location: oci://%s/org/exact:1.0.0
+    namespace: false
+  floating:
+    location: git+file://%s
+    namespace: false
+  tagged:
+    location: git+file://%s#v1
+    namespace: false
+`, ranged, registry.Host(), upstream.dir, upstream.dir),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadAndLoadProject(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.UpdateWithOptions(UpdateOptions{Refresh: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		updates, err := project.CheckUpdates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(updates) != 0 {
+			t.Fatalf("expected no updates, got %+v", updates)
+		}
+
+		v11 := registry.Push("org/ranged", "1.1.0", map[string]string{"/policy.rego": "package ranged.v1_1"})
+		registry.Push("org/exact", "1.1.0", map[string]string{"/policy.rego": "package exact"})
+		second := upstream.commit("package floating\n\nallow := true").String()
+
+		updates, err = project.CheckUpdates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []AvailableUpdate{
+			{Path: "floating", Location: "git+file://" + upstream.dir, Current: first, Latest: second},
+			{Path: "ranged", Location: ranged, Current: v10, Latest: v11, CurrentTag: "1.0.0", LatestTag: "1.1.0"},
+		}
+		if !reflect.DeepEqual(updates, expected) {
+			t.Fatalf("expected updates:\n%+v\ngot:\n%+v", expected, updates)
+		}
+
+		// Checking doesn't fetch anything, nor touch the lock
+		bs, err := os.ReadFile(filepath.Join(project.Dependencies["floating"].dir(dependenciesDir(root)), "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package floating" {
+			t.Fatalf("expected fetched content to be unchanged, got %s", bs)
+		}
+		lock, err := ReadLockFile(lockFilePath(project.filePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lock.Get(ranged); locked.Digest != v10 {
+			t.Fatalf("expected locked digest %s, got %v", v10, locked)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
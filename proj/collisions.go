@@ -0,0 +1,117 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checkPackageCollisions verifies that no dependency shadows, or merges into, the packages of the project itself: a
+// package of a dependency, as placed in the project's data tree, must not be a first-party package, nor be nested in or
+// enclose one. Compiled bundle dependencies are verified by checkBundleRoots.
+func (p *Project) checkPackageCollisions() error {
+	own, err := p.ownModules()
+	if err != nil || len(own) == 0 {
+		return err
+	}
+
+	type collision struct {
+		pkg, ownPkg string
+	}
+	seen := make(map[collision]bool)
+	var collisions []string
+	err = p.walkUniqueDependencies(func(dep Dependency) error {
+		if dep.isBundle() {
+			return nil
+		}
+		locations, err := dep.dataLocations()
+		if err != nil {
+			return err
+		}
+		for _, location := range locations {
+			modules, err := scanModules(location)
+			if err != nil {
+				return err
+			}
+			for _, m := range modules {
+				for _, o := range own {
+					c := collision{m.pkg, o.pkg}
+					if seen[c] || !utils.RootsOverlap(packagePath(m.pkg), packagePath(o.pkg)) {
+						continue
+					}
+					seen[c] = true
+					collisions = append(collisions, fmt.Sprintf("package %s of dependency %s (%s), in %s, collides with package %s, in %s",
+						strings.TrimPrefix(m.pkg, "data."), dep.Name, dep.location(), m.path, strings.TrimPrefix(o.pkg, "data."),
+						o.path))
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil || len(collisions) == 0 {
+		return err
+	}
+
+	sort.Strings(collisions)
+	return errs.New(errs.NamespaceCollision, "dependencies collide with packages of the project:\n  %s",
+		strings.Join(collisions, "\n  "))
+}
+
+// ownModules returns the Rego modules of the project's own source; leaving out the .opa directory, and local
+// dependencies located within the project directory.
+func (p *Project) ownModules() ([]regoModule, error) {
+	projDir := p.Dir()
+	dirs := []string{projDir}
+	if len(p.SourceDirs) > 0 {
+		dirs = dirs[:0]
+		for _, dir := range p.SourceDirs {
+			dirs = append(dirs, filepath.Join(projDir, dir))
+		}
+	}
+
+	excluded := []string{filepath.Join(projDir, dotOpaDir)}
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		if strings.HasPrefix(dep.location(), "file:") {
+			path, err := dep.localPath(projDir)
+			if err != nil {
+				return err
+			}
+			excluded = append(excluded, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []regoModule
+	for _, dir := range utils.FilterExistingFiles(dirs) {
+		found, err := scanModules(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range found {
+			if !withinDirs(m.path, excluded) {
+				modules = append(modules, m)
+			}
+		}
+	}
+	return modules, nil
+}
+
+// packagePath returns the slash-separated path of the package in the data tree.
+func packagePath(pkg string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(pkg, "data."), ".", "/")
+}
+
+func withinDirs(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
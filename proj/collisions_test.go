@@ -0,0 +1,101 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/errs"
+	"strings"
+	"testing"
+)
+
+func TestUpdatePackageCollisions(t *testing.T) {
+	tests := []struct {
+		note     string
+		project  string
+		expected string
+	}{
+		{
+			note: "namespaced",
+			project: `source: src
+dependencies:
+  lib: file:///lib
+`,
+		},
+		{
+			note: "merged into first-party package",
+			project: `source: src
+dependencies:
+  lib:
+    location: file:///lib
+    namespace: false
+`,
+			expected: "package main of dependency lib (file:///lib)",
+		},
+		{
+			note: "nested in first-party package",
+			project: `source: src
+dependencies:
+  main: file:///lib
+`,
+			expected: "package main.main of dependency main (file:///lib)",
+		},
+		{
+			note: "enclosing first-party package",
+			project: `source: src
+dependencies:
+  lib:
+    location: file:///lib
+    namespace: main.lib
+`,
+			expected: "package main.lib.main of dependency lib (file:///lib)",
+		},
+		{
+			note: "outside source",
+			project: `source: other
+dependencies:
+  lib:
+    location: file:///lib
+    namespace: false
+`,
+		},
+		{
+			note: "local dependency within project",
+			project: `dependencies:
+  shared:
+    location: file:///shared
+    namespace: false
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project":       tc.project,
+				"src/main.rego":     "package main\n\nallow := true",
+				"src/main/lib.rego": "package main.lib\n\nx := 1",
+				"other/other.rego":  "package other",
+				"lib/main.rego":     "package main\n\nallow := false",
+				"shared/lib.rego":   "package shared",
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = project.Update()
+				if tc.expected == "" {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				if !errs.Is(err, errs.NamespaceCollision) || !strings.Contains(err.Error(), tc.expected) {
+					t.Fatalf("expected namespace collision of %s, got %v", tc.expected, err)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/oci"
+)
+
+// Deprecation is a notice shown to users of a deprecated dependency, declared by the dependency's project file or by
+// its registry's metadata.
+type Deprecation struct {
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	// Replacement is the location of a suggested replacement, if any
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+func (d Deprecation) String() string {
+	notice := d.Message
+	if notice == "" {
+		notice = "no reason given"
+	}
+	if d.Replacement != "" {
+		notice += "; use " + d.Replacement + " instead"
+	}
+	return notice
+}
+
+func deprecationFromOci(d *oci.Deprecation) *Deprecation {
+	if d == nil {
+		return nil
+	}
+	return &Deprecation{Message: d.Message, Replacement: d.Replacement}
+}
+
+// deprecation returns the deprecation of the fetched dependency, as declared by its project file, or else as recorded
+// from its registry in the lock file; nil if not deprecated.
+func (d Dependency) deprecation(lock *Lock) *Deprecation {
+	if d.Project != nil && d.Project.Deprecated != nil {
+		return d.Project.Deprecated
+	}
+	if locked, ok := lock.Get(d.location()); ok {
+		return locked.Deprecation
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDependencyDeprecation(t *testing.T) {
+	t.Setenv("ODM_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/old", "1.0.0", map[string]string{"/policy.rego": "package old"})
+	registry.Push("org/lib", "1.0.0", map[string]string{
+		"/opa.project": `name: lib
+deprecated:
+  message: no longer maintained
+`,
+		"/policy.rego": "package lib",
+	})
+	registry.SetMetadata("org/old", map[string]interface{}{
+		"deprecatedVersions": map[string]interface{}{
+			"1.0.0": map[string]string{"message": "insecure", "replacement": "oci://example.com/org/new:^2.0"},
+		},
+	})
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  current: oci://%[1]s/org/lib:1.0.0
+  old: oci://%[1]s/org/old:1.0.0
+`, registry.Host()),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		project, err = ReadAndLoadProject(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]*Deprecation{
+			"current": {Message: "no longer maintained"},
+			"old":     {Message: "insecure", Replacement: "oci://example.com/org/new:^2.0"},
+		}
+		for _, path := range project.DependencyPaths() {
+			details, err := project.DependencyDetails(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(details.Deprecation, expected[path]) {
+				t.Fatalf("expected deprecation %v of %s, got %v", expected[path], path, details.Deprecation)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeprecationString(t *testing.T) {
+	tests := []struct {
+		deprecation Deprecation
+		expected    string
+	}{
+		{Deprecation{}, "no reason given"},
+		{Deprecation{Message: "insecure"}, "insecure"},
+		{Deprecation{Message: "insecure", Replacement: "lib/new"}, "insecure; use lib/new instead"},
+	}
+
+	for _, tc := range tests {
+		if actual := tc.deprecation.String(); actual != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/johanfylling/odm/config"
+	"sort"
+	"strings"
+)
+
+// DependencyDetails describes a resolved dependency, as fetched by the last update.
+type DependencyDetails struct {
+	Name string
+	// Path is the dot-separated path of the dependency in the dependency tree
+	Path string
+	// Location is the location as declared in the enclosing project file
+	Location string
+	// ResolvedLocation is the location with variables and short names expanded
+	ResolvedLocation string
+	// Revision is the git commit or OCI digest that was fetched, if any
+	Revision string
+	// Namespace is the full namespace of the dependency's packages; empty if not namespaced
+	Namespace string
+	Dir       string
+	// Dependencies are the dependency's own (transitive) dependencies, by name
+	Dependencies map[string]string
+	// Project is the dependency's project file, if any
+	Project *Project
+	// Deprecation is the dependency's deprecation notice, if deprecated
+	Deprecation *Deprecation
+	// WasmEntrypoints are the entrypoints of the dependency's wasm modules, if a wasm bundle
+	WasmEntrypoints []string
+}
+
+// DependencyDetails returns the details of the dependency at the given dot-separated path in the loaded dependency tree;
+// e.g. 'foo' for a direct dependency, or 'foo.bar' for a dependency declared by 'foo'.
+func (p *Project) DependencyDetails(path string) (*DependencyDetails, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := ReadLockFile(lockFilePath(p.filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	return p.dependencyDetails(path, cfg, lock)
+}
+
+func (p *Project) dependencyDetails(path string, cfg *config.Config, lock *Lock) (*DependencyDetails, error) {
+	dep, err := p.findDependency(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvedLocation, err := cfg.ExpandShortName(dep.location())
+	if err != nil {
+		return nil, err
+	}
+
+	details := &DependencyDetails{
+		Name:             dep.Name,
+		Path:             path,
+		Location:         dep.Location,
+		ResolvedLocation: resolvedLocation,
+		Revision:         dep.revision(resolvedLocation, lock),
+		Dir:              dep.dirPath,
+		Dependencies:     make(map[string]string),
+		Project:          dep.Project,
+		Deprecation:      dep.deprecation(lock),
+	}
+	if p.NamespacingEnabled() && !dep.isBundle() {
+		details.Namespace = dep.fullNamespace()
+	}
+	if details.WasmEntrypoints, err = dep.wasmEntrypoints(); err != nil {
+		return nil, err
+	}
+	if dep.Project != nil {
+		for name, transitive := range dep.Project.Dependencies {
+			details.Dependencies[name] = transitive.Location
+		}
+	}
+
+	return details, nil
+}
+
+func (p *Project) findDependency(path string) (*Dependency, error) {
+	current := p
+	var dep Dependency
+	for i, name := range strings.Split(path, ".") {
+		if current == nil {
+			return nil, fmt.Errorf("dependency %s not found", path)
+		}
+		var ok bool
+		if dep, ok = current.Dependencies[name]; !ok {
+			if i == 0 {
+				return nil, fmt.Errorf("dependency %s not found; available dependencies: %s", path,
+					strings.Join(sortedDependencyNames(p.Dependencies), ", "))
+			}
+			return nil, fmt.Errorf("dependency %s not found", path)
+		}
+		current = dep.Project
+	}
+	return &dep, nil
+}
+
+// DependencyPaths returns the dot-separated paths of all dependencies in the loaded dependency tree, depth first.
+func (p *Project) DependencyPaths() []string {
+	var paths []string
+	if p == nil {
+		return paths
+	}
+	for _, name := range sortedDependencyNames(p.Dependencies) {
+		paths = append(paths, name)
+		for _, path := range p.Dependencies[name].Project.DependencyPaths() {
+			paths = append(paths, name+"."+path)
+		}
+	}
+	return paths
+}
+
+// DependencyDirs returns the directories of all fetched dependencies in the loaded dependency tree, by the dot-separated
+// path of the dependency.
+func (p *Project) DependencyDirs() (map[string]string, error) {
+	dirs := make(map[string]string)
+	for _, path := range p.DependencyPaths() {
+		dep, err := p.findDependency(path)
+		if err != nil {
+			return nil, err
+		}
+		if dep.dirPath != "" {
+			dirs[path] = dep.dirPath
+		}
+	}
+	return dirs, nil
+}
+
+func sortedDependencyNames(deps Dependencies) []string {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// revision returns the fetched git commit, OCI digest, or tarball digest of the dependency, if known.
+func (d Dependency) revision(resolvedLocation string, lock *Lock) string {
+	switch {
+	case strings.HasPrefix(resolvedLocation, "git+"):
+		// The content of subdirectory dependencies is within the repository
+		repo, err := git.PlainOpenWithOptions(d.dirPath, &git.PlainOpenOptions{DetectDotGit: true})
+		if err != nil {
+			return ""
+		}
+		head, err := repo.Head()
+		if err != nil {
+			return ""
+		}
+		return head.Hash().String()
+	case strings.HasPrefix(resolvedLocation, "oci://") || isTarballLocation(resolvedLocation):
+		if locked, ok := lock.Get(d.location()); ok {
+			return locked.Digest
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package proj
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDependencyDetails(t *testing.T) {
+	t.Setenv("ODM_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+
+	depA := DepId("dep_a", "file://dep_a")
+	depB := DepId("dep_a.dep_b", "file://dep_b")
+
+	files := map[string]string{
+		"opa.project": `name: proj
+dependencies:
+  dep_a: file://dep_a
+`,
+		filepath.Join(".opa", "dependencies", depA, "opa.project"): `name: dep_a
+version: 1.0.0
+source: src
+build:
+  entrypoints:
+    - dep_a/allow
+dependencies:
+  dep_b: file://dep_b`,
+		filepath.Join(".opa", "dependencies", depA, "src", "policy.rego"): `package dep_a`,
+		filepath.Join(".opa", "dependencies", depB, "policy.rego"):        `package dep_b`,
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadAndLoadProject(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		details, err := project.DependencyDetails("dep_a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if details.Namespace != "dep_a" || details.Dir != filepath.Join(root, ".opa", "dependencies", depA) {
+			t.Fatalf("unexpected details: %+v", details)
+		}
+		if expected := map[string]string{"dep_b": "file://dep_b"}; !reflect.DeepEqual(details.Dependencies, expected) {
+			t.Fatalf("expected dependencies %v, got %v", expected, details.Dependencies)
+		}
+		if details.Project == nil || details.Project.Version != "1.0.0" ||
+			!reflect.DeepEqual(details.Project.Build.Entrypoints, []string{"dep_a/allow"}) {
+			t.Fatalf("unexpected project: %+v", details.Project)
+		}
+
+		details, err = project.DependencyDetails("dep_a.dep_b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if details.Namespace != "dep_a.dep_b" || details.Dir != filepath.Join(root, ".opa", "dependencies", depB) {
+			t.Fatalf("unexpected details: %+v", details)
+		}
+
+		if _, err := project.DependencyDetails("dep_c"); err == nil {
+			t.Fatal("expected error for unknown dependency")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+package proj
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const downloadsDir = "downloads"
+
+// downloadState is the state of the last download of an HTTP(S) tarball location, kept next to the downloaded tarball.
+type downloadState struct {
+	Location string `json:"location"`
+	utils.Validators
+}
+
+// downloadPaths returns the paths of the last downloaded tarball of the HTTP(S) location, and of its state.
+func downloadPaths(rootDir string, location string) (string, string) {
+	sum := sha256.Sum256([]byte(location))
+	base := filepath.Join(rootDir, dotOpaDir, downloadsDir, hex.EncodeToString(sum[:]))
+	return base + ".tar.gz", base + ".json"
+}
+
+// downloadHttp downloads the tarball at the HTTP(S) location to the file at dst, requested with the given header, if
+// any. The tarball of the last download is kept, along with its ETag and Last-Modified validators; and re-used, instead
+// of downloaded again, if the server reports it unchanged.
+func (ctx *updateContext) downloadHttp(location string, header http.Header, dst string) error {
+	archivePath, statePath := downloadPaths(ctx.rootDir, location)
+
+	var state downloadState
+	if bs, err := os.ReadFile(statePath); err == nil && utils.FileExists(archivePath) {
+		if err := json.Unmarshal(bs, &state); err != nil || state.Location != location {
+			printer.Debug("Ignoring download state of %s: %v", location, err)
+			state = downloadState{}
+		}
+	}
+
+	validators, modified, err := utils.DownloadFileIfModified(location, dst, state.Validators, header)
+	if err != nil {
+		return err
+	}
+	if !modified {
+		printer.Debug("Using previously downloaded %s", location)
+		return copyFile(archivePath, dst)
+	}
+
+	_ = os.Remove(statePath)
+	if validators.IsZero() {
+		_ = os.Remove(archivePath)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return err
+	}
+	// Renamed into place, as the same location may be downloaded concurrently for dependencies of other namespaces
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), "download-*")
+	if err != nil {
+		return err
+	}
+	_ = tmp.Close()
+	if err := copyFile(dst, tmp.Name()); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), archivePath); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	bs, err := json.Marshal(downloadState{Location: location, Validators: validators})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, bs, 0644)
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
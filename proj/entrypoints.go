@@ -0,0 +1,54 @@
+package proj
+
+import (
+	"strings"
+)
+
+// normalizeEntrypoint returns the entrypoint as a slash-separated path without the data root; e.g. lib/http/allow for
+// data.lib.http.allow.
+func normalizeEntrypoint(entrypoint string) string {
+	if strings.HasPrefix(entrypoint, "data.") || !strings.Contains(entrypoint, "/") {
+		entrypoint = strings.ReplaceAll(strings.TrimPrefix(entrypoint, "data."), ".", "/")
+	}
+	return strings.Trim(entrypoint, "/")
+}
+
+// Entrypoints returns the entrypoints of the project's bundle: those configured by 'build.entrypoints', followed by the
+// entrypoints re-exported from dependencies, placed in the namespace of their dependency.
+func (p *Project) Entrypoints() []string {
+	entrypoints := append([]string{}, p.Build.Entrypoints...)
+	seen := make(map[string]bool)
+	for _, entrypoint := range entrypoints {
+		seen[normalizeEntrypoint(entrypoint)] = true
+	}
+
+	for _, name := range sortedDependencyNames(p.Dependencies) {
+		dep := p.Dependencies[name]
+		namespace := ""
+		if p.NamespacingEnabled() && !dep.isBundle() {
+			namespace = strings.ReplaceAll(dep.fullNamespace(), ".", "/")
+		}
+		for _, entrypoint := range dep.Entrypoints {
+			entrypoint = normalizeEntrypoint(entrypoint)
+			if namespace != "" {
+				entrypoint = namespace + "/" + entrypoint
+			}
+			if !seen[entrypoint] {
+				seen[entrypoint] = true
+				entrypoints = append(entrypoints, entrypoint)
+			}
+		}
+	}
+	return entrypoints
+}
+
+// EntrypointPaths returns the entrypoints of the project's bundle as slash-separated paths, as decisions are referenced
+// by; e.g. lib/http/allow.
+func (p *Project) EntrypointPaths() []string {
+	entrypoints := p.Entrypoints()
+	paths := make([]string, 0, len(entrypoints))
+	for _, entrypoint := range entrypoints {
+		paths = append(paths, normalizeEntrypoint(entrypoint))
+	}
+	return paths
+}
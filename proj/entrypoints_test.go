@@ -0,0 +1,78 @@
+package proj
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEntrypoints(t *testing.T) {
+	tests := []struct {
+		note     string
+		project  string
+		expected []string
+	}{
+		{
+			note: "build entrypoints only",
+			project: `build:
+  entrypoints: [main/allow]
+dependencies:
+  http: file:///http
+`,
+			expected: []string{"main/allow"},
+		},
+		{
+			note: "namespaced re-exports",
+			project: `build:
+  entrypoints: [main/allow]
+dependencies:
+  http:
+    location: file:///http
+    entrypoints: [http/allow, data.http.deny]
+  jwt:
+    location: file:///jwt
+    namespace: auth.jwt
+    entrypoints: [jwt.valid]
+`,
+			expected: []string{"main/allow", "http/http/allow", "http/http/deny", "auth/jwt/jwt/valid"},
+		},
+		{
+			note: "namespacing disabled",
+			project: `build:
+  entrypoints: [http/allow]
+dependencies:
+  http:
+    location: file:///http
+    namespace: false
+    entrypoints: [http/allow, http/deny]
+`,
+			expected: []string{"http/allow", "http/deny"},
+		},
+		{
+			note: "compiled bundle",
+			project: `dependencies:
+  authz:
+    location: file:///authz
+    bundle: true
+    entrypoints: [authz/allow]
+`,
+			expected: []string{"authz/allow"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			err := withTempFiles(map[string]string{"opa.project": tc.project}, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual := project.Entrypoints(); !reflect.DeepEqual(actual, tc.expected) {
+					t.Fatalf("expected entrypoints %v, got %v", tc.expected, actual)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/printer"
+	"strings"
+)
+
+// excludeDependencies removes the dependencies of the dependency's project that are excluded by the root project: by
+// their dot-separated path in the dependency tree, or by name. The names of the removed dependencies are recorded in
+// the project.
+func (d Dependency) excludeDependencies(project *Project) {
+	root := d
+	for root.ParentDependency != nil {
+		root = *root.ParentDependency
+	}
+	if len(root.exclusions) == 0 {
+		return
+	}
+	prefix := d.path() + "."
+	for _, name := range sortedDependencyNames(project.Dependencies) {
+		if root.exclusions[prefix+name] || root.exclusions[name] {
+			printer.Debug("Excluding dependency %s%s", prefix, name)
+			delete(project.Dependencies, name)
+			project.excluded = append(project.excluded, name)
+		}
+	}
+}
+
+// checkExclusions warns of the exclusions of the project not applying to any transitive dependency of the loaded
+// dependency tree; and of references, in the modules of dependencies declaring excluded dependencies, to packages no
+// remaining module provides, as they may have been provided by the excluded dependencies. References are found
+// lexically, and so may also be to data documents.
+func (p *Project) checkExclusions() error {
+	if len(p.Exclude) == 0 {
+		return nil
+	}
+
+	used := make(map[string]bool)
+	var declaring []Dependency
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		if dep.Project == nil || len(dep.Project.excluded) == 0 {
+			return nil
+		}
+		for _, name := range dep.Project.excluded {
+			used[dep.path()+"."+name] = true
+			used[name] = true
+		}
+		declaring = append(declaring, dep)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, excluded := range p.Exclude {
+		if !used[excluded] {
+			printer.Warn("exclusion of %s applies to no transitive dependency", excluded)
+		}
+	}
+	if len(declaring) == 0 {
+		return nil
+	}
+
+	provided, err := p.providedPackages()
+	if err != nil {
+		return err
+	}
+	for _, dep := range declaring {
+		if dep.isBundle() {
+			continue
+		}
+		locations, err := dep.dataLocations()
+		if err != nil {
+			return err
+		}
+		missing := make(map[string]bool)
+		for _, location := range locations {
+			modules, err := scanModules(location)
+			if err != nil {
+				return err
+			}
+			for _, m := range modules {
+				for _, ref := range m.refs {
+					if !isProvided(ref, provided) {
+						missing[strings.TrimPrefix(ref, "data.")] = true
+					}
+				}
+			}
+		}
+		if len(missing) > 0 {
+			refs := sortedKeys(missing)
+			printer.Warn("dependency %s (%s) references %s, provided by no remaining package; possibly by its excluded "+
+				"dependencies %s", dep.path(), dep.location(), strings.Join(refs, ", "),
+				strings.Join(dep.Project.excluded, ", "))
+		}
+	}
+	return nil
+}
+
+// providedPackages returns the packages of the project's own modules, and of the modules of all its dependencies.
+func (p *Project) providedPackages() ([]string, error) {
+	own, err := p.ownModules()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, m := range own {
+		seen[m.pkg] = true
+	}
+	err = p.walkUniqueDependencies(func(dep Dependency) error {
+		if dep.isBundle() {
+			return nil
+		}
+		locations, err := dep.dataLocations()
+		if err != nil {
+			return err
+		}
+		for _, location := range locations {
+			modules, err := scanModules(location)
+			if err != nil {
+				return err
+			}
+			for _, m := range modules {
+				seen[m.pkg] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(seen), nil
+}
+
+// isProvided returns true if the data reference is to, or within, one of the given packages; or to a prefix of one.
+func isProvided(ref string, packages []string) bool {
+	for _, pkg := range packages {
+		if within(ref, pkg) || within(pkg, ref) {
+			return true
+		}
+	}
+	return false
+}
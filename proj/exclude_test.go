@@ -0,0 +1,105 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"gopkg.in/yaml.v3"
+	"os"
+	"testing"
+)
+
+func TestUpdateExclude(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	registry.Push("org/helper", "1.0.0", map[string]string{"/helper.rego": "package helper\n\nallow := true"})
+	helper := fmt.Sprintf("oci://%s/org/helper:1.0.0", registry.Host())
+
+	tests := []struct {
+		note     string
+		exclude  string
+		excluded bool
+	}{
+		{
+			note: "no exclusion",
+		},
+		{
+			note:     "by name",
+			exclude:  "exclude:\n- helper",
+			excluded: true,
+		},
+		{
+			note:     "by path",
+			exclude:  "exclude:\n- a.helper",
+			excluded: true,
+		},
+		{
+			note:    "other path",
+			exclude: "exclude:\n- b.helper",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`%s
+dependencies:
+  a:
+    location: file:/a
+    namespace: false
+`, tc.exclude),
+				"a/opa.project": "dependencies:\n  helper:\n    location: " + helper + "\n    namespace: false\n",
+				"a/a.rego":      "package a\n\nallow := data.helper.allow",
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				lock, err := ReadLockFile(lockFilePath(project.filePath))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, locked := lock.Get(helper); locked == tc.excluded {
+					t.Fatalf("expected helper locked: %v, got %v", !tc.excluded, lock.Dependencies)
+				}
+
+				entries, err := os.ReadDir(dependenciesDir(root))
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := 2
+				if tc.excluded {
+					expected = 1
+				}
+				if len(entries) != expected {
+					t.Fatalf("expected %d fetched dependencies, got %d", expected, len(entries))
+				}
+
+				project, err = ReadAndLoadProject(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				a := project.Dependencies["a"].Project
+				if _, ok := a.Dependencies["helper"]; ok == tc.excluded {
+					t.Fatalf("expected helper loaded: %v, got %v", !tc.excluded, a.Dependencies)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalProjectInvalidExclude(t *testing.T) {
+	var project Project
+	if err := yaml.Unmarshal([]byte("exclude:\n- \"\"\n"), &project); !errs.Is(err, errs.InvalidProject) {
+		t.Fatalf("expected invalid project error, got %v", err)
+	}
+}
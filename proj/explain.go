@@ -0,0 +1,123 @@
+package proj
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// ExplainTags is the event of the available versions of a dependency being listed
+	ExplainTags = "tags"
+	// ExplainConstraint is the event of a dependency in the tree requiring a version, or version range, of another
+	ExplainConstraint = "constraint"
+	// ExplainRevision is the event of a dependency in the tree requiring a revision of another that takes no part in
+	// version resolution, such as a branch or digest
+	ExplainRevision = "revision"
+	// ExplainSelected is the event of a single version being selected for a dependency constrained in multiple places
+	ExplainSelected = "selected"
+	// ExplainConflict is the event of a dependency being required at conflicting versions, or revisions
+	ExplainConflict = "conflict"
+	// ExplainOverride is the event of the location of a transitive dependency being forced by an override
+	ExplainOverride = "override"
+)
+
+// ResolutionEvent is a single decision, or input to a decision, of the resolution of an update.
+type ResolutionEvent struct {
+	// Pass is the resolution pass of the event, starting at 1
+	Pass int    `json:"pass"`
+	Kind string `json:"kind"`
+	// Key identifies the resolved dependency: its repository, by URL or by registry and repository
+	Key string `json:"key"`
+	// Dependency is the dot-separated path in the dependency tree of the dependency requiring a version, or overridden
+	Dependency string `json:"dependency,omitempty"`
+	// Constraint is the version, version range or revision required; or the declared location, if overridden
+	Constraint string `json:"constraint,omitempty"`
+	// Constraints are the constraints a version is selected for, with the paths of the dependencies declaring them
+	Constraints []string `json:"constraints,omitempty"`
+	// Tags are the available versions listed
+	Tags []string `json:"tags,omitempty"`
+	// Version is the version resolved or selected; or the forced location, if overridden
+	Version string `json:"version,omitempty"`
+	// Reason is why the version was resolved, or selected
+	Reason string `json:"reason,omitempty"`
+}
+
+// ResolutionTrace collects the resolution events of an update, in the order they occurred, to explain why each
+// dependency resolved to the version it did.
+type ResolutionTrace struct {
+	Events []ResolutionEvent `json:"events"`
+	// mu guards the trace, as used by dependencies updated concurrently
+	mu sync.Mutex
+}
+
+// NewResolutionTrace returns an empty trace, to collect an update's resolution events into.
+func NewResolutionTrace() *ResolutionTrace {
+	return &ResolutionTrace{Events: []ResolutionEvent{}}
+}
+
+// add appends the event to the trace; a no-op on a nil trace.
+func (t *ResolutionTrace) add(event ResolutionEvent) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Events = append(t.Events, event)
+}
+
+// Report writes the trace to w, in the given format: text, as one line per event grouped by resolution pass; or json.
+func (t *ResolutionTrace) Report(w io.Writer, format string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if format == "json" {
+		bs, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(bs))
+		return err
+	}
+
+	pass := 0
+	for _, event := range t.Events {
+		if event.Pass != pass {
+			pass = event.Pass
+			if _, err := fmt.Fprintf(w, "Resolution pass %d:\n", pass); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "  %s\n", event.describe()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describe returns a single line describing the event.
+func (e ResolutionEvent) describe() string {
+	var line string
+	switch e.Kind {
+	case ExplainTags:
+		line = fmt.Sprintf("%s: listed versions %s", e.Key, strings.Join(e.Tags, ", "))
+	case ExplainConstraint:
+		line = fmt.Sprintf("%s: %s requires %s, resolved to %s", e.Key, e.Dependency, e.Constraint, e.Version)
+	case ExplainRevision:
+		line = fmt.Sprintf("%s: %s requires revision %s", e.Key, e.Dependency, e.Constraint)
+	case ExplainSelected:
+		line = fmt.Sprintf("%s: selected %s for %s", e.Key, e.Version, strings.Join(e.Constraints, "; "))
+	case ExplainConflict:
+		line = fmt.Sprintf("%s: conflict between %s", e.Key, strings.Join(e.Constraints, "; "))
+	case ExplainOverride:
+		line = fmt.Sprintf("%s: location %s overridden with %s", e.Dependency, e.Constraint, e.Version)
+	default:
+		line = fmt.Sprintf("%s: %s", e.Key, e.Kind)
+	}
+	if e.Reason != "" {
+		line += " (" + e.Reason + ")"
+	}
+	return line
+}
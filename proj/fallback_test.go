@@ -0,0 +1,122 @@
+package proj
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateFallbackLocations(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "policy.rego"), []byte("package mirrored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var tarball bytes.Buffer
+	if err := utils.CreateTarGz(&tarball, srcDir, []string{srcDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bundle.tar.gz":
+			_, _ = w.Write(tarball.Bytes())
+		case "/tampered.tar.gz":
+			_, _ = w.Write([]byte("tampered"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	sum := sha256.Sum256(tarball.Bytes())
+	digest := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		note            string
+		locations       []string
+		expectedSource  string
+		expectedErr     string
+		expectedErrCode errs.Code
+	}{
+		{
+			note:      "first location",
+			locations: []string{"/bundle.tar.gz", "/unavailable.tar.gz"},
+		},
+		{
+			note:           "fallback location",
+			locations:      []string{"/unavailable.tar.gz", "/bundle.tar.gz"},
+			expectedSource: "/bundle.tar.gz",
+		},
+		{
+			note:            "all locations failing",
+			locations:       []string{"/unavailable.tar.gz", "/other.tar.gz"},
+			expectedErr:     "other.tar.gz: unexpected status 404",
+			expectedErrCode: errs.FetchFailed,
+		},
+		{
+			note:            "mismatching content not falling back",
+			locations:       []string{"/tampered.tar.gz", "/bundle.tar.gz"},
+			expectedErr:     "sha256 digest of " + server.URL + "/tampered.tar.gz",
+			expectedErrCode: errs.ContentMismatch,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			dep := "    location:\n"
+			for _, location := range tc.locations {
+				dep += fmt.Sprintf("      - %s%s\n", server.URL, location)
+			}
+			files := map[string]string{
+				"opa.project": "dependencies:\n  lib:\n" + dep + "    namespace: false\n    sha256: " + digest + "\n",
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadAndLoadProject(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = project.UpdateWithOptions(UpdateOptions{})
+				if tc.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected error containing %q, got %v", tc.expectedErr, err)
+					}
+					if code := errs.CodeOf(err); code != tc.expectedErrCode {
+						t.Fatalf("expected error code %s, got %s", tc.expectedErrCode.ID, code.ID)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !utils.FileExists(filepath.Join(project.Dependencies["lib"].dir(dependenciesDir(root)), "policy.rego")) {
+					t.Fatal("expected policy to be extracted")
+				}
+				lock, err := ReadLockFile(lockFilePath(project.filePath))
+				if err != nil {
+					t.Fatal(err)
+				}
+				expectedSource := ""
+				if tc.expectedSource != "" {
+					expectedSource = server.URL + tc.expectedSource
+				}
+				if locked, _ := lock.Get(server.URL + tc.locations[0]); locked.Source != expectedSource {
+					t.Fatalf("expected source %q, got %q", expectedSource, locked.Source)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/keyring"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// configureFetching applies the network settings of the config to all subsequent fetches: plain HTTP to the proxy, if
+// configured, the bandwidth limit, the verification of TLS certificates, and the SSH authentication of the project
+// being updated.
+func configureFetching(cfg *config.Config, project *Project) error {
+	if project != nil && project.Fetch != nil {
+		cfg.Fetch = cfg.Fetch.Merge(config.FetchLimits{
+			Retries: project.Fetch.Retries,
+			Backoff: project.Fetch.Backoff,
+			Timeout: project.Fetch.Timeout,
+		})
+	}
+	if host, plainHTTP := cfg.ProxyHost(); plainHTTP {
+		oci.AllowPlainHTTP(host)
+	}
+
+	limit := cfg.BandwidthLimit()
+	if limit > 0 {
+		printer.Debug("Limiting download bandwidth to %d bytes/s", limit)
+	}
+	utils.ThrottleHTTP(limit)
+	utils.ConfigureTimeout(cfg.FetchTimeout())
+	if err := configureTLS(cfg); err != nil {
+		return err
+	}
+	// The git HTTP transport holds on to the default transport it was created with
+	client.InstallProtocol("http", githttp.NewClient(nil))
+	client.InstallProtocol("https", githttp.NewClient(nil))
+
+	gitSSH = project.sshConfig(cfg)
+	gitTokens = cfg.GitTokens
+	return nil
+}
+
+// gitTokenEnv are the environment variables holding tokens for well-known git hosts, as set by their CI systems.
+var gitTokenEnv = map[string]string{
+	"github.com": "GITHUB_TOKEN",
+	"gitlab.com": "GITLAB_TOKEN",
+}
+
+// gitTokens are the configured tokens of git hosts, as set by configureFetching.
+var gitTokens map[string]config.GitToken
+
+// gitAuth returns the credential for the host of a git HTTP(S) URL, as basic authentication; the configured SSH
+// authentication for SSH URLs; and nil for other URLs, and hosts without a credential, which are accessed anonymously.
+func gitAuth(url string) transport.AuthMethod {
+	if isSSHUrl(url) {
+		return sshAuth(url)
+	}
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil
+	}
+	credential, ok := gitCredential(keyring.Host(url))
+	if !ok {
+		return nil
+	}
+	return &githttp.BasicAuth{Username: credential.Username, Password: credential.Secret}
+}
+
+// gitCredential returns the credential for the git host, in order of precedence: the credential of the credential
+// helper configured for the host, the token of the environment variable configured for the host, the token of the host's well-known environment variable (e.g. GITHUB_TOKEN), the
+// credential stored by 'odm login', the credential of the user's netrc file, or the token of ODM_GIT_TOKEN, which is
+// sent to any host.
+func gitCredential(host string) (keyring.Credential, bool) {
+	if credential, ok := keyring.HelperCredential(host); ok {
+		return credential, true
+	}
+	for configured, token := range gitTokens {
+		if keyring.Host(configured) != host {
+			continue
+		}
+		if secret := os.Getenv(token.Env); secret != "" {
+			username := token.Username
+			if username == "" {
+				username = tokenUsername(host)
+			}
+			printer.Debug("Using token of %s for %s", token.Env, host)
+			return keyring.Credential{Username: username, Secret: secret}, true
+		}
+		printer.Debug("Not using token for %s: %s is not set", host, token.Env)
+	}
+	if env, ok := gitTokenEnv[host]; ok {
+		if secret := os.Getenv(env); secret != "" {
+			printer.Debug("Using token of %s for %s", env, host)
+			return keyring.Credential{Username: tokenUsername(host), Secret: secret}, true
+		}
+	}
+	if credential, ok := keyring.Lookup(host); ok {
+		return credential, true
+	}
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	if login, password, ok := utils.NetrcCredential(hostname); ok {
+		return keyring.Credential{Username: login, Secret: password}, true
+	}
+	if secret := os.Getenv("ODM_GIT_TOKEN"); secret != "" {
+		printer.Debug("Using token of ODM_GIT_TOKEN for %s", host)
+		return keyring.Credential{Username: tokenUsername(host), Secret: secret}, true
+	}
+	return keyring.Credential{}, false
+}
+
+// tokenUsername returns the username a token is sent to the git host with: 'oauth2' for GitLab, which requires it for
+// OAuth and job tokens, and 'x-access-token' otherwise; GitHub, and most other hosts, accept any username.
+func tokenUsername(host string) string {
+	if strings.HasPrefix(host, "gitlab.") {
+		return "oauth2"
+	}
+	return "x-access-token"
+}
+
+// markUpdated marks the dependency with the given id as updated; returning false if it already was.
+func (ctx *updateContext) markUpdated(id string) bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.updated[id] {
+		return false
+	}
+	ctx.updated[id] = true
+	return true
+}
+
+// acquireFetch blocks until fewer than the configured maximum of fetches are in progress, and returns a function
+// releasing the acquired slot.
+func (ctx *updateContext) acquireFetch() func() {
+	ctx.fetches <- struct{}{}
+	return func() { <-ctx.fetches }
+}
+
+// updateDependencies updates the given dependencies concurrently, within the limit of parallel fetches, and stores the
+// updated dependencies back in deps. Returns the error of the first failed dependency, by name.
+func (ctx *updateContext) updateDependencies(deps Dependencies, parent *Dependency, wrapErr bool) error {
+	names := sortedDependencyNames(deps)
+	updated := make([]Dependency, len(names))
+	failures := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		dep := deps[name]
+		if parent != nil {
+			dep.ParentDependency = parent
+		}
+		wg.Add(1)
+		go func(i int, dep Dependency) {
+			defer wg.Done()
+			failures[i] = dep.update(ctx)
+			updated[i] = dep
+		}(i, dep)
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		if err := failures[i]; err != nil {
+			if wrapErr {
+				return fmt.Errorf("failed to update dependency %s: %w", name, err)
+			}
+			return err
+		}
+		deps[name] = updated[i]
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package proj
+
+import (
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/johanfylling/odm/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitAuthTokens(t *testing.T) {
+	// No credentials stored by 'odm login'
+	t.Setenv("ODM_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	t.Setenv("ODM_KEYRING", "file")
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine netrc.example.com login alice password secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+
+	previous := gitTokens
+	t.Cleanup(func() { gitTokens = previous })
+	gitTokens = map[string]config.GitToken{
+		"git.example.com":    {Env: "GHE_TOKEN"},
+		"GITLAB.example.com": {Env: "SELF_HOSTED_TOKEN", Username: "ci"},
+	}
+
+	tests := []struct {
+		note     string
+		url      string
+		env      map[string]string
+		expected *githttp.BasicAuth
+	}{
+		{
+			note:     "configured host",
+			url:      "https://git.example.com/org/repo.git",
+			env:      map[string]string{"GHE_TOKEN": "ghe", "ODM_GIT_TOKEN": "generic"},
+			expected: &githttp.BasicAuth{Username: "x-access-token", Password: "ghe"},
+		},
+		{
+			note:     "configured host and username",
+			url:      "https://gitlab.example.com/org/repo.git",
+			env:      map[string]string{"SELF_HOSTED_TOKEN": "self-hosted"},
+			expected: &githttp.BasicAuth{Username: "ci", Password: "self-hosted"},
+		},
+		{
+			note:     "configured variable not set",
+			url:      "https://git.example.com/org/repo.git",
+			env:      map[string]string{"ODM_GIT_TOKEN": "generic"},
+			expected: &githttp.BasicAuth{Username: "x-access-token", Password: "generic"},
+		},
+		{
+			note:     "GitHub",
+			url:      "https://github.com/org/repo.git",
+			env:      map[string]string{"GITHUB_TOKEN": "gh", "GITLAB_TOKEN": "gl"},
+			expected: &githttp.BasicAuth{Username: "x-access-token", Password: "gh"},
+		},
+		{
+			note:     "GitLab",
+			url:      "https://gitlab.com/org/repo.git",
+			env:      map[string]string{"GITHUB_TOKEN": "gh", "GITLAB_TOKEN": "gl"},
+			expected: &githttp.BasicAuth{Username: "oauth2", Password: "gl"},
+		},
+		{
+			note:     "netrc",
+			url:      "https://netrc.example.com:8443/org/repo.git",
+			env:      map[string]string{"ODM_GIT_TOKEN": "generic"},
+			expected: &githttp.BasicAuth{Username: "alice", Password: "secret"},
+		},
+		{
+			note: "no token",
+			url:  "https://github.com/org/repo.git",
+			env:  map[string]string{"GITLAB_TOKEN": "gl"},
+		},
+		{
+			note: "not HTTP(S)",
+			url:  "file:///tmp/repo",
+			env:  map[string]string{"ODM_GIT_TOKEN": "generic"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			for _, name := range []string{"GHE_TOKEN", "SELF_HOSTED_TOKEN", "GITHUB_TOKEN", "GITLAB_TOKEN", "ODM_GIT_TOKEN"} {
+				t.Setenv(name, tc.env[name])
+			}
+			auth := gitAuth(tc.url)
+			if tc.expected == nil {
+				if auth != nil {
+					t.Fatalf("expected no authentication, got %v", auth)
+				}
+				return
+			}
+			basic, ok := auth.(*githttp.BasicAuth)
+			if !ok || *basic != *tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, auth)
+			}
+		})
+	}
+}
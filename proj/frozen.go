@@ -0,0 +1,74 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"strings"
+)
+
+// checkFrozen fails a frozen update of the dependency if its location isn't recorded in the lock file, before anything
+// is fetched from location. Local dependencies aren't locked, and are always accepted.
+func (d Dependency) checkFrozen(ctx *updateContext, location string) error {
+	if !ctx.frozen || strings.HasPrefix(location, "file:") {
+		return nil
+	}
+	if _, ok := ctx.lock.Get(d.location()); !ok {
+		return errs.New(errs.LockMismatch, "dependency %s (%s) is not in the lock file; run 'odm update' to lock it",
+			d.Name, d.location())
+	}
+	return nil
+}
+
+// checkFrozen fails if the lock doesn't resolve exactly the locations of the previous lock, to the same states; i.e.
+// if a frozen update would change the lock file.
+func (l *Lock) checkFrozen(previous *Lock) error {
+	var mismatches []string
+	for _, location := range sortedKeys(l.Dependencies) {
+		locked, ok := previous.Get(location)
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: not in the lock file", location))
+		} else if diff := lockDiff(locked, l.Dependencies[location]); diff != "" {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s", location, diff))
+		}
+	}
+	for _, location := range sortedKeys(previous.Dependencies) {
+		if _, ok := l.Dependencies[location]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: locked, but no longer a dependency", location))
+		}
+	}
+	if len(mismatches) > 0 {
+		return errs.New(errs.LockMismatch, "dependencies do not match the lock file; run 'odm update' to update it:\n  %s",
+			strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+// lockDiff describes how the resolved state differs from the locked state; empty if it doesn't.
+func lockDiff(locked LockedDependency, resolved LockedDependency) string {
+	var diffs []string
+	for _, field := range []struct {
+		name     string
+		locked   string
+		resolved string
+	}{
+		{"digest", locked.Digest, resolved.Digest},
+		{"tag", locked.Tag, resolved.Tag},
+		{"commit", locked.Commit, resolved.Commit},
+		{"branch", locked.Branch, resolved.Branch},
+		{"hash", locked.Hash, resolved.Hash},
+		{"source", locked.Source, resolved.Source},
+	} {
+		if field.locked != field.resolved {
+			diffs = append(diffs, fmt.Sprintf("%s %s, locked %s", field.name, orNone(field.resolved),
+				orNone(field.locked)))
+		}
+	}
+	return strings.Join(diffs, "; ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateFrozen(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	for _, tag := range []string{"v1", "v2"} {
+		upstream.tag(tag, "package "+tag)
+	}
+
+	project := func(tags ...string) string {
+		content := "name: proj\ndependencies:\n"
+		for _, tag := range tags {
+			content += fmt.Sprintf("  policy_%s:\n    location: git+file://%s#%s\n", tag, upstream.dir, tag)
+		}
+		return content
+	}
+	files := map[string]string{
+		"opa.project": project("v1"),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		updateFrozen := func(content string) error {
+			if err := os.WriteFile(filepath.Join(root, "opa.project"), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			project, err := ReadProjectFromFile(root, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return project.UpdateWithOptions(UpdateOptions{Frozen: true})
+		}
+		lockPath := filepath.Join(root, "opa.project.lock")
+
+		if err := updateFrozen(project("v1")); !errs.Is(err, errs.LockMismatch) {
+			t.Fatalf("expected lock mismatch without lock file, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(root, ".opa", "dependencies", "policy_v1", "policy.rego")); err == nil {
+			t.Fatal("expected nothing to be fetched for unlocked dependency")
+		}
+
+		updateAndRead(t, root, "policy_v1", "policy.rego")
+		locked, err := os.ReadFile(lockPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := updateFrozen(project("v1")); err != nil {
+			t.Fatal(err)
+		}
+		if bs, _ := os.ReadFile(lockPath); string(bs) != string(locked) {
+			t.Fatalf("expected lock file to be left untouched, got %s", bs)
+		}
+
+		err = updateFrozen(project("v1", "v2"))
+		if !errs.Is(err, errs.LockMismatch) || !strings.Contains(err.Error(), "policy_v2") {
+			t.Fatalf("expected lock mismatch for dependency not in lock file, got %v", err)
+		}
+
+		err = updateFrozen("name: proj\n")
+		if !errs.Is(err, errs.LockMismatch) || !strings.Contains(err.Error(), "no longer a dependency") {
+			t.Fatalf("expected lock mismatch for stale lock entry, got %v", err)
+		}
+		if bs, _ := os.ReadFile(lockPath); string(bs) != string(locked) {
+			t.Fatalf("expected lock file to be left untouched, got %s", bs)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,196 @@
+package proj
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gateQuery is the rule evaluated by dependency policies; a non-empty set of messages rejects the update.
+const gateQuery = "data.odm.gate.deny"
+
+// GateInput is the input document of dependency policies, describing the resolved dependency graph.
+type GateInput struct {
+	Project      GateProject      `json:"project"`
+	Dependencies []GateDependency `json:"dependencies"`
+}
+
+type GateProject struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// GateDependency describes a single resolved dependency to dependency policies.
+type GateDependency struct {
+	Name string `json:"name"`
+	// Path is the dot-separated path of the dependency in the dependency tree
+	Path string `json:"path"`
+	// Parent is the path of the dependency declaring this dependency; empty for direct dependencies
+	Parent           string `json:"parent,omitempty"`
+	Location         string `json:"location"`
+	ResolvedLocation string `json:"resolved_location"`
+	// Type is the location type: 'git', 'oci', or 'file'
+	Type      string `json:"type"`
+	Revision  string `json:"revision,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Version is the version declared by the dependency's project file, if any
+	Version    string       `json:"version,omitempty"`
+	Deprecated *Deprecation `json:"deprecated,omitempty"`
+}
+
+// gatePolicies returns the dependency policies applying to the project: those configured for the user, followed by
+// those declared by the project. Relative paths are resolved against the directory of the declaring file.
+func (p *Project) gatePolicies(cfg *config.Config) ([]string, error) {
+	var policies []string
+	if len(cfg.Policy) > 0 {
+		path, err := config.FilePath()
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range cfg.Policy {
+			policies = append(policies, resolvePath(filepath.Dir(path), policy))
+		}
+	}
+	for _, policy := range p.Policy {
+		policies = append(policies, resolvePath(p.Dir(), policy))
+	}
+	return policies, nil
+}
+
+func resolvePath(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// gateInput builds the dependency policy input from the loaded dependency tree, and the given lock.
+func (p *Project) gateInput(cfg *config.Config, lock *Lock) (*GateInput, error) {
+	input := &GateInput{
+		Project:      GateProject{Name: p.Name, Version: p.Version},
+		Dependencies: []GateDependency{},
+	}
+
+	for _, path := range p.DependencyPaths() {
+		details, err := p.dependencyDetails(path, cfg, lock)
+		if err != nil {
+			return nil, err
+		}
+
+		dep := GateDependency{
+			Name:             details.Name,
+			Path:             path,
+			Location:         details.Location,
+			ResolvedLocation: details.ResolvedLocation,
+			Type:             locationType(details.ResolvedLocation),
+			Revision:         details.Revision,
+			Namespace:        details.Namespace,
+			Deprecated:       details.Deprecation,
+		}
+		if i := strings.LastIndex(path, "."); i >= 0 {
+			dep.Parent = path[:i]
+		}
+		if details.Project != nil {
+			dep.Version = details.Project.Version
+		}
+		input.Dependencies = append(input.Dependencies, dep)
+	}
+
+	return input, nil
+}
+
+func locationType(location string) string {
+	switch {
+	case strings.HasPrefix(location, "git+"):
+		return "git"
+	case strings.HasPrefix(location, "oci://"):
+		return "oci"
+	case strings.HasPrefix(location, "file:"):
+		return "file"
+	case isTarballLocation(location):
+		return "tarball"
+	}
+	return ""
+}
+
+// checkGate evaluates the dependency policies against the resolved dependency tree, and returns an error listing all
+// violations, if any.
+func (p *Project) checkGate(cfg *config.Config, lock *Lock) error {
+	policies, err := p.gatePolicies(cfg)
+	if err != nil || len(policies) == 0 {
+		return err
+	}
+
+	printer.Debug("Evaluating dependency policies %v", policies)
+
+	input, err := p.gateInput(cfg, lock)
+	if err != nil {
+		return err
+	}
+
+	violations, err := evalGate(policies, input)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate dependency policies: %w", err)
+	}
+	if len(violations) > 0 {
+		return errs.New(errs.PolicyViolation, "dependency policy violations:\n  %s", strings.Join(violations, "\n  "))
+	}
+	return nil
+}
+
+func evalGate(policies []string, input *GateInput) ([]string, error) {
+	inputFile, err := os.CreateTemp("", "odm-gate-input-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(inputFile.Name()) }()
+
+	err = json.NewEncoder(inputFile).Encode(input)
+	_ = inputFile.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := utils.NewOpa(policies...).Eval("--format", "json", "--input", inputFile.Name(), gateQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result []struct {
+			Expressions []struct {
+				Value interface{} `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+
+	// An undefined deny rule denies nothing
+	if len(result.Result) == 0 || len(result.Result[0].Expressions) == 0 {
+		return nil, nil
+	}
+	values, ok := result.Result[0].Expressions[0].Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected %s to be a set of messages, got %T", gateQuery,
+			result.Result[0].Expressions[0].Value)
+	}
+
+	violations := make([]string, 0, len(values))
+	for _, value := range values {
+		if msg, ok := value.(string); ok {
+			violations = append(violations, msg)
+		} else {
+			bs, _ := json.Marshal(value)
+			violations = append(violations, string(bs))
+		}
+	}
+	return violations, nil
+}
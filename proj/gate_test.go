@@ -0,0 +1,90 @@
+package proj
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateDependencyPolicy(t *testing.T) {
+	t.Setenv("ODM_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+
+	policy := `package odm.gate
+
+import future.keywords.contains
+import future.keywords.if
+import future.keywords.in
+
+deny contains msg if {
+	some dep in input.dependencies
+	dep.type == "file"
+	startswith(dep.name, "forbidden")
+	msg := sprintf("dependency %s (%s) is not allowed", [dep.path, dep.location])
+}
+`
+
+	tests := []struct {
+		note        string
+		project     string
+		expectedErr string
+	}{
+		{
+			note: "no violations",
+			project: `policy: gate.rego
+dependencies:
+  allowed: file:///allowed
+`,
+		},
+		{
+			note: "transitive violation",
+			project: `policy: gate.rego
+dependencies:
+  allowed: file:///transitive
+`,
+			expectedErr: "dependency allowed.forbidden_b (file:///forbidden) is not allowed",
+		},
+		{
+			note: "direct violation",
+			project: `policy:
+  - gate.rego
+dependencies:
+  forbidden_a: file:///forbidden
+`,
+			expectedErr: "dependency forbidden_a (file:///forbidden) is not allowed",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project":                    tc.project,
+				"gate.rego":                      policy,
+				"allowed/policy.rego":            "package allowed_lib",
+				"forbidden/policy.rego":          "package forbidden",
+				"transitive/opa.project":         "dependencies:\n  forbidden_b: file:///forbidden\n",
+				"transitive/src/transitive.rego": "package transitive",
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = project.Update()
+				if tc.expectedErr == "" {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("expected error containing %s, got %v", tc.expectedErr, err)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
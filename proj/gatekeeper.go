@@ -0,0 +1,133 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GatekeeperLibRoot is the package all library code of a Gatekeeper ConstraintTemplate must be placed in.
+const GatekeeperLibRoot = "data.lib"
+
+// ConstraintTemplate is the Rego of a Gatekeeper ConstraintTemplate: an entrypoint policy, with the library modules it
+// references inlined.
+type ConstraintTemplate struct {
+	// Name is the name of the template; the lower-cased Kind, as required by Gatekeeper
+	Name string
+	// Kind is the kind of the constraints the template defines
+	Kind string
+	// Rego is the entrypoint policy
+	Rego string
+	// Libs are the library modules referenced by the entrypoint policy, directly or transitively, ordered by path
+	Libs []string
+}
+
+// GatekeeperTemplate returns the ConstraintTemplate of the entrypoint policy of package pkg, given with or without the
+// 'data.' prefix, for constraints of the given kind; derived from the last segment of the package if empty. The
+// entrypoint policy must be a single module of the project's source, or of a namespaced dependency.
+// Modules it references must be placed under data.lib, as Gatekeeper requires; dependencies may be namespaced there.
+// As for package filtering, references are found lexically, and so are over-approximated.
+func (p *Project) GatekeeperTemplate(pkg string, kind string) (*ConstraintTemplate, error) {
+	if !strings.HasPrefix(pkg, "data.") {
+		pkg = "data." + pkg
+	}
+	if kind == "" {
+		kind = defaultConstraintKind(pkg)
+	}
+
+	locations, err := p.DataLocations()
+	if err != nil {
+		return nil, err
+	}
+	var modules []regoModule
+	for _, location := range locations {
+		found, err := scanModules(location)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range found {
+			if !strings.HasSuffix(m.path, "_test.rego") {
+				modules = append(modules, m)
+			}
+		}
+	}
+
+	var entrypoint *regoModule
+	for i, m := range modules {
+		if m.pkg != pkg {
+			continue
+		}
+		if entrypoint != nil {
+			return nil, errs.New(errs.InvalidUsage,
+				"package %s is declared by both %s and %s; a constraint template's policy must be a single module",
+				strings.TrimPrefix(pkg, "data."), entrypoint.path, m.path)
+		}
+		entrypoint = &modules[i]
+	}
+	if entrypoint == nil {
+		return nil, errs.New(errs.InvalidUsage, "package %s not found", strings.TrimPrefix(pkg, "data."))
+	}
+
+	libs := map[string]regoModule{}
+	queue := []regoModule{*entrypoint}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, m := range modules {
+			if _, ok := libs[m.path]; ok || m.path == entrypoint.path {
+				continue
+			}
+			for _, ref := range current.refs {
+				if !within(ref, m.pkg) && !within(m.pkg, ref) {
+					continue
+				}
+				if !within(m.pkg, GatekeeperLibRoot) {
+					return nil, errs.New(errs.InvalidUsage,
+						"package %s, in %s, referenced by %s, must be placed under %s to be inlined into a constraint template; e.g. by namespacing its dependency there",
+						strings.TrimPrefix(m.pkg, "data."), m.path, strings.TrimPrefix(current.pkg, "data."),
+						strings.TrimPrefix(GatekeeperLibRoot, "data."))
+				}
+				libs[m.path] = m
+				queue = append(queue, m)
+				break
+			}
+		}
+	}
+
+	template := &ConstraintTemplate{Name: strings.ToLower(kind), Kind: kind}
+	if template.Rego, err = readModule(entrypoint.path); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(libs))
+	for path := range libs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		lib, err := readModule(path)
+		if err != nil {
+			return nil, err
+		}
+		template.Libs = append(template.Libs, lib)
+	}
+	return template, nil
+}
+
+// defaultConstraintKind returns the last segment of the package, with its first letter upper-cased; e.g.
+// K8srequiredlabels for data.policies.k8srequiredlabels.
+func defaultConstraintKind(pkg string) string {
+	name := []rune(pkg[strings.LastIndex(pkg, ".")+1:])
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+func readModule(path string) (string, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return string(bs), nil
+}
@@ -0,0 +1,107 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/errs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGatekeeperTemplate(t *testing.T) {
+	required := "package k8srequiredlabels\n\nimport data.lib.labels\nimport data.lib.strings\n\nviolation[msg] { not labels.has(input.review.object); msg := strings.concat(\"missing\") }"
+
+	tests := []struct {
+		note     string
+		pkg      string
+		kind     string
+		expected *ConstraintTemplate
+		err      string
+	}{
+		{
+			note: "libs inlined transitively",
+			pkg:  "k8srequiredlabels",
+			kind: "K8sRequiredLabels",
+			expected: &ConstraintTemplate{
+				Name: "k8srequiredlabels",
+				Kind: "K8sRequiredLabels",
+				Rego: required,
+				Libs: []string{
+					"package lib.labels.helpers\n\nnonempty(x) {\n\tcount(x) > 0\n}\n",
+					"package lib.labels.labels\n\nimport data.lib.labels.helpers\n\nhas(obj) {\n\thelpers.nonempty(obj.metadata.labels)\n}\n",
+					"package lib.strings\n\nconcat(x) := x",
+				},
+			},
+		},
+		{
+			note:     "derived kind",
+			pkg:      "data.k8sallowedrepos",
+			expected: &ConstraintTemplate{Name: "k8sallowedrepos", Kind: "K8sallowedrepos", Rego: "package k8sallowedrepos\n\nviolation[msg] { false; msg := \"\" }"},
+		},
+		{
+			note: "reference outside lib",
+			pkg:  "k8sdisallowed",
+			err:  "package other, in",
+		},
+		{
+			note: "split package",
+			pkg:  "split",
+			err:  "package split is declared by both",
+		},
+		{
+			note: "unknown package",
+			pkg:  "missing",
+			err:  "package missing not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project": `source: src
+dependencies:
+  labels:
+    location: file:///labels
+    namespace: lib.labels
+`,
+				"src/required.rego":      required,
+				"src/required_test.rego": "package k8srequiredlabels_test\n\nimport data.k8srequiredlabels",
+				"src/repos.rego":         "package k8sallowedrepos\n\nviolation[msg] { false; msg := \"\" }",
+				"src/disallowed.rego":    "package k8sdisallowed\n\nviolation[msg] { data.other.x; msg := \"\" }",
+				"src/other.rego":         "package other\n\nx := true",
+				"src/split/a.rego":       "package split",
+				"src/split/b.rego":       "package split",
+				"src/lib/strings.rego":   "package lib.strings\n\nconcat(x) := x",
+				"src/lib/unused.rego":    "package lib.unused",
+				"labels/labels.rego":     "package labels\n\nimport data.helpers\n\nhas(obj) { helpers.nonempty(obj.metadata.labels) }",
+				"labels/helpers.rego":    "package helpers\n\nnonempty(x) { count(x) > 0 }",
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				actual, err := project.GatekeeperTemplate(tc.pkg, tc.kind)
+				if tc.err != "" {
+					if !errs.Is(err, errs.InvalidUsage) || !strings.Contains(err.Error(), tc.err) {
+						t.Fatalf("expected error %q, got %v", tc.err, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(actual, tc.expected) {
+					t.Fatalf("expected:\n%+v\ngot:\n%+v", tc.expected, actual)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
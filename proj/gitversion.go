@@ -0,0 +1,179 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"path"
+	"strings"
+	"time"
+)
+
+// gitLatestRef is the git reference resolving to the newest tag of the repository.
+const gitLatestRef = "latest"
+
+// isGitTagPattern returns true if the git reference name is a glob pattern of tags, such as 'v1.*' or 'release-202?',
+// rather than a version range.
+func isGitTagPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[") && !strings.ContainsAny(name, "^~<>=|, ")
+}
+
+// resolvable returns true if the reference is resolved to a tag of the remote repository before fetching: a version
+// range, a tag pattern, or 'latest'.
+func (r gitRef) resolvable() bool {
+	return r.kind == "" && (utils.IsVersionRange(r.name) || isGitTagPattern(r.name) || r.name == gitLatestRef)
+}
+
+// resolveGitVersion resolves the git reference, if a version range, tag pattern or 'latest', to a tag of the remote
+// repository at url, and returns a reference to that tag, along with the tag. Version ranges resolve to the highest
+// matching semver tag; tag patterns to the newest matching tag, and 'latest' to the newest tag. The tag planned, or
+// recorded in the lock file, is preferred; delete the lock entry to re-resolve the reference. Other references are
+// returned as-is, without a tag.
+func (d Dependency) resolveGitVersion(ctx *updateContext, url string, ref gitRef) (gitRef, string, error) {
+	listTags := func() ([]string, error) {
+		return remoteGitTags(ctx.recording, url)
+	}
+	if isExactVersion(ref.tag()) {
+		// Exact versions are unified with the other versions of the repository required throughout the dependency tree
+		tag, err := ctx.resolver.resolve(url, ref.name, d.path(), listTags)
+		if err != nil {
+			return ref, "", errs.Wrap(errs.ResolutionFailed, fmt.Errorf("failed to resolve version of %s: %w",
+				d.location(), err))
+		}
+		if tag == ref.name {
+			return ref, "", nil
+		}
+		printer.Debug("Selected version %s of %s instead of %s", tag, url, ref.name)
+		return gitRef{name: tag}, tag, nil
+	}
+	if !ref.resolvable() {
+		ctx.resolver.require(url, ref.revision(), d.path())
+		return ref, "", nil
+	}
+
+	var tag string
+	if planned, ok := ctx.plan[d.location()]; ok && planned.Tag != "" {
+		tag = planned.Tag
+	} else if locked, ok := ctx.lock.Get(d.location()); ok && locked.Tag != "" {
+		printer.Debug("Using locked tag %s for %s", locked.Tag, d.location())
+		tag = locked.Tag
+	} else {
+		var err error
+		if ref.name == gitLatestRef {
+			tag, err = newestGitTag(ctx.recording, url, "")
+		} else if isGitTagPattern(ref.name) {
+			tag, err = newestGitTag(ctx.recording, url, ref.name)
+		} else {
+			tag, err = ctx.resolver.resolve(url, ref.name, d.path(), listTags)
+		}
+		if err != nil {
+			return ref, "", errs.Wrap(errs.ResolutionFailed, fmt.Errorf("failed to resolve version of %s: %w",
+				d.location(), err))
+		}
+		printer.Debug("Resolved version %s of %s to tag %s", ref.name, url, tag)
+	}
+	return gitRef{name: tag}, tag, nil
+}
+
+// revision returns the reference as declared in git locations; 'HEAD' for the default branch.
+func (r gitRef) revision() string {
+	switch {
+	case r.name == "":
+		return "HEAD"
+	case r.kind != "":
+		return r.kind + "=" + r.name
+	default:
+		return r.name
+	}
+}
+
+// remoteGitTags lists the tags of the remote repository at url.
+func remoteGitTags(recording *ResolutionRecording, url string) ([]string, error) {
+	refs, err := listRemoteGitRefs(recording, url)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, r := range refs {
+		if r.Name().IsTag() {
+			tags = append(tags, r.Name().Short())
+		}
+	}
+	return tags, nil
+}
+
+// newestGitTag returns the newest tag of the remote repository at url matching the glob pattern, or of all its tags if
+// the pattern is empty: the highest semver version, pre-releases excluded, if any matching tag is one; or else the tag
+// of the most recent commit.
+func newestGitTag(recording *ResolutionRecording, url string, pattern string) (string, error) {
+	tags, err := remoteGitTags(recording, url)
+	if err != nil {
+		return "", errs.New(errs.FetchFailed, "%w", err)
+	}
+
+	var matching []string
+	var newest string
+	var newestVersion *semver.Version
+	for _, tag := range tags {
+		if pattern != "" {
+			if ok, err := path.Match(pattern, tag); err != nil {
+				return "", errs.New(errs.InvalidLocation, "invalid tag pattern '%s': %w", pattern, err)
+			} else if !ok {
+				continue
+			}
+		}
+		matching = append(matching, tag)
+		if version, err := semver.NewVersion(tag); err == nil && version.Prerelease() == "" &&
+			(newestVersion == nil || version.GreaterThan(newestVersion)) {
+			newest, newestVersion = tag, version
+		}
+	}
+	if len(matching) == 0 && pattern == "" {
+		return "", errs.New(errs.ResolutionFailed, "repository has no tags")
+	} else if len(matching) == 0 {
+		return "", errs.New(errs.ResolutionFailed, "no tag matching '%s'", pattern)
+	}
+	if newest != "" {
+		return newest, nil
+	}
+	return newestGitTagByCommitDate(url, matching)
+}
+
+// newestGitTagByCommitDate returns the tag, of the given tags of the remote repository at url, pointing at the most
+// recent commit; breaking ties by the highest tag name. The repository is cloned into memory to read the dates of its
+// commits.
+func newestGitTagByCommitDate(url string, tags []string) (string, error) {
+	printer.Debug("Cloning %s to order tags %s by commit date", url, strings.Join(tags, ", "))
+	cloneCtx, cancel := utils.FetchContext()
+	defer cancel()
+	repo, err := git.CloneContext(cloneCtx, memory.NewStorage(), nil, &git.CloneOptions{
+		URL:  url,
+		Auth: gitAuth(url),
+		Tags: git.AllTags,
+	})
+	if err != nil {
+		return "", errs.New(errs.FetchFailed, "failed to clone git repository %s: %w", url, err)
+	}
+
+	var newest string
+	var newestTime time.Time
+	for _, tag := range tags {
+		hash, err := tagCommit(repo, tag)
+		if err != nil {
+			return "", errs.New(errs.FetchFailed, "failed to resolve tag '%s' of git repository %s: %w", tag, url, err)
+		}
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			return "", errs.New(errs.FetchFailed, "failed to resolve tag '%s' of git repository %s: %w", tag, url, err)
+		}
+		when := commit.Committer.When
+		if newest == "" || when.After(newestTime) || (when.Equal(newestTime) && tag > newest) {
+			newest, newestTime = tag, when
+		}
+	}
+	return newest, nil
+}
@@ -0,0 +1,131 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// importedBundlesDir is the directory, relative to the project directory, that bundles imported as dependencies are
+// copied to.
+const importedBundlesDir = "bundles"
+
+// BundleImport describes how a bundle was imported into a project.
+type BundleImport struct {
+	// Roots are the roots declared by the bundle's manifest; nil if it claims the entire data tree
+	Roots []string
+	// Files are the source files extracted from the bundle, relative to the project directory
+	Files []string
+	// Dependency is the name of the dependency the bundle was imported as; empty if it was imported as source
+	Dependency string
+}
+
+// ImportBundle scaffolds the project after the gzipped tarball bundle at bundlePath. If dependency is empty, the
+// bundle's Rego and data files are extracted into sourceDir, keeping their paths within the bundle, and sourceDir is
+// added to the project's source; compiled bundles can't be, as they have no source. Otherwise, the bundle is copied
+// into the project and declared as a compiled bundle dependency of the given name.
+// The project file isn't written.
+func (p *Project) ImportBundle(bundlePath string, sourceDir string, dependency string) (*BundleImport, error) {
+	if utils.IsDir(bundlePath) || !strings.HasSuffix(bundlePath, ".tar.gz") {
+		return nil, fmt.Errorf("expected a gzipped tarball bundle, got %s", bundlePath)
+	}
+	roots, err := utils.ReadBundleRoots(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	imported := &BundleImport{Roots: roots}
+
+	if dependency != "" {
+		if _, ok := p.Dependencies[dependency]; ok {
+			return nil, fmt.Errorf("dependency %s already declared", dependency)
+		}
+		dir := path.Join(importedBundlesDir, dependency)
+		if err := utils.CopyAll(bundlePath, filepath.Join(p.Dir(), filepath.FromSlash(dir)), nil, false); err != nil {
+			return nil, fmt.Errorf("failed to copy bundle %s: %w", bundlePath, err)
+		}
+		p.SetDependency(dependency, DependencyInfo{
+			Location:  "file:/" + dir,
+			Namespace: dependency,
+			Bundle:    true,
+		})
+		imported.Dependency = dependency
+		imported.Files = []string{path.Join(dir, filepath.Base(bundlePath))}
+		return imported, nil
+	}
+
+	if compiled, err := utils.IsCompiledBundle(bundlePath); err != nil {
+		return nil, err
+	} else if compiled {
+		return nil, fmt.Errorf("bundle %s is compiled, and has no source to import; import it as a dependency instead",
+			bundlePath)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "odm-import-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	err = utils.ExtractTarGz(file, tmpDir)
+	_ = file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract bundle %s: %w", bundlePath, err)
+	}
+
+	// The project directory is the source when none is declared, and so already covers the extracted files, unless
+	// it has no source of its own
+	hasSource := len(p.SourceDirs) > 0
+	if !hasSource {
+		if hasSource, err = containsRego(p.Dir()); err != nil {
+			return nil, err
+		}
+	}
+
+	dstDir := filepath.Join(p.Dir(), filepath.FromSlash(sourceDir))
+	err = filepath.Walk(tmpDir, func(src string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !isSourceFile(info.Name()) {
+			return err
+		}
+		rel, err := filepath.Rel(tmpDir, src)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstDir, rel)
+		if utils.FileExists(dst) {
+			return fmt.Errorf("file %s already exists", dst)
+		}
+		if err := utils.CopyAll(src, filepath.Dir(dst), nil, false); err != nil {
+			return err
+		}
+		imported.Files = append(imported.Files, path.Join(sourceDir, filepath.ToSlash(rel)))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import source of bundle %s: %w", bundlePath, err)
+	}
+	sort.Strings(imported.Files)
+
+	if len(p.SourceDirs) > 0 || !hasSource {
+		if !utils.Contains(p.SourceDirs, sourceDir) {
+			p.SourceDirs = append(p.SourceDirs, sourceDir)
+		}
+	}
+	return imported, nil
+}
+
+// isSourceFile reports whether the bundle file with the given name is Rego source or data; as opposed to e.g. its
+// manifest or signatures.
+func isSourceFile(name string) bool {
+	switch name {
+	case "data.json", "data.yaml", "data.yml":
+		return true
+	}
+	return strings.HasSuffix(name, ".rego")
+}
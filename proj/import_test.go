@@ -0,0 +1,148 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportBundle(t *testing.T) {
+	tests := []struct {
+		note           string
+		bundle         map[string]string
+		files          map[string]string
+		dependency     string
+		expectedFiles  []string
+		expectedSource []string
+		expectedRoots  []string
+		expectedDep    *DependencyInfo
+		expectedErr    string
+	}{
+		{
+			note: "source",
+			bundle: map[string]string{
+				".manifest":    `{"roots": ["a"]}`,
+				"a/b/p.rego":   "package a.b",
+				"a/data.json":  `{"c": 1}`,
+				"a/README.md":  "",
+				"a/b/sig.json": "{}",
+			},
+			expectedFiles:  []string{"src/a/b/p.rego", "src/a/data.json"},
+			expectedSource: []string{"src"},
+			expectedRoots:  []string{"a"},
+		},
+		{
+			note: "source, added to declared source",
+			bundle: map[string]string{
+				"a/p.rego": "package a",
+			},
+			files:          map[string]string{"opa.project": "source: policy\n"},
+			expectedFiles:  []string{"src/a/p.rego"},
+			expectedSource: []string{"policy", "src"},
+		},
+		{
+			note: "source, covered by project directory",
+			bundle: map[string]string{
+				"a/p.rego": "package a",
+			},
+			files:         map[string]string{"x.rego": "package x"},
+			expectedFiles: []string{"src/a/p.rego"},
+		},
+		{
+			note: "source, existing file",
+			bundle: map[string]string{
+				"a/p.rego": "package a",
+			},
+			files:       map[string]string{"src/a/p.rego": "package a"},
+			expectedErr: "already exists",
+		},
+		{
+			note: "compiled bundle as source",
+			bundle: map[string]string{
+				"plan.json": "{}",
+			},
+			expectedErr: "is compiled",
+		},
+		{
+			note: "dependency",
+			bundle: map[string]string{
+				".manifest": `{"roots": ["authz"]}`,
+				"plan.json": "{}",
+			},
+			dependency:    "authz",
+			expectedFiles: []string{"bundles/authz/bundle.tar.gz"},
+			expectedRoots: []string{"authz"},
+			expectedDep: &DependencyInfo{
+				Location:  "file:/bundles/authz",
+				Namespace: "authz",
+				Bundle:    true,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{}
+			for name, content := range tc.bundle {
+				files["bundle/"+name] = content
+			}
+			for name, content := range tc.files {
+				files["project/"+name] = content
+			}
+			files["project/.keep"] = ""
+			err := withTempFiles(files, func(root string) {
+				bundlePath := filepath.Join(root, "bundle.tar.gz")
+				f, err := os.Create(bundlePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = utils.CreateTarGz(f, filepath.Join(root, "bundle"), []string{filepath.Join(root, "bundle")})
+				_ = f.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				project, err := ReadProjectFromFile(filepath.Join(root, "project"), true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				imported, err := project.ImportBundle(bundlePath, "src", tc.dependency)
+				if tc.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected error containing %q, got %v", tc.expectedErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(imported.Files, tc.expectedFiles) {
+					t.Fatalf("expected files %v, got %v", tc.expectedFiles, imported.Files)
+				}
+				for _, file := range imported.Files {
+					if !utils.FileExists(filepath.Join(root, "project", file)) {
+						t.Fatalf("expected file %s to exist", file)
+					}
+				}
+				if !reflect.DeepEqual(project.SourceDirs, tc.expectedSource) {
+					t.Fatalf("expected source %v, got %v", tc.expectedSource, project.SourceDirs)
+				}
+				if !reflect.DeepEqual(imported.Roots, tc.expectedRoots) {
+					t.Fatalf("expected roots %v, got %v", tc.expectedRoots, imported.Roots)
+				}
+				if tc.expectedDep != nil {
+					if dep := project.Dependencies[tc.dependency]; !reflect.DeepEqual(dep.DependencyInfo, *tc.expectedDep) {
+						t.Fatalf("expected dependency %v, got %v", *tc.expectedDep, dep.DependencyInfo)
+					}
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package proj
+
+import (
+	"bytes"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// lfsPointerPrefix starts the content of Git LFS pointer files, which are checked out in place of the content they
+// point to unless Git LFS fetches it.
+var lfsPointerPrefix = []byte("version https://git-lfs.github.com/spec/v1")
+
+// lfsPointerMaxSize is the size above which files aren't LFS pointers; as by Git LFS.
+const lfsPointerMaxSize = 1024
+
+// pullLfsContent replaces the Git LFS pointer files checked out in the git dependency in dir, if any, with the content
+// they point to; through the git-lfs extension of the git CLI, authenticated by git's own credential helpers. Fails if
+// git-lfs isn't available, rather than leaving pointer files in place of policy or data.
+func pullLfsContent(url string, dir string) error {
+	pointers, err := lfsPointers(dir)
+	if err != nil || len(pointers) == 0 {
+		return err
+	}
+
+	if !lfsAvailable() {
+		return errs.New(errs.FetchFailed,
+			"%d files of git repository %s, e.g. %s, are stored in Git LFS; install git-lfs to fetch their content",
+			len(pointers), url, pointers[0])
+	}
+	printer.Debug("Pulling %d Git LFS files of %s", len(pointers), url)
+	var args []string
+	for _, setting := range gitTLSConfig(url) {
+		args = append(args, "-c", setting)
+	}
+	if _, err := utils.RunGit(dir, append(args, "lfs", "pull")...); err != nil {
+		return errs.New(errs.FetchFailed, "failed to pull Git LFS content of git repository %s: %w", url, err)
+	}
+
+	if pointers, err = lfsPointers(dir); err != nil {
+		return err
+	} else if len(pointers) > 0 {
+		return errs.New(errs.FetchFailed, "failed to pull Git LFS content of git repository %s: %s is still a pointer",
+			url, pointers[0])
+	}
+	return nil
+}
+
+func lfsAvailable() bool {
+	if !utils.GitAvailable() {
+		return false
+	}
+	_, err := utils.RunGit("", "lfs", "version")
+	return err == nil
+}
+
+// lfsPointers returns the slash-separated paths, relative to dir, of the Git LFS pointer files checked out in dir.
+func lfsPointers(dir string) ([]string, error) {
+	var pointers []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() > lfsPointerMaxSize || info.Size() < int64(len(lfsPointerPrefix)) {
+			return nil
+		}
+		if isPointer, err := isLfsPointer(path); err != nil {
+			return err
+		} else if isPointer {
+			rel, _ := filepath.Rel(dir, path)
+			pointers = append(pointers, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return pointers, err
+}
+
+func isLfsPointer(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	prefix := make([]byte, len(lfsPointerPrefix))
+	if _, err := io.ReadFull(f, prefix); err != nil {
+		return false, err
+	}
+	return bytes.Equal(prefix, lfsPointerPrefix), nil
+}
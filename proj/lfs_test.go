@@ -0,0 +1,68 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const lfsPointer = `version https://git-lfs.github.com/spec/v1
+oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+size 12345
+`
+
+func TestLfsPointers(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"policy.rego":      "package policy",
+		"data/large.json":  lfsPointer,
+		"data/small.json":  `{"version": "https://git-lfs.github.com/spec/v1"}`,
+		".git/lfs/pointer": lfsPointer,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pointers, err := lfsPointers(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"data/large.json"}; !reflect.DeepEqual(pointers, expected) {
+		t.Fatalf("expected %v, got %v", expected, pointers)
+	}
+}
+
+func TestUpdateGitDependencyLfsPointers(t *testing.T) {
+	if lfsAvailable() {
+		t.Skip("git-lfs available")
+	}
+
+	upstream := newGitUpstream(t, "policy.rego")
+	upstream.commitFiles(map[string]string{"policy.rego": "package policy", "data.json": lfsPointer}, "initial")
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf("dependencies:\n  lib: git+file://%s\n", upstream.dir),
+	}
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = project.Update()
+		if !errs.Is(err, errs.FetchFailed) || !strings.Contains(err.Error(), "install git-lfs") {
+			t.Fatalf("expected Git LFS fetch error, got %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+package proj
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// licenseFilePrefixes are the upper-cased name prefixes of files holding license terms or notices, which several
+// licenses require to accompany redistributed copies.
+var licenseFilePrefixes = []string{"LICENSE", "LICENCE", "NOTICE", "COPYING"}
+
+func isLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, prefix := range licenseFilePrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// LicenseFiles returns the paths of the license and notice files in the root directory of every dependency in the
+// loaded dependency tree, by the dot-separated path of the dependency. Dependencies without such files are omitted.
+func (p *Project) LicenseFiles() (map[string][]string, error) {
+	licenses := make(map[string][]string)
+	for _, path := range p.DependencyPaths() {
+		dep, err := p.findDependency(path)
+		if err != nil {
+			return nil, err
+		}
+		if dep.dirPath == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(dep.dirPath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && isLicenseFile(entry.Name()) {
+				licenses[path] = append(licenses[path], filepath.Join(dep.dirPath, entry.Name()))
+			}
+		}
+	}
+	return licenses, nil
+}
@@ -0,0 +1,153 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sync"
+	"time"
+)
+
+const lockFileHeader = "# This file is generated by ODM. Do not edit manually.\n"
+
+// Lock records how dependency locations were resolved, so that repeated updates produce identical dependency trees.
+type Lock struct {
+	Dependencies map[string]LockedDependency `yaml:"dependencies,omitempty"`
+	filePath     string
+	// mu guards Dependencies, as set by dependencies updated concurrently
+	mu sync.Mutex
+}
+
+// LockedDependency is the resolved state of a dependency location.
+type LockedDependency struct {
+	// Digest is the digest of the OCI manifest the location resolved to; or of the downloaded archive of HTTP(S) tarball
+	// locations
+	Digest string `yaml:"digest,omitempty" json:"digest,omitempty"`
+	// Tag is the tag a version range resolved to
+	Tag string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	// Deprecation is the deprecation of the resolved version, as published in the registry
+	Deprecation *Deprecation `yaml:"deprecated,omitempty" json:"deprecated,omitempty"`
+	// Hash is the hash of the fetched content of OCI artifacts and tagged git references, before namespacing
+	Hash string `yaml:"hash,omitempty" json:"hash,omitempty"`
+	// Commit is the commit the tag of a git location pointed at when locked; or, for floating git locations, the commit
+	// they are pinned to
+	Commit string `yaml:"commit,omitempty" json:"commit,omitempty"`
+	// Branch is the branch tracked by a floating git location
+	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
+	// Source is the fallback location the dependency was fetched from, if it couldn't be fetched from its location
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+	// Resolved is when the location was resolved to its locked state, in RFC 3339 format
+	Resolved string `yaml:"resolved,omitempty" json:"resolved,omitempty"`
+}
+
+// sameResolution returns true if the locked states resolve the location to the same content, from the same source.
+func (d LockedDependency) sameResolution(other LockedDependency) bool {
+	return lockDiff(d, other) == ""
+}
+
+func newLock(path string) *Lock {
+	return &Lock{
+		Dependencies: make(map[string]LockedDependency),
+		filePath:     path,
+	}
+}
+
+// lockFilePath returns the path of the lock file belonging to the project file at the given path.
+func lockFilePath(projectFilePath string) string {
+	return projectFilePath + ".lock"
+}
+
+// ReadLockFile reads the lock file at the given path. A missing lock file yields an empty lock.
+func ReadLockFile(path string) (*Lock, error) {
+	if !utils.FileExists(path) {
+		return newLock(path), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file %s: %w", path, err)
+	}
+
+	lock := newLock(path)
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lock file %s: %w", path, err)
+	}
+	if lock.Dependencies == nil {
+		lock.Dependencies = make(map[string]LockedDependency)
+	}
+
+	return lock, nil
+}
+
+// Get returns the locked state of the given dependency location, if any.
+func (l *Lock) Get(location string) (LockedDependency, bool) {
+	if l == nil {
+		return LockedDependency{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	locked, ok := l.Dependencies[location]
+	return locked, ok
+}
+
+func (l *Lock) set(location string, locked LockedDependency) {
+	l.modify(location, func(entry *LockedDependency) {
+		*entry = locked
+	})
+}
+
+// remove removes the locked state of the given location, if any.
+func (l *Lock) remove(location string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.Dependencies, location)
+}
+
+// modify applies f to the locked state of the given location, creating it if missing.
+func (l *Lock) modify(location string, f func(*LockedDependency)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := l.Dependencies[location]
+	f(&entry)
+	l.Dependencies[location] = entry
+}
+
+// stampResolved records when every location of the lock was resolved: as recorded in the previous lock, for locations
+// still resolving to the same state; or else now.
+func (l *Lock) stampResolved(previous *Lock, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for location, entry := range l.Dependencies {
+		if prev, ok := previous.Get(location); ok && prev.Resolved != "" && prev.sameResolution(entry) {
+			entry.Resolved = prev.Resolved
+		} else {
+			entry.Resolved = now.UTC().Format(time.RFC3339)
+		}
+		l.Dependencies[location] = entry
+	}
+}
+
+// WriteToFile writes the lock to its file. A lock without entries removes any existing lock file.
+func (l *Lock) WriteToFile() error {
+	if len(l.Dependencies) == 0 {
+		if utils.FileExists(l.filePath) {
+			printer.Debug("Removing empty lock file %s", l.filePath)
+			return os.Remove(l.filePath)
+		}
+		return nil
+	}
+
+	printer.Debug("Writing lock file to %s", l.filePath)
+
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock file %s: %w", l.filePath, err)
+	}
+
+	if err := os.WriteFile(l.filePath, append([]byte(lockFileHeader), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file %s: %w", l.filePath, err)
+	}
+	return nil
+}
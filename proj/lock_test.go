@@ -0,0 +1,624 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateOciDependencyLocked(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	v1 := registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1"})
+
+	location := fmt.Sprintf("oci://%s/org/policy:1.0.0", registry.Host())
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: %s
+    namespace: false
+`, location),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		update := func() string {
+			project, err := ReadProjectFromFile(root, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := project.Update(); err != nil {
+				t.Fatal(err)
+			}
+			depDir := project.Dependencies["policy"].dir(dependenciesDir(root))
+			bs, err := os.ReadFile(filepath.Join(depDir, "policy.rego"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(bs)
+		}
+
+		if policy := update(); policy != "package v1" {
+			t.Fatalf("expected package v1, got %s", policy)
+		}
+
+		lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, ok := lock.Get(location); !ok || locked.Digest != v1 {
+			t.Fatalf("expected locked digest %s, got %v", v1, locked)
+		}
+
+		// Moving the tag must not change the pulled content while the lock file is present
+		registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1_moved"})
+		if policy := update(); policy != "package v1" {
+			t.Fatalf("expected package v1, got %s", policy)
+		}
+
+		if err := os.Remove(filepath.Join(root, "opa.project.lock")); err != nil {
+			t.Fatal(err)
+		}
+		if policy := update(); policy != "package v1_moved" {
+			t.Fatalf("expected package v1_moved, got %s", policy)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateOciDependencyVersionRange(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1_0"})
+	v12 := registry.Push("org/policy", "1.2.0", map[string]string{"/policy.rego": "package v1_2"})
+	registry.Push("org/policy", "2.0.0", map[string]string{"/policy.rego": "package v2_0"})
+	registry.Push("org/policy", "latest", map[string]string{"/policy.rego": "package latest"})
+
+	location := fmt.Sprintf("oci://%s/org/policy:^1.0", registry.Host())
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: %s
+    namespace: false
+`, location),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		depDir := project.Dependencies["policy"].dir(dependenciesDir(root))
+		bs, err := os.ReadFile(filepath.Join(depDir, "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package v1_2" {
+			t.Fatalf("expected package v1_2, got %s", string(bs))
+		}
+
+		lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := LockedDependency{Digest: v12, Tag: "1.2.0"}
+		if locked, _ := lock.Get(location); locked.Digest != expected.Digest || locked.Tag != expected.Tag {
+			t.Fatalf("expected locked %v, got %v", expected, locked)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateOciDependencyShortName(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	v1 := registry.Push("acme-policies/http", "1.2.0", map[string]string{"/policy.rego": "package http"})
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf(`registries:
+  lib/: oci://%s/acme-policies/
+`, registry.Host())), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ODM_CONFIG", configPath)
+
+	files := map[string]string{
+		"opa.project": `name: proj
+dependencies:
+  http:
+    location: lib/http@1.2.0
+    namespace: false
+`,
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		depDir := project.Dependencies["http"].dir(dependenciesDir(root))
+		if !utils.FileExists(filepath.Join(depDir, "policy.rego")) {
+			t.Fatalf("expected policy.rego in %s", depDir)
+		}
+
+		lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, ok := lock.Get("lib/http@1.2.0"); !ok || locked.Digest != v1 {
+			t.Fatalf("expected locked digest %s for short name, got %v", v1, lock.Dependencies)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateOciDependencyYanked(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1_0"})
+	registry.Push("org/policy", "1.1.0", map[string]string{"/policy.rego": "package v1_1"})
+
+	tests := []struct {
+		note           string
+		version        string
+		lock           bool
+		expectedPolicy string
+		expectedErr    string
+	}{
+		{
+			note:           "range skips yanked version",
+			version:        "^1.0",
+			expectedPolicy: "package v1_0",
+		},
+		{
+			note:           "locked range keeps yanked version",
+			version:        "^1.0",
+			lock:           true,
+			expectedPolicy: "package v1_1",
+		},
+		{
+			note:        "exact yanked version",
+			version:     "1.1.0",
+			expectedErr: "version 1.1.0 of oci://" + registry.Host() + "/org/policy:1.1.0 is yanked: broken",
+		},
+		{
+			note:           "locked exact yanked version",
+			version:        "1.1.0",
+			lock:           true,
+			expectedPolicy: "package v1_1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			registry.Yank("org/policy", nil)
+
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: oci://%s/org/policy:%s
+    namespace: false
+`, registry.Host(), tc.version),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if tc.lock {
+					if err := project.Update(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				registry.Yank("org/policy", map[string]string{"1.1.0": "broken"})
+
+				err = project.Update()
+				if tc.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected error containing %s, got %v", tc.expectedErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				depDir := project.Dependencies["policy"].dir(dependenciesDir(root))
+				bs, err := os.ReadFile(filepath.Join(depDir, "policy.rego"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(bs) != tc.expectedPolicy {
+					t.Fatalf("expected %s, got %s", tc.expectedPolicy, string(bs))
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateGitDependencyMovedTag(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	v1 := upstream.tag("v1", "package v1")
+
+	location := fmt.Sprintf("git+file://%s#v1", upstream.dir)
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: %s
+    namespace: false
+`, location),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package v1" {
+			t.Fatalf("expected package v1, got %s", policy)
+		}
+		lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lock.Get(location); locked.Commit != v1.String() {
+			t.Fatalf("expected locked commit %s, got %v", v1, locked)
+		}
+
+		// Moving the tag must not change the fetched content while the lock file is present
+		moved := upstream.commit("package v1_moved")
+		if err := upstream.repo.DeleteTag("v1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := upstream.repo.CreateTag("v1", moved, nil); err != nil {
+			t.Fatal(err)
+		}
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package v1" {
+			t.Fatalf("expected package v1, got %s", policy)
+		}
+
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.UpdateWithOptions(UpdateOptions{Strict: true}); !errs.Is(err, errs.TagMoved) {
+			t.Fatalf("expected moved tag error, got %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateGitDependencyVersionRange(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	upstream.tag("v1.1.0", "package v1_1")
+	upstream.tag("v1.2.0", "package v1_2")
+	upstream.tag("v2.0.0", "package v2_0")
+	upstream.tag("latest", "package latest")
+
+	location := fmt.Sprintf("git+file://%s#>=1.1.0 <2.0.0", upstream.dir)
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: %s
+    namespace: false
+`, location),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package v1_2" {
+			t.Fatalf("expected package v1_2, got %s", policy)
+		}
+		lockPath := filepath.Join(root, "opa.project.lock")
+		lock, err := ReadLockFile(lockPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lock.Get(location); locked.Tag != "v1.2.0" || locked.Commit == "" {
+			t.Fatalf("expected locked tag v1.2.0 and its commit, got %v", locked)
+		}
+
+		// A newer matching tag is only resolved to once the lock entry is deleted
+		upstream.tag("v1.3.0", "package v1_3")
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package v1_2" {
+			t.Fatalf("expected locked package v1_2, got %s", policy)
+		}
+		if err := os.Remove(lockPath); err != nil {
+			t.Fatal(err)
+		}
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package v1_3" {
+			t.Fatalf("expected package v1_3, got %s", policy)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files["opa.project"] = strings.Replace(files["opa.project"], ">=1.1.0 <2.0.0", "^3", 1)
+	err = withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); !errs.Is(err, errs.ResolutionFailed) {
+			t.Fatalf("expected resolution error, got %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateGitDependencyTagPattern(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	upstream.tag("v1.1", "package v1_1")
+	upstream.tag("v1.2", "package v1_2")
+	upstream.tag("v1.3-rc1", "package v1_3_rc1")
+	upstream.tag("v2.0", "package v2_0")
+	upstream.tag("release-b", "package release_b")
+	upstream.tag("release-a", "package release_a")
+
+	tests := []struct {
+		note            string
+		pattern         string
+		expectedTag     string
+		expectedContent string
+	}{
+		{
+			note:            "highest version",
+			pattern:         "v1.*",
+			expectedTag:     "v1.2",
+			expectedContent: "package v1_2",
+		},
+		{
+			note:            "most recent commit",
+			pattern:         "release-*",
+			expectedTag:     "release-a",
+			expectedContent: "package release_a",
+		},
+		{
+			note:    "no matching tag",
+			pattern: "v3.?",
+		},
+		{
+			note:            "latest",
+			pattern:         "latest",
+			expectedTag:     "v2.0",
+			expectedContent: "package v2_0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			location := fmt.Sprintf("git+file://%s#%s", upstream.dir, tc.pattern)
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: %s
+    namespace: false
+`, location),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				if tc.expectedTag == "" {
+					project, err := ReadProjectFromFile(root, false)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := project.Update(); !errs.Is(err, errs.ResolutionFailed) {
+						t.Fatalf("expected resolution error, got %v", err)
+					}
+					return
+				}
+
+				if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != tc.expectedContent {
+					t.Fatalf("expected %s, got %s", tc.expectedContent, policy)
+				}
+				lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if locked, _ := lock.Get(location); locked.Tag != tc.expectedTag {
+					t.Fatalf("expected locked tag %s, got %v", tc.expectedTag, locked)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateGitDependencyFloatingBranch(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	upstream.commit("package stable")
+	upstream.branch("dev")
+	first := upstream.commit("package first")
+
+	location := fmt.Sprintf("git+file://%s#dev", upstream.dir)
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: %s
+    namespace: false
+`, location),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		readLocked := func() LockedDependency {
+			lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			locked, _ := lock.Get(location)
+			return locked
+		}
+
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package first" {
+			t.Fatalf("expected package first, got %s", policy)
+		}
+		firstLocked := readLocked()
+		if firstLocked.Commit != first.String() || firstLocked.Branch != "dev" || firstLocked.Hash == "" {
+			t.Fatalf("expected dev pinned to commit %s, with content hash, got %v", first, firstLocked)
+		}
+
+		// New commits on the branch aren't fetched, unless refreshed
+		second := upstream.commit("package second")
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package first" {
+			t.Fatalf("expected package first, got %s", policy)
+		}
+
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.UpdateWithOptions(UpdateOptions{Refresh: true}); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := os.ReadFile(filepath.Join(project.Dependencies["policy"].dir(dependenciesDir(root)), "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package second" {
+			t.Fatalf("expected package second, got %s", bs)
+		}
+		// The content of the new commit is locked, rather than verified against that of the previous one
+		if locked := readLocked(); locked.Commit != second.String() || locked.Hash == "" || locked.Hash == firstLocked.Hash {
+			t.Fatalf("expected dev pinned to commit %s, with new content hash, got %v", second, locked)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateGitDependencyExplicitBranch(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	// A tag and a branch of the same name, at different commits
+	upstream.tag("dev", "package tagged")
+	upstream.branch("dev")
+	head := upstream.commit("package branched")
+
+	tagged := fmt.Sprintf("git+file://%s#dev", upstream.dir)
+	branched := fmt.Sprintf("git+file://%s#branch=dev", upstream.dir)
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  tagged:
+    location: %s
+    namespace: false
+  branched:
+    location: %s
+    namespace: false
+`, tagged, branched),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		if policy := updateAndRead(t, root, "tagged", "policy.rego"); policy != "package tagged" {
+			t.Fatalf("expected package tagged, got %s", policy)
+		}
+		if policy := updateAndRead(t, root, "branched", "policy.rego"); policy != "package branched" {
+			t.Fatalf("expected package branched, got %s", policy)
+		}
+		lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lock.Get(branched); locked.Commit != head.String() || locked.Branch != "dev" {
+			t.Fatalf("expected dev pinned to commit %s, got %v", head, locked)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateGitDependencyCommit(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	first := upstream.commit("package first")
+	upstream.commit("package second")
+
+	location := fmt.Sprintf("git+file://%s#commit=%s", upstream.dir, first)
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: %s
+    namespace: false
+`, location),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package first" {
+			t.Fatalf("expected package first, got %s", policy)
+		}
+		lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lock.Get(location); locked.Commit != first.String() || locked.Branch != "" {
+			t.Fatalf("expected locked commit %s, got %v", first, locked)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStampResolved(t *testing.T) {
+	previous := newLock("")
+	previous.set("git+https://host/unchanged#v1", LockedDependency{Commit: "abc", Resolved: "2024-01-01T00:00:00Z"})
+	previous.set("git+https://host/moved#branch=main", LockedDependency{Commit: "abc", Branch: "main",
+		Resolved: "2024-01-01T00:00:00Z"})
+
+	lock := newLock("")
+	lock.set("git+https://host/unchanged#v1", LockedDependency{Commit: "abc"})
+	lock.set("git+https://host/moved#branch=main", LockedDependency{Commit: "def", Branch: "main"})
+	lock.set("oci://registry/new:1.0.0", LockedDependency{Digest: "sha256:123"})
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	lock.stampResolved(previous, now)
+
+	expected := map[string]string{
+		"git+https://host/unchanged#v1":      "2024-01-01T00:00:00Z",
+		"git+https://host/moved#branch=main": "2025-06-01T12:00:00Z",
+		"oci://registry/new:1.0.0":           "2025-06-01T12:00:00Z",
+	}
+	for location, resolved := range expected {
+		if locked, _ := lock.Get(location); locked.Resolved != resolved {
+			t.Fatalf("expected %s resolved at %s, got %s", location, resolved, locked.Resolved)
+		}
+	}
+}
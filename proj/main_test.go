@@ -0,0 +1,104 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMain points the ODM cache to a temporary directory, so that tests neither read from nor write to the user's cache.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "odm-cache-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Setenv("ODM_CACHE_DIR", dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// gitUpstream is a git repository in a temporary directory, for tests to depend on through git+file:// locations.
+type gitUpstream struct {
+	t        *testing.T
+	dir      string
+	file     string
+	repo     *git.Repository
+	worktree *git.Worktree
+	// committed is the time of the last commit; commits are a minute apart, as commit times are only precise to the
+	// second
+	committed time.Time
+	// signKey, if set, signs the commits made
+	signKey *openpgp.Entity
+}
+
+// newGitUpstream initializes a git repository in a temporary directory, with commits writing to the named file.
+func newGitUpstream(t *testing.T, file string) *gitUpstream {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &gitUpstream{t: t, dir: dir, file: file, repo: repo, worktree: worktree,
+		committed: time.Now().Add(-time.Hour)}
+}
+
+// commit writes content to the file of the upstream, and commits it to the current branch with content as message.
+func (u *gitUpstream) commit(content string) plumbing.Hash {
+	return u.commitFiles(map[string]string{u.file: content}, content)
+}
+
+// commitFiles writes the files, by slash-separated path within the upstream, and commits them to the current branch.
+func (u *gitUpstream) commitFiles(files map[string]string, message string) plumbing.Hash {
+	for path, content := range files {
+		file := filepath.Join(u.dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			u.t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			u.t.Fatal(err)
+		}
+		if _, err := u.worktree.Add(path); err != nil {
+			u.t.Fatal(err)
+		}
+	}
+	u.committed = u.committed.Add(time.Minute)
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: u.committed}
+	hash, err := u.worktree.Commit(message, &git.CommitOptions{Author: signature, Committer: signature,
+		SignKey: u.signKey, AllowEmptyCommits: true})
+	if err != nil {
+		u.t.Fatal(err)
+	}
+	return hash
+}
+
+// tag commits content, like commit, and tags the commit.
+func (u *gitUpstream) tag(tag string, content string) plumbing.Hash {
+	hash := u.commit(content)
+	if _, err := u.repo.CreateTag(tag, hash, nil); err != nil {
+		u.t.Fatal(err)
+	}
+	return hash
+}
+
+// branch creates the named branch at the current commit, and checks it out.
+func (u *gitUpstream) branch(name string) {
+	err := u.worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name), Create: true})
+	if err != nil {
+		u.t.Fatal(err)
+	}
+}
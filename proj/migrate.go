@@ -0,0 +1,474 @@
+package proj
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	conftestPolicyPattern = regexp.MustCompile(`(?m)^\s*policy\s*=\s*"([^"]+)"`)
+	invalidNamePattern    = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+)
+
+// fetchScriptCommands are the commands recognized in fetch scripts, by the name they're invoked by.
+var fetchScriptCommands = map[string]bool{
+	"git":       true,
+	"opa_fetch": true,
+	"opa-fetch": true,
+	"conftest":  true,
+	"oras":      true,
+}
+
+// Migration is an opa.project generated from an existing setup, with notes on what was found, and warnings on anything
+// that couldn't be carried over and needs attention.
+type Migration struct {
+	Project  *Project
+	Notes    []string
+	Warnings []string
+}
+
+// migratedDependency is a dependency found in an existing setup, vendored into dir, relative to the project directory.
+type migratedDependency struct {
+	dir      string
+	location string
+	origin   string
+}
+
+// Migrate inspects the directory of a project managing its dependencies without ODM, through git submodules,
+// scripts fetching policies (opa_fetch, git clone, conftest pull, oras pull), or a conftest policy/ layout; and
+// generates an equivalent project, with dependencies and source and test directories filled in.
+// Dependencies aren't namespaced, as their packages were loaded as-is.
+func Migrate(dir string) (*Migration, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migration{
+		Project: NewProject(filepath.Join(absDir, "opa.project")),
+	}
+	m.Project.Name = filepath.Base(absDir)
+
+	submodules, err := m.submoduleDependencies(absDir)
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := m.scriptDependencies(absDir)
+	if err != nil {
+		return nil, err
+	}
+
+	vendored := make(map[string]bool)
+	for _, dep := range append(submodules, fetched...) {
+		name := dependencyName(dep.dir, m.Project.Dependencies)
+		m.Project.SetDependency(name, DependencyInfo{Location: dep.location})
+		vendored[dep.dir] = true
+		m.note("%s: %s as %s (%s)", dep.origin, dep.dir, name, dep.location)
+	}
+
+	if err := m.sourceDirs(absDir, vendored); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Migration) note(format string, args ...interface{}) {
+	m.Notes = append(m.Notes, fmt.Sprintf(format, args...))
+}
+
+func (m *Migration) warn(format string, args ...interface{}) {
+	m.Warnings = append(m.Warnings, fmt.Sprintf(format, args...))
+}
+
+// submoduleDependencies returns the git submodules declared in .gitmodules, pinned to the tag of their checked out
+// commit, if any.
+func (m *Migration) submoduleDependencies(dir string) ([]migratedDependency, error) {
+	bs, err := os.ReadFile(filepath.Join(dir, ".gitmodules"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	modules := gitconfig.NewModules()
+	if err := modules.Unmarshal(bs); err != nil {
+		return nil, fmt.Errorf("failed to parse .gitmodules: %w", err)
+	}
+
+	var deps []migratedDependency
+	for _, name := range sortedKeys(modules.Submodules) {
+		submodule := modules.Submodules[name]
+		location := "git+" + submodule.URL
+		if tag := checkedOutTag(filepath.Join(dir, filepath.FromSlash(submodule.Path))); tag != "" {
+			location += "#" + tag
+		} else {
+			m.warn("submodule %s isn't checked out at a tag; its dependency follows the default branch", submodule.Path)
+		}
+		deps = append(deps, migratedDependency{
+			dir:      path.Clean(submodule.Path),
+			location: location,
+			origin:   "git submodule",
+		})
+	}
+	return deps, nil
+}
+
+// checkedOutTag returns a tag of the commit checked out in the git repository in dir; empty if there is none, or dir
+// isn't a checked out repository.
+func checkedOutTag(dir string) string {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return ""
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return ""
+	}
+	tags, err := repo.Tags()
+	if err != nil {
+		return ""
+	}
+	var found []string
+	_ = tags.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			hash = tag.Target
+		}
+		if hash == head.Hash() {
+			found = append(found, ref.Name().Short())
+		}
+		return nil
+	})
+	if len(found) == 0 {
+		return ""
+	}
+	sort.Strings(found)
+	return found[len(found)-1]
+}
+
+// scriptDependencies returns the dependencies fetched by the shell scripts and Makefile at the top of dir, or in its
+// scripts/ directory.
+func (m *Migration) scriptDependencies(dir string) ([]migratedDependency, error) {
+	var scripts []string
+	for _, pattern := range []string{"*.sh", "Makefile", "scripts/*"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if !utils.IsDir(match) {
+				scripts = append(scripts, match)
+			}
+		}
+	}
+
+	var deps []migratedDependency
+	for _, script := range scripts {
+		file, err := os.Open(script)
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(dir, script)
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			for _, command := range strings.FieldsFunc(scanner.Text(), func(r rune) bool {
+				return r == ';' || r == '&' || r == '|'
+			}) {
+				if dep := m.fetchCommandDependency(strings.Fields(command), filepath.ToSlash(rel)); dep != nil {
+					deps = append(deps, *dep)
+				}
+			}
+		}
+		err = scanner.Err()
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", script, err)
+		}
+	}
+	return deps, nil
+}
+
+// fetchCommandDependency returns the dependency fetched by the given command of a fetch script; nil if it doesn't fetch
+// one.
+func (m *Migration) fetchCommandDependency(fields []string, script string) *migratedDependency {
+	// Skip leading variable assignments and command prefixes
+	for len(fields) > 0 && (strings.Contains(fields[0], "=") || fields[0] == "sudo" || fields[0] == "exec") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || !fetchScriptCommands[path.Base(fields[0])] {
+		return nil
+	}
+	command := path.Base(fields[0])
+	args, opts := commandArgs(fields[1:])
+
+	var raw, dir, ref string
+	switch command {
+	case "git":
+		if len(args) < 2 || args[0] != "clone" {
+			return nil
+		}
+		raw = args[1]
+		if len(args) > 2 {
+			dir = args[2]
+		}
+		ref = firstOption(opts, "-b", "--branch")
+		raw = "git+" + raw
+	case "opa_fetch", "opa-fetch":
+		if len(args) < 1 {
+			return nil
+		}
+		raw = args[0]
+		if len(args) > 1 {
+			dir = args[1]
+		}
+	case "conftest":
+		if len(args) < 2 || args[0] != "pull" {
+			return nil
+		}
+		raw = args[1]
+		if dir = firstOption(opts, "-p", "--policy"); dir == "" {
+			dir = "policy"
+		}
+	case "oras":
+		if len(args) < 2 || args[0] != "pull" {
+			return nil
+		}
+		raw = args[1]
+		dir = firstOption(opts, "-o", "--output")
+	}
+
+	location, err := migrateLocation(raw, ref)
+	if err != nil {
+		m.warn("%s: %s; not migrated", script, err)
+		return nil
+	}
+	if dir == "" {
+		// Vendored into a directory named after the repository
+		base := path.Base(strings.Split(strings.TrimPrefix(location, "git+"), "#")[0])
+		base, _, _ = strings.Cut(base, "@")
+		base, _, _ = strings.Cut(base, ":")
+		dir = strings.TrimSuffix(base, ".git")
+	}
+	return &migratedDependency{
+		dir:      path.Clean(strings.Trim(dir, `"'`)),
+		location: location,
+		origin:   script,
+	}
+}
+
+// commandArgs splits the arguments of a command into its positional arguments and its options, by name.
+// Options are assumed to take a value, unless given as --name=value or followed by another option.
+func commandArgs(fields []string) ([]string, map[string]string) {
+	var args []string
+	opts := make(map[string]string)
+	for i := 0; i < len(fields); i++ {
+		field := strings.Trim(fields[i], `"'`)
+		if !strings.HasPrefix(field, "-") {
+			args = append(args, field)
+			continue
+		}
+		if name, value, ok := strings.Cut(field, "="); ok {
+			opts[name] = value
+		} else if i+1 < len(fields) && !strings.HasPrefix(fields[i+1], "-") && isValueOption(field) {
+			opts[field] = strings.Trim(fields[i+1], `"'`)
+			i++
+		} else {
+			opts[field] = ""
+		}
+	}
+	return args, opts
+}
+
+// isValueOption reports whether the fetch command option takes a value.
+func isValueOption(name string) bool {
+	switch name {
+	case "-b", "--branch", "-p", "--policy", "-o", "--output", "--depth", "--config", "-c":
+		return true
+	}
+	return false
+}
+
+func firstOption(opts map[string]string, names ...string) string {
+	for _, name := range names {
+		if value := opts[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// migrateLocation converts the location of a fetch command, in any of the notations of git, go-getter (as used by
+// conftest) and OCI registries, to a dependency location; pinned to ref, if given.
+func migrateLocation(raw string, ref string) (string, error) {
+	raw = strings.Trim(raw, `"'`)
+	if strings.Contains(raw, "$") {
+		return "", fmt.Errorf("location %s references variables", raw)
+	}
+
+	switch {
+	case strings.HasPrefix(raw, "git::"), strings.HasPrefix(raw, "git+"):
+		url := strings.TrimPrefix(strings.TrimPrefix(raw, "git::"), "git+")
+		if base, query, ok := strings.Cut(url, "?"); ok {
+			url = base
+			for _, param := range strings.Split(query, "&") {
+				if value, ok := strings.CutPrefix(param, "ref="); ok && ref == "" {
+					ref = value
+				}
+			}
+		}
+		if scheme, rest, ok := strings.Cut(url, "://"); ok {
+			if i := strings.Index(rest, "//"); i >= 0 {
+				return "", fmt.Errorf("subdirectory %s of git repository %s://%s can't be depended on", rest[i+2:], scheme,
+					rest[:i])
+			}
+			url = scheme + "://" + rest
+		}
+		if ref != "" {
+			url += "#" + ref
+		}
+		return "git+" + url, nil
+	case strings.HasPrefix(raw, "oci://"):
+		return raw, nil
+	case strings.HasPrefix(raw, "file:"):
+		return raw, nil
+	case strings.HasPrefix(raw, "http://"), strings.HasPrefix(raw, "https://"):
+		if strings.HasSuffix(raw, ".git") {
+			return migrateLocation("git+"+raw, ref)
+		}
+		return "", fmt.Errorf("location %s isn't a git repository or OCI artifact", raw)
+	case strings.HasPrefix(raw, "git@"):
+		return migrateLocation("git+ssh://"+strings.Replace(raw, ":", "/", 1), ref)
+	}
+
+	// Registry references, such as conftest and oras pull, e.g. registry.example.com/policies:v1
+	if host, _, ok := strings.Cut(raw, "/"); ok && strings.ContainsAny(host, ".:") {
+		return "oci://" + raw, nil
+	}
+	return "", fmt.Errorf("unrecognized location %s", raw)
+}
+
+// dependencyName returns a unique dependency name for the vendored directory, usable as namespace.
+func dependencyName(dir string, deps Dependencies) string {
+	name := strings.Trim(invalidNamePattern.ReplaceAllString(path.Base(dir), "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "dep_" + name
+	}
+	unique := name
+	for i := 2; ; i++ {
+		if _, ok := deps[unique]; !ok {
+			return unique
+		}
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+}
+
+// sourceDirs fills in the source and test directories of the project: the policy directory of a conftest layout;
+// otherwise, the top-level directories containing Rego files, with test/ and tests/ as test directories. Vendored
+// dependencies are left out, and if they can't be, noted for removal.
+func (m *Migration) sourceDirs(dir string, vendored map[string]bool) error {
+	var vendoredDirs []string
+	for vendoredDir := range vendored {
+		vendoredDirs = append(vendoredDirs, vendoredDir)
+	}
+	sort.Strings(vendoredDirs)
+
+	if policyDir := conftestPolicyDir(dir); policyDir != "" {
+		m.Project.SourceDirs = []string{policyDir}
+		m.note("conftest layout: %s as source", policyDir)
+	} else {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		rootHasRego := false
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || vendored[name] {
+				continue
+			}
+			if !entry.IsDir() {
+				rootHasRego = rootHasRego || strings.HasSuffix(name, ".rego")
+				continue
+			}
+			hasRego, err := containsRego(filepath.Join(dir, name))
+			if err != nil {
+				return err
+			}
+			if !hasRego {
+				continue
+			}
+			if name == "test" || name == "tests" {
+				m.Project.TestDirs = append(m.Project.TestDirs, name)
+			} else {
+				m.Project.SourceDirs = append(m.Project.SourceDirs, name)
+			}
+		}
+		if rootHasRego {
+			// The project directory itself is the source
+			m.Project.SourceDirs = nil
+		}
+	}
+
+	for _, vendoredDir := range vendoredDirs {
+		for _, sourceDir := range m.sourceLocations() {
+			if sourceDir == "." || strings.HasPrefix(vendoredDir+"/", sourceDir+"/") {
+				m.warn("%s is vendored in source directory %s; remove it once 'odm update' fetches it", vendoredDir,
+					sourceDir)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Migration) sourceLocations() []string {
+	if len(m.Project.SourceDirs) == 0 {
+		return []string{"."}
+	}
+	return m.Project.SourceDirs
+}
+
+// conftestPolicyDir returns the policy directory of a conftest layout in dir: as configured in conftest.toml, or
+// policy/ by default; empty if dir doesn't have a conftest layout.
+func conftestPolicyDir(dir string) string {
+	policyDir := ""
+	if bs, err := os.ReadFile(filepath.Join(dir, "conftest.toml")); err == nil {
+		policyDir = "policy"
+		if match := conftestPolicyPattern.FindSubmatch(bs); match != nil {
+			policyDir = path.Clean(string(match[1]))
+		}
+	} else if hasRego, _ := containsRego(filepath.Join(dir, "policy")); hasRego {
+		policyDir = "policy"
+	}
+	if policyDir == "" || !utils.IsDir(filepath.Join(dir, filepath.FromSlash(policyDir))) {
+		return ""
+	}
+	return policyDir
+}
+
+// containsRego reports whether there are Rego files anywhere in dir.
+func containsRego(dir string) (bool, error) {
+	if !utils.IsDir(dir) {
+		return false, nil
+	}
+	found := false
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".rego") {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, err
+}
@@ -0,0 +1,144 @@
+package proj
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		note             string
+		files            map[string]string
+		expectedSource   []string
+		expectedTests    []string
+		expectedDeps     map[string]string
+		expectedWarnings []string
+	}{
+		{
+			note: "conftest layout",
+			files: map[string]string{
+				"policy/main.rego":      "package main",
+				"policy/main_test.rego": "package main",
+			},
+			expectedSource: []string{"policy"},
+			expectedDeps:   map[string]string{},
+		},
+		{
+			note: "conftest layout, configured policy directory",
+			files: map[string]string{
+				"conftest.toml":    `policy = "rules"`,
+				"rules/main.rego":  "package main",
+				"other/other.rego": "package other",
+			},
+			expectedSource: []string{"rules"},
+			expectedDeps:   map[string]string{},
+		},
+		{
+			note: "source and test directories",
+			files: map[string]string{
+				"src/a.rego":        "package a",
+				"lib/b.rego":        "package b",
+				"tests/a_test.rego": "package a",
+				"docs/README.md":    "",
+			},
+			expectedSource: []string{"lib", "src"},
+			expectedTests:  []string{"tests"},
+			expectedDeps:   map[string]string{},
+		},
+		{
+			note: "git submodules",
+			files: map[string]string{
+				".gitmodules":     "[submodule \"vendor/shared\"]\n\tpath = vendor/shared\n\turl = https://example.com/org/shared.git\n",
+				"src/a.rego":      "package a",
+				"vendor/shared/x": "",
+			},
+			expectedSource: []string{"src"},
+			expectedDeps:   map[string]string{"shared": "git+https://example.com/org/shared.git"},
+			expectedWarnings: []string{
+				"submodule vendor/shared isn't checked out at a tag; its dependency follows the default branch",
+			},
+		},
+		{
+			note: "fetch script",
+			files: map[string]string{
+				"fetch.sh": `#!/bin/sh
+set -e
+git clone --depth 1 -b v2.0.0 https://example.com/org/shared-policies.git lib/shared
+opa_fetch registry.example.com/team/authz:1.2 deps/authz && echo done
+conftest pull git::https://example.com/org/rules.git?ref=v1 --policy=rules
+oras pull registry.example.com/team/authz:1.3 -o other
+conftest pull https://example.com/rules.tar.gz
+`,
+				"policy/main.rego": "package main",
+			},
+			expectedSource: []string{"policy"},
+			expectedDeps: map[string]string{
+				"shared": "git+https://example.com/org/shared-policies.git#v2.0.0",
+				"authz":  "oci://registry.example.com/team/authz:1.2",
+				"rules":  "git+https://example.com/org/rules.git#v1",
+				"other":  "oci://registry.example.com/team/authz:1.3",
+			},
+			expectedWarnings: []string{
+				"fetch.sh: location https://example.com/rules.tar.gz isn't a git repository or OCI artifact; not migrated",
+			},
+		},
+		{
+			note: "dependency vendored in source",
+			files: map[string]string{
+				"fetch.sh":        "git clone https://example.com/org/shared.git policy/shared",
+				"policy/a.rego":   "package a",
+				"policy/shared/x": "",
+			},
+			expectedSource: []string{"policy"},
+			expectedDeps:   map[string]string{"shared": "git+https://example.com/org/shared.git"},
+			expectedWarnings: []string{
+				"policy/shared is vendored in source directory policy; remove it once 'odm update' fetches it",
+			},
+		},
+		{
+			note: "project directory as source",
+			files: map[string]string{
+				"fetch.sh": "git clone https://example.com/org/shared.git",
+				"a.rego":   "package a",
+				"b/b.rego": "package b",
+			},
+			expectedDeps: map[string]string{"shared": "git+https://example.com/org/shared.git"},
+			expectedWarnings: []string{
+				"shared is vendored in source directory .; remove it once 'odm update' fetches it",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			err := withTempFiles(tc.files, func(root string) {
+				m, err := Migrate(root)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(m.Project.SourceDirs, tc.expectedSource) {
+					t.Fatalf("expected source %v, got %v", tc.expectedSource, m.Project.SourceDirs)
+				}
+				if !reflect.DeepEqual(m.Project.TestDirs, tc.expectedTests) {
+					t.Fatalf("expected tests %v, got %v", tc.expectedTests, m.Project.TestDirs)
+				}
+				deps := make(map[string]string)
+				for name, dep := range m.Project.Dependencies {
+					if dep.Namespace != "" {
+						t.Fatalf("expected dependency %s not to be namespaced", name)
+					}
+					deps[name] = dep.Location
+				}
+				if !reflect.DeepEqual(deps, tc.expectedDeps) {
+					t.Fatalf("expected dependencies %v, got %v", tc.expectedDeps, deps)
+				}
+				if !reflect.DeepEqual(m.Warnings, tc.expectedWarnings) {
+					t.Fatalf("expected warnings %v, got %v", tc.expectedWarnings, m.Warnings)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/printer"
+	"strings"
+)
+
+// override returns the location the overrides of the root project force on the dependency, if transitive: by its
+// dot-separated path in the dependency tree, or else by its name.
+func (d Dependency) override() (string, bool) {
+	if d.ParentDependency == nil {
+		return "", false
+	}
+	root := d.ParentDependency
+	for root.ParentDependency != nil {
+		root = root.ParentDependency
+	}
+	if location, ok := root.overrides[d.path()]; ok {
+		return location, true
+	}
+	location, ok := root.overrides[d.Name]
+	return location, ok
+}
+
+// checkOverrides warns of the overrides of the project not applying to any transitive dependency of the loaded
+// dependency tree, e.g. as misspelled, or no longer needed.
+func (p *Project) checkOverrides() {
+	if len(p.Overrides) == 0 {
+		return
+	}
+	used := make(map[string]bool)
+	_ = WalkDependencies(p, func(dep Dependency) error {
+		if dep.ParentDependency != nil {
+			used[dep.path()] = true
+			used[dep.Name] = true
+		}
+		return nil
+	})
+	for _, dependency := range sortedKeys(p.Overrides) {
+		if !used[dependency] {
+			kind := "name"
+			if strings.Contains(dependency, ".") {
+				kind = "path"
+			}
+			printer.Warn("override of %s applies to no transitive dependency of that %s", dependency, kind)
+		}
+	}
+}
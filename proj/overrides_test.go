@@ -0,0 +1,135 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateOverrides(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	registry.Push("org/lib", "1.0.0", map[string]string{"/lib.rego": "package lib\n\nversion := 0"})
+	registry.Push("org/lib", "1.0.1", map[string]string{"/lib.rego": "package lib\n\nversion := 1"})
+	declared := fmt.Sprintf("oci://%s/org/lib:1.0.0", registry.Host())
+	patched := fmt.Sprintf("oci://%s/org/lib:1.0.1", registry.Host())
+
+	tests := []struct {
+		note      string
+		overrides string
+		expected  string
+	}{
+		{
+			note:     "no override",
+			expected: declared,
+		},
+		{
+			note:      "by name",
+			overrides: "overrides:\n  lib: " + patched,
+			expected:  patched,
+		},
+		{
+			note:      "by path",
+			overrides: "overrides:\n  a.lib: " + patched,
+			expected:  patched,
+		},
+		{
+			note:      "other path",
+			overrides: "overrides:\n  b.lib: " + patched,
+			expected:  declared,
+		},
+		{
+			note:      "direct dependency",
+			overrides: "overrides:\n  a: file:/b",
+			expected:  declared,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`%s
+dependencies:
+  a:
+    location: file:/a
+    namespace: false
+`, tc.overrides),
+				"a/opa.project": "dependencies:\n  lib: " + declared + "\n",
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				lock, err := ReadLockFile(lockFilePath(project.filePath))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(lock.Dependencies) != 1 {
+					t.Fatalf("expected a single locked dependency, got %v", lock.Dependencies)
+				}
+				if _, ok := lock.Get(tc.expected); !ok {
+					t.Fatalf("expected %s to be locked, got %v", tc.expected, lock.Dependencies)
+				}
+
+				project, err = ReadAndLoadProject(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lib := project.Dependencies["a"].Project.Dependencies["lib"]
+				bs, err := os.ReadFile(filepath.Join(lib.dirPath, "lib.rego"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := "version := 0"
+				if tc.expected == patched {
+					expected = "version := 1"
+				}
+				if !strings.Contains(string(bs), expected) {
+					t.Fatalf("expected %q, got %q", expected, bs)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalProjectInvalidOverride(t *testing.T) {
+	tests := []struct {
+		note     string
+		project  string
+		expected errs.Code
+	}{
+		{
+			note:     "empty location",
+			project:  "overrides:\n  lib: \"\"\n",
+			expected: errs.InvalidProject,
+		},
+		{
+			note:     "undefined variable",
+			project:  "overrides:\n  lib: git+https://example.com/lib.git#${version}\n",
+			expected: errs.InvalidLocation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			var project Project
+			if err := yaml.Unmarshal([]byte(tc.project), &project); errs.CodeOf(err) != tc.expected {
+				t.Fatalf("expected error code %s, got %v", tc.expected.ID, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	packagePattern = regexp.MustCompile(`(?m)^\s*package\s+([^\s#]+)`)
+	dataRefPattern = regexp.MustCompile(`\bdata(?:\.[A-Za-z_][A-Za-z0-9_]*)+`)
+)
+
+// regoModule is a Rego file, with its package and the data references it makes, as found by a lexical scan.
+type regoModule struct {
+	path string
+	pkg  string
+	refs []string
+}
+
+// filterPackages removes all Rego files from dir that don't belong to one of the given packages (or packages nested in
+// them), unless a kept file references their package, directly or transitively. Packages may be given with or without
+// the 'data.' prefix.
+// References are found lexically, and so are over-approximated: a file referencing data.lib keeps all packages in lib.
+func filterPackages(dir string, packages []string) error {
+	modules, err := scanModules(dir)
+	if err != nil {
+		return err
+	}
+
+	roots := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		if !strings.HasPrefix(pkg, "data.") {
+			pkg = "data." + pkg
+		}
+		roots = append(roots, pkg)
+	}
+
+	keep := make(map[string]bool)
+	var queue []regoModule
+	for _, m := range modules {
+		for _, root := range roots {
+			if within(m.pkg, root) {
+				keep[m.path] = true
+				queue = append(queue, m)
+				break
+			}
+		}
+	}
+	if len(queue) == 0 {
+		return fmt.Errorf("none of the packages %s found", strings.Join(packages, ", "))
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, m := range modules {
+			if keep[m.path] {
+				continue
+			}
+			for _, ref := range current.refs {
+				if within(ref, m.pkg) || within(m.pkg, ref) {
+					printer.Debug("Keeping %s, referenced by %s", m.pkg, current.pkg)
+					keep[m.path] = true
+					queue = append(queue, m)
+					break
+				}
+			}
+		}
+	}
+
+	for _, m := range modules {
+		if !keep[m.path] {
+			printer.Debug("Removing %s of package %s", m.path, m.pkg)
+			if err := os.Remove(m.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scanModules returns the Rego files in dir, ordered by path.
+func scanModules(dir string) ([]regoModule, error) {
+	var modules []regoModule
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || filepath.Ext(path) != ".rego" {
+			return nil
+		}
+
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		match := packagePattern.FindSubmatch(bs)
+		if match == nil {
+			return nil
+		}
+		modules = append(modules, regoModule{
+			path: path,
+			pkg:  "data." + string(match[1]),
+			refs: dataRefPattern.FindAllString(string(bs), -1),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan Rego files in %s: %w", dir, err)
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].path < modules[j].path
+	})
+	return modules, nil
+}
+
+// within reports whether the dot-separated path equals prefix, or is nested in it; e.g. data.lib.http.jwt in
+// data.lib.http, but not data.lib.http_test.
+func within(path string, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+".")
+}
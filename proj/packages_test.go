@@ -0,0 +1,136 @@
+package proj
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdatePackagesFilter(t *testing.T) {
+	tests := []struct {
+		note        string
+		packages    string
+		expected    []string
+		expectedErr string
+	}{
+		{
+			note:     "package and its references",
+			packages: "[lib.http]",
+			expected: []string{"http.rego", "util/strings.rego", "util/time.rego"},
+		},
+		{
+			note:     "nested packages, data prefix",
+			packages: "[data.lib.util]",
+			expected: []string{"util/strings.rego", "util/time.rego"},
+		},
+		{
+			note:     "multiple packages",
+			packages: "[lib.jwt, lib.util.time]",
+			expected: []string{"jwt.rego", "util/time.rego"},
+		},
+		{
+			note:        "unknown package",
+			packages:    "[lib.unknown]",
+			expectedErr: "none of the packages lib.unknown found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project": `dependencies:
+  lib:
+    location: file:///lib
+    namespace: false
+    packages: ` + tc.packages + `
+`,
+				"lib/http.rego": `package lib.http
+
+import data.lib.util
+
+allow if util.trim(input.path) == "/"`,
+				"lib/util/strings.rego": `package lib.util
+
+trim(s) := trim_space(s)
+
+now := data.lib.util.time.now`,
+				"lib/util/time.rego": `package lib.util.time
+
+now := time.now_ns()`,
+				"lib/jwt.rego": `package lib.jwt
+
+valid := true`,
+				"lib/http_test.rego": `package lib.http_test
+
+test_allow if data.lib.http.allow with input as {"path": "/"}`,
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = project.Update()
+				if tc.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected error containing %q, got %v", tc.expectedErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				dir := project.Dependencies["lib"].dir(dependenciesDir(root))
+				modules, err := scanModules(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var actual []string
+				for _, m := range modules {
+					rel, _ := filepath.Rel(dir, m.path)
+					actual = append(actual, filepath.ToSlash(rel))
+				}
+				if !reflect.DeepEqual(actual, tc.expected) {
+					t.Fatalf("expected files %v, got %v", tc.expected, actual)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMarshalDependencyPackages(t *testing.T) {
+	files := map[string]string{
+		"opa.project": `dependencies:
+  lib:
+    location: file:///lib
+    packages: [lib.http, lib.jwt]
+`,
+	}
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lib := project.Dependencies["lib"]
+		if expected := []string{"lib.http", "lib.jwt"}; !reflect.DeepEqual(lib.Packages, expected) {
+			t.Fatalf("expected packages %v, got %v", expected, lib.Packages)
+		}
+
+		marshalled, err := lib.MarshalYAML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"location": "file:///lib", "packages": []string{"lib.http", "lib.jwt"}}
+		if !reflect.DeepEqual(marshalled, expected) {
+			t.Fatalf("expected %v, got %v", expected, marshalled)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,91 @@
+package proj
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// partialClone clones the git repository at url into targetDir without the content of any file (a blobless partial
+// clone), and without checking out; file content is fetched on checkout, for the files checked out only. Servers not
+// supporting partial clones are fully cloned by git. As go-git doesn't support partial clones, the git CLI is used;
+// nil is returned if it isn't available.
+func partialClone(url string, targetDir string) (*git.Repository, error) {
+	if !utils.GitAvailable() {
+		printer.Info("git not found, cloning %s in full", url)
+		return nil, nil
+	}
+	args := []string{"clone", "--quiet", "--filter=blob:none", "--no-checkout"}
+	// File content is fetched on checkout, and so the configured ssh command is kept in the clone's config
+	if command := sshCommand(); command != "" && isSSHUrl(url) {
+		args = append(args, "--config", "core.sshCommand="+command)
+	}
+	// As is the TLS config, for the same reason
+	for _, setting := range gitTLSConfig(url) {
+		args = append(args, "--config", setting)
+	}
+	if _, err := utils.RunGit("", append(args, url, targetDir)...); err != nil {
+		return nil, errs.New(errs.FetchFailed, "failed to clone git repository %s: %w", url, err)
+	}
+	// Cone mode without directories covers the files at the root of the repository only
+	if _, err := utils.RunGit(targetDir, "sparse-checkout", "set", "--cone"); err != nil {
+		return nil, errs.New(errs.FetchFailed, "failed to set up sparse checkout of git repository %s: %w", url, err)
+	}
+	return git.PlainOpen(targetDir)
+}
+
+// sparseCheckout checks out commit in the partial clone of targetDir: the files at the root of the repository, and
+// subdir, if given; or else the source and test directories declared by the project file found there, or the entire
+// tree, if the project file doesn't declare any, or declares any outside the repository.
+func sparseCheckout(url string, targetDir string, commit plumbing.Hash, subdir string) error {
+	if _, err := utils.RunGit(targetDir, "checkout", "--quiet", "--detach", commit.String()); err != nil {
+		return errs.New(errs.FetchFailed, "failed to checkout commit %s for git repository %s: %w", commit, url, err)
+	}
+
+	args := []string{"sparse-checkout", "disable"}
+	dirs := sparseDirs(targetDir)
+	if subdir != "" {
+		dirs = []string{subdir}
+	}
+	if len(dirs) > 0 {
+		printer.Debug("Checking out %s of %s", strings.Join(dirs, ", "), url)
+		args = append([]string{"sparse-checkout", "set", "--"}, dirs...)
+	}
+	if _, err := utils.RunGit(targetDir, args...); err != nil {
+		return errs.New(errs.FetchFailed, "failed to checkout commit %s for git repository %s: %w", commit, url, err)
+	}
+	return nil
+}
+
+// sparseDirs returns the slash-separated source and test directories declared by the project file in dir; nil if
+// none are declared, if any is outside dir, or if the project file can't be read.
+func sparseDirs(dir string) []string {
+	projectFile := projectFilePath(dir)
+	if !utils.FileExists(projectFile) {
+		return nil
+	}
+	project, err := ReadProjectFromFile(projectFile, false)
+	if err != nil {
+		printer.Debug("Checking out all of %s: %s", dir, err)
+		return nil
+	}
+	if len(project.SourceDirs) == 0 {
+		// The project's source is the entire repository
+		return nil
+	}
+
+	var dirs []string
+	for _, d := range append(append([]string{}, project.SourceDirs...), project.TestDirs...) {
+		d = path.Clean(filepath.ToSlash(d))
+		if d == "." || path.IsAbs(d) || d == ".." || strings.HasPrefix(d, "../") {
+			return nil
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs
+}
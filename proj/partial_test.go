@@ -0,0 +1,157 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestUpdateGitDependencyPartialClone(t *testing.T) {
+	if !utils.GitAvailable() {
+		t.Skip("git not available")
+	}
+
+	tests := []struct {
+		note         string
+		files        map[string]string
+		subdirectory string
+		expected     []string
+	}{
+		{
+			note: "source and test directories",
+			files: map[string]string{
+				"opa.project":          "source: src\ntests: test\n",
+				"src/lib.rego":         "package lib",
+				"test/lib_test.rego":   "package lib_test",
+				"data/large.json":      `{"large": true}`,
+				"docs/guide/lib.rego":  "package docs",
+				"README.md":            "# lib",
+				"src/nested/util.rego": "package lib.util",
+			},
+			expected: []string{"README.md", "opa.project", "src/lib.rego", "src/nested/util.rego", "test/lib_test.rego"},
+		},
+		{
+			note: "no declared source",
+			files: map[string]string{
+				"opa.project":     "name: lib\n",
+				"src/lib.rego":    "package lib",
+				"data/large.json": `{"large": true}`,
+			},
+			expected: []string{"data/large.json", "opa.project", "src/lib.rego"},
+		},
+		{
+			note: "no project file",
+			files: map[string]string{
+				"src/lib.rego":    "package lib",
+				"data/large.json": `{"large": true}`,
+			},
+			expected: []string{"data/large.json", "src/lib.rego"},
+		},
+		{
+			note: "subdirectory",
+			files: map[string]string{
+				"opa.project":                   "source: src\n",
+				"src/lib.rego":                  "package lib",
+				"policies/http/opa.project":     "source: src\n",
+				"policies/http/src/http.rego":   "package http",
+				"policies/http/data/large.json": `{"large": true}`,
+				"policies/kafka/kafka.rego":     "package kafka",
+				"README.md":                     "# monorepo",
+			},
+			subdirectory: "policies/http",
+			expected: []string{"README.md", "opa.project", "policies/http/data/large.json",
+				"policies/http/opa.project", "policies/http/src/http.rego"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			upstream := newGitUpstream(t, "opa.project")
+			cfg, err := upstream.repo.Config()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.Raw.Section("uploadpack").SetOption("allowFilter", "true")
+			if err := upstream.repo.SetConfig(cfg); err != nil {
+				t.Fatal(err)
+			}
+			head := upstream.commitFiles(tc.files, "initial")
+			if _, err := upstream.repo.CreateTag("v1", head, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			option := "partialClone: true"
+			if tc.subdirectory != "" {
+				option = "subdirectory: " + tc.subdirectory
+			}
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(
+					"dependencies:\n  lib:\n    location: git+file://%s#v1\n    namespace: false\n    %s\n",
+					upstream.dir, option),
+			}
+			err = withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				dir := project.Dependencies["lib"].dir(dependenciesDir(root))
+				var actual []string
+				err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+					if info.IsDir() && info.Name() == ".git" {
+						return filepath.SkipDir
+					}
+					if !info.IsDir() {
+						rel, _ := filepath.Rel(dir, path)
+						actual = append(actual, filepath.ToSlash(rel))
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Strings(actual)
+				if !reflect.DeepEqual(actual, tc.expected) {
+					t.Fatalf("expected files %v, got %v", tc.expected, actual)
+				}
+
+				expectedSource := []string{filepath.Join(dir, filepath.FromSlash(tc.subdirectory), "src")}
+				if tc.subdirectory != "" {
+					reloaded, err := ReadAndLoadProject(root, false)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if actual := reloaded.Dependencies["lib"].SourceDirs(); !reflect.DeepEqual(actual, expectedSource) {
+						t.Fatalf("expected source %v, got %v", expectedSource, actual)
+					}
+				}
+
+				clone, err := git.PlainOpen(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cloneCfg, err := clone.Config()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if promisor := cloneCfg.Raw.Section("remote").Subsection("origin").Option("promisor"); promisor != "true" {
+					t.Fatalf("expected partial clone, got promisor %q", promisor)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package proj
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/utils"
+	"strings"
+)
+
+// PinnedDependency is a dependency whose floating location was rewritten by Pin.
+type PinnedDependency struct {
+	Name     string
+	Location string
+	Pinned   string
+}
+
+// Pin rewrites the locations of the project's floating dependencies to the exact commit or digest they are locked to:
+// git dependencies tracking a branch or HEAD, or referencing a version range, tag pattern or 'latest', and OCI
+// dependencies referenced by version range or by a tag other than an exact version. Only the named dependencies are
+// pinned, or all direct dependencies if none are named; transitive dependencies are declared by their own projects, and
+// so aren't pinned.
+// The lock entries of pinned dependencies are copied to their new locations, and the lock file written; writing the
+// project file is left to the caller.
+func (p *Project) Pin(names []string) ([]PinnedDependency, error) {
+	lock, err := ReadLockFile(lockFilePath(p.filePath))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		names = sortedDependencyNames(p.Dependencies)
+	}
+
+	var pinned []PinnedDependency
+	for _, name := range names {
+		dep, ok := p.Dependencies[name]
+		if !ok {
+			return nil, errs.New(errs.InvalidUsage, "no dependency named %s", name)
+		}
+		location := dep.location()
+		expanded, err := cfg.ExpandShortName(location)
+		if err != nil {
+			return nil, errs.New(errs.InvalidLocation, "invalid location for dependency %s: %w", name, err)
+		}
+		floating, err := isFloating(expanded)
+		if err != nil {
+			return nil, err
+		}
+		locked, isLocked := lock.Get(location)
+		if !floating && locked.Branch == "" {
+			continue
+		}
+		if !isLocked {
+			return nil, errs.New(errs.ResolutionFailed,
+				"dependency %s has not been resolved; run 'odm update' before pinning it", name)
+		}
+
+		exact, err := pinLocation(location, expanded, locked)
+		if err != nil {
+			return nil, err
+		}
+
+		locked.Branch = ""
+		lock.set(exact, locked)
+		dep.Location = exact
+		p.Dependencies[name] = dep
+		pinned = append(pinned, PinnedDependency{Name: name, Location: location, Pinned: exact})
+	}
+
+	if len(pinned) == 0 {
+		return nil, nil
+	}
+	return pinned, lock.WriteToFile()
+}
+
+// isFloating returns true if the expanded location may resolve differently across updates: a git location without
+// reference, or referencing a version range, tag pattern or 'latest'; or an OCI location without digest, referenced by
+// version range or by a tag other than an exact version.
+// Git locations referencing a branch by bare name can't be told from tags by the location alone, and are recognized by
+// their lock entry.
+func isFloating(location string) (bool, error) {
+	switch {
+	case strings.HasPrefix(location, "git+"):
+		_, ref, err := parseGitUrl(location)
+		return ref.name == "" || ref.isBranch() || ref.resolvable(), err
+	case strings.HasPrefix(location, "oci://"):
+		ref, err := oci.ParseReference(strings.TrimPrefix(location, "oci://"))
+		if err != nil {
+			return false, errs.New(errs.InvalidLocation, "invalid OCI location %s: %w", location, err)
+		}
+		if ref.Digest != "" {
+			return false, nil
+		}
+		return !isVersioned(ref.Tag) || utils.IsVersionRange(ref.Tag), nil
+	default:
+		return false, nil
+	}
+}
+
+// pinLocation returns the location pinned to the commit or digest of the lock entry. Short-name locations stay short,
+// with their version replaced by the commit or digest.
+func pinLocation(location string, expanded string, locked LockedDependency) (string, error) {
+	var exact, version string
+	switch {
+	case strings.HasPrefix(expanded, "git+"):
+		if !plumbing.IsHash(locked.Commit) {
+			return "", errs.New(errs.ResolutionFailed, "no commit locked for %s; run 'odm update' before pinning it",
+				location)
+		}
+		url, _, err := parseGitUrl(expanded)
+		if err != nil {
+			return "", err
+		}
+		version = locked.Commit
+		exact = "git+" + url + "#" + version
+	case strings.HasPrefix(expanded, "oci://"):
+		if locked.Digest == "" {
+			return "", errs.New(errs.ResolutionFailed, "no digest locked for %s; run 'odm update' before pinning it",
+				location)
+		}
+		ref, err := oci.ParseReference(strings.TrimPrefix(expanded, "oci://"))
+		if err != nil {
+			return "", errs.New(errs.InvalidLocation, "invalid OCI location %s: %w", expanded, err)
+		}
+		// The tag a version range resolved to is kept for readability; other tags would be misleading next to the digest
+		ref.Tag = locked.Tag
+		version = locked.Digest
+		exact = "oci://" + ref.WithDigest(locked.Digest).String()
+	default:
+		return location, nil
+	}
+
+	if config.IsShortName(location) {
+		name, _, _ := strings.Cut(location, "@")
+		return name + "@" + version, nil
+	}
+	return exact, nil
+}
@@ -0,0 +1,116 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1_0"})
+	v11 := registry.Push("org/policy", "1.1.0", map[string]string{"/policy.rego": "package v1_1"})
+	registry.Push("org/exact", "2.0.0", map[string]string{"/exact.rego": "package exact"})
+
+	upstream := newGitUpstream(t, "head.rego")
+	first := upstream.commit("package first").String()
+
+	tagged := newGitUpstream(t, "tagged.rego")
+	tagged.tag("v1.0.0", "package v1_0")
+	v11Commit := tagged.tag("v1.1.0", "package v1_1").String()
+
+	ranged := fmt.Sprintf("oci://%s/org/policy:^1.0", registry.Host())
+	exact := fmt.Sprintf("oci://%s/org/exact:2.0.0", registry.Host())
+	head := fmt.Sprintf("git+file://%s", upstream.dir)
+	tagRange := fmt.Sprintf("git+file://%s#^1.0", tagged.dir)
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  ranged:
+    location: %s
+    namespace: false
+  exact:
+    location: %s
+    namespace: false
+  head:
+    location: %s
+    namespace: false
+  tagged:
+    location: %s
+    namespace: false
+`, ranged, exact, head, tagRange),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := project.Pin(nil); !errs.Is(err, errs.ResolutionFailed) {
+			t.Fatalf("expected resolution failure before update, got %v", err)
+		}
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := project.Pin([]string{"unknown"}); !errs.Is(err, errs.InvalidUsage) {
+			t.Fatalf("expected invalid usage, got %v", err)
+		}
+		pinned, err := project.Pin(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []PinnedDependency{
+			{Name: "head", Location: head, Pinned: head + "#" + first},
+			{Name: "ranged", Location: ranged, Pinned: fmt.Sprintf("oci://%s/org/policy:1.1.0@%s", registry.Host(), v11)},
+			{Name: "tagged", Location: tagRange, Pinned: fmt.Sprintf("git+file://%s#%s", tagged.dir, v11Commit)},
+		}
+		if fmt.Sprint(pinned) != fmt.Sprint(expected) {
+			t.Fatalf("expected pinned %v, got %v", expected, pinned)
+		}
+		if location := project.Dependencies["exact"].Location; location != exact {
+			t.Fatalf("expected exact location to be unchanged, got %s", location)
+		}
+		if err := project.WriteToFile(root, true); err != nil {
+			t.Fatal(err)
+		}
+
+		// Pinned dependencies don't move, even when refreshed
+		upstream.commit("package second")
+		tagged.tag("v1.2.0", "package v1_2")
+		registry.Push("org/policy", "1.2.0", map[string]string{"/policy.rego": "package v1_2"})
+		project, err = ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.UpdateWithOptions(UpdateOptions{Refresh: true}); err != nil {
+			t.Fatal(err)
+		}
+		for name, expected := range map[string]string{
+			"head/head.rego":     "package first",
+			"ranged/policy.rego": "package v1_1",
+			"tagged/tagged.rego": "package v1_1",
+		} {
+			dep, file := filepath.Split(name)
+			bs, err := os.ReadFile(filepath.Join(project.Dependencies[filepath.Clean(dep)].dir(dependenciesDir(root)), file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(bs) != expected {
+				t.Fatalf("expected %s in %s, got %s", expected, name, bs)
+			}
+		}
+
+		if pinned, err := project.Pin(nil); err != nil || len(pinned) != 0 {
+			t.Fatalf("expected nothing left to pin, got %v, %v", pinned, err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
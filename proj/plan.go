@@ -0,0 +1,245 @@
+package proj
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"strings"
+)
+
+// Plan is the intended outcome of an update: the source, and resolved revision, of every dependency; as determined by
+// querying metadata only, without fetching any dependency content.
+//
+// As the dependencies of a dependency are declared in its fetched content, transitive dependencies are planned as
+// declared by the dependencies fetched by the last update.
+type Plan struct {
+	Dependencies []PlannedDependency `json:"dependencies"`
+}
+
+// PlannedDependency is the planned state of a single dependency.
+type PlannedDependency struct {
+	// Path is the dot-separated path of the dependency in the dependency tree
+	Path string `json:"path"`
+	// Location is the location as declared, with variables expanded
+	Location string `json:"location"`
+	// Source is the location the dependency is fetched from, with short names expanded and proxies applied
+	Source string `json:"source"`
+	// Tag is the tag a version range resolved to, if any
+	Tag string `json:"tag,omitempty"`
+	// Revision is the git commit or OCI manifest digest to fetch; empty for local dependencies
+	Revision string `json:"revision,omitempty"`
+	// Locked is true if the revision is pinned by the lock file
+	Locked bool `json:"locked,omitempty"`
+	// Cached is true if the revision is present in the configured remote cache
+	Cached bool `json:"cached,omitempty"`
+	// Size is the download size in bytes, if known
+	Size int64 `json:"size,omitempty"`
+}
+
+// ReadPlanFile reads a plan, as written in JSON by 'odm plan'.
+func ReadPlanFile(path string) (*Plan, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan %s: %w", path, err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(bs, &plan); err != nil {
+		return nil, errs.New(errs.InvalidUsage, "invalid plan %s: %w", path, err)
+	}
+	return &plan, nil
+}
+
+// byLocation returns the planned dependencies by declared location.
+func (pl *Plan) byLocation() map[string]PlannedDependency {
+	planned := make(map[string]PlannedDependency, len(pl.Dependencies))
+	for _, dep := range pl.Dependencies {
+		planned[dep.Location] = dep
+	}
+	return planned
+}
+
+// Plan resolves the dependencies of the loaded dependency tree as an update would, but only queries remote metadata:
+// git references, OCI tags and manifests, and the presence of content in the remote cache.
+func (p *Project) Plan() (*Plan, error) {
+	lock, err := ReadLockFile(lockFilePath(p.filePath))
+	if err != nil {
+		return nil, err
+	}
+	return p.plan(lock)
+}
+
+// plan plans the update of the loaded dependency tree, with revisions pinned by the given lock.
+func (p *Project) plan(lock *Lock) (*Plan, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := configureFetching(cfg, p); err != nil {
+		return nil, err
+	}
+
+	res := newResolver(p.Resolution)
+	metadata := make(map[string]*oci.Metadata)
+	for pass := 1; ; pass++ {
+		ctx := p.newUpdateContext(lock, cfg, res, metadata)
+		res.startPass()
+
+		plan := &Plan{}
+		for _, path := range p.DependencyPaths() {
+			dep, err := p.findDependency(path)
+			if err != nil {
+				return nil, err
+			}
+			planned, err := dep.plan(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to plan dependency %s: %w", path, err)
+			}
+			planned.Path = path
+			plan.Dependencies = append(plan.Dependencies, planned)
+		}
+
+		if changed, err := res.unify(); err != nil {
+			return nil, err
+		} else if !changed {
+			return plan, res.checkRevisions()
+		}
+		if pass == maxResolutionPasses {
+			return nil, errs.New(errs.ResolutionFailed, "dependency versions did not settle after %d resolution passes",
+				pass)
+		}
+	}
+}
+
+// source returns the location the dependency is fetched from.
+func (d Dependency) source(cfg *config.Config) (string, error) {
+	return d.sourceOf(cfg, d.location())
+}
+
+// sourceOf returns the location the declared location of the dependency is fetched from: expanded if a short name,
+// rewritten by the configured URL rewrites, and proxied.
+func (d Dependency) sourceOf(cfg *config.Config, declared string) (string, error) {
+	location, err := cfg.ExpandShortName(declared)
+	if err != nil {
+		return "", errs.New(errs.InvalidLocation, "invalid location for dependency %s: %w", d.Namespace, err)
+	}
+	if location != declared {
+		printer.Debug("Expanded location %s to %s", declared, location)
+	}
+	if rewritten := cfg.RewriteLocation(location); rewritten != location {
+		printer.Debug("Rewrote location %s to %s", location, rewritten)
+		location = rewritten
+	}
+	if proxied := cfg.ProxyLocation(location); proxied != location {
+		printer.Debug("Fetching %s through proxy as %s", location, proxied)
+		location = proxied
+	}
+	return location, nil
+}
+
+func (d Dependency) plan(ctx *updateContext) (PlannedDependency, error) {
+	location, err := d.source(ctx.config)
+	if err != nil {
+		return PlannedDependency{}, err
+	}
+	planned := PlannedDependency{Location: d.location(), Source: location}
+
+	switch {
+	case strings.HasPrefix(location, "git+"):
+		url, ref, err := parseGitUrl(location)
+		if err != nil {
+			return planned, err
+		}
+		if ref, planned.Tag, err = d.resolveGitVersion(ctx, url, ref); err != nil {
+			return planned, err
+		}
+		hash, err := remoteGitHash(ctx.recording, url, ref)
+		if err != nil {
+			return planned, errs.New(errs.FetchFailed, "failed to resolve %s: %w", location, err)
+		}
+		if lockedDep, ok := ctx.lock.Get(d.location()); ok && ref.tag() != "" && lockedDep.Commit != "" && lockedDep.Branch == "" {
+			hash = plumbing.NewHash(lockedDep.Commit)
+			planned.Locked = true
+		}
+		planned.Revision = hash.String()
+		planned.Cached = ctx.cache.has(gitCacheKey(hash))
+	case strings.HasPrefix(location, "oci://"):
+		ref, err := oci.ParseReference(strings.TrimPrefix(location, "oci://"))
+		if err != nil {
+			return planned, errs.New(errs.InvalidLocation, "invalid OCI location %s: %w", location, err)
+		}
+		client := oci.NewClient()
+		if !isVersioned(ref.Tag) || ref.Digest != "" {
+			ctx.resolver.require(ref.Registry+"/"+ref.Repository, ociRevision(ref), d.path())
+		}
+		if ref.Digest == "" {
+			if lockedDep, ok := ctx.lock.Get(d.location()); ok && lockedDep.Digest != "" {
+				ref = ref.WithDigest(lockedDep.Digest)
+				planned.Tag = lockedDep.Tag
+				planned.Locked = true
+			} else if isVersioned(ref.Tag) {
+				tag, err := ctx.resolver.resolve(ref.Registry+"/"+ref.Repository, ref.Tag, d.path(), func() ([]string, error) {
+					return unyankedTags(ctx, client, ref)
+				})
+				if err != nil {
+					return planned, errs.New(errs.ResolutionFailed, "failed to resolve version of %s: %w", location, err)
+				}
+				if tag != ref.Tag || utils.IsVersionRange(ref.Tag) {
+					planned.Tag = tag
+				}
+				ref.Tag = tag
+			}
+		}
+		manifest, digest, err := client.ResolveManifest(ref)
+		if err != nil {
+			return planned, errs.New(errs.FetchFailed, "failed to resolve %s: %w", ref, err)
+		}
+		planned.Revision = digest
+		planned.Cached = ctx.cache.has(ociCacheKey(digest))
+		for _, layer := range manifest.Layers {
+			planned.Size += layer.Size
+		}
+	case isTarballLocation(location):
+		// Tarballs are only known by digest if declared with one
+		if digest := d.checksum(); digest != "" {
+			planned.Revision = "sha256:" + digest
+			planned.Cached = ctx.cache.has(tarballCacheKey(digest))
+		}
+	case strings.HasPrefix(location, "file:"):
+		// Local dependencies are copied as-is
+	default:
+		return planned, errs.New(errs.InvalidLocation, "unsupported dependency location: %s", location)
+	}
+	return planned, nil
+}
+
+// checkPlanned fails if the dependency isn't part of the update's plan, or is fetched from another source than planned.
+func (d Dependency) checkPlanned(ctx *updateContext, location string) error {
+	if ctx.plan == nil {
+		return nil
+	}
+	planned, ok := ctx.plan[d.location()]
+	if !ok {
+		return errs.New(errs.ResolutionFailed,
+			"dependency %s (%s) is not part of the plan; run 'odm plan' again", d.Name, d.location())
+	}
+	if planned.Source != location {
+		return errs.New(errs.ResolutionFailed, "dependency %s is fetched from %s, but was planned to be fetched from %s",
+			d.Name, location, planned.Source)
+	}
+	printer.Debug("Using planned revision %s of %s", planned.Revision, location)
+	return nil
+}
+
+// plannedGitHash returns the commit the plan of the update pins the dependency to; the zero hash if none.
+func (d Dependency) plannedGitHash(ctx *updateContext) plumbing.Hash {
+	if planned, ok := ctx.plan[d.location()]; ok && planned.Revision != "" {
+		return plumbing.NewHash(planned.Revision)
+	}
+	return plumbing.ZeroHash
+}
@@ -0,0 +1,122 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanOciDependency(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1_0"})
+	v11 := registry.Push("org/policy", "1.1.0", map[string]string{"/policy.rego": "package v1_1"})
+
+	location := fmt.Sprintf("oci://%s/org/policy:^1.0", registry.Host())
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: %s
+    namespace: false
+`, location),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadAndLoadProject(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := project.Plan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan.Dependencies) != 1 {
+			t.Fatalf("expected 1 planned dependency, got %v", plan.Dependencies)
+		}
+		planned := plan.Dependencies[0]
+		if planned.Path != "policy" || planned.Source != location || planned.Tag != "1.1.0" || planned.Revision != v11 ||
+			planned.Size == 0 {
+			t.Fatalf("unexpected plan %+v", planned)
+		}
+		if utils.FileExists(filepath.Join(root, ".opa")) {
+			t.Fatalf("expected nothing to be fetched by planning")
+		}
+
+		// Newer versions published after planning aren't fetched
+		registry.Push("org/policy", "1.2.0", map[string]string{"/policy.rego": "package v1_2"})
+		if err := project.UpdateWithOptions(UpdateOptions{Plan: plan}); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := os.ReadFile(filepath.Join(project.Dependencies["policy"].dir(dependenciesDir(root)), "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package v1_1" {
+			t.Fatalf("expected package v1_1, got %s", bs)
+		}
+		lock, err := ReadLockFile(lockFilePath(project.filePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lock.Get(location); locked.Digest != v11 || locked.Tag != "1.1.0" {
+			t.Fatalf("expected locked digest %s at 1.1.0, got %v", v11, locked)
+		}
+
+		// Dependencies not in the plan fail the update
+		if err := project.UpdateWithOptions(UpdateOptions{Plan: &Plan{}}); !errs.Is(err, errs.ResolutionFailed) {
+			t.Fatalf("expected resolution failure, got %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanGitDependency(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	first := upstream.commit("package first").String()
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: git+file://%s
+    namespace: false
+`, upstream.dir),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadAndLoadProject(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := project.Plan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revision := plan.Dependencies[0].Revision; revision != first {
+			t.Fatalf("expected planned revision %s, got %s", first, revision)
+		}
+
+		upstream.commit("package second")
+		if err := project.UpdateWithOptions(UpdateOptions{Plan: plan}); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := os.ReadFile(filepath.Join(project.Dependencies["policy"].dir(dependenciesDir(root)), "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package first" {
+			t.Fatalf("expected package first, got %s", bs)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
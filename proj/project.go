@@ -2,16 +2,27 @@ package proj
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci"
 	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/timing"
 	"github.com/johanfylling/odm/utils"
 	"gopkg.in/yaml.v3"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 const (
@@ -20,22 +31,50 @@ const (
 )
 
 type Project struct {
-	Name         string       `yaml:"name,omitempty"`
-	Version      string       `yaml:"version,omitempty"`
-	SourceDirs   []string     `yaml:"source,omitempty"`
-	TestDirs     []string     `yaml:"tests,omitempty"`
-	Dependencies Dependencies `yaml:"dependencies,omitempty"`
-	Build        Build        `yaml:"build,omitempty"`
+	Name         string              `yaml:"name,omitempty"`
+	Version      string              `yaml:"version,omitempty"`
+	SourceDirs   []string            `yaml:"source,omitempty"`
+	TestDirs     []string            `yaml:"tests,omitempty"`
+	Schemas      string              `yaml:"schemas,omitempty"`
+	Vars         map[string]string   `yaml:"vars,omitempty"`
+	Namespacing  *bool               `yaml:"namespacing,omitempty"`
+	Renames      map[string]string   `yaml:"renames,omitempty"`
+	Resolution   string              `yaml:"resolution,omitempty"`
+	MaxDepth     int                 `yaml:"maxDepth,omitempty"`
+	Overrides    map[string]string   `yaml:"overrides,omitempty"`
+	Exclude      []string            `yaml:"exclude,omitempty"`
+	Deprecated   *Deprecation        `yaml:"deprecated,omitempty"`
+	Policy       []string            `yaml:"policy,omitempty"`
+	SSH          *config.SSH         `yaml:"ssh,omitempty"`
+	Fetch        *config.FetchLimits `yaml:"fetch,omitempty"`
+	Dependencies Dependencies        `yaml:"dependencies,omitempty"`
+	Build        Build               `yaml:"build,omitempty"`
 	filePath     string
+	// namespacePrefix is the configured prefix applied to the namespaces of the project's dependencies
+	namespacePrefix string
+	// excluded are the names of the dependencies of the project excluded by the root project
+	excluded []string
 }
 
 type ProjectSerialization struct {
-	Name         string       `yaml:"name,omitempty"`
-	Version      string       `yaml:"version,omitempty"`
-	Source       interface{}  `yaml:"source,omitempty"`
-	Test         interface{}  `yaml:"tests,omitempty"`
-	Dependencies Dependencies `yaml:"dependencies,omitempty"`
-	Build        Build        `yaml:"build,omitempty"`
+	Name         string              `yaml:"name,omitempty"`
+	Version      string              `yaml:"version,omitempty"`
+	Source       interface{}         `yaml:"source,omitempty"`
+	Test         interface{}         `yaml:"tests,omitempty"`
+	Schemas      string              `yaml:"schemas,omitempty"`
+	Vars         map[string]string   `yaml:"vars,omitempty"`
+	Namespacing  *bool               `yaml:"namespacing,omitempty"`
+	Renames      map[string]string   `yaml:"renames,omitempty"`
+	Resolution   string              `yaml:"resolution,omitempty"`
+	MaxDepth     int                 `yaml:"maxDepth,omitempty"`
+	Overrides    map[string]string   `yaml:"overrides,omitempty"`
+	Exclude      []string            `yaml:"exclude,omitempty"`
+	Deprecated   *Deprecation        `yaml:"deprecated,omitempty"`
+	Policy       interface{}         `yaml:"policy,omitempty"`
+	SSH          *config.SSH         `yaml:"ssh,omitempty"`
+	Fetch        *config.FetchLimits `yaml:"fetch,omitempty"`
+	Dependencies Dependencies        `yaml:"dependencies,omitempty"`
+	Build        Build               `yaml:"build,omitempty"`
 }
 
 type Build struct {
@@ -47,6 +86,33 @@ type Build struct {
 type DependencyInfo struct {
 	Location  string `yaml:"location"`
 	Namespace string `yaml:"namespace,omitempty"`
+	// FallbackLocations are the locations to fetch the dependency from, in order, if it can't be fetched from Location;
+	// declared as the rest of a list of locations
+	FallbackLocations []string `yaml:"-"`
+	// Packages, if set, restricts the dependency's content to the Rego files of these packages, and those they depend on
+	Packages []string `yaml:"packages,omitempty"`
+	// Bundle marks the dependency as a compiled bundle, merged into the project's bundle as-is instead of being refactored
+	Bundle bool `yaml:"bundle,omitempty"`
+	// Entrypoints are entrypoints of the dependency, as declared by the dependency, to re-export as entrypoints of the
+	// project's bundle
+	Entrypoints []string `yaml:"entrypoints,omitempty"`
+	// Keyring is an armored PGP keyring; if set, the checked-out tag or commit of the git dependency must be signed by
+	// one of its keys. A relative path is relative to the declaring project file.
+	Keyring string `yaml:"keyring,omitempty"`
+	// IsolatedTests runs the tests of the dependency in a sandbox, against its original package paths, instead of against
+	// its namespaced copy
+	IsolatedTests bool `yaml:"isolatedTests,omitempty"`
+	// PartialClone clones the git dependency without the content of files, fetching only that of the files checked out;
+	// which are limited to the source and test directories declared by the dependency, if any
+	PartialClone bool `yaml:"partialClone,omitempty"`
+	// ShallowClone, if set, overrides whether the git dependency is cloned without history; as configured by the
+	// user-level config otherwise
+	ShallowClone *bool `yaml:"shallowClone,omitempty"`
+	// Subdirectory, if set, limits the git dependency to this slash-separated directory of its repository; only which is
+	// checked out, from a partial clone
+	Subdirectory string `yaml:"subdirectory,omitempty"`
+	// Sha256 is the hex-encoded sha256 digest the tarball of an HTTP(S) dependency must match
+	Sha256 string `yaml:"sha256,omitempty"`
 }
 
 type Dependency struct {
@@ -55,6 +121,19 @@ type Dependency struct {
 	Project          *Project    `yaml:"-"`
 	ParentDependency *Dependency `yaml:"-"`
 	dirPath          string      `yaml:"-"`
+	// vars are the variables declared by the project declaring the dependency
+	vars map[string]string
+	// namespacePrefix is the configured prefix applied to the top-level namespace of the dependency
+	namespacePrefix string
+	// namespacingDisabled is true if the project declaring the dependency has disabled namespacing of all its
+	// dependencies; only that of the root project applies, to its whole dependency tree
+	namespacingDisabled bool
+	// overrides are the locations forced on transitive dependencies by the project declaring the dependency, with any
+	// variables expanded; only those of the root project apply
+	overrides map[string]string
+	// exclusions are the transitive dependencies excluded by the project declaring the dependency; only those of the
+	// root project apply
+	exclusions map[string]bool
 }
 
 type Dependencies map[string]Dependency
@@ -99,9 +178,115 @@ func (ds *Dependencies) UnmarshalYAML(unmarshal func(interface{}) error) error {
 				namespace = k
 			}
 			info = DependencyInfo{
-				Location:  v.(map[string]interface{})["location"].(string),
 				Namespace: namespace,
 			}
+			switch location := v.(map[string]interface{})["location"].(type) {
+			case string:
+				info.Location = location
+			case []interface{}:
+				for _, l := range location {
+					s, ok := l.(string)
+					if !ok {
+						return fmt.Errorf("invalid location type: %T", l)
+					}
+					if info.Location == "" {
+						info.Location = s
+					} else {
+						info.FallbackLocations = append(info.FallbackLocations, s)
+					}
+				}
+				if info.Location == "" {
+					return fmt.Errorf("no location of dependency %s", k)
+				}
+			default:
+				return fmt.Errorf("invalid location type: %T", location)
+			}
+			if packages := v.(map[string]interface{})["packages"]; packages != nil {
+				list, ok := packages.([]interface{})
+				if !ok {
+					return fmt.Errorf("invalid packages type: %T", packages)
+				}
+				for _, pkg := range list {
+					name, ok := pkg.(string)
+					if !ok {
+						return fmt.Errorf("invalid package type: %T", pkg)
+					}
+					info.Packages = append(info.Packages, name)
+				}
+			}
+			if bundle := v.(map[string]interface{})["bundle"]; bundle != nil {
+				b, ok := bundle.(bool)
+				if !ok {
+					return fmt.Errorf("invalid bundle type: %T", bundle)
+				}
+				if b && len(info.Packages) > 0 {
+					return fmt.Errorf("packages of dependency %s can't be filtered, as it is a compiled bundle", k)
+				}
+				info.Bundle = b
+			}
+			if entrypoints := v.(map[string]interface{})["entrypoints"]; entrypoints != nil {
+				list, ok := entrypoints.([]interface{})
+				if !ok {
+					return fmt.Errorf("invalid entrypoints type: %T", entrypoints)
+				}
+				for _, entrypoint := range list {
+					name, ok := entrypoint.(string)
+					if !ok {
+						return fmt.Errorf("invalid entrypoint type: %T", entrypoint)
+					}
+					info.Entrypoints = append(info.Entrypoints, name)
+				}
+			}
+			if keyring := v.(map[string]interface{})["keyring"]; keyring != nil {
+				path, ok := keyring.(string)
+				if !ok {
+					return fmt.Errorf("invalid keyring type: %T", keyring)
+				}
+				info.Keyring = path
+			}
+			if isolated := v.(map[string]interface{})["isolatedTests"]; isolated != nil {
+				b, ok := isolated.(bool)
+				if !ok {
+					return fmt.Errorf("invalid isolatedTests type: %T", isolated)
+				}
+				info.IsolatedTests = b
+			}
+			if partial := v.(map[string]interface{})["partialClone"]; partial != nil {
+				b, ok := partial.(bool)
+				if !ok {
+					return fmt.Errorf("invalid partialClone type: %T", partial)
+				}
+				info.PartialClone = b
+			}
+			if shallow := v.(map[string]interface{})["shallowClone"]; shallow != nil {
+				b, ok := shallow.(bool)
+				if !ok {
+					return fmt.Errorf("invalid shallowClone type: %T", shallow)
+				}
+				info.ShallowClone = &b
+			}
+			if subdir := v.(map[string]interface{})["subdirectory"]; subdir != nil {
+				dir, ok := subdir.(string)
+				if !ok {
+					return fmt.Errorf("invalid subdirectory type: %T", subdir)
+				}
+				clean := path.Clean(dir)
+				if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+					return fmt.Errorf("invalid subdirectory of dependency %s: %s is not within the repository", k, dir)
+				}
+				info.Subdirectory = clean
+			}
+			if sha := v.(map[string]interface{})["sha256"]; sha != nil {
+				digest, ok := sha.(string)
+				if !ok {
+					return fmt.Errorf("invalid sha256 type: %T", sha)
+				}
+				hex := strings.TrimPrefix(digest, "sha256:")
+				if len(hex) != 64 || strings.Trim(strings.ToLower(hex), "0123456789abcdef") != "" {
+					return fmt.Errorf("invalid sha256 of dependency %s: expected 64 hexadecimal digits", k)
+				}
+				info.Sha256 = digest
+			}
 		}
 		(*ds)[k] = Dependency{
 			DependencyInfo: info,
@@ -124,26 +309,119 @@ func (ds *Dependencies) MarshalYAML() (interface{}, error) {
 func (d Dependency) MarshalYAML() (interface{}, error) {
 	printer.Debug("Marshalling dependency %s", d.Name)
 
-	if d.Namespace == d.Name {
+	if d.Namespace == d.Name && len(d.Packages) == 0 && !d.Bundle && len(d.Entrypoints) == 0 && d.Keyring == "" &&
+		!d.IsolatedTests && !d.PartialClone && d.ShallowClone == nil && d.Sha256 == "" &&
+		d.Subdirectory == "" && len(d.FallbackLocations) == 0 {
 		return d.Location, nil
 	}
 
+	m := map[string]interface{}{
+		"location": d.Location,
+	}
+	if len(d.FallbackLocations) > 0 {
+		m["location"] = append([]string{d.Location}, d.FallbackLocations...)
+	}
 	if d.Namespace == "" {
-		return map[string]interface{}{
-			"namespace": false,
-			"location":  d.Location,
-		}, nil
+		m["namespace"] = false
+	} else if d.Namespace != d.Name {
+		m["namespace"] = d.Namespace
+	}
+	if len(d.Packages) > 0 {
+		m["packages"] = d.Packages
+	}
+	if d.Bundle {
+		m["bundle"] = true
+	}
+	if len(d.Entrypoints) > 0 {
+		m["entrypoints"] = d.Entrypoints
+	}
+	if d.Keyring != "" {
+		m["keyring"] = d.Keyring
+	}
+	if d.IsolatedTests {
+		m["isolatedTests"] = true
+	}
+	if d.PartialClone {
+		m["partialClone"] = true
+	}
+	if d.ShallowClone != nil {
+		m["shallowClone"] = *d.ShallowClone
+	}
+	if d.Sha256 != "" {
+		m["sha256"] = d.Sha256
+	}
+	if d.Subdirectory != "" {
+		m["subdirectory"] = d.Subdirectory
+	}
+	return m, nil
+}
 
+// id returns the id of the dependency, naming its directory; dependencies of the same id throughout the dependency tree
+// are fetched once, into a single directory. With namespacing disabled, the content of dependencies doesn't depend on
+// their namespace, and so dependencies of the same location share their directory wherever they're declared.
+func (d Dependency) id() string {
+	namespace := d.fullNamespace()
+	if !d.namespaced() {
+		namespace = ""
 	}
+	if d.Subdirectory != "" {
+		// Subdirectories of the same repository are distinct dependencies
+		return DepId(namespace, d.location()+"//"+d.Subdirectory)
+	}
+	return DepId(namespace, d.location())
+}
 
-	return map[string]interface{}{
-		"namespace": d.Namespace,
-		"location":  d.Location,
-	}, nil
+// namespaced returns false if the root project has disabled namespacing of all its dependencies.
+func (d Dependency) namespaced() bool {
+	if d.ParentDependency != nil {
+		return d.ParentDependency.namespaced()
+	}
+	return !d.namespacingDisabled
 }
 
-func (d Dependency) id() string {
-	return DepId(d.fullNamespace(), d.Location)
+// location returns the dependency location, with any variables referenced as ${name} expanded; or the location forced
+// by the overrides of the root project, if any.
+func (d Dependency) location() string {
+	if location, ok := d.override(); ok {
+		return location
+	}
+	location, _ := expandVars(d.Location, d.vars)
+	return location
+}
+
+// locations returns the location of the dependency followed by its fallback locations, with any variables expanded.
+// Overridden dependencies have no fallback locations.
+func (d Dependency) locations() []string {
+	locations := []string{d.location()}
+	if _, ok := d.override(); ok {
+		return locations
+	}
+	for _, fallback := range d.FallbackLocations {
+		location, _ := expandVars(fallback, d.vars)
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+var varPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)}`)
+
+// expandVars replaces all ${name} references in s with the value of the named variable.
+// References to undeclared variables are left as-is, and reported as an error.
+func expandVars(s string, vars map[string]string) (string, error) {
+	var undefined []string
+	expanded := varPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varPattern.FindStringSubmatch(ref)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		undefined = append(undefined, name)
+		return ref
+	})
+
+	if len(undefined) > 0 {
+		return expanded, fmt.Errorf("undefined variable(s): %s", strings.Join(undefined, ", "))
+	}
+	return expanded, nil
 }
 
 func DepId(namespace, location string) string {
@@ -157,8 +435,71 @@ func (d Dependency) dir(rootDir string) string {
 	return filepath.Join(rootDir, d.id())
 }
 
-func (d Dependency) Update(rootDir, depsRootDir string) error {
-	targetDir := d.dir(depsRootDir)
+// contentDir returns the directory of the dependency's content within its fetched targetDir: the declared subdirectory,
+// if any.
+func (d Dependency) contentDir(targetDir string) string {
+	if d.Subdirectory == "" {
+		return targetDir
+	}
+	return filepath.Join(targetDir, filepath.FromSlash(d.Subdirectory))
+}
+
+// updateContext holds the state shared by all dependencies updated as part of a single project update.
+type updateContext struct {
+	rootDir     string
+	depsRootDir string
+	// lock is the lock of the previous update, consulted for pinning locations to previously resolved states
+	lock *Lock
+	// newLock records the resolved state of all locations in this update
+	newLock *Lock
+	// config is the user-level configuration, for expanding short-name locations
+	config *config.Config
+	// namespacing is false if the root project has disabled namespacing of all dependencies
+	namespacing bool
+	// updated holds the ids of all dependencies updated so far, so that dependencies occurring multiple times in the
+	// dependency tree are only fetched once
+	updated map[string]bool
+	// cache is the configured remote cache of fetched dependencies; nil if none
+	cache *remoteCache
+	// metadata caches the ODM metadata of OCI repositories, by registry and repository
+	metadata map[string]*oci.Metadata
+	// resolver selects versions for dependencies constrained in multiple places
+	resolver *resolver
+	// plan holds the planned dependencies by declared location, when executing a plan; nil otherwise
+	plan map[string]PlannedDependency
+	// strict fails the update, instead of warning, if a locked git tag has moved
+	strict bool
+	// refresh re-resolves floating git locations to the current head of their branch, instead of their pinned commit
+	refresh bool
+	// frozen fails the update of locations not in the lock file
+	frozen bool
+	// fetches limits the number of concurrent fetches, by holding a token for each fetch in progress
+	fetches chan struct{}
+	// recording records, or replays, the remote responses resolution depends on; nil if neither
+	recording *ResolutionRecording
+	// maxDepth is the maximum depth of the dependency tree, direct dependencies being at depth 1; 0 if unlimited
+	maxDepth int
+	// mu guards the state shared by dependencies updated concurrently
+	mu sync.Mutex
+}
+
+func (d Dependency) update(ctx *updateContext) error {
+	if ctx.maxDepth > 0 && d.depth() > ctx.maxDepth {
+		return errs.New(errs.ResolutionFailed, "dependency chain %s exceeds the maximum depth of %d", d.chain(),
+			ctx.maxDepth)
+	}
+
+	if !ctx.markUpdated(d.id()) {
+		printer.Debug("Dependency %s (%s) already updated", d.Name, d.location())
+		return nil
+	}
+	if location, ok := d.override(); ok {
+		declared, _ := expandVars(d.Location, d.vars)
+		ctx.resolver.explain(ResolutionEvent{Kind: ExplainOverride, Key: location, Dependency: d.path(),
+			Constraint: declared, Version: location, Reason: "overrides of the root project"})
+	}
+
+	targetDir := d.dir(ctx.depsRootDir)
 
 	if err := os.RemoveAll(targetDir); err != nil {
 		return err
@@ -172,36 +513,59 @@ func (d Dependency) Update(rootDir, depsRootDir string) error {
 		return fmt.Errorf("failed to create destination directory %s: %w", targetDir, err)
 	}
 
-	if strings.HasPrefix(d.Location, "git+") {
-		printer.Debug("Updating git dependency %s", d.Namespace)
-		if err := d.updateGit(targetDir); err != nil {
+	if err := d.fetchAny(ctx, targetDir); err != nil {
+		return err
+	}
+
+	targetDir = d.contentDir(targetDir)
+	if !utils.IsDir(targetDir) {
+		return errs.New(errs.FetchFailed, "subdirectory %s of dependency %s (%s) does not exist", d.Subdirectory, d.Name,
+			d.location())
+	}
+	d.dirPath = targetDir
+	if d.isBundle() {
+		// Compiled bundles may contain optimized or generated code that can't be refactored, and so are used as-is; OPA
+		// honors their roots when merging them into the project's bundle
+		printer.Debug("Dependency %s is a compiled bundle, skipping namespace refactoring", d.Name)
+		return nil
+	}
+
+	if d.IsolatedTests {
+		if err := d.prepareSandbox(ctx, targetDir); err != nil {
 			return err
 		}
-	} else if strings.HasPrefix(d.Location, "file:") {
-		printer.Debug("Updating git dependency %s", d.Namespace)
-		printer.Debug("Updating transitive dependencies for %s", d.Namespace)
-		if err := d.updateLocal(rootDir, targetDir); err != nil {
-			return err
+	}
+
+	if err := applyBundleRoots(targetDir); err != nil {
+		return fmt.Errorf("failed to apply bundle roots of dependency %s: %w", d.Name, err)
+	}
+
+	if len(d.Packages) > 0 {
+		if err := filterPackages(targetDir, d.Packages); err != nil {
+			return fmt.Errorf("failed to filter packages of dependency %s: %w", d.Name, err)
 		}
-	} else {
-		return fmt.Errorf("unsupported dependency location: %s", d.Location)
 	}
 
-	depProjectFile := fmt.Sprintf("%s/opa.project", targetDir)
+	depProjectFile := projectFilePath(targetDir)
 	if utils.FileExists(depProjectFile) {
 		var err error
 		d.Project, err = ReadProjectFromFile(depProjectFile, false)
 		if err != nil {
 			return err
 		}
+		d.excludeDependencies(d.Project)
+		if deprecation := d.Project.Deprecated; deprecation != nil {
+			printer.Warn("dependency %s (%s) is deprecated: %s", d.Name, d.location(), deprecation)
+		}
 	}
-	d.dirPath = targetDir
 
-	if err := d.updateTransitive(rootDir, depsRootDir); err != nil {
+	if err := d.updateTransitive(ctx); err != nil {
 		return fmt.Errorf("failed to update transitive dependencies for %s: %w", d.Namespace, err)
 	}
 
-	if namespace := d.fullNamespace(); namespace != "" {
+	if !ctx.namespacing {
+		printer.Debug("Namespacing disabled, skipping namespace refactoring of %s", d.Name)
+	} else if namespace := d.fullNamespace(); namespace != "" {
 		var dirs []string
 		if srcDirs := d.SourceDirs(); len(srcDirs) > 0 {
 			dirs = append(dirs, srcDirs...)
@@ -212,9 +576,8 @@ func (d Dependency) Update(rootDir, depsRootDir string) error {
 		dirs = utils.FilterExistingFiles(dirs)
 
 		if len(dirs) > 0 {
-			opa := utils.NewOpa(dirs...)
-			if err := opa.Refactor("data", fmt.Sprintf("data.%s", namespace)); err != nil {
-				return fmt.Errorf("failed to refactor namespace %s: %w", d.Namespace, err)
+			if err := refactorNamespace(targetDir, dirs, namespace); err != nil {
+				return errs.New(errs.RefactorFailed, "failed to refactor namespace %s: %w", d.Namespace, err)
 			}
 		} else {
 			printer.Debug("Dependency %s has no source, skipping namespace refactoring", d.Name)
@@ -224,16 +587,128 @@ func (d Dependency) Update(rootDir, depsRootDir string) error {
 	return nil
 }
 
+// fetchAny fetches the dependency into targetDir from the first of its locations it can be fetched from, recording the
+// fallback location fetched from, if any, in the new lock. Only failures to fetch fall back to the next location;
+// fetched content not matching the lock fails the update. Plans are executed against the planned location only.
+func (d Dependency) fetchAny(ctx *updateContext, targetDir string) error {
+	locations := d.locations()
+	if ctx.plan != nil {
+		locations = locations[:1]
+	}
+
+	for i, declared := range locations {
+		location, err := d.sourceOf(ctx.config, declared)
+		if err != nil {
+			return err
+		}
+		if err := d.checkPlanned(ctx, location); err != nil {
+			return err
+		}
+		if err := d.checkFrozen(ctx, location); err != nil {
+			return err
+		}
+
+		err = d.fetchWithRetries(ctx, location, targetDir)
+		if err == nil {
+			if i > 0 {
+				ctx.newLock.modify(d.location(), func(entry *LockedDependency) {
+					entry.Source = declared
+				})
+			}
+			return nil
+		}
+		if i == len(locations)-1 || !errs.Is(err, errs.FetchFailed) {
+			return err
+		}
+		printer.Warn("failed to fetch dependency %s from %s, trying %s: %s", d.Name, declared, locations[i+1], err)
+		clearDir(targetDir)
+		ctx.newLock.set(d.location(), LockedDependency{})
+	}
+	return nil
+}
+
+// fetch fetches the content of the dependency from location into targetDir, within the limit of parallel fetches.
+func (d Dependency) fetch(ctx *updateContext, location string, targetDir string) error {
+	release := ctx.acquireFetch()
+	defer release()
+
+	if d.Subdirectory != "" && !strings.HasPrefix(location, "git+") {
+		return errs.New(errs.InvalidLocation, "subdirectory of dependency %s is only supported for git locations: %s",
+			d.Namespace, location)
+	}
+
+	if strings.HasPrefix(location, "git+") {
+		printer.Debug("Updating git dependency %s", d.Namespace)
+		stop := timing.Start(timing.Fetch, location)
+		err := d.updateGit(ctx, location, targetDir)
+		stop()
+		if err != nil {
+			return err
+		}
+		// Only the content of tagged and commit references, and of floating references still pinned to the locked commit,
+		// is expected to stay the same across updates
+		_, ref, _ := parseGitUrl(location)
+		newLocked, _ := ctx.newLock.Get(d.location())
+		tag := ref.tag()
+		if ref.resolvable() {
+			tag = newLocked.Tag
+		}
+		locked, _ := ctx.lock.Get(d.location())
+		if ref.isCommit() || (tag != "" && isLocalGitTag(targetDir, tag)) || locked.Commit == newLocked.Commit {
+			if err := d.verifyContent(ctx, targetDir); err != nil {
+				return err
+			}
+		} else if err := d.recordContent(ctx, targetDir); err != nil {
+			return err
+		}
+	} else if strings.HasPrefix(location, "file:") {
+		printer.Debug("Updating git dependency %s", d.Namespace)
+		printer.Debug("Updating transitive dependencies for %s", d.Namespace)
+		if err := d.updateLocal(ctx.rootDir, targetDir); err != nil {
+			return err
+		}
+	} else if isTarballLocation(location) {
+		printer.Debug("Updating tarball dependency %s", d.Namespace)
+		stop := timing.Start(timing.Fetch, location)
+		err := d.updateTarball(ctx, location, targetDir)
+		stop()
+		if err != nil {
+			return err
+		}
+		if err := d.verifyContent(ctx, targetDir); err != nil {
+			return err
+		}
+	} else if strings.HasPrefix(location, "oci://") {
+		printer.Debug("Updating OCI dependency %s", d.Namespace)
+		stop := timing.Start(timing.Fetch, location)
+		err := d.updateOci(ctx, location, targetDir)
+		stop()
+		if err != nil {
+			return err
+		}
+		if err := d.verifyContent(ctx, targetDir); err != nil {
+			return err
+		}
+	} else {
+		return errs.New(errs.InvalidLocation, "unsupported dependency location: %s", location)
+	}
+	return nil
+}
+
 func (d Dependency) Load(rootDir, targetDir string) (*Dependency, error) {
 	targetDir = d.dir(targetDir)
-	d.dirPath = targetDir
-	depProjectFile := fmt.Sprintf("%s/opa.project", targetDir)
+	d.dirPath = d.contentDir(targetDir)
+	if d.isBundle() {
+		return &d, nil
+	}
+	depProjectFile := projectFilePath(d.dirPath)
 	if utils.FileExists(depProjectFile) {
 		var err error
 		d.Project, err = ReadProjectFromFile(depProjectFile, false)
 		if err != nil {
 			return nil, err
 		}
+		d.excludeDependencies(d.Project)
 	}
 	if err := d.loadTransitive(rootDir, targetDir); err != nil {
 		return nil, fmt.Errorf("failed to update transitive dependencies for %s: %w", d.Namespace, err)
@@ -242,23 +717,24 @@ func (d Dependency) Load(rootDir, targetDir string) (*Dependency, error) {
 }
 
 func (d Dependency) updateLocal(rootDir, targetDir string) error {
-	sourceLocation, err := utils.NormalizeFilePath(d.Location)
+	sourceLocation, err := d.localPath(rootDir)
 	if err != nil {
 		return err
 	}
 
-	if !filepath.IsAbs(sourceLocation) {
-		sourceLocation = filepath.Join(rootDir, sourceLocation)
-	}
-
 	if !utils.FileExists(sourceLocation) {
-		return fmt.Errorf("dependency %s does not exist", sourceLocation)
+		return errs.New(errs.FetchFailed, "dependency %s does not exist", sourceLocation)
 	}
 
-	if !utils.IsDir(sourceLocation) && utils.GetFileName(sourceLocation) == "opa.project" {
+	if !utils.IsDir(sourceLocation) && isProjectFileName(utils.GetFileName(sourceLocation)) {
 		sourceLocation = utils.GetParentDir(sourceLocation)
 	}
 
+	defer timing.Start(timing.Copy, d.location())()
+	if !utils.IsDir(sourceLocation) && isArchive(sourceLocation) {
+		// The archive's bundle manifest, if any, is extracted along with its content, and its roots applied on update
+		return extractArchive(sourceLocation, targetDir)
+	}
 	// Ignore empty files, as an empty module will break the 'opa refactor' command
 	if err := utils.CopyAll(sourceLocation, targetDir, []string{".opa"}, true); err != nil {
 		return err
@@ -267,52 +743,459 @@ func (d Dependency) updateLocal(rootDir, targetDir string) error {
 	return nil
 }
 
-func (d Dependency) updateGit(targetDir string) error {
-	url, tag, err := parseGitUrl(d.Location)
+// localPath returns the absolute path of a local (file:) dependency.
+func (d Dependency) localPath(rootDir string) (string, error) {
+	sourceLocation, err := utils.NormalizeFilePath(d.location())
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(sourceLocation) {
+		sourceLocation = filepath.Join(rootDir, sourceLocation)
+	}
+	return sourceLocation, nil
+}
+
+// updateGit clones a git repository into targetDir, checking out the tag, branch or full commit hash of the location, if
+// any.
+// With a remote cache configured, the reference is resolved remotely first, and the repository restored from the cache
+// if present; otherwise, the verified clone is stored in the cache.
+// As git tags are mutable, the commit a tag points at is recorded in the new lock. If a tag has moved since it was locked,
+// the locked commit is checked out with a warning; or the update fails, if strict. Floating locations, tracking a branch
+// or HEAD, are pinned to the commit they resolved to in the new lock; see pinFloatingCommit.
+func (d Dependency) updateGit(ctx *updateContext, location string, targetDir string) error {
+	url, ref, err := parseGitUrl(location)
 	if err != nil {
 		return err
 	}
+	ref, resolvedTag, err := d.resolveGitVersion(ctx, url, ref)
+	if err != nil {
+		return err
+	}
+	if resolvedTag != "" {
+		ctx.newLock.modify(d.location(), func(entry *LockedDependency) {
+			entry.Tag = resolvedTag
+		})
+	}
 
-	repo, err := git.PlainClone(targetDir, false, &git.CloneOptions{
-		URL:      url,
-		Progress: printer.DebugPrinter(),
-	})
+	// Partial clones only hold part of the repository's content, and so aren't cached
+	cache := ctx.cache
+	if d.PartialClone || d.Subdirectory != "" {
+		cache = nil
+	}
+
+	hash := d.plannedGitHash(ctx)
+	planned := !hash.IsZero()
+	if cache != nil && !planned {
+		if hash, err = remoteGitHash(ctx.recording, url, ref); err != nil {
+			printer.Debug("Not using cache for %s: %s", location, err)
+		}
+	}
+
+	var repo *git.Repository
+	cloned, partial, shallow := false, false, false
+	if cache != nil && !hash.IsZero() && cache.restore(gitCacheKey(hash), targetDir) {
+		if restored, err := localGitHash(targetDir, ref); err == nil && restored == hash {
+			repo, _ = git.PlainOpen(targetDir)
+		}
+		if repo == nil {
+			printer.Info("Ignoring cache entry for %s not matching %s", location, hash)
+			clearDir(targetDir)
+		}
+	}
+
+	if repo == nil && (d.PartialClone || d.Subdirectory != "") {
+		if repo, err = partialClone(url, targetDir); err != nil {
+			return err
+		}
+		cloned, partial = repo != nil, repo != nil
+	}
+	if repo == nil && d.shallowClone(ctx) {
+		if repo, err = shallowClone(ctx, url, ref, targetDir); err != nil {
+			return err
+		}
+		cloned, shallow = repo != nil, repo != nil
+	}
+	if repo == nil {
+		if repo, err = fullClone(url, targetDir); err != nil {
+			return err
+		}
+		cloned = true
+	}
+	if err := ctx.recording.clonedGitRefs(url, repo, !partial); err != nil {
+		return err
+	}
+
+	var commit plumbing.Hash
+	switch {
+	case ref.tag() != "" && isGitTag(repo, ref.tag()):
+		commit, err = d.lockTagCommit(ctx, repo, url, ref.tag())
+	case ref.isCommit():
+		commit, err = d.lockCommit(ctx, repo, url, ref.name)
+	default:
+		commit, err = d.pinFloatingCommit(ctx, repo, url, ref)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to clone git repository %s: %w", url, err)
+		return err
+	}
+	if planned {
+		commit = hash
+	}
+
+	// The commit to check out, if pinned by the lock file or planned, may not be the tip the shallow clone holds
+	if _, err := repo.CommitObject(commit); err != nil && shallow {
+		printer.Debug("Commit %s of %s not in its shallow clone, cloning in full", commit, url)
+		clearDir(targetDir)
+		if repo, err = fullClone(url, targetDir); err != nil {
+			return err
+		}
+		if err := ctx.recording.clonedGitRefs(url, repo, true); err != nil {
+			return err
+		}
 	}
 
-	if tag != "" {
+	if partial {
+		if err := sparseCheckout(url, targetDir, commit, d.Subdirectory); err != nil {
+			return err
+		}
+	} else if head, err := repo.Head(); err != nil || head.Hash() != commit || ref.isCommit() {
 		w, err := repo.Worktree()
 		if err != nil {
 			return fmt.Errorf("failed to get worktree for git repository %s: %w", url, err)
 		}
+		// Commits are checked out forcibly, so that the worktree holds exactly the pinned content
+		if err := w.Checkout(&git.CheckoutOptions{Hash: commit, Force: ref.isCommit()}); err != nil {
+			return errs.New(errs.FetchFailed, "failed to checkout commit %s for git repository %s: %w", commit, url,
+				err)
+		}
+	}
+
+	if err := pullLfsContent(url, targetDir); err != nil {
+		return err
+	}
+
+	if err := d.verifySignature(ctx, repo, url, ref.tag()); err != nil {
+		return err
+	}
+
+	if cloned && cache != nil && !hash.IsZero() {
+		if current, err := localGitHash(targetDir, ref); err == nil && current == hash {
+			cache.store(gitCacheKey(hash), targetDir)
+		}
+	}
+
+	return nil
+}
+
+// lockTagCommit records the commit the tag points at in the new lock, and returns the commit to check out: the locked
+// commit, if the tag has moved since it was locked; failing instead, if strict.
+func (d Dependency) lockTagCommit(ctx *updateContext, repo *git.Repository, url string, tag string) (plumbing.Hash,
+	error) {
+	commit, err := tagCommit(repo, tag)
+	if err != nil {
+		return plumbing.ZeroHash, errs.New(errs.FetchFailed, "failed to resolve tag '%s' of git repository %s: %w", tag,
+			url, err)
+	}
+
+	if locked, ok := ctx.lock.Get(d.location()); ok && locked.Commit != "" && locked.Commit != commit.String() {
+		msg := fmt.Sprintf("tag '%s' of git repository %s was moved from commit %s to %s since it was locked", tag, url,
+			locked.Commit, commit)
+		if ctx.strict {
+			return plumbing.ZeroHash, errs.New(errs.TagMoved, "%s", msg)
+		}
+		printer.Warn("%s; using the locked commit", msg)
+		commit = plumbing.NewHash(locked.Commit)
+	}
+
+	ctx.newLock.modify(d.location(), func(entry *LockedDependency) {
+		entry.Commit = commit.String()
+	})
+	return commit, nil
+}
+
+// lockCommit resolves the commit of the given full hash in the repository, and records it in the new lock.
+func (d Dependency) lockCommit(ctx *updateContext, repo *git.Repository, url string, hash string) (plumbing.Hash,
+	error) {
+	commit := plumbing.NewHash(hash)
+	if _, err := repo.CommitObject(commit); err != nil {
+		return plumbing.ZeroHash, errs.New(errs.FetchFailed, "failed to resolve commit %s of git repository %s: %w", hash,
+			url, err)
+	}
+	ctx.newLock.modify(d.location(), func(entry *LockedDependency) {
+		entry.Commit = commit.String()
+	})
+	return commit, nil
+}
+
+// pinFloatingCommit records the commit the floating reference of the location, a branch or else HEAD, resolved to in
+// the new lock, along with the tracked branch; and returns the commit to check out. Unless floating references are
+// refreshed, the commit pinned by the lock is preferred over the current head of the branch; a planned commit is
+// always preferred.
+func (d Dependency) pinFloatingCommit(ctx *updateContext, repo *git.Repository, url string, gitRef gitRef) (
+	plumbing.Hash, error) {
+	branch := gitRef.name
+	var ref *plumbing.Reference
+	var err error
+	if branch == "" {
+		ref, err = repo.Head()
+		if err == nil && ref.Name().IsBranch() {
+			branch = ref.Name().Short()
+		}
+	} else {
+		ref, err = repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch), true)
+	}
+	if err != nil && gitRef.isBranch() {
+		return plumbing.ZeroHash, errs.New(errs.FetchFailed, "failed to resolve branch '%s' of git repository %s: %w",
+			branch, url, err)
+	} else if err != nil {
+		return plumbing.ZeroHash, errs.New(errs.FetchFailed, "failed to resolve reference '%s' of git repository %s; "+
+			"not a tag or branch: %w", branch, url, err)
+	}
 
-		if err := w.Checkout(&git.CheckoutOptions{
-			Branch: plumbing.NewTagReferenceName(tag),
-		}); err != nil {
-			return fmt.Errorf("failed to checkout tag '%s' for git repository %s: %w", tag, url, err)
+	commit := ref.Hash()
+	if planned := d.plannedGitHash(ctx); !planned.IsZero() {
+		commit = planned
+	} else if locked, ok := ctx.lock.Get(d.location()); ok && locked.Commit != "" && !ctx.refresh {
+		if locked.Commit != commit.String() {
+			printer.Debug("Using commit %s of %s pinned by the lock file, instead of %s", locked.Commit, url, commit)
 		}
+		commit = plumbing.NewHash(locked.Commit)
 	} else {
-		printer.Debug("No tag specified, using HEAD")
+		printer.Debug("Pinning %s to commit %s", d.location(), commit)
+	}
+
+	ctx.newLock.modify(d.location(), func(entry *LockedDependency) {
+		entry.Commit = commit.String()
+		entry.Branch = branch
+	})
+	return commit, nil
+}
+
+// tagCommit returns the commit the tag points at, peeling annotated tags.
+func tagCommit(repo *git.Repository, tag string) (plumbing.Hash, error) {
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if tagObject, err := repo.TagObject(ref.Hash()); err == nil {
+		commit, err := tagObject.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return commit.Hash, nil
+	}
+	return ref.Hash(), nil
+}
+
+// updateOci pulls an OCI artifact into targetDir. Locations pinned by digest are pulled as-is; for locations pinned
+// by tag or version range, the digest recorded in the lock file is preferred, so that tag mutation in the registry
+// cannot change the pulled content. Version ranges not in the lock file are resolved to the highest matching semver
+// tag in the repository. The pulled digest is recorded in the new lock.
+// Lock entries are keyed by the declared location, so that short-name locations stay locked when the registry they
+// expand to changes.
+// Versions yanked in the repository's metadata are skipped when resolving version ranges, and refused when pinned by
+// tag; unless pinned by the lock file, in which case they are pulled with a warning.
+// Content not matching its digest is quarantined. When executing a plan, the planned digest is pulled instead.
+func (d Dependency) updateOci(ctx *updateContext, location string, targetDir string) error {
+	ref, err := oci.ParseReference(strings.TrimPrefix(location, "oci://"))
+	if err != nil {
+		return errs.New(errs.InvalidLocation, "invalid OCI location %s: %w", location, err)
+	}
+
+	client := oci.NewClient()
+	var resolvedTag string
+	var deprecation *Deprecation
+	locked := false
+	if !isVersioned(ref.Tag) || ref.Digest != "" {
+		ctx.resolver.require(ref.Registry+"/"+ref.Repository, ociRevision(ref), d.path())
+	}
+	if ref.Digest == "" {
+		if planned, ok := ctx.plan[d.location()]; ok && planned.Revision != "" {
+			ref = ref.WithDigest(planned.Revision)
+			resolvedTag = planned.Tag
+			locked = true
+		} else if lockedDep, ok := ctx.lock.Get(d.location()); ok && lockedDep.Digest != "" {
+			printer.Debug("Using locked digest %s for %s", lockedDep.Digest, location)
+			ref = ref.WithDigest(lockedDep.Digest)
+			resolvedTag = lockedDep.Tag
+			locked = true
+		} else if isVersioned(ref.Tag) {
+			tag, err := ctx.resolver.resolve(ref.Registry+"/"+ref.Repository, ref.Tag, d.path(), func() ([]string, error) {
+				return unyankedTags(ctx, client, ref)
+			})
+			if err != nil {
+				return errs.New(errs.ResolutionFailed, "failed to resolve version of %s: %w", location, err)
+			}
+			if tag != ref.Tag || utils.IsVersionRange(ref.Tag) {
+				printer.Debug("Resolved version %s of %s to tag %s", ref.Tag, location, tag)
+				resolvedTag = tag
+			}
+			ref.Tag = tag
+		}
+	}
+	tagRef := ref
+	if digest, ok := ctx.recording.ociDigest(ref); ok && ref.Digest == "" {
+		printer.Debug("Using recorded digest %s for %s", digest, ref)
+		ref = ref.WithDigest(digest)
+	}
+
+	if version := ref.Tag; version != "" {
+		if resolvedTag != "" {
+			version = resolvedTag
+		}
+		metadata, err := repositoryMetadata(ctx, client, ref)
+		if err != nil {
+			return err
+		}
+		if reason, yanked := metadata.YankReason(version); yanked {
+			if !locked && ref.Digest == "" {
+				return errs.New(errs.VersionYanked, "version %s of %s is yanked: %s", version, location, reason)
+			}
+			printer.Warn("version %s of %s is yanked: %s", version, location, reason)
+		}
+		if deprecation = deprecationFromOci(metadata.DeprecationOf(version)); deprecation != nil {
+			printer.Warn("version %s of %s is deprecated: %s", version, location, deprecation)
+		}
+	}
+
+	if ctx.cache != nil && ref.Digest == "" {
+		if ref.Digest, err = client.Resolve(ref); err != nil {
+			return errs.New(errs.FetchFailed, "failed to resolve %s: %w", ref, err)
+		}
+	}
+
+	digest := ref.Digest
+	if !ctx.cache.restore(ociCacheKey(digest), targetDir) {
+		if digest, err = client.Pull(ref, targetDir); errors.Is(err, oci.ErrDigestMismatch) {
+			return d.quarantine(ctx, targetDir, err.Error(), nil, nil)
+		} else if err != nil {
+			return errs.New(errs.FetchFailed, "failed to pull %s: %w", ref, err)
+		}
+		// Pull verifies the content against the digest
+		ctx.cache.store(ociCacheKey(digest), targetDir)
+	}
+
+	ctx.recording.recordOciDigest(tagRef, digest)
+	ctx.newLock.set(d.location(), LockedDependency{Digest: digest, Tag: resolvedTag, Deprecation: deprecation})
+	return nil
+}
+
+// ociRevision returns the revision an OCI reference is pinned to: its digest, if any, or else its tag.
+func ociRevision(ref oci.Reference) string {
+	if ref.Digest != "" {
+		return ref.Digest
+	}
+	return ref.Tag
+}
+
+// repositoryMetadata returns the ODM metadata of the reference's repository, fetching it once per update.
+func repositoryMetadata(ctx *updateContext, client *oci.Client, ref oci.Reference) (*oci.Metadata, error) {
+	key := ref.Registry + "/" + ref.Repository
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if metadata, ok := ctx.metadata[key]; ok {
+		return metadata, nil
+	}
+	metadata, err := ctx.recording.ociMetadata(key, func() (*oci.Metadata, error) {
+		return client.Metadata(ref)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx.metadata[key] = metadata
+	return metadata, nil
+}
+
+// unyankedTags lists the tags of the reference's repository that haven't been yanked.
+func unyankedTags(ctx *updateContext, client *oci.Client, ref oci.Reference) ([]string, error) {
+	metadata, err := repositoryMetadata(ctx, client, ref)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := ctx.recording.ociTags(ref.Registry+"/"+ref.Repository, func() ([]string, error) {
+		return client.Tags(ref)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	available := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if reason, yanked := metadata.YankReason(tag); yanked {
+			printer.Debug("Skipping yanked version %s of %s/%s: %s", tag, ref.Registry, ref.Repository, reason)
+			continue
+		}
+		available = append(available, tag)
+	}
+	return available, nil
+}
+
+// Kinds of explicit git references, declared as '<kind>=<name>'.
+const (
+	gitRefBranch = "branch"
+	gitRefCommit = "commit"
+)
+
+// gitRef is the reference of a git location, following its '#' separator.
+type gitRef struct {
+	// name is the tag, branch or commit referenced; empty for the default branch
+	name string
+	// kind is gitRefBranch or gitRefCommit for explicit references; empty for bare names, which are tags, or else
+	// branches, or else full commit hashes
+	kind string
+}
+
+func (r gitRef) isBranch() bool {
+	return r.kind == gitRefBranch
+}
+
+// tag returns the name of the tag the reference may refer to; empty if it can only be a branch or commit.
+func (r gitRef) tag() string {
+	if r.kind != "" || plumbing.IsHash(r.name) {
+		return ""
 	}
+	return r.name
+}
 
-	return nil
+// isCommit returns true if the reference is a full commit hash.
+func (r gitRef) isCommit() bool {
+	return r.kind == gitRefCommit || (r.kind == "" && plumbing.IsHash(r.name))
 }
 
-func parseGitUrl(fullUrl string) (url string, tag string, err error) {
+func parseGitUrl(fullUrl string) (url string, ref gitRef, err error) {
 	trimmedUrl := strings.TrimPrefix(fullUrl, "git+")
 	parts := strings.Split(trimmedUrl, "#")
 	if len(parts) > 2 {
-		return "", "", fmt.Errorf("invalid git url %s; only one tag separator '#' allowed", fullUrl)
+		return "", ref, errs.New(errs.InvalidLocation, "invalid git url %s; only one tag separator '#' allowed", fullUrl)
 	}
 
 	url = parts[0]
 	if len(parts) == 2 {
-		tag = parts[1]
+		ref.name = parts[1]
+		// Version ranges, such as '>=1.2', also hold a '='
+		if kind, value, ok := strings.Cut(parts[1], "="); ok && isRefKind(kind) {
+			if (kind != gitRefBranch && kind != gitRefCommit) || value == "" {
+				return "", gitRef{}, errs.New(errs.InvalidLocation,
+					"invalid git url %s; expected a tag, branch=<name> or commit=<sha> after '#'", fullUrl)
+			}
+			if kind == gitRefCommit {
+				if !plumbing.IsHash(value) {
+					return "", gitRef{}, errs.New(errs.InvalidLocation,
+						"invalid git url %s; expected a full, 40 character, commit hash", fullUrl)
+				}
+				value = strings.ToLower(value)
+			}
+			ref = gitRef{name: value, kind: kind}
+		}
 	}
 	return
 }
 
+// isRefKind returns true if s could be the kind of an explicit git reference, as in branch=<name>; i.e. it is a word.
+func isRefKind(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }) < 0
+}
+
 func (d Dependency) loadTransitive(rootDir, targetDir string) error {
 	printer.Debug("Loading transitive dependencies for %s (%s)", d.Namespace, d.id())
 
@@ -330,22 +1213,41 @@ func (d Dependency) loadTransitive(rootDir, targetDir string) error {
 	return nil
 }
 
-func (d Dependency) updateTransitive(rootDir, targetDir string) error {
+func (d Dependency) updateTransitive(ctx *updateContext) error {
 	printer.Debug("Updating transitive dependencies for %s (%s)", d.Namespace, d.id())
 
 	if d.Project != nil {
-		for name, dep := range d.Project.Dependencies {
-			dep.ParentDependency = &d
-			if err := dep.Update(rootDir, targetDir); err != nil {
-				return err
-			}
-			d.Project.Dependencies[name] = dep
-		}
+		return ctx.updateDependencies(d.Project.Dependencies, &d, false)
 	}
 
 	return nil
 }
 
+// path returns the dot-separated path of the dependency in the dependency tree.
+func (d Dependency) path() string {
+	if d.ParentDependency != nil {
+		return d.ParentDependency.path() + "." + d.Name
+	}
+	return d.Name
+}
+
+// depth returns the depth of the dependency in the dependency tree; 1 for direct dependencies.
+func (d Dependency) depth() int {
+	if d.ParentDependency != nil {
+		return d.ParentDependency.depth() + 1
+	}
+	return 1
+}
+
+// chain returns the chain of dependencies from the direct dependency down to the dependency, with their locations.
+func (d Dependency) chain() string {
+	link := fmt.Sprintf("%s (%s)", d.Name, d.location())
+	if d.ParentDependency != nil {
+		return d.ParentDependency.chain() + " -> " + link
+	}
+	return link
+}
+
 func (d Dependency) fullNamespace() string {
 	if d.ParentDependency != nil && d.ParentDependency.Namespace != "" {
 		if parentNamespace := d.ParentDependency.fullNamespace(); parentNamespace != "" {
@@ -355,7 +1257,10 @@ func (d Dependency) fullNamespace() string {
 			return fmt.Sprintf("%s.%s", parentNamespace, d.Namespace)
 		}
 	}
-	return d.Namespace
+	if d.Namespace == "" {
+		return ""
+	}
+	return d.namespacePrefix + d.Namespace
 }
 
 func (d Dependency) SourceDirs() []string {
@@ -380,6 +1285,56 @@ func (d Dependency) TestDirs() []string {
 	return []string{}
 }
 
+// isTestFile reports whether the file with the given name is a Rego test file.
+func isTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test.rego")
+}
+
+// isTestPath reports whether the given path is one of the dependency's test directories, or a Rego test file.
+func (d Dependency) isTestPath(path string, info os.FileInfo) bool {
+	if info.IsDir() {
+		return utils.Contains(d.TestDirs(), path)
+	}
+	return isTestFile(info.Name())
+}
+
+// dataLocations returns the locations covering the dependency's source, excluding its test directories and any Rego
+// test files; so that upstream tests never end up in built bundles, even when the dependency doesn't declare its tests.
+func (d Dependency) dataLocations() ([]string, error) {
+	var locations []string
+	for _, dir := range utils.FilterExistingFiles(d.SourceDirs()) {
+		paths, err := utils.ExcludeFiles(dir, d.isTestPath)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, paths...)
+	}
+	return locations, nil
+}
+
+// testFiles returns the Rego test files in the dependency's source, outside its test directories.
+func (d Dependency) testFiles() ([]string, error) {
+	var files []string
+	for _, dir := range utils.FilterExistingFiles(d.SourceDirs()) {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && utils.Contains(d.TestDirs(), path) {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() && isTestFile(info.Name()) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
 func (p *Project) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var raw ProjectSerialization
 	if err := unmarshal(&raw); err != nil {
@@ -388,9 +1343,82 @@ func (p *Project) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 	p.Name = raw.Name
 	p.Version = raw.Version
+	p.Schemas = raw.Schemas
+	p.Vars = raw.Vars
+	p.Namespacing = raw.Namespacing
+	p.Renames = raw.Renames
+	p.Resolution = raw.Resolution
+	p.MaxDepth = raw.MaxDepth
+	p.Overrides = raw.Overrides
+	p.Exclude = raw.Exclude
+	p.Deprecated = raw.Deprecated
+	p.SSH = raw.SSH
+	p.Fetch = raw.Fetch
 	p.Dependencies = raw.Dependencies
 	p.Build = raw.Build
 
+	if p.Fetch != nil {
+		if p.Fetch.Parallel != 0 || p.Fetch.Bandwidth != "" {
+			return errs.New(errs.InvalidProject,
+				"invalid fetch settings: parallel and bandwidth can only be configured in the user-level config")
+		}
+		if err := p.Fetch.Validate(); err != nil {
+			return errs.New(errs.InvalidProject, "invalid fetch settings: %w", err)
+		}
+	}
+
+	switch p.Resolution {
+	case "", ResolutionLatest, ResolutionMVS, ResolutionHighest, ResolutionFail:
+	default:
+		return errs.New(errs.InvalidProject, "invalid resolution '%s'; expected %s, %s, %s or %s", p.Resolution,
+			ResolutionLatest, ResolutionMVS, ResolutionHighest, ResolutionFail)
+	}
+
+	if p.MaxDepth < 0 {
+		return errs.New(errs.InvalidProject, "invalid maxDepth %d; expected a positive depth, or 0 for no limit",
+			p.MaxDepth)
+	}
+
+	overrides := make(map[string]string, len(p.Overrides))
+	for dependency, location := range p.Overrides {
+		if dependency == "" || location == "" {
+			return errs.New(errs.InvalidProject, "invalid override '%s: %s'; expected a dependency and a location",
+				dependency, location)
+		}
+		expanded, err := expandVars(location, p.Vars)
+		if err != nil {
+			return errs.New(errs.InvalidLocation, "invalid override of dependency %s: %w", dependency, err)
+		}
+		overrides[dependency] = expanded
+	}
+
+	exclusions := make(map[string]bool, len(p.Exclude))
+	for _, dependency := range p.Exclude {
+		if dependency == "" {
+			return errs.New(errs.InvalidProject, "invalid exclusion; expected a dependency name or path")
+		}
+		exclusions[dependency] = true
+	}
+
+	for name, dep := range p.Dependencies {
+		for _, location := range append([]string{dep.Location}, dep.FallbackLocations...) {
+			if _, err := expandVars(location, p.Vars); err != nil {
+				return errs.New(errs.InvalidLocation, "invalid location for dependency %s: %w", name, err)
+			}
+		}
+		if len(p.Vars) > 0 {
+			dep.vars = p.Vars
+		}
+		dep.namespacingDisabled = !p.NamespacingEnabled()
+		if len(overrides) > 0 {
+			dep.overrides = overrides
+		}
+		if len(exclusions) > 0 {
+			dep.exclusions = exclusions
+		}
+		p.Dependencies[name] = dep
+	}
+
 	var err error
 	p.SourceDirs, err = unmarshalDirs(raw.Source)
 	if err != nil {
@@ -402,6 +1430,11 @@ func (p *Project) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return fmt.Errorf("invalid tests: %w", err)
 	}
 
+	p.Policy, err = unmarshalDirs(raw.Policy)
+	if err != nil {
+		return fmt.Errorf("invalid policy: %w", err)
+	}
+
 	return nil
 }
 
@@ -409,6 +1442,17 @@ func (p Project) MarshalYAML() (interface{}, error) {
 	var raw ProjectSerialization
 	raw.Name = p.Name
 	raw.Version = p.Version
+	raw.Schemas = p.Schemas
+	raw.Vars = p.Vars
+	raw.Namespacing = p.Namespacing
+	raw.Renames = p.Renames
+	raw.Resolution = p.Resolution
+	raw.MaxDepth = p.MaxDepth
+	raw.Overrides = p.Overrides
+	raw.Exclude = p.Exclude
+	raw.Deprecated = p.Deprecated
+	raw.SSH = p.SSH
+	raw.Fetch = p.Fetch
 	raw.Dependencies = p.Dependencies
 	raw.Build = p.Build
 	if len(p.SourceDirs) == 1 {
@@ -421,6 +1465,11 @@ func (p Project) MarshalYAML() (interface{}, error) {
 	} else if len(p.TestDirs) > 1 {
 		raw.Test = p.TestDirs
 	}
+	if len(p.Policy) == 1 {
+		raw.Policy = p.Policy[0]
+	} else if len(p.Policy) > 1 {
+		raw.Policy = p.Policy
+	}
 	return raw, nil
 }
 
@@ -451,8 +1500,10 @@ func (p *Project) SetDependency(name string, info DependencyInfo) {
 		p.Dependencies = make(map[string]Dependency)
 	}
 	p.Dependencies[name] = Dependency{
-		DependencyInfo: info,
-		Name:           name,
+		DependencyInfo:  info,
+		Name:            name,
+		vars:            p.Vars,
+		namespacePrefix: p.namespacePrefix,
 	}
 }
 
@@ -463,23 +1514,33 @@ func ReadProjectFromFile(path string, allowMissing bool) (*Project, error) {
 		if allowMissing {
 			return NewProject(path), nil
 		} else {
-			return nil, fmt.Errorf("project file %s does not exist", path)
+			return nil, errs.New(errs.InvalidProject, "project file %s does not exist", path)
 		}
 	}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read project file %s: %w", path, err)
+		return nil, errs.New(errs.InvalidProject, "failed to read project file %s: %w", path, err)
 	}
 
 	var project Project
 	err = yaml.Unmarshal(data, &project)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal project file %s: %w", path, err)
+		return nil, errs.New(errs.InvalidProject, "failed to unmarshal project file %s: %w", path, err)
 	}
 
 	project.filePath = path
 
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	project.namespacePrefix = cfg.NamespacePrefix
+	for name, dep := range project.Dependencies {
+		dep.namespacePrefix = cfg.NamespacePrefix
+		project.Dependencies[name] = dep
+	}
+
 	return &project, nil
 }
 
@@ -496,24 +1557,241 @@ func ReadAndLoadProject(path string, allowMissing bool) (*Project, error) {
 	return project, nil
 }
 
+// Update fetches all dependencies of the project into the project's .opa directory, and records their resolved state in
+// the project's lock file. Previously locked states are preferred over re-resolving dependency locations.
 func (p *Project) Update() error {
-	rootDir := filepath.Dir(p.filePath)
-	return p.update(rootDir)
+	return p.UpdateWithOptions(UpdateOptions{})
 }
 
-func (p *Project) update(rootDir string) error {
-	depRootDir := dependenciesDir(rootDir)
+// UpdateOptions configures an update.
+type UpdateOptions struct {
+	// Plan, if set, pins every dependency to its revision in the plan, as produced by Plan; failing the update if a
+	// dependency isn't part of the plan, or has a different source than planned
+	Plan *Plan
+	// Strict fails the update, instead of warning, if a git tag has moved since it was locked
+	Strict bool
+	// Refresh re-resolves floating git locations, tracking a branch or HEAD, to the current head of the branch; otherwise,
+	// the commits pinned by the lock file are fetched
+	Refresh bool
+	// Recording, if set, records the remote responses resolution depends on, and the lock the update starts from; or,
+	// if read by ReadResolutionRecording, replays them, so that the update resolves exactly as when recorded
+	Recording *ResolutionRecording
+	// Frozen fails the update, without writing the lock file, if any dependency isn't resolved exactly as locked, or the
+	// lock file holds locations no longer depended on; and fetches nothing from locations not in the lock file.
+	// Floating git locations aren't refreshed
+	Frozen bool
+	// Dependencies, if set, limits the update to the named direct dependencies, and their transitive dependencies; the
+	// fetched directories and lock entries of all other dependencies are left as they are
+	Dependencies []string
+	// Trace, if set, collects the resolution events of the update, explaining why each dependency resolved to the
+	// version it did
+	Trace *ResolutionTrace
+}
 
-	for name, dep := range p.Dependencies {
-		if err := dep.Update(rootDir, depRootDir); err != nil {
-			return fmt.Errorf("failed to update dependency %s: %w", name, err)
+// UpdateWithOptions updates the project like Update, as configured by opts.
+func (p *Project) UpdateWithOptions(opts UpdateOptions) error {
+	lock, err := ReadLockFile(lockFilePath(p.filePath))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := configureFetching(cfg, p); err != nil {
+		return err
+	}
+	lock = opts.Recording.startLock(lock)
+
+	var sel *selection
+	if len(opts.Dependencies) > 0 {
+		if sel, err = p.selectDependencies(opts.Dependencies); err != nil {
+			return err
 		}
-		p.Dependencies[name] = dep
+		if err := sel.prune(p.Dir()); err != nil {
+			return err
+		}
+	} else if err := os.RemoveAll(filepath.Join(p.Dir(), dotOpaDir, sandboxesDir)); err != nil {
+		// Sandboxes of dependencies no longer declared with isolated tests mustn't linger
+		return err
+	}
+
+	res := newResolver(p.Resolution)
+	res.trace = opts.Trace
+	metadata := make(map[string]*oci.Metadata)
+	var ctx *updateContext
+	for pass := 1; ; pass++ {
+		ctx = p.newUpdateContext(lock, cfg, res, metadata)
+		ctx.strict = opts.Strict
+		ctx.refresh = opts.Refresh && !opts.Frozen
+		ctx.frozen = opts.Frozen
+		ctx.recording = opts.Recording
+		if opts.Plan != nil {
+			ctx.plan = opts.Plan.byLocation()
+		}
+		res.startPass()
+
+		if sel != nil {
+			err = sel.update(ctx, p)
+		} else {
+			err = p.update(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		if changed, err := res.unify(); err != nil {
+			return err
+		} else if !changed {
+			break
+		}
+		if pass == maxResolutionPasses {
+			return errs.New(errs.ResolutionFailed, "dependency versions did not settle after %d resolution passes", pass)
+		}
+		printer.Debug("Dependency versions changed, updating again")
+	}
+	if err := res.checkRevisions(); err != nil {
+		return err
+	}
+	if opts.Frozen {
+		if err := ctx.newLock.checkFrozen(ctx.lock); err != nil {
+			return err
+		}
+	}
+
+	if err := p.Load(); err != nil {
+		return err
+	}
+
+	p.checkOverrides()
+	if err := p.checkExclusions(); err != nil {
+		return err
+	}
+
+	if err := p.checkRoots(); err != nil {
+		return err
 	}
 
+	if err := p.checkPackageCollisions(); err != nil {
+		return err
+	}
+
+	if len(p.Renames) > 0 {
+		var include func(Dependency) bool
+		if sel != nil {
+			// Kept dependencies were renamed by the update that fetched them
+			include = func(dep Dependency) bool {
+				return !sel.keptIds[dep.id()]
+			}
+		}
+		if err := p.applyRenames(include); err != nil {
+			return err
+		}
+	}
+
+	// The lock is only written once the dependency policies accept the resolved dependency graph
+	if err := p.checkGate(cfg, ctx.newLock); err != nil {
+		return err
+	}
+
+	if err := p.recordDependencySums(); err != nil {
+		return err
+	}
+
+	if opts.Frozen {
+		// The lock matches the lock file, which is left untouched
+		return nil
+	}
+	ctx.newLock.stampResolved(ctx.lock, time.Now())
+	return ctx.newLock.WriteToFile()
+}
+
+// newUpdateContext returns the context of a single pass over the project's dependency tree.
+func (p *Project) newUpdateContext(lock *Lock, cfg *config.Config, res *resolver,
+	metadata map[string]*oci.Metadata) *updateContext {
+	rootDir := filepath.Dir(p.filePath)
+	return &updateContext{
+		rootDir:     rootDir,
+		depsRootDir: dependenciesDir(rootDir),
+		lock:        lock,
+		newLock:     newLock(lock.filePath),
+		config:      cfg,
+		namespacing: p.NamespacingEnabled(),
+		updated:     make(map[string]bool),
+		metadata:    metadata,
+		cache:       newRemoteCache(cfg.Cache),
+		resolver:    res,
+		fetches:     make(chan struct{}, cfg.ParallelFetches()),
+		maxDepth:    p.MaxDepth,
+	}
+}
+
+// applyRenames moves packages across the resolved dependencies accepted by include, or all if nil, as declared in the
+// project's rename table. Renames are applied after namespacing, so the packages to rename are referenced by their
+// namespaced paths. The project's own source, and compiled bundles, are not rewritten.
+func (p *Project) applyRenames(include func(Dependency) bool) error {
+	var dirs []string
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		if dep.isBundle() || (include != nil && !include(dep)) {
+			return nil
+		}
+		dirs = append(dirs, dep.SourceDirs()...)
+		dirs = append(dirs, dep.TestDirs()...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	dirs = utils.FilterExistingFiles(dirs)
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	opa := utils.NewOpa(dirs...)
+	for _, rename := range renameMappings(p.Renames) {
+		printer.Debug("Renaming package %s to %s", rename[0], rename[1])
+		if err := opa.Refactor(rename[0], rename[1]); err != nil {
+			return fmt.Errorf("failed to rename package %s to %s: %w", rename[0], rename[1], err)
+		}
+	}
 	return nil
 }
 
+// renameMappings returns the from/to package pairs of a rename table, in a deterministic order.
+// Package paths may omit the 'data.' prefix.
+func renameMappings(renames map[string]string) [][2]string {
+	dataPath := func(path string) string {
+		if path == "data" || strings.HasPrefix(path, "data.") {
+			return path
+		}
+		return "data." + path
+	}
+
+	froms := make([]string, 0, len(renames))
+	for from := range renames {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	mappings := make([][2]string, 0, len(renames))
+	for _, from := range froms {
+		mappings = append(mappings, [2]string{dataPath(from), dataPath(renames[from])})
+	}
+	return mappings
+}
+
+// NamespacingEnabled returns false if the project has disabled namespacing of all its dependencies, merging their
+// packages at their original paths.
+func (p *Project) NamespacingEnabled() bool {
+	return p.Namespacing == nil || *p.Namespacing
+}
+
+func (p *Project) update(ctx *updateContext) error {
+	return ctx.updateDependencies(p.Dependencies, nil, true)
+}
+
 func (p *Project) Load() error {
 	rootDir := filepath.Dir(p.filePath)
 	return p.load(rootDir)
@@ -552,11 +1830,37 @@ func (p *Project) DataLocations() ([]string, error) {
 			}
 		}
 	} else {
-		dataLocations = append(dataLocations, projDir)
+		// Compiled bundle dependencies are loaded as bundles, and test sandboxes hold un-namespaced copies of
+		// dependencies, so both must be kept out of the project directory's content. The .opa directory is left out too,
+		// as OPA refuses ODM's own files when listed individually
+		bundleDirs, err := p.bundleDirs()
+		if err != nil {
+			return nil, err
+		}
+		hasSandboxes := utils.FileExists(filepath.Join(projDir, dotOpaDir, sandboxesDir))
+		if len(bundleDirs) == 0 && !hasSandboxes {
+			dataLocations = append(dataLocations, projDir)
+		} else {
+			dotOpaPath := filepath.Join(projDir, dotOpaDir)
+			locations, err := utils.ExcludeFiles(projDir, func(path string, info os.FileInfo) bool {
+				return info.IsDir() && (path == dotOpaPath || utils.Contains(bundleDirs, path))
+			})
+			if err != nil {
+				return nil, err
+			}
+			dataLocations = append(dataLocations, locations...)
+		}
 	}
 
-	err := WalkDependencies(p, func(dep Dependency) error {
-		dataLocations = append(dataLocations, dep.SourceDirs()...)
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		if dep.isBundle() {
+			return nil
+		}
+		locations, err := dep.dataLocations()
+		if err != nil {
+			return fmt.Errorf("failed to list source of dependency %s: %w", dep.Name, err)
+		}
+		dataLocations = append(dataLocations, locations...)
 		return nil
 	})
 	if err != nil {
@@ -582,8 +1886,17 @@ func (p *Project) TestLocations(includeDependencies bool) ([]string, error) {
 	}
 
 	if includeDependencies {
-		err := WalkDependencies(p, func(dep Dependency) error {
+		err := p.walkUniqueDependencies(func(dep Dependency) error {
+			if dep.isBundle() || dep.IsolatedTests {
+				return nil
+			}
 			testLocations = append(testLocations, dep.TestDirs()...)
+			// Test files mixed with the source are excluded from the data locations, and so must be added here
+			testFiles, err := dep.testFiles()
+			if err != nil {
+				return fmt.Errorf("failed to list tests of dependency %s: %w", dep.Name, err)
+			}
+			testLocations = append(testLocations, testFiles...)
 			return nil
 		})
 		if err != nil {
@@ -594,6 +1907,83 @@ func (p *Project) TestLocations(includeDependencies bool) ([]string, error) {
 	return testLocations, nil
 }
 
+// SchemaLocation returns the JSON schema file, or directory of schema files, declared by the project, for OPA to
+// type-check references to the input and data documents against; empty if none is declared.
+func (p *Project) SchemaLocation() (string, error) {
+	if p.Schemas == "" {
+		return "", nil
+	}
+	path, err := utils.NormalizeFilePath(p.Schemas)
+	if err != nil {
+		return "", err
+	}
+	path = filepath.Join(p.Dir(), path)
+	if !utils.FileExists(path) {
+		return "", errs.New(errs.InvalidProject, "schemas %s not found", path)
+	}
+	return path, nil
+}
+
+// LocalLocations returns the project file, the project's source and test locations, and the locations of all local
+// (file:) dependencies; i.e. every location on the local filesystem that affects the resolved project.
+func (p *Project) LocalLocations() ([]string, error) {
+	var locations []string
+
+	dataLocations, err := p.DataLocations()
+	if err != nil {
+		return nil, err
+	}
+	testLocations, err := p.TestLocations(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range append(dataLocations, testLocations...) {
+		if !strings.HasPrefix(location, dependenciesDir(p.Dir())) {
+			locations = append(locations, location)
+		}
+	}
+
+	depLocations, err := p.LocalDependencyLocations()
+	if err != nil {
+		return nil, err
+	}
+	locations = append(locations, depLocations...)
+
+	return utils.FilterExistingFiles(locations), nil
+}
+
+// VendoredLocations returns the lock file, the dependencies directory, and the sums recorded by the last update, as far
+// as they exist; i.e. everything that, next to the project's source, is needed to build the project without fetching.
+func (p *Project) VendoredLocations() []string {
+	return utils.FilterExistingFiles([]string{
+		lockFilePath(p.filePath),
+		dependenciesDir(p.Dir()),
+		dependencySumsPath(p.Dir()),
+	})
+}
+
+// LocalDependencyLocations returns the project file and the locations of all local (file:) dependencies; i.e. every
+// location on the local filesystem that, when changed, requires the project to be updated.
+func (p *Project) LocalDependencyLocations() ([]string, error) {
+	locations := []string{p.filePath}
+
+	err := WalkDependencies(p, func(dep Dependency) error {
+		if strings.HasPrefix(dep.location(), "file:") {
+			location, err := dep.localPath(p.Dir())
+			if err != nil {
+				return err
+			}
+			locations = append(locations, location)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.FilterExistingFiles(locations), nil
+}
+
 func (p *Project) WriteToFile(path string, override bool) error {
 	path = normalizeProjectPath(path)
 	printer.Debug("Writing project file to %s", path)
@@ -650,27 +2040,102 @@ func (p *Project) Dir() string {
 	return filepath.Dir(p.filePath)
 }
 
+// projectFileNames are the names a project file is looked up by in a project directory, in order of precedence.
+var projectFileNames = []string{"opa.project", "opa.project.yaml", "opa.project.yml"}
+
+// isProjectFileName reports whether name is one of the names a project file is looked up by.
+func isProjectFileName(name string) bool {
+	return utils.Contains(projectFileNames, name)
+}
+
+// projectFilePath returns the path of the project file in dir: the first of projectFileNames that exists; or
+// opa.project, if none does.
+func projectFilePath(dir string) string {
+	for _, name := range projectFileNames {
+		if path := filepath.Join(dir, name); utils.FileExists(path) {
+			return path
+		}
+	}
+	return filepath.Join(dir, projectFileNames[0])
+}
+
+// FindProjectDir returns the nearest directory containing a project file, starting at dir and walking up its parents,
+// like git and go do; relative to dir, if dir is relative. Returns false if no parent directory contains one.
+func FindProjectDir(dir string) (string, bool) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for current := absDir; ; {
+		if utils.FileExists(projectFilePath(current)) {
+			if filepath.IsAbs(dir) {
+				return current, true
+			}
+			rel, err := filepath.Rel(absDir, current)
+			if err != nil {
+				return "", false
+			}
+			return filepath.Join(dir, rel), true
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", false
+		}
+		current = parent
+	}
+}
+
+// ProjectFilePath returns the path of the project file denoted by path: either a project file, or a project directory.
+func ProjectFilePath(path string) string {
+	return normalizeProjectPath(path)
+}
+
+// normalizeProjectPath returns the path of the project file denoted by path. Existing files, and paths named like a
+// project file or with a YAML extension, are project files; other paths are project directories.
 func normalizeProjectPath(path string) string {
-	l := len(path)
-	if l >= 11 && path[l-11:] == "opa.project" {
+	if utils.IsDir(path) || strings.HasSuffix(path, "/") {
+		return projectFilePath(path)
+	}
+	if ext := filepath.Ext(path); utils.FileExists(path) || isProjectFileName(filepath.Base(path)) ||
+		ext == ".yaml" || ext == ".yml" {
 		return path
-	} else if l >= 1 && path[l-1] == '/' {
-		return path + "opa.project"
-	} else {
-		return path + "/opa.project"
 	}
+	return projectFilePath(path)
+}
+
+// FilePath returns the path of the project file.
+func (p *Project) FilePath() string {
+	return p.filePath
 }
 
 func dependenciesDir(root string) string {
 	return filepath.Join(root, dotOpaDir, depDir)
 }
 
+// walkUniqueDependencies calls f once for every distinct dependency in the dependency tree of p, so that diamond
+// dependencies (A→B, A→C, B→D, C→D) contribute a single copy of D. Dependencies are the same if they share their id,
+// and so resolve to the same directory; with namespacing disabled, if they share location and subdirectory.
+func (p *Project) walkUniqueDependencies(f func(Dependency) error) error {
+	seen := make(map[string]bool)
+	return WalkDependencies(p, func(dep Dependency) error {
+		key := dep.id()
+		if seen[key] {
+			printer.Debug("Skipping duplicate of dependency %s (%s)", dep.Name, dep.location())
+			return nil
+		}
+		seen[key] = true
+		return f(dep)
+	})
+}
+
+// WalkDependencies calls f for every dependency in the dependency tree of p, depth first and ordered by name.
 func WalkDependencies(p *Project, f func(Dependency) error) error {
 	if p == nil {
 		return nil
 	}
 
-	for _, dep := range p.Dependencies {
+	for _, name := range sortedDependencyNames(p.Dependencies) {
+		dep := p.Dependencies[name]
 		if err := f(dep); err != nil {
 			return err
 		}
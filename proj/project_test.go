@@ -2,10 +2,13 @@ package proj
 
 import (
 	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
 	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -121,6 +124,50 @@ version: 0.0.1
 source: src
 dependencies:
     foo: git+https://example.com/my/repo
+`,
+		},
+		{
+			note: "namespacing disabled",
+			project: &Project{
+				Name:        "test_project",
+				Namespacing: new(bool),
+				Dependencies: Dependencies{
+					"foo": Dependency{
+						Name: "foo",
+						DependencyInfo: DependencyInfo{
+							Location:  "git+https://example.com/my/repo",
+							Namespace: "foo",
+						},
+					},
+				},
+			},
+			expected: `name: test_project
+namespacing: false
+dependencies:
+    foo: git+https://example.com/my/repo
+`,
+		},
+		{
+			note: "fallback locations",
+			project: &Project{
+				Name: "test_project",
+				Dependencies: Dependencies{
+					"foo": Dependency{
+						Name: "foo",
+						DependencyInfo: DependencyInfo{
+							Location:          "git+https://mirror.example.com/my/repo",
+							FallbackLocations: []string{"git+https://example.com/my/repo"},
+							Namespace:         "foo",
+						},
+					},
+				},
+			},
+			expected: `name: test_project
+dependencies:
+    foo:
+        location:
+            - git+https://mirror.example.com/my/repo
+            - git+https://example.com/my/repo
 `,
 		},
 	}
@@ -139,6 +186,393 @@ dependencies:
 	}
 }
 
+func TestUnmarshalProjectUndefinedVariable(t *testing.T) {
+	input := `vars:
+    libVersion: v2.3.0
+dependencies:
+    foo: git+https://example.com/my/repo#${libVerson}
+`
+	var project Project
+	err := yaml.Unmarshal([]byte(input), &project)
+	if err == nil || err.Error() != "invalid location for dependency foo: undefined variable(s): libVerson" {
+		t.Fatalf("expected undefined variable error, got %v", err)
+	}
+}
+
+func TestDependencyLocationVariables(t *testing.T) {
+	var project Project
+	err := yaml.Unmarshal([]byte(`vars:
+    host: example.com
+    libVersion: v2.3.0
+dependencies:
+    foo: git+https://${host}/my/repo#${libVersion}
+`), &project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dep := project.Dependencies["foo"]
+	if expected := "git+https://example.com/my/repo#v2.3.0"; dep.location() != expected {
+		t.Fatalf("expected location %s, got %s", expected, dep.location())
+	}
+	if expected := DepId("foo", "git+https://example.com/my/repo#v2.3.0"); dep.id() != expected {
+		t.Fatalf("expected id %s, got %s", expected, dep.id())
+	}
+}
+
+func TestUpdateNamespacingDisabled(t *testing.T) {
+	files := map[string]string{
+		"opa.project": `namespacing: false
+dependencies:
+  foo: file:/foo
+`,
+		"foo/policy.rego": "package foo",
+	}
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if project.NamespacingEnabled() {
+			t.Fatal("expected namespacing to be disabled")
+		}
+
+		// Namespacing would require refactoring through OPA
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		depDir := project.Dependencies["foo"].dir(dependenciesDir(root))
+		bs, err := os.ReadFile(filepath.Join(depDir, "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package foo" {
+			t.Fatalf("expected original package, got %s", string(bs))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateSharedDependency(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	registry.Push("org/lib", "1.0.0", map[string]string{"/lib.rego": "package lib"})
+	lib := fmt.Sprintf("oci://%s/org/lib:1.0.0", registry.Host())
+
+	tests := []struct {
+		note    string
+		project string
+		// expected is the number of fetched dependency directories
+		expected int
+	}{
+		{
+			note: "namespaced",
+			project: `dependencies:
+  a: file:/a
+  b: file:/b
+`,
+			expected: 4,
+		},
+		{
+			note: "intermediate dependencies not namespaced",
+			project: `dependencies:
+  a:
+    location: file:/a
+    namespace: false
+  b:
+    location: file:/b
+    namespace: false
+`,
+			expected: 3,
+		},
+		{
+			note: "namespacing disabled",
+			project: `namespacing: false
+dependencies:
+  a: file:/a
+  b: file:/b
+`,
+			expected: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project":   tc.project,
+				"a/opa.project": "dependencies:\n  lib: " + lib + "\n",
+				"b/opa.project": "dependencies:\n  lib: " + lib + "\n",
+			}
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				entries, err := os.ReadDir(dependenciesDir(root))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(entries) != tc.expected {
+					t.Fatalf("expected %d dependency directories, got %d", tc.expected, len(entries))
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRenameMappings(t *testing.T) {
+	var project Project
+	err := yaml.Unmarshal([]byte(`renames:
+    data.http.internal.v2.util: data.http_util
+    jwt.internal: jwt_internal
+`), &project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][2]string{
+		{"data.http.internal.v2.util", "data.http_util"},
+		{"data.jwt.internal", "data.jwt_internal"},
+	}
+	if actual := renameMappings(project.Renames); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestDependencyNamespacePrefix(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("namespacePrefix: ext_\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ODM_CONFIG", configPath)
+
+	files := map[string]string{
+		"opa.project": `dependencies:
+  foo: file://foo
+  bar:
+    location: file://bar
+    namespace: false
+`,
+	}
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		foo := project.Dependencies["foo"]
+		if expected := "ext_foo"; foo.fullNamespace() != expected {
+			t.Fatalf("expected namespace %s, got %s", expected, foo.fullNamespace())
+		}
+
+		if bar := project.Dependencies["bar"]; bar.fullNamespace() != "" {
+			t.Fatalf("expected no namespace, got %s", bar.fullNamespace())
+		}
+
+		transitive := Dependency{DependencyInfo: DependencyInfo{Namespace: "baz"}, ParentDependency: &foo, namespacePrefix: "ext_"}
+		if expected := "ext_foo.baz"; transitive.fullNamespace() != expected {
+			t.Fatalf("expected namespace %s, got %s", expected, transitive.fullNamespace())
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiamondDependencies(t *testing.T) {
+	tests := []struct {
+		note    string
+		project string
+		// depD are the directories of dep_d, in name order of the dependencies depending on it
+		depD []string
+	}{
+		{
+			note: "intermediate dependencies not namespaced",
+			project: `dependencies:
+  dep_b:
+    location: file://dep_b
+    namespace: false
+  dep_c:
+    location: file://dep_c
+    namespace: false
+`,
+			depD: []string{DepId("dep_d", "file://dep_d")},
+		},
+		{
+			note: "namespacing disabled",
+			project: `namespacing: false
+dependencies:
+  dep_b: file://dep_b
+  dep_c: file://dep_c
+`,
+			// Namespaces don't apply, and so dep_d is shared
+			depD: []string{DepId("", "file://dep_d")},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			depB := DepId("dep_b", "file://dep_b")
+			depC := DepId("dep_c", "file://dep_c")
+			if strings.Contains(tc.project, "namespace: false") || strings.Contains(tc.project, "namespacing: false") {
+				depB = DepId("", "file://dep_b")
+				depC = DepId("", "file://dep_c")
+			}
+
+			files := map[string]string{
+				"opa.project": tc.project,
+				filepath.Join(".opa", "dependencies", depB, "opa.project"): `source: src
+tests: test
+dependencies:
+  dep_d: file://dep_d`,
+				filepath.Join(".opa", "dependencies", depC, "opa.project"): `source: src
+tests: test
+dependencies:
+  dep_d: file://dep_d`,
+			}
+			for _, depD := range tc.depD {
+				files[filepath.Join(".opa", "dependencies", depD, "opa.project")] = "source: src\ntests: test"
+				files[filepath.Join(".opa", "dependencies", depD, "src", "policy.rego")] = "package dep_d"
+				files[filepath.Join(".opa", "dependencies", depD, "test", "policy_test.rego")] = "package dep_d_test"
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadAndLoadProject(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				dataLocations, err := project.DataLocations()
+				if err != nil {
+					t.Fatal(err)
+				}
+				testLocations, err := project.TestLocations(true)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Only the first copy of dep_d, in name order, is included
+				for _, locations := range [][]string{dataLocations, testLocations} {
+					var depDLocations []string
+					for _, location := range locations {
+						for _, depD := range tc.depD {
+							if strings.HasPrefix(location, filepath.Join(root, ".opa", "dependencies", depD)) {
+								depDLocations = append(depDLocations, location)
+							}
+						}
+					}
+					expectedDir := filepath.Join(root, ".opa", "dependencies", tc.depD[0])
+					if len(depDLocations) != 1 || !strings.HasPrefix(depDLocations[0], expectedDir) {
+						t.Fatalf("expected single dep_d location in %s, got %v", expectedDir, depDLocations)
+					}
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestWalkUniqueSubdirectoryDependencies(t *testing.T) {
+	var project Project
+	err := yaml.Unmarshal([]byte(`namespacing: false
+dependencies:
+  a:
+    location: git+https://example.com/policies.git#v1
+    subdirectory: x
+  b:
+    location: git+https://example.com/policies.git#v1
+    subdirectory: y
+  c:
+    location: git+https://example.com/policies.git#v1
+    subdirectory: x
+`), &project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var visited []string
+	err = project.walkUniqueDependencies(func(dep Dependency) error {
+		visited = append(visited, dep.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Subdirectories of the same repository are distinct dependencies, also with namespacing disabled
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("expected dependencies %v visited, got %v", expected, visited)
+	}
+}
+
+func TestUpdateMaxDepth(t *testing.T) {
+	tests := []struct {
+		note     string
+		maxDepth int
+		expected string
+	}{
+		{
+			note: "no limit",
+		},
+		{
+			note:     "within limit",
+			maxDepth: 3,
+		},
+		{
+			note:     "exceeding limit",
+			maxDepth: 2,
+			expected: "dependency chain a (file:/a) -> b (file:/b) -> c (file:/c) exceeds the maximum depth of 2",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project":   fmt.Sprintf("maxDepth: %d\ndependencies:\n  a: file:/a\n", tc.maxDepth),
+				"a/opa.project": "dependencies:\n  b: file:/b\n",
+				"b/opa.project": "dependencies:\n  c: file:/c\n",
+				"c/c.rego":      "package c",
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = project.Update()
+				if tc.expected == "" {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				if !errs.Is(err, errs.ResolutionFailed) || !strings.Contains(err.Error(), tc.expected) {
+					t.Fatalf("expected error %q, got %v", tc.expected, err)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	var project Project
+	if err := yaml.Unmarshal([]byte("maxDepth: -1\n"), &project); !errs.Is(err, errs.InvalidProject) {
+		t.Fatalf("expected invalid project error, got %v", err)
+	}
+}
+
 func TestUnmarshalProject(t *testing.T) {
 	tests := []struct {
 		note     string
@@ -279,6 +713,52 @@ dependencies:
 				},
 			},
 		},
+		{
+			note: "git dependency with location variable",
+			input: `name: test_project
+vars:
+    libVersion: v2.3.0
+dependencies:
+    foo: git+https://example.com/my/repo#${libVersion}
+`,
+			expected: &Project{
+				Name: "test_project",
+				Vars: map[string]string{"libVersion": "v2.3.0"},
+				Dependencies: Dependencies{
+					"foo": Dependency{
+						Name: "foo",
+						DependencyInfo: DependencyInfo{
+							Location:  "git+https://example.com/my/repo#${libVersion}",
+							Namespace: "foo",
+						},
+						vars: map[string]string{"libVersion": "v2.3.0"},
+					},
+				},
+			},
+		},
+		{
+			note: "fallback locations",
+			input: `name: test_project
+dependencies:
+    foo:
+        location:
+          - git+https://mirror.example.com/my/repo#v1
+          - git+https://github.com/my/repo#v1
+`,
+			expected: &Project{
+				Name: "test_project",
+				Dependencies: Dependencies{
+					"foo": Dependency{
+						Name: "foo",
+						DependencyInfo: DependencyInfo{
+							Location:          "git+https://mirror.example.com/my/repo#v1",
+							FallbackLocations: []string{"git+https://github.com/my/repo#v1"},
+							Namespace:         "foo",
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -391,6 +871,210 @@ source: baz`,
 	}
 }
 
+func TestProjectFilePath(t *testing.T) {
+	tests := []struct {
+		note     string
+		files    map[string]string
+		path     string
+		expected string
+	}{
+		{
+			note:     "directory",
+			files:    map[string]string{"opa.project": "name: a"},
+			path:     "",
+			expected: "opa.project",
+		},
+		{
+			note:     "directory, yaml extension",
+			files:    map[string]string{"opa.project.yaml": "name: a"},
+			path:     "",
+			expected: "opa.project.yaml",
+		},
+		{
+			note:     "directory, opa.project takes precedence",
+			files:    map[string]string{"opa.project": "name: a", "opa.project.yml": "name: b"},
+			path:     "",
+			expected: "opa.project",
+		},
+		{
+			note:     "directory without project file",
+			files:    map[string]string{"sub/policy.rego": "package a"},
+			path:     "sub",
+			expected: "sub/opa.project",
+		},
+		{
+			note:     "missing directory",
+			files:    map[string]string{},
+			path:     "sub",
+			expected: "sub/opa.project",
+		},
+		{
+			note:     "non-standard file name",
+			files:    map[string]string{"prod.project": "name: a"},
+			path:     "prod.project",
+			expected: "prod.project",
+		},
+		{
+			note:     "missing yaml file",
+			files:    map[string]string{},
+			path:     "sub/custom.yaml",
+			expected: "sub/custom.yaml",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			err := withTempFiles(tc.files, func(root string) {
+				actual := ProjectFilePath(filepath.Join(root, tc.path))
+				if expected := filepath.Join(root, filepath.FromSlash(tc.expected)); actual != expected {
+					t.Fatalf("expected %s, got %s", expected, actual)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFindProjectDir(t *testing.T) {
+	files := map[string]string{
+		"opa.project":             "name: root",
+		"src/nested/policy.rego":  "package a",
+		"sub/opa.project.yaml":    "name: sub",
+		"sub/src/policy.rego":     "package b",
+		"sub/.opa/dependencies/x": "",
+	}
+	tests := []struct {
+		note     string
+		dir      string
+		expected string
+	}{
+		{note: "project directory", dir: "", expected: ""},
+		{note: "nested directory", dir: "src/nested", expected: ""},
+		{note: "nested project", dir: "sub/src", expected: "sub"},
+		{note: "dependencies directory", dir: "sub/.opa/dependencies", expected: "sub"},
+	}
+
+	err := withTempFiles(files, func(root string) {
+		for _, tc := range tests {
+			t.Run(tc.note, func(t *testing.T) {
+				dir, ok := FindProjectDir(filepath.Join(root, tc.dir))
+				if !ok {
+					t.Fatalf("expected project directory to be found")
+				}
+				if expected := filepath.Join(root, tc.expected); dir != expected {
+					t.Fatalf("expected %s, got %s", expected, dir)
+				}
+			})
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dir, ok := FindProjectDir(t.TempDir()); ok {
+		t.Fatalf("expected no project directory, got %s", dir)
+	}
+}
+
+func TestParseGitUrl(t *testing.T) {
+	tests := []struct {
+		note        string
+		location    string
+		expectedUrl string
+		expectedRef gitRef
+		expectedErr bool
+	}{
+		{note: "no reference", location: "git+https://host/repo", expectedUrl: "https://host/repo"},
+		{note: "tag", location: "git+https://host/repo#v1", expectedUrl: "https://host/repo",
+			expectedRef: gitRef{name: "v1"}},
+		{note: "branch", location: "git+https://host/repo#branch=main", expectedUrl: "https://host/repo",
+			expectedRef: gitRef{name: "main", kind: gitRefBranch}},
+		{note: "empty branch", location: "git+https://host/repo#branch=", expectedErr: true},
+		{note: "commit", location: "git+https://host/repo#commit=" + strings.Repeat("AB", 20),
+			expectedUrl: "https://host/repo", expectedRef: gitRef{name: strings.Repeat("ab", 20), kind: gitRefCommit}},
+		{note: "abbreviated commit", location: "git+https://host/repo#commit=abcdef1", expectedErr: true},
+		{note: "version range", location: "git+https://host/repo#>=1.2.0 <2.0.0", expectedUrl: "https://host/repo",
+			expectedRef: gitRef{name: ">=1.2.0 <2.0.0"}},
+		{note: "unknown reference kind", location: "git+https://host/repo#ref=main", expectedErr: true},
+		{note: "multiple separators", location: "git+https://host/repo#v1#v2", expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			url, ref, err := parseGitUrl(tc.location)
+			if tc.expectedErr {
+				if !errs.Is(err, errs.InvalidLocation) {
+					t.Fatalf("expected invalid location error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if url != tc.expectedUrl || ref != tc.expectedRef {
+				t.Fatalf("expected %s %v, got %s %v", tc.expectedUrl, tc.expectedRef, url, ref)
+			}
+		})
+	}
+}
+
+func TestDependencyTestsExcluded(t *testing.T) {
+	dep := DepId("lib", "file://lib")
+	depDir := filepath.Join(".opa", "dependencies", dep)
+	files := map[string]string{
+		"opa.project": `source: src
+dependencies:
+  lib: file://lib
+`,
+		"src/policy.rego":                                      `package test`,
+		filepath.Join(depDir, "opa.project"):                   `tests: test`,
+		filepath.Join(depDir, "policy.rego"):                   `package lib`,
+		filepath.Join(depDir, "policy_test.rego"):              `package lib_test`,
+		filepath.Join(depDir, "util", "helpers.rego"):          `package lib.util`,
+		filepath.Join(depDir, "util", "helpers_test.rego"):     `package lib.util_test`,
+		filepath.Join(depDir, "test", "integration_test.rego"): `package lib.integration_test`,
+	}
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadAndLoadProject(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(root, depDir)
+
+		dataLocations, err := project.DataLocations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			filepath.Join(root, "src"),
+			filepath.Join(dir, "opa.project"),
+			filepath.Join(dir, "policy.rego"),
+			filepath.Join(dir, "util", "helpers.rego"),
+		}
+		if !reflect.DeepEqual(dataLocations, expected) {
+			t.Fatalf("expected data locations\n\n%v\n\nbut got\n\n%v", expected, dataLocations)
+		}
+
+		testLocations, err := project.TestLocations(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = []string{
+			filepath.Join(dir, "test"),
+			filepath.Join(dir, "policy_test.rego"),
+			filepath.Join(dir, "util", "helpers_test.rego"),
+		}
+		if !reflect.DeepEqual(testLocations, expected) {
+			t.Fatalf("expected test locations\n\n%v\n\nbut got\n\n%v", expected, testLocations)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func withTempFiles(files map[string]string, f func(string)) error {
 	root, err := os.MkdirTemp("", "test-")
 	if err != nil {
@@ -0,0 +1,206 @@
+package proj
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	quarantineDir = "quarantine"
+	sumsDir       = "sums"
+)
+
+// contentSums returns the sha256 sums of all regular files in dir, by slash-separated path relative to dir.
+// Git metadata is not part of a dependency's content.
+func contentSums(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(rel)] = fmt.Sprintf("%x", h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash content of %s: %w", dir, err)
+	}
+	return sums, nil
+}
+
+// formatSums formats file sums as sorted lines of '<sum>  <path>', as printed by sha256sum.
+func formatSums(sums map[string]string) string {
+	paths := make([]string, 0, len(sums))
+	for path := range sums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	for _, path := range paths {
+		sb.WriteString(fmt.Sprintf("%s  %s\n", sums[path], path))
+	}
+	return sb.String()
+}
+
+func parseSums(s string) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		if sum, path, ok := strings.Cut(scanner.Text(), "  "); ok {
+			sums[path] = sum
+		}
+	}
+	return sums
+}
+
+// contentHash returns the hash of a dependency's content: the sha256 of its formatted file sums.
+func contentHash(sums map[string]string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(formatSums(sums))))
+}
+
+// diffSums lists the files added, removed and modified in actual compared to expected, ordered by path.
+func diffSums(expected, actual map[string]string) []string {
+	var changes []string
+	for path, sum := range actual {
+		if expectedSum, ok := expected[path]; !ok {
+			changes = append(changes, "added     "+path)
+		} else if sum != expectedSum {
+			changes = append(changes, "modified  "+path)
+		}
+	}
+	for path := range expected {
+		if _, ok := actual[path]; !ok {
+			changes = append(changes, "removed   "+path)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i][10:] < changes[j][10:]
+	})
+	return changes
+}
+
+func (d Dependency) sumsFile(rootDir string) string {
+	return filepath.Join(rootDir, dotOpaDir, sumsDir, d.id()+".sum")
+}
+
+// verifyContent checks the fetched content of a pinned dependency against the content hash recorded in the lock file,
+// and records its hash in the new lock. On mismatch, the content is quarantined and the update fails.
+// The file sums of verified content are kept in the .opa directory, for reporting what changed on a later mismatch.
+func (d Dependency) verifyContent(ctx *updateContext, targetDir string) error {
+	sums, err := contentSums(targetDir)
+	if err != nil {
+		return err
+	}
+	hash := contentHash(sums)
+
+	if locked, ok := ctx.lock.Get(d.location()); ok && locked.Hash != "" && locked.Hash != hash {
+		mismatch := fmt.Sprintf("content hash %s does not match locked hash %s", hash, locked.Hash)
+		var changes []string
+		if bs, err := os.ReadFile(d.sumsFile(ctx.rootDir)); err == nil {
+			if previous := parseSums(string(bs)); contentHash(previous) == locked.Hash {
+				changes = diffSums(previous, sums)
+			}
+		}
+		return d.quarantine(ctx, targetDir, mismatch, changes, sums)
+	}
+
+	return d.recordSums(ctx, sums)
+}
+
+// recordContent records the hash of the fetched content of the dependency in targetDir in the new lock, without
+// verifying it; for content expected to change, such as that of a floating git location pinned to a new commit.
+func (d Dependency) recordContent(ctx *updateContext, targetDir string) error {
+	sums, err := contentSums(targetDir)
+	if err != nil {
+		return err
+	}
+	return d.recordSums(ctx, sums)
+}
+
+// recordSums records the hash of the content sums in the new lock, and the sums themselves next to the dependency, for
+// reporting changes to it.
+func (d Dependency) recordSums(ctx *updateContext, sums map[string]string) error {
+	ctx.newLock.modify(d.location(), func(entry *LockedDependency) {
+		entry.Hash = contentHash(sums)
+	})
+
+	sumsFile := d.sumsFile(ctx.rootDir)
+	if err := os.MkdirAll(filepath.Dir(sumsFile), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(sumsFile, []byte(formatSums(sums)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sumsFile, err)
+	}
+	return nil
+}
+
+// quarantine moves the fetched content of the dependency out of the dependencies directory, into a timestamped
+// directory under .opa/quarantine, along with a report of the mismatch. changes lists the changed files, if the
+// previously verified content is known; otherwise, the sums of all fetched files are reported, if known.
+// Always returns an error, for failing the update.
+func (d Dependency) quarantine(ctx *updateContext, targetDir string, mismatch string, changes []string,
+	sums map[string]string) error {
+	now := time.Now().UTC()
+	dir := filepath.Join(ctx.rootDir, dotOpaDir, quarantineDir, fmt.Sprintf("%s-%s", d.Name, now.Format("20060102T150405")))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory %s: %w", dir, err)
+	}
+	if err := os.Rename(targetDir, filepath.Join(dir, "content")); err != nil {
+		return fmt.Errorf("failed to quarantine %s: %w", targetDir, err)
+	}
+
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("Dependency:  %s\n", d.Name))
+	report.WriteString(fmt.Sprintf("Location:    %s\n", d.location()))
+	report.WriteString(fmt.Sprintf("Quarantined: %s\n", now.Format(time.RFC3339)))
+	report.WriteString(fmt.Sprintf("Mismatch:    %s\n", mismatch))
+	switch {
+	case changes != nil:
+		report.WriteString("\nChanged files since the last verified fetch:\n\n")
+		for _, change := range changes {
+			report.WriteString("  " + change + "\n")
+		}
+	case sums != nil:
+		report.WriteString("\nFetched files:\n\n")
+		report.WriteString(formatSums(sums))
+	}
+
+	reportFile := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(reportFile, []byte(report.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write quarantine report %s: %w", reportFile, err)
+	}
+	printer.Debug("Quarantined %s in %s", d.Name, dir)
+
+	return errs.New(errs.ContentMismatch, "fetched content of dependency %s (%s) does not match the lock file: %s; "+
+		"the content has been quarantined, see %s", d.Name, d.location(), mismatch, reportFile)
+}
@@ -0,0 +1,106 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffSums(t *testing.T) {
+	expected := map[string]string{"a.rego": "1", "b.rego": "2", "c.rego": "3"}
+	actual := map[string]string{"a.rego": "1", "b.rego": "4", "d.rego": "5"}
+
+	changes := diffSums(expected, actual)
+	if exp := []string{"modified  b.rego", "removed   c.rego", "added     d.rego"}; !reflect.DeepEqual(changes, exp) {
+		t.Fatalf("expected changes %v, got %v", exp, changes)
+	}
+}
+
+func TestUpdateGitDependencyQuarantine(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	commit := func(files map[string]string) {
+		hash := upstream.commitFiles(files, "commit")
+		_ = upstream.repo.DeleteTag("v1")
+		if _, err := upstream.repo.CreateTag("v1", hash, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit(map[string]string{"policy.rego": "package origin"})
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  policy:
+    location: git+file://%s#v1
+    namespace: false
+`, upstream.dir),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		if policy := updateAndRead(t, root, "policy", "policy.rego"); policy != "package origin" {
+			t.Fatalf("expected package origin, got %s", policy)
+		}
+
+		// Lock files predating recorded tag commits only pin the content of tags
+		lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for location := range lock.Dependencies {
+			lock.modify(location, func(entry *LockedDependency) {
+				entry.Commit = ""
+			})
+		}
+		if err := lock.WriteToFile(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Moving the tag changes the fetched content
+		commit(map[string]string{"policy.rego": "package moved", "extra.rego": "package extra"})
+
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = project.Update()
+		if !errs.Is(err, errs.ContentMismatch) || !strings.Contains(err.Error(), "does not match the lock file") {
+			t.Fatalf("expected content mismatch error, got %v", err)
+		}
+
+		depDir := project.Dependencies["policy"].dir(dependenciesDir(root))
+		if utils.FileExists(depDir) {
+			t.Fatalf("expected %s to be quarantined", depDir)
+		}
+
+		quarantined, err := filepath.Glob(filepath.Join(root, ".opa", "quarantine", "policy-*"))
+		if err != nil || len(quarantined) != 1 {
+			t.Fatalf("expected single quarantine directory, got %v", quarantined)
+		}
+		bs, err := os.ReadFile(filepath.Join(quarantined[0], "content", "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package moved" {
+			t.Fatalf("expected quarantined package moved, got %s", string(bs))
+		}
+
+		bs, err = os.ReadFile(filepath.Join(quarantined[0], "report.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := string(bs)
+		for _, expected := range []string{"added     extra.rego", "modified  policy.rego"} {
+			if !strings.Contains(report, expected) {
+				t.Fatalf("expected report to contain %q, got:\n%s", expected, report)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,374 @@
+package proj
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	recordingFormat = "1"
+	// symbolicRefPrefix prefixes recorded symbolic references, as in git's own files
+	symbolicRefPrefix = "ref: "
+)
+
+// ResolutionRecording holds the remote responses the resolution of an update depends on, and the lock it started from;
+// recorded during one update, so that the exact resolution can be reproduced by replaying them in another, e.g. to
+// debug intermittent resolution failures. Dependency content is still fetched when replaying, at the recorded
+// revisions.
+type ResolutionRecording struct {
+	Format string `json:"format"`
+	// Lock is the lock the update started from
+	Lock map[string]LockedDependency `json:"lock"`
+	// GitRefs are the references of git repositories, by URL: HEAD, branches and tags, by full name, to the hash, or
+	// the target of symbolic references prefixed with 'ref: '
+	GitRefs map[string]map[string]string `json:"gitRefs,omitempty"`
+	// OciTags are the tags of OCI repositories, by registry and repository
+	OciTags map[string][]string `json:"ociTags,omitempty"`
+	// OciMetadata is the ODM metadata of OCI repositories, by registry and repository
+	OciMetadata map[string]*oci.Metadata `json:"ociMetadata,omitempty"`
+	// OciDigests are the manifest digests OCI references without digest resolved to, by reference
+	OciDigests map[string]string `json:"ociDigests,omitempty"`
+
+	replay bool
+	// mu guards the recording, as used by dependencies updated concurrently
+	mu sync.Mutex
+}
+
+// NewResolutionRecording returns an empty recording, to record an update's resolution into.
+func NewResolutionRecording() *ResolutionRecording {
+	return &ResolutionRecording{
+		Format:      recordingFormat,
+		GitRefs:     make(map[string]map[string]string),
+		OciTags:     make(map[string][]string),
+		OciMetadata: make(map[string]*oci.Metadata),
+		OciDigests:  make(map[string]string),
+	}
+}
+
+// ReadResolutionRecording reads a recording, as written by WriteToFile, to replay.
+func ReadResolutionRecording(path string) (*ResolutionRecording, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.New(errs.InvalidUsage, "failed to read resolution recording %s: %w", path, err)
+	}
+	r := NewResolutionRecording()
+	if err := json.Unmarshal(bs, r); err != nil {
+		return nil, errs.New(errs.InvalidUsage, "failed to parse resolution recording %s: %w", path, err)
+	}
+	if r.Format != recordingFormat {
+		return nil, errs.New(errs.InvalidUsage, "unsupported format '%s' of resolution recording %s; expected %s",
+			r.Format, path, recordingFormat)
+	}
+	r.replay = true
+	return r, nil
+}
+
+// WriteToFile writes the recording as JSON.
+func (r *ResolutionRecording) WriteToFile(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bs, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(bs, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write resolution recording %s: %w", path, err)
+	}
+	return nil
+}
+
+// startLock returns the lock the update is to start from: the recorded lock when replaying; otherwise the given lock,
+// which is recorded.
+func (r *ResolutionRecording) startLock(lock *Lock) *Lock {
+	if r == nil {
+		return lock
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.replay {
+		replayed := newLock(lock.filePath)
+		for location, locked := range r.Lock {
+			replayed.Dependencies[location] = locked
+		}
+		return replayed
+	}
+	r.Lock = make(map[string]LockedDependency, len(lock.Dependencies))
+	for location, locked := range lock.Dependencies {
+		r.Lock[location] = locked
+	}
+	return lock
+}
+
+// notRecorded returns the error of a replay requiring a remote response missing from the recording.
+func notRecorded(what string) error {
+	return errs.New(errs.ResolutionFailed, "%s not found in the replayed resolution recording; "+
+		"the project's dependencies differ from when it was recorded", what)
+}
+
+// ociTags returns the tags of the repository with the given key, as recorded when replaying; otherwise as listed by
+// list, and recorded.
+func (r *ResolutionRecording) ociTags(key string, list func() ([]string, error)) ([]string, error) {
+	if r == nil {
+		return list()
+	}
+	return replayOrRecord(r, r.OciTags, key, "tags of "+key, list)
+}
+
+// ociMetadata returns the metadata of the repository with the given key, as recorded when replaying; otherwise as
+// fetched by fetch, and recorded.
+func (r *ResolutionRecording) ociMetadata(key string, fetch func() (*oci.Metadata, error)) (*oci.Metadata, error) {
+	if r == nil {
+		return fetch()
+	}
+	return replayOrRecord(r, r.OciMetadata, key, "metadata of "+key, fetch)
+}
+
+// ociDigest returns the recorded digest of ref, when replaying a recording holding it.
+func (r *ResolutionRecording) ociDigest(ref oci.Reference) (string, bool) {
+	if r == nil || !r.replay {
+		return "", false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest, ok := r.OciDigests[ref.String()]
+	return digest, ok
+}
+
+// recordOciDigest records the digest ref, without digest, resolved to.
+func (r *ResolutionRecording) recordOciDigest(ref oci.Reference, digest string) {
+	if r == nil || r.replay || ref.Digest != "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.OciDigests[ref.String()] = digest
+}
+
+// replaying returns true if the recording is replayed, rather than recorded.
+func (r *ResolutionRecording) replaying() bool {
+	return r != nil && r.replay
+}
+
+// remoteGitRefs returns the references of the git repository at url, as recorded when replaying; otherwise as listed
+// by list, and recorded.
+func (r *ResolutionRecording) remoteGitRefs(url string, list func() ([]*plumbing.Reference, error)) (
+	[]*plumbing.Reference, error) {
+	if r == nil {
+		return list()
+	}
+	refs, err := replayOrRecord(r, r.GitRefs, url, "references of "+url, func() (map[string]string, error) {
+		refs, err := list()
+		if err != nil {
+			return nil, err
+		}
+		return recordedRefs(refs), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replayedRefs(refs), nil
+}
+
+// clonedGitRefs records the references of the remote of the repository cloned from url, unless already listed from the
+// remote; or, when replaying, resets the references of the clone to the recorded ones, checking out the recorded HEAD
+// if checkout is true.
+func (r *ResolutionRecording) clonedGitRefs(url string, repo *git.Repository, checkout bool) error {
+	if r == nil {
+		return nil
+	}
+	if !r.replay {
+		refs, err := cloneRemoteRefs(repo)
+		if err != nil {
+			return fmt.Errorf("failed to record references of %s: %w", url, err)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.GitRefs[url]; !ok {
+			r.GitRefs[url] = recordedRefs(refs)
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	recorded, ok := r.GitRefs[url]
+	r.mu.Unlock()
+	if !ok {
+		return notRecorded("references of " + url)
+	}
+	if err := resetCloneRefs(repo, replayedRefs(recorded), checkout); err != nil {
+		return errs.New(errs.ResolutionFailed, "failed to replay references of %s: %w", url, err)
+	}
+	return nil
+}
+
+// replayOrRecord returns the value of key in the recorded values when replaying; otherwise the value returned by get,
+// which is recorded.
+func replayOrRecord[V any](r *ResolutionRecording, recorded map[string]V, key string, what string,
+	get func() (V, error)) (V, error) {
+	if r.replay {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		value, ok := recorded[key]
+		if !ok {
+			return value, notRecorded(what)
+		}
+		return value, nil
+	}
+
+	value, err := get()
+	if err != nil {
+		return value, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recorded[key] = value
+	return value, nil
+}
+
+func recordedRefs(refs []*plumbing.Reference) map[string]string {
+	recorded := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		if ref.Type() == plumbing.SymbolicReference {
+			recorded[ref.Name().String()] = symbolicRefPrefix + ref.Target().String()
+		} else {
+			recorded[ref.Name().String()] = ref.Hash().String()
+		}
+	}
+	return recorded
+}
+
+func replayedRefs(recorded map[string]string) []*plumbing.Reference {
+	names := make([]string, 0, len(recorded))
+	for name := range recorded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	refs := make([]*plumbing.Reference, 0, len(recorded))
+	for _, name := range names {
+		value := recorded[name]
+		if strings.HasPrefix(value, symbolicRefPrefix) {
+			refs = append(refs, plumbing.NewSymbolicReference(plumbing.ReferenceName(name),
+				plumbing.ReferenceName(strings.TrimPrefix(value, symbolicRefPrefix))))
+		} else {
+			refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(name), plumbing.NewHash(value)))
+		}
+	}
+	return refs
+}
+
+// cloneRemoteRefs returns the references of the remote of a clone, as the remote lists them: HEAD, branches and tags.
+func cloneRemoteRefs(repo *git.Repository) ([]*plumbing.Reference, error) {
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+	refs := []*plumbing.Reference{head}
+
+	iter, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	remotePrefix := "refs/remotes/" + git.DefaultRemoteName + "/"
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		switch {
+		case ref.Type() != plumbing.HashReference:
+		case ref.Name().IsTag():
+			refs = append(refs, ref)
+		case strings.HasPrefix(name, remotePrefix):
+			branch := plumbing.NewBranchReferenceName(strings.TrimPrefix(name, remotePrefix))
+			refs = append(refs, plumbing.NewHashReference(branch, ref.Hash()))
+		}
+		return nil
+	})
+	return refs, err
+}
+
+// resetCloneRefs resets the references of a clone to the given references of its remote, as the remote lists them;
+// removing tags and remote branches not among them. HEAD is checked out if checkout is true.
+func resetCloneRefs(repo *git.Repository, refs []*plumbing.Reference, checkout bool) error {
+	remotePrefix := "refs/remotes/" + git.DefaultRemoteName + "/"
+	iter, err := repo.References()
+	if err != nil {
+		return err
+	}
+	var stale []plumbing.ReferenceName
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name().IsTag() || strings.HasPrefix(ref.Name().String(), remotePrefix) {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		if err := repo.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		name := ref.Name()
+		switch {
+		case name == plumbing.HEAD:
+			head = ref
+			continue
+		case name.IsBranch():
+			name = plumbing.NewRemoteReferenceName(git.DefaultRemoteName, name.Short())
+		case !name.IsTag():
+			continue
+		}
+		if ref.Type() != plumbing.HashReference {
+			continue
+		}
+		if _, err := repo.Storer.EncodedObject(plumbing.AnyObject, ref.Hash()); err != nil {
+			return fmt.Errorf("recorded %s %s not found: %w", ref.Name(), ref.Hash(), err)
+		}
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(name, ref.Hash())); err != nil {
+			return err
+		}
+	}
+	if head == nil {
+		return fmt.Errorf("recorded HEAD not found")
+	}
+
+	// The clone's HEAD is its local branch of the remote's default branch
+	hash := head.Hash()
+	if head.Type() == plumbing.SymbolicReference {
+		branch, err := storer.ResolveReference(repo.Storer,
+			plumbing.NewRemoteReferenceName(git.DefaultRemoteName, head.Target().Short()))
+		if err != nil {
+			return fmt.Errorf("recorded HEAD target %s not found: %w", head.Target(), err)
+		}
+		hash = branch.Hash()
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(head.Target(), hash)); err != nil {
+			return err
+		}
+		if err := repo.Storer.SetReference(head); err != nil {
+			return err
+		}
+	} else if err := repo.Storer.SetReference(head); err != nil {
+		return err
+	}
+
+	if !checkout {
+		return nil
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset})
+}
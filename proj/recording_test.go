@@ -0,0 +1,91 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateRecordAndReplayResolution(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	v10 := registry.Push("org/ranged", "1.0.0", map[string]string{"/policy.rego": "package ranged"})
+
+	upstream := newGitUpstream(t, "policy.rego")
+	first := upstream.commit("package floating").String()
+
+	ranged := fmt.Sprintf("oci://%s/org/ranged:^1.0", registry.Host())
+	floating := "git+file://" + upstream.dir
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`dependencies:
+  ranged: %s
+  floating: %s
+`, ranged, floating),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recording := NewResolutionRecording()
+		if err := project.UpdateWithOptions(UpdateOptions{Refresh: true, Recording: recording}); err != nil {
+			t.Fatal(err)
+		}
+		recordingFile := filepath.Join(root, "resolution.json")
+		if err := recording.WriteToFile(recordingFile); err != nil {
+			t.Fatal(err)
+		}
+
+		// Newer revisions, and a forgotten lock, don't change the replayed resolution
+		registry.Push("org/ranged", "1.1.0", map[string]string{"/policy.rego": "package ranged.v1_1"})
+		upstream.commit("package floating\n\nallow := true")
+		if err := os.Remove(lockFilePath(project.filePath)); err != nil {
+			t.Fatal(err)
+		}
+
+		replay, err := ReadResolutionRecording(recordingFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.UpdateWithOptions(UpdateOptions{Refresh: true, Recording: replay}); err != nil {
+			t.Fatal(err)
+		}
+		lock, err := ReadLockFile(lockFilePath(project.filePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lock.Get(ranged); locked.Digest != v10 || locked.Tag != "1.0.0" {
+			t.Fatalf("expected replayed version 1.0.0 (%s), got %+v", v10, locked)
+		}
+		if locked, _ := lock.Get(floating); locked.Commit != first || locked.Branch != "master" {
+			t.Fatalf("expected replayed commit %s of master, got %+v", first, locked)
+		}
+		bs, err := os.ReadFile(filepath.Join(project.Dependencies["floating"].dir(dependenciesDir(root)), "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package floating.floating\n" {
+			t.Fatalf("expected content of the replayed commit, got %s", bs)
+		}
+
+		// Dependencies not recorded can't be replayed
+		other := fmt.Sprintf("dependencies:\n  other: oci://%s/org/other:^1.0\n", registry.Host())
+		if err := os.WriteFile(project.filePath, []byte(other), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if project, err = ReadProjectFromFile(root, false); err != nil {
+			t.Fatal(err)
+		}
+		replay, _ = ReadResolutionRecording(recordingFile)
+		if err := project.UpdateWithOptions(UpdateOptions{Recording: replay}); !errs.Is(err, errs.ResolutionFailed) {
+			t.Fatalf("expected resolution failure, got %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
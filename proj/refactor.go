@@ -0,0 +1,210 @@
+package proj
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/timing"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	refactorCacheDir = "refactor"
+	// refactorCacheFormat is bumped whenever ODM changes how refactored content is produced, invalidating cached results
+	refactorCacheFormat = "2"
+	// refactorSumsFile records the sums of the files of a cache entry, verified before the entry is restored
+	refactorSumsFile = "refactored.sum"
+	restoreSuffix    = ".odm-restore"
+)
+
+// refactorNamespace moves the packages of the Rego files in dirs, within the dependency's targetDir, into the given
+// namespace. As refactoring is slow for big libraries, the refactored files are cached in the ODM cache directory, by
+// the content of the refactored files, the namespace, and the OPA version; repeated resolutions of the same content
+// skip refactoring entirely.
+func refactorNamespace(targetDir string, dirs []string, namespace string) error {
+	defer timing.Start(timing.Refactor, namespace)()
+
+	opa := utils.NewOpa(dirs...)
+
+	key, err := refactorCacheKey(opa, targetDir, dirs, namespace)
+	if err != nil {
+		printer.Debug("Not caching refactoring of %s: %s", targetDir, err)
+	} else if restored, err := restoreRefactored(key, targetDir); err != nil {
+		return fmt.Errorf("failed to restore cached refactoring: %w", err)
+	} else if restored {
+		printer.Debug("Restored refactoring of %s into namespace %s from cache", targetDir, namespace)
+		return nil
+	}
+
+	if err := opa.Refactor("data", fmt.Sprintf("data.%s", namespace)); err != nil {
+		return err
+	}
+
+	if key != "" {
+		storeRefactored(key, targetDir, dirs)
+	}
+	return nil
+}
+
+// refactorCacheKey returns the key of the refactored content of the Rego files in dirs: a hash of their paths and
+// content, the namespace, and the version of OPA refactoring them.
+func refactorCacheKey(opa *utils.Opa, targetDir string, dirs []string, namespace string) (string, error) {
+	version, err := opa.Version()
+	if err != nil {
+		return "", err
+	}
+	sums, err := regoSums(targetDir, dirs)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%s\n%s\n%s", refactorCacheFormat, version, namespace, formatSums(sums))
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// regoSums returns the sums of the Rego files in dirs, by slash-separated path relative to targetDir.
+func regoSums(targetDir string, dirs []string) (map[string]string, error) {
+	sums := make(map[string]string)
+	for _, dir := range dirs {
+		dirSums, err := contentSums(dir)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(targetDir, dir)
+		if err != nil {
+			return nil, err
+		}
+		for path, sum := range dirSums {
+			if strings.HasSuffix(path, ".rego") {
+				sums[filepath.ToSlash(filepath.Join(rel, path))] = sum
+			}
+		}
+	}
+	return sums, nil
+}
+
+func refactorCachePath(key string) (string, error) {
+	cacheDir, err := utils.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, refactorCacheDir, key), nil
+}
+
+// restoreRefactored materializes cached refactored Rego files over those of targetDir, returning false if none are
+// cached. Files are linked from the cache where the filesystem allows it, rather than copied; see utils.LinkFile.
+// As files linked from the cache may have been written through by hand-edits in other projects, entries are verified
+// against their recorded sums first; modified entries are dropped. All cached files are staged next to their targets
+// before any is moved into place, as partially restored content can't be refactored again.
+func restoreRefactored(key string, targetDir string) (bool, error) {
+	dir, err := refactorCachePath(key)
+	if err != nil || !utils.IsDir(dir) {
+		return false, nil
+	}
+	if err := verifyRefactored(dir); err != nil {
+		printer.Debug("Dropping cached refactoring %s: %s", key, err)
+		_ = os.RemoveAll(dir)
+		return false, nil
+	}
+	var staged []string
+	discard := func() {
+		for _, path := range staged {
+			_ = os.Remove(path)
+		}
+	}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == refactorSumsFile {
+			return err
+		}
+		tmp := filepath.Join(targetDir, rel) + restoreSuffix
+		staged = append(staged, tmp)
+		return utils.LinkFile(path, tmp)
+	})
+	if err != nil {
+		discard()
+		printer.Debug("Ignoring cached refactoring %s: %s", key, err)
+		return false, nil
+	}
+
+	for _, tmp := range staged {
+		if err := os.Rename(tmp, strings.TrimSuffix(tmp, restoreSuffix)); err != nil {
+			discard()
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// verifyRefactored fails if the files of the cache entry in dir don't match its recorded sums.
+func verifyRefactored(dir string) error {
+	bs, err := os.ReadFile(filepath.Join(dir, refactorSumsFile))
+	if err != nil {
+		return err
+	}
+	sums, err := contentSums(dir)
+	if err != nil {
+		return err
+	}
+	delete(sums, refactorSumsFile)
+	if changes := diffSums(parseSums(string(bs)), sums); len(changes) > 0 {
+		return fmt.Errorf("content modified:\n  %s", strings.Join(changes, "\n  "))
+	}
+	return nil
+}
+
+// storeRefactored stores the refactored Rego files in dirs of targetDir in the cache. Failures are only logged, as the
+// cache is an optimization. Cached files are read-only, as they may be hardlinked into the .opa directories of projects.
+func storeRefactored(key string, targetDir string, dirs []string) {
+	dir, err := refactorCachePath(key)
+	if err != nil {
+		printer.Debug("Not caching refactoring: %s", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		printer.Debug("Not caching refactoring: %s", err)
+		return
+	}
+	// Entries are written in full before being moved into place, so that concurrent updates never see partial entries
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "tmp-")
+	if err != nil {
+		printer.Debug("Not caching refactoring: %s", err)
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	sums, err := regoSums(targetDir, dirs)
+	if err != nil {
+		printer.Debug("Not caching refactoring: %s", err)
+		return
+	}
+	for path := range sums {
+		bs, err := os.ReadFile(filepath.Join(targetDir, filepath.FromSlash(path)))
+		if err == nil {
+			dst := filepath.Join(tmp, filepath.FromSlash(path))
+			if err = os.MkdirAll(filepath.Dir(dst), 0755); err == nil {
+				err = os.WriteFile(dst, bs, 0444)
+			}
+		}
+		if err != nil {
+			printer.Debug("Not caching refactoring: %s", err)
+			return
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmp, refactorSumsFile), []byte(formatSums(sums)), 0444); err != nil {
+		printer.Debug("Not caching refactoring: %s", err)
+		return
+	}
+
+	if err := os.Rename(tmp, dir); err != nil && !utils.FileExists(dir) {
+		printer.Debug("Not caching refactoring: %s", err)
+		return
+	}
+	printer.Debug("Cached refactoring as %s", key)
+}
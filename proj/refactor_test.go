@@ -0,0 +1,99 @@
+package proj
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateRefactorCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("ODM_CACHE_DIR", cacheDir)
+
+	files := map[string]string{
+		"opa.project":  "dependencies:\n  lib: file:///lib\n",
+		"lib/lib.rego": "package lib\n\nallow := true",
+	}
+
+	err := withTempFiles(files, func(root string) {
+		if policy := updateAndRead(t, root, "lib", "lib.rego"); !strings.HasPrefix(policy, "package lib.lib") {
+			t.Fatalf("expected package lib.lib, got %s", policy)
+		}
+
+		entries, err := filepath.Glob(filepath.Join(cacheDir, refactorCacheDir, "*", "lib.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 cached refactoring, got %v", entries)
+		}
+
+		// Repeated updates of the same content are restored from the cache, instead of being refactored
+		cached := "package lib.lib\n\n# from cache\nallow := true\n"
+		writeCacheEntry(t, entries[0], cached, true)
+		if policy := updateAndRead(t, root, "lib", "lib.rego"); policy != cached {
+			t.Fatalf("expected cached refactoring, got %s", policy)
+		}
+
+		// Restored files may be linked from the cache, but rewriting them in place leaves the cache untouched
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		project.Renames = map[string]string{"lib.lib": "lib.renamed"}
+		if err := project.applyRenames(nil); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := os.ReadFile(entries[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != cached {
+			t.Fatalf("expected cache entry to be untouched, got %s", bs)
+		}
+
+		// Cache entries modified since stored, e.g. by a hand-edit through a hardlink, are dropped and refactored again
+		writeCacheEntry(t, entries[0], "package lib.lib\n\n# edited\nallow := true\n", false)
+		if policy := updateAndRead(t, root, "lib", "lib.rego"); strings.Contains(policy, "edited") ||
+			!strings.HasPrefix(policy, "package lib.lib") {
+			t.Fatalf("expected refactored package lib.lib, got %s", policy)
+		}
+
+		// Changed content is refactored again
+		if err := os.WriteFile(filepath.Join(root, "lib", "lib.rego"), []byte("package lib\n\nallow := false"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if policy := updateAndRead(t, root, "lib", "lib.rego"); !strings.Contains(policy, "allow := false") ||
+			!strings.HasPrefix(policy, "package lib.lib") {
+			t.Fatalf("expected refactored package lib.lib, got %s", policy)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeCacheEntry replaces the content of the cached file at path, optionally recording its sum in the entry.
+func writeCacheEntry(t *testing.T, path string, content string, recordSum bool) {
+	t.Helper()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0444); err != nil {
+		t.Fatal(err)
+	}
+	if !recordSum {
+		return
+	}
+	sumsPath := filepath.Join(filepath.Dir(path), refactorSumsFile)
+	sums := map[string]string{filepath.Base(path): fmt.Sprintf("%x", sha256.Sum256([]byte(content)))}
+	if err := os.Remove(sumsPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sumsPath, []byte(formatSums(sums)), 0444); err != nil {
+		t.Fatal(err)
+	}
+}
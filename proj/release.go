@@ -0,0 +1,138 @@
+package proj
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const githubReleaseScheme = "github-release://"
+
+// githubRelease is a release asset, as located by github-release://<owner>/<repo>@<tag>/<asset>.
+type githubRelease struct {
+	owner string
+	repo  string
+	tag   string
+	asset string
+}
+
+func (r githubRelease) String() string {
+	return fmt.Sprintf("%s/%s@%s", r.owner, r.repo, r.tag)
+}
+
+// parseGithubRelease parses a github-release://<owner>/<repo>@<tag>/<asset> location. The asset is the last path
+// segment, and so the tag may contain slashes.
+func parseGithubRelease(location string) (githubRelease, error) {
+	rest, ok := strings.CutPrefix(location, githubReleaseScheme)
+	if !ok {
+		return githubRelease{}, errs.New(errs.InvalidLocation, "not a GitHub release location: %s", location)
+	}
+	repo, rest, _ := strings.Cut(rest, "@")
+	owner, name, _ := strings.Cut(repo, "/")
+	i := strings.LastIndex(rest, "/")
+	if owner == "" || name == "" || strings.Contains(name, "/") || i <= 0 || i == len(rest)-1 {
+		return githubRelease{}, errs.New(errs.InvalidLocation,
+			"invalid GitHub release location %s: expected github-release://<owner>/<repo>@<tag>/<asset>", location)
+	}
+	return githubRelease{owner: owner, repo: name, tag: rest[:i], asset: rest[i+1:]}, nil
+}
+
+// githubApiUrl returns the base URL of the GitHub API: as set by GITHUB_API_URL, for GitHub Enterprise Server; or else
+// that of github.com.
+func githubApiUrl() string {
+	if api := os.Getenv("GITHUB_API_URL"); api != "" {
+		return strings.TrimSuffix(api, "/")
+	}
+	return "https://api.github.com"
+}
+
+// githubHost returns the host of the GitHub instance serving the API at apiUrl, for looking up its credentials.
+func githubHost(apiUrl string) string {
+	u, err := url.Parse(apiUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Host, "api.")
+}
+
+// download downloads the release asset to the file at dst, authenticated with the token of the GitHub host, if any;
+// as required for private repositories.
+func (release githubRelease) download(dst string) error {
+	api := githubApiUrl()
+	credential, authenticated := gitCredential(githubHost(api))
+
+	get := func(u string, accept string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", accept)
+		if authenticated {
+			req.Header.Set("Authorization", "Bearer "+credential.Secret)
+		}
+		return http.DefaultClient.Do(req)
+	}
+
+	releaseUrl := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", api, url.PathEscape(release.owner),
+		url.PathEscape(release.repo), url.PathEscape(release.tag))
+	printer.Debug("Resolving GitHub release %s", release)
+	resp, err := get(releaseUrl, "application/vnd.github+json")
+	if err != nil {
+		return fmt.Errorf("failed to resolve GitHub release %s: %w", release, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("GitHub release %s not found", release)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to resolve GitHub release %s: %w", release, utils.NewStatusError(resp))
+	}
+	var body struct {
+		Assets []struct {
+			Name string `json:"name"`
+			Url  string `json:"url"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to read GitHub release %s: %w", release, err)
+	}
+
+	var assetUrl string
+	for _, asset := range body.Assets {
+		if asset.Name == release.asset {
+			assetUrl = asset.Url
+		}
+	}
+	if assetUrl == "" {
+		return fmt.Errorf("GitHub release %s has no asset %s", release, release.asset)
+	}
+
+	printer.Debug("Downloading asset %s of GitHub release %s to %s", release.asset, release, dst)
+	// The asset is served from storage redirected to; the authorization header isn't forwarded to other hosts
+	assetResp, err := get(assetUrl, "application/octet-stream")
+	if err != nil {
+		return fmt.Errorf("failed to download asset %s of GitHub release %s: %w", release.asset, release, err)
+	}
+	defer func() { _ = assetResp.Body.Close() }()
+	if assetResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download asset %s of GitHub release %s: %w", release.asset, release,
+			utils.NewStatusError(assetResp))
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", dst, err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(f, assetResp.Body); err != nil {
+		return fmt.Errorf("failed to download asset %s of GitHub release %s: %w", release.asset, release, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package proj
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGithubRelease(t *testing.T) {
+	tests := []struct {
+		note     string
+		location string
+		expected githubRelease
+		invalid  bool
+	}{
+		{
+			note:     "tag and asset",
+			location: "github-release://org/lib@v1.2.3/bundle.tar.gz",
+			expected: githubRelease{owner: "org", repo: "lib", tag: "v1.2.3", asset: "bundle.tar.gz"},
+		},
+		{
+			note:     "tag with slashes",
+			location: "github-release://org/lib@release/v1/bundle.tar.gz",
+			expected: githubRelease{owner: "org", repo: "lib", tag: "release/v1", asset: "bundle.tar.gz"},
+		},
+		{
+			note:     "no tag",
+			location: "github-release://org/lib/bundle.tar.gz",
+			invalid:  true,
+		},
+		{
+			note:     "no asset",
+			location: "github-release://org/lib@v1.2.3",
+			invalid:  true,
+		},
+		{
+			note:     "no repository",
+			location: "github-release://org@v1.2.3/bundle.tar.gz",
+			invalid:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			actual, err := parseGithubRelease(tc.location)
+			if tc.invalid {
+				if code := errs.CodeOf(err); code != errs.InvalidLocation {
+					t.Fatalf("expected error code %s, got %v", errs.InvalidLocation.ID, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUpdateGithubRelease(t *testing.T) {
+	t.Setenv("ODM_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	t.Setenv("ODM_KEYRING", "file")
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "netrc"))
+	t.Setenv("ODM_GIT_TOKEN", "secret")
+
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "policy.rego"), []byte("package release"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var tarball bytes.Buffer
+	if err := utils.CreateTarGz(&tarball, srcDir, []string{srcDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/org/lib/releases/tags/v1.2.3":
+			_, _ = fmt.Fprintf(w, `{"assets": [{"name": "bundle.tar.gz", "url": "%s/assets/1"}]}`, server.URL)
+		case "/assets/1":
+			if r.Header.Get("Accept") != "application/octet-stream" {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			_, _ = w.Write(tarball.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_API_URL", server.URL)
+
+	tests := []struct {
+		note            string
+		location        string
+		expectedErr     string
+		expectedErrCode errs.Code
+	}{
+		{
+			note:     "asset",
+			location: "github-release://org/lib@v1.2.3/bundle.tar.gz",
+		},
+		{
+			note:            "missing asset",
+			location:        "github-release://org/lib@v1.2.3/other.tar.gz",
+			expectedErr:     "GitHub release org/lib@v1.2.3 has no asset other.tar.gz",
+			expectedErrCode: errs.FetchFailed,
+		},
+		{
+			note:            "missing release",
+			location:        "github-release://org/lib@v9.9.9/bundle.tar.gz",
+			expectedErr:     "GitHub release org/lib@v9.9.9 not found",
+			expectedErrCode: errs.FetchFailed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project": fmt.Sprintf("name: proj\ndependencies:\n  lib:\n    location: %s\n    namespace: false\n",
+					tc.location),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadAndLoadProject(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = project.UpdateWithOptions(UpdateOptions{})
+				if tc.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected error containing %q, got %v", tc.expectedErr, err)
+					}
+					if code := errs.CodeOf(err); code != tc.expectedErrCode {
+						t.Fatalf("expected error code %s, got %s", tc.expectedErrCode.ID, code.ID)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				bs, err := os.ReadFile(filepath.Join(project.Dependencies["lib"].dir(dependenciesDir(root)), "policy.rego"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(bs) != "package release" {
+					t.Fatalf("expected extracted policy, got %s", bs)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dependencyHealth tells the fetched dependency directories that are intact from those that are missing, partially
+// fetched, or modified, by comparing them to the sums recorded by the last update.
+type dependencyHealth struct {
+	depsDir string
+	// recorded holds the recorded sums by dependency id, then by path relative to the dependency directory; nil if no
+	// sums were recorded
+	recorded map[string]map[string]string
+	checked  map[string]string
+}
+
+func newDependencyHealth(rootDir string) (*dependencyHealth, error) {
+	h := &dependencyHealth{
+		depsDir: dependenciesDir(rootDir),
+		checked: make(map[string]string),
+	}
+	bs, err := os.ReadFile(dependencySumsPath(rootDir))
+	if os.IsNotExist(err) {
+		return h, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	h.recorded = make(map[string]map[string]string)
+	for path, sum := range parseSums(string(bs)) {
+		if id, rel, ok := strings.Cut(path, "/"); ok {
+			if h.recorded[id] == nil {
+				h.recorded[id] = make(map[string]string)
+			}
+			h.recorded[id][rel] = sum
+		}
+	}
+	return h, nil
+}
+
+// problem returns why the directory of the dependency with the given id needs repair; empty if it's intact.
+// Without recorded sums, only missing and empty directories are detected.
+func (h *dependencyHealth) problem(id string) (string, error) {
+	if problem, ok := h.checked[id]; ok {
+		return problem, nil
+	}
+
+	problem := ""
+	dir := filepath.Join(h.depsDir, id)
+	if entries, err := os.ReadDir(dir); os.IsNotExist(err) {
+		problem = "missing"
+	} else if err != nil {
+		return "", err
+	} else if len(entries) == 0 {
+		problem = "empty"
+	} else if h.recorded != nil {
+		sums, err := contentSums(dir)
+		if err != nil {
+			return "", err
+		}
+		if expected, ok := h.recorded[id]; !ok {
+			problem = "not recorded by the last update"
+		} else if changes := diffSums(expected, sums); len(changes) > 0 {
+			problem = fmt.Sprintf("%d files changed", len(changes))
+		}
+	}
+	h.checked[id] = problem
+	return problem, nil
+}
+
+// Repair re-fetches the dependencies whose directories are missing, partially fetched, or don't match the content
+// recorded by the last update; leaving intact dependencies untouched. Locked dependencies are fetched as locked.
+// Returns the dot-separated paths of the dependencies in need of repair, with the problem found; which are only
+// reported, and not re-fetched, if dryRun is true.
+func (p *Project) Repair(dryRun bool) (map[string]string, error) {
+	rootDir := p.Dir()
+	health, err := newDependencyHealth(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var broken []Dependency
+	problems := make(map[string]string)
+	var walk func(deps Dependencies, parent *Dependency, prefix string) error
+	walk = func(deps Dependencies, parent *Dependency, prefix string) error {
+		for _, name := range sortedDependencyNames(deps) {
+			dep := deps[name]
+			dep.ParentDependency = parent
+			problem, err := health.problem(dep.id())
+			if err != nil {
+				return err
+			}
+			if problem != "" {
+				// Transitive dependencies of broken dependencies are found when the dependency is re-fetched
+				problems[prefix+name] = problem
+				broken = append(broken, dep)
+				continue
+			}
+
+			projectFile := projectFilePath(dep.dir(health.depsDir))
+			if !dep.isBundle() && utils.FileExists(projectFile) {
+				depProject, err := ReadProjectFromFile(projectFile, false)
+				if err != nil {
+					return err
+				}
+				dep.excludeDependencies(depProject)
+				if err := walk(depProject.Dependencies, &dep, prefix+name+"."); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(p.Dependencies, nil, ""); err != nil {
+		return nil, err
+	}
+	if dryRun || len(broken) == 0 {
+		return problems, nil
+	}
+
+	lock, err := ReadLockFile(lockFilePath(p.filePath))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := configureFetching(cfg, p); err != nil {
+		return nil, err
+	}
+
+	ctx := p.newUpdateContext(lock, cfg, newResolver(p.Resolution), make(map[string]*oci.Metadata))
+	ctx.resolver.startPass()
+	// Intact dependencies are marked as updated, for re-fetched dependencies not to replace their intact transitive
+	// dependencies
+	entries, err := os.ReadDir(health.depsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	intact := make(map[string]bool)
+	for _, entry := range entries {
+		if problem, err := health.problem(entry.Name()); err != nil {
+			return nil, err
+		} else if problem == "" {
+			intact[entry.Name()] = true
+			ctx.markUpdated(entry.Name())
+		}
+	}
+
+	for _, dep := range broken {
+		printer.Info("Repairing dependency %s (%s)", dep.Name, dep.location())
+		if err := dep.update(ctx); err != nil {
+			return nil, fmt.Errorf("failed to repair dependency %s: %w", dep.Name, err)
+		}
+	}
+
+	if err := p.Load(); err != nil {
+		return nil, err
+	}
+	if len(p.Renames) > 0 {
+		err := p.applyRenames(func(dep Dependency) bool {
+			return !intact[dep.id()]
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return problems, p.recordDependencySums()
+}
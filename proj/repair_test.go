@@ -0,0 +1,108 @@
+package proj
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		note     string
+		modify   func(depsDir string, libDir string) error
+		expected map[string]string
+	}{
+		{
+			note:     "intact",
+			modify:   func(string, string) error { return nil },
+			expected: map[string]string{},
+		},
+		{
+			note: "modified file",
+			modify: func(_ string, libDir string) error {
+				return os.WriteFile(filepath.Join(libDir, "policy.rego"), []byte("package lib\n\nallow := false"), 0644)
+			},
+			expected: map[string]string{"lib": "1 files changed"},
+		},
+		{
+			note: "emptied directory",
+			modify: func(_ string, libDir string) error {
+				return os.Remove(filepath.Join(libDir, "policy.rego"))
+			},
+			expected: map[string]string{"lib": "empty"},
+		},
+		{
+			note: "missing dependencies directory",
+			modify: func(depsDir string, _ string) error {
+				return os.RemoveAll(depsDir)
+			},
+			expected: map[string]string{"lib": "missing", "other": "missing"},
+		},
+	}
+
+	for _, tc := range tests {
+		for _, dryRun := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s (dry run: %v)", tc.note, dryRun), func(t *testing.T) {
+				files := map[string]string{
+					"opa.project":       "dependencies:\n  lib: file:///lib\n  other: file:///other\n",
+					"lib/policy.rego":   "package lib\n\nallow := true",
+					"other/policy.rego": "package other\n\nallow := true",
+				}
+				err := withTempFiles(files, func(root string) {
+					project, err := ReadProjectFromFile(root, false)
+					if err != nil {
+						t.Fatal(err)
+					}
+					depsDir := dependenciesDir(root)
+					if err := os.MkdirAll(depsDir, 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := project.Update(); err != nil {
+						t.Fatal(err)
+					}
+					otherPath := filepath.Join(project.Dependencies["other"].dir(depsDir), "policy.rego")
+					otherInfo, err := os.Stat(otherPath)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if err := tc.modify(depsDir, project.Dependencies["lib"].dir(depsDir)); err != nil {
+						t.Fatal(err)
+					}
+
+					problems, err := project.Repair(dryRun)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !reflect.DeepEqual(problems, tc.expected) {
+						t.Fatalf("expected problems %v, got %v", tc.expected, problems)
+					}
+
+					changes, err := project.ModifiedDependencyFiles()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if dryRun != (len(changes) > 0) && len(tc.expected) > 0 {
+						t.Fatalf("expected modified files only for dry run, got %v", changes)
+					}
+
+					// Intact dependencies are left untouched
+					if _, ok := tc.expected["other"]; !ok {
+						info, err := os.Stat(otherPath)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if !info.ModTime().Equal(otherInfo.ModTime()) {
+							t.Fatalf("expected intact dependency to be left untouched")
+						}
+					}
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
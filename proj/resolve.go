@@ -0,0 +1,319 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/timing"
+	"github.com/johanfylling/odm/utils"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// ResolutionLatest selects the highest version satisfying all constraints on a dependency
+	ResolutionLatest = "latest"
+	// ResolutionMVS selects the lowest version satisfying all constraints on a dependency, with exact versions
+	// treated as minimum requirements (minimal version selection)
+	ResolutionMVS = "mvs"
+	// ResolutionHighest selects the highest of the versions required of a dependency, as resolved for each constraint
+	// on its own, provided it satisfies all version ranges
+	ResolutionHighest = "highest"
+	// ResolutionFail fails the update if a dependency is required at different versions, or revisions
+	ResolutionFail = "fail"
+
+	maxResolutionPasses = 10
+)
+
+// strategyReasons explain, by strategy, why a version is selected for a dependency constrained in multiple places.
+var strategyReasons = map[string]string{
+	ResolutionLatest:  "latest: the highest version satisfying all constraints",
+	ResolutionMVS:     "mvs: the lowest version satisfying all constraints, exact versions being minimums",
+	ResolutionHighest: "highest: the highest version required, satisfying all version ranges",
+}
+
+// resolver selects a single version for dependencies constrained to different versions throughout the dependency tree;
+// e.g. when two dependencies depend on the same OCI repository at '^1.2' and '1.4.0'.
+//
+// As constraints of transitive dependencies are only known once their enclosing dependencies are fetched, an update is
+// done in passes: each pass records all constraints, and unify selects versions for the next pass. Passes are repeated
+// until the selection no longer changes.
+type resolver struct {
+	strategy string
+	// constraints are the version constraints seen in the current pass, by dependency key
+	constraints map[string][]string
+	// requiredBy are the paths of the dependencies declaring each of the constraints, by dependency key
+	requiredBy map[string][]string
+	// revisions are the other revisions required in the current pass, such as branches and digests, which take no part
+	// in version resolution; by dependency key, then by revision, with the paths of the dependencies requiring them
+	revisions map[string]map[string][]string
+	// used are the versions fetched in the current pass, by dependency key
+	used map[string]map[string]bool
+	// selected are the versions selected by the previous pass, by dependency key
+	selected map[string]string
+	tags     map[string][]string
+	listTags map[string]func() ([]string, error)
+	// pass is the number of the current pass, starting at 1
+	pass int
+	// trace, if set, collects the resolution events explaining the selected versions
+	trace *ResolutionTrace
+	// mu guards the resolver, as used by dependencies updated concurrently
+	mu sync.Mutex
+}
+
+func newResolver(strategy string) *resolver {
+	if strategy == "" {
+		strategy = ResolutionLatest
+	}
+	return &resolver{
+		strategy: strategy,
+		selected: make(map[string]string),
+		tags:     make(map[string][]string),
+		listTags: make(map[string]func() ([]string, error)),
+	}
+}
+
+// startPass resets the constraints recorded in the previous pass.
+func (r *resolver) startPass() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pass++
+	r.constraints = make(map[string][]string)
+	r.requiredBy = make(map[string][]string)
+	r.revisions = make(map[string]map[string][]string)
+	r.used = make(map[string]map[string]bool)
+}
+
+// explain adds the event, of the current pass, to the trace of the resolver; if any.
+func (r *resolver) explain(event ResolutionEvent) {
+	event.Pass = r.pass
+	r.trace.add(event)
+}
+
+// isVersioned reports whether the given tag or ref takes part in version resolution; i.e. it is a version range, or a
+// semver version.
+func isVersioned(tag string) bool {
+	if utils.IsVersionRange(tag) {
+		return true
+	}
+	_, err := semver.StrictNewVersion(strings.TrimPrefix(tag, "v"))
+	return err == nil
+}
+
+// resolve returns the version to fetch for a dependency, at the given path of the dependency tree, declared with the
+// given version constraint. listTags lists the available versions of the dependency; it is only called when needed.
+func (r *resolver) resolve(key string, constraint string, by string, listTags func() ([]string, error)) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.constraints[key] = append(r.constraints[key], constraint)
+	r.requiredBy[key] = append(r.requiredBy[key], by)
+	r.listTags[key] = listTags
+
+	version, ok := r.selected[key]
+	reason := "selected in the previous pass"
+	if !ok {
+		if utils.IsVersionRange(constraint) {
+			var err error
+			if version, err = r.selectVersion(key, []string{constraint}); err != nil {
+				return "", err
+			}
+			reason = "highest matching version"
+			if r.strategy == ResolutionMVS {
+				reason = "lowest matching version"
+			}
+		} else {
+			version = constraint
+			reason = "exact version"
+		}
+	}
+	r.explain(ResolutionEvent{Kind: ExplainConstraint, Key: key, Dependency: by, Constraint: constraint,
+		Version: version, Reason: reason})
+
+	if r.used[key] == nil {
+		r.used[key] = make(map[string]bool)
+	}
+	r.used[key][version] = true
+	return version, nil
+}
+
+// require records that the dependency at the given path of the dependency tree requires a revision that takes no part
+// in version resolution, such as a branch or digest.
+func (r *resolver) require(key string, revision string, by string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.revisions[key] == nil {
+		r.revisions[key] = make(map[string][]string)
+	}
+	r.revisions[key][revision] = append(r.revisions[key][revision], by)
+	r.explain(ResolutionEvent{Kind: ExplainRevision, Key: key, Dependency: by, Constraint: revision})
+}
+
+// checkRevisions reports dependencies required at different revisions taking no part in version resolution in the last
+// pass, which can't be unified, and so are fetched once per revision: failing with the ResolutionFail strategy, or
+// else warning.
+func (r *resolver) checkRevisions() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range sortedKeys(r.revisions) {
+		revisions := r.revisions[key]
+		if len(revisions) < 2 && len(r.constraints[key]) == 0 {
+			continue
+		}
+		var required []string
+		for _, revision := range sortedKeys(revisions) {
+			required = append(required, fmt.Sprintf("%s by %s", revision, strings.Join(revisions[revision], ", ")))
+		}
+		if len(r.constraints[key]) > 0 {
+			required = append(required, r.describeConstraints(key))
+		}
+		if r.strategy == ResolutionFail {
+			r.explain(ResolutionEvent{Kind: ExplainConflict, Key: key, Constraints: required,
+				Reason: "failing, as resolution is " + ResolutionFail})
+			return errs.New(errs.ResolutionFailed, "conflicting revisions of %s: %s", key, strings.Join(required, "; "))
+		}
+		r.explain(ResolutionEvent{Kind: ExplainConflict, Key: key, Constraints: required,
+			Reason: "fetched once per revision"})
+		printer.Warn("%s is required at conflicting revisions, fetching each: %s", key, strings.Join(required, "; "))
+	}
+	return nil
+}
+
+// unify selects a single version for every dependency fetched at multiple versions, or at a version not satisfying all
+// constraints, in the last pass. Returns true if the selection changed, and another pass is required.
+// With the ResolutionFail strategy, dependencies fetched at multiple versions fail the update instead.
+func (r *resolver) unify() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := false
+	for _, key := range sortedKeys(r.constraints) {
+		constraints := r.constraints[key]
+		if len(constraints) < 2 {
+			// The dependency is no longer constrained in multiple places, and needn't be unified
+			if _, ok := r.selected[key]; ok {
+				delete(r.selected, key)
+				changed = true
+			}
+			continue
+		}
+
+		if r.strategy == ResolutionFail {
+			if len(r.used[key]) > 1 {
+				r.explain(ResolutionEvent{Kind: ExplainConflict, Key: key, Constraints: r.constraintsBy(key),
+					Reason: "failing, as resolution is " + ResolutionFail})
+				return false, errs.New(errs.ResolutionFailed, "conflicting versions of %s: %s", key,
+					r.describeConstraints(key))
+			}
+			continue
+		}
+
+		version, err := r.selectVersion(key, constraints)
+		if err != nil {
+			return false, err
+		}
+		r.explain(ResolutionEvent{Kind: ExplainSelected, Key: key, Constraints: r.constraintsBy(key), Version: version,
+			Reason: strategyReasons[r.strategy]})
+
+		if len(r.used[key]) != 1 || !r.used[key][version] {
+			printer.Debug("Selected version %s of %s for constraints %s (%s)", version, key,
+				strings.Join(constraints, ", "), r.strategy)
+			r.selected[key] = version
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// selectVersion returns the available version satisfying all the given constraints, according to the strategy.
+func (r *resolver) selectVersion(key string, constraints []string) (string, error) {
+	defer timing.Start(timing.Resolve, key)()
+
+	tags, ok := r.tags[key]
+	if !ok {
+		var err error
+		if tags, err = r.listTags[key](); err != nil {
+			return "", errs.New(errs.FetchFailed, "failed to list versions of %s: %w", key, err)
+		}
+		r.tags[key] = tags
+		listed := append([]string(nil), tags...)
+		sort.Strings(listed)
+		r.explain(ResolutionEvent{Kind: ExplainTags, Key: key, Tags: listed})
+	}
+
+	if r.strategy == ResolutionHighest {
+		return r.selectHighest(key, constraints, tags)
+	}
+
+	ranges := make([]string, 0, len(constraints))
+	for _, constraint := range constraints {
+		if !utils.IsVersionRange(constraint) {
+			if r.strategy == ResolutionMVS {
+				constraint = ">=" + constraint
+			} else {
+				constraint = "=" + constraint
+			}
+		}
+		ranges = append(ranges, constraint)
+	}
+
+	version, err := utils.SelectVersion(ranges, tags, r.strategy == ResolutionMVS)
+	if err != nil {
+		return "", errs.New(errs.ResolutionFailed, "failed to select version of %s: %w", key, err)
+	}
+	return version, nil
+}
+
+// selectHighest returns the highest of the versions the given constraints resolve to on their own: exact versions as-is,
+// and version ranges to their highest matching version; provided it satisfies all version ranges.
+func (r *resolver) selectHighest(key string, constraints []string, tags []string) (string, error) {
+	var highest string
+	var highestVersion *semver.Version
+	var ranges []string
+	for _, constraint := range constraints {
+		version := constraint
+		if utils.IsVersionRange(constraint) {
+			ranges = append(ranges, constraint)
+			var err error
+			if version, err = utils.SelectVersion([]string{constraint}, tags, false); err != nil {
+				return "", errs.New(errs.ResolutionFailed, "failed to select version of %s: %w", key, err)
+			}
+		}
+		if v, err := semver.NewVersion(version); err == nil && (highestVersion == nil || v.GreaterThan(highestVersion)) {
+			highest, highestVersion = version, v
+		}
+	}
+	if len(ranges) > 0 {
+		if _, err := utils.SelectVersion(ranges, []string{highest}, false); err != nil {
+			return "", errs.New(errs.ResolutionFailed, "failed to select version of %s: highest required version %s "+
+				"doesn't satisfy all constraints: %s", key, highest, r.describeConstraints(key))
+		}
+	}
+	return highest, nil
+}
+
+// describeConstraints lists the version constraints on the dependency at key, with the paths of the dependencies
+// declaring them.
+func (r *resolver) describeConstraints(key string) string {
+	return strings.Join(r.constraintsBy(key), "; ")
+}
+
+// constraintsBy returns the version constraints on the dependency at key, each with the path of the dependency
+// declaring it; sorted, as dependencies are updated concurrently.
+func (r *resolver) constraintsBy(key string) []string {
+	described := make([]string, 0, len(r.constraints[key]))
+	for i, constraint := range r.constraints[key] {
+		described = append(described, fmt.Sprintf("%s by %s", constraint, r.requiredBy[key][i]))
+	}
+	sort.Strings(described)
+	return described
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
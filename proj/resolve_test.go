@@ -0,0 +1,407 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestUpdateResolution(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	digests := map[string]string{}
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"} {
+		digests[version] = registry.Push("org/lib", version, map[string]string{"/lib.rego": "package lib"})
+	}
+
+	tests := []struct {
+		note       string
+		resolution string
+		expected   string
+	}{
+		{
+			note:     "default",
+			expected: "1.2.0",
+		},
+		{
+			note:       "latest",
+			resolution: "resolution: latest",
+			expected:   "1.2.0",
+		},
+		{
+			note:       "mvs",
+			resolution: "resolution: mvs",
+			expected:   "1.1.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			libA := fmt.Sprintf("oci://%s/org/lib:^1.0", registry.Host())
+			libB := fmt.Sprintf("oci://%s/org/lib:>=1.1.0", registry.Host())
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`namespacing: false
+%s
+dependencies:
+  lib: %s
+  b: file:/b
+`, tc.resolution, libA),
+				"b/opa.project": fmt.Sprintf(`dependencies:
+  lib: %s
+`, libB),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := LockedDependency{Digest: digests[tc.expected], Tag: tc.expected}
+				for _, location := range []string{libA, libB} {
+					if locked, _ := lock.Get(location); locked.Digest != expected.Digest || locked.Tag != expected.Tag {
+						t.Fatalf("expected %s to be locked to %v, got %v", location, expected, locked)
+					}
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateResolutionConflict(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	registry.Push("org/lib", "1.0.0", map[string]string{"/lib.rego": "package lib"})
+	registry.Push("org/lib", "2.0.0", map[string]string{"/lib.rego": "package lib"})
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`namespacing: false
+dependencies:
+  lib: oci://%s/org/lib:^1.0
+  b: file:/b
+`, registry.Host()),
+		"b/opa.project": fmt.Sprintf(`dependencies:
+  lib: oci://%s/org/lib:^2.0
+`, registry.Host()),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err == nil {
+			t.Fatal("expected conflicting constraints to fail the update")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateResolutionStrategies(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+
+	digests := map[string]string{}
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		digests[version] = registry.Push("org/lib", version, map[string]string{"/lib.rego": "package lib"})
+	}
+
+	tests := []struct {
+		note        string
+		resolution  string
+		constraintA string
+		constraintB string
+		expected    string
+		expectedErr string
+	}{
+		{
+			note:        "latest, exact versions",
+			resolution:  ResolutionLatest,
+			constraintA: "1.0.0",
+			constraintB: "1.1.0",
+			expectedErr: "no version matching '=1.0.0', '=1.1.0'",
+		},
+		{
+			note:        "mvs, exact versions",
+			resolution:  ResolutionMVS,
+			constraintA: "1.0.0",
+			constraintB: "1.1.0",
+			expected:    "1.1.0",
+		},
+		{
+			note:        "highest, exact versions",
+			resolution:  ResolutionHighest,
+			constraintA: "1.0.0",
+			constraintB: "1.1.0",
+			expected:    "1.1.0",
+		},
+		{
+			note:        "highest, exact version and range",
+			resolution:  ResolutionHighest,
+			constraintA: "1.0.0",
+			constraintB: "^1.0",
+			expected:    "1.2.0",
+		},
+		{
+			note:        "highest, unsatisfied range",
+			resolution:  ResolutionHighest,
+			constraintA: "1.2.0",
+			constraintB: "~1.0",
+			expectedErr: "highest required version 1.2.0 doesn't satisfy all constraints: 1.2.0 by lib; ~1.0 by b.lib",
+		},
+		{
+			note:        "fail, conflicting versions",
+			resolution:  ResolutionFail,
+			constraintA: "1.0.0",
+			constraintB: "^1.0",
+			expectedErr: "conflicting versions of " + registry.Host() + "/org/lib: 1.0.0 by lib; ^1.0 by b.lib",
+		},
+		{
+			note:        "fail, agreeing versions",
+			resolution:  ResolutionFail,
+			constraintA: "1.2.0",
+			constraintB: "^1.0",
+			expected:    "1.2.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			libA := fmt.Sprintf("oci://%s/org/lib:%s", registry.Host(), tc.constraintA)
+			libB := fmt.Sprintf("oci://%s/org/lib:%s", registry.Host(), tc.constraintB)
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`namespacing: false
+resolution: %s
+dependencies:
+  lib: %s
+  b: file:/b
+`, tc.resolution, libA),
+				"b/opa.project": fmt.Sprintf(`dependencies:
+  lib: %s
+`, libB),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = project.Update()
+				if tc.expectedErr != "" {
+					if !errs.Is(err, errs.ResolutionFailed) || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected resolution error containing %q, got %v", tc.expectedErr, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, location := range []string{libA, libB} {
+					if locked, _ := lock.Get(location); locked.Digest != digests[tc.expected] {
+						t.Fatalf("expected %s to be locked to %s, got %v", location, tc.expected, locked)
+					}
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateResolutionGitTags(t *testing.T) {
+	upstream := newGitUpstream(t, "lib.rego")
+	commits := map[string]string{}
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "v1.2.0"} {
+		commits[tag] = upstream.tag(tag, "package lib").String()
+	}
+
+	tests := []struct {
+		resolution string
+		expected   string
+	}{
+		{resolution: ResolutionLatest, expected: "v1.2.0"},
+		{resolution: ResolutionMVS, expected: "v1.1.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.resolution, func(t *testing.T) {
+			libA := fmt.Sprintf("git+file://%s#^1.0", upstream.dir)
+			libB := fmt.Sprintf("git+file://%s#>=1.1.0", upstream.dir)
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`resolution: %s
+dependencies:
+  lib: %s
+  b: file:/b
+`, tc.resolution, libA),
+				"b/opa.project": fmt.Sprintf(`dependencies:
+  lib: %s
+`, libB),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, location := range []string{libA, libB} {
+					if locked, _ := lock.Get(location); locked.Tag != tc.expected || locked.Commit != commits[tc.expected] {
+						t.Fatalf("expected %s to be locked to %s, got %v", location, tc.expected, locked)
+					}
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateResolutionConflictingRevisions(t *testing.T) {
+	upstream := newGitUpstream(t, "lib.rego")
+	hash := upstream.commit("package lib")
+	if err := upstream.repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/dev", hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, resolution := range []string{ResolutionLatest, ResolutionFail} {
+		t.Run(resolution, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`resolution: %s
+dependencies:
+  lib: git+file://%s
+  b: file:/b
+`, resolution, upstream.dir),
+				"b/opa.project": fmt.Sprintf(`dependencies:
+  lib: git+file://%s#branch=dev
+`, upstream.dir),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = project.Update()
+				if resolution != ResolutionFail {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				expected := fmt.Sprintf("conflicting revisions of file://%s: HEAD by lib; branch=dev by b.lib", upstream.dir)
+				if !errs.Is(err, errs.ResolutionFailed) || !strings.Contains(err.Error(), expected) {
+					t.Fatalf("expected resolution error containing %q, got %v", expected, err)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateResolutionTrace(t *testing.T) {
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		registry.Push("org/lib", version, map[string]string{"/lib.rego": "package lib"})
+	}
+	key := registry.Host() + "/org/lib"
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`namespacing: false
+dependencies:
+  lib: oci://%s:^1.0
+  b: file:/b
+`, key),
+		"b/opa.project": fmt.Sprintf("dependencies:\n  lib: oci://%s:1.1.0\n", key),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trace := NewResolutionTrace()
+		if err := project.UpdateWithOptions(UpdateOptions{Trace: trace}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Dependencies are updated concurrently, and so the events of a pass are compared in any order
+		var described []string
+		for _, event := range trace.Events {
+			if event.Key == key {
+				described = append(described, fmt.Sprintf("%d %s", event.Pass, event.describe()))
+			}
+		}
+		sort.Strings(described)
+		expected := []string{
+			fmt.Sprintf("1 %s: b.lib requires 1.1.0, resolved to 1.1.0 (exact version)", key),
+			fmt.Sprintf("1 %s: lib requires ^1.0, resolved to 1.2.0 (highest matching version)", key),
+			fmt.Sprintf("1 %s: listed versions 1.0.0, 1.1.0, 1.2.0", key),
+			fmt.Sprintf("1 %s: selected 1.1.0 for 1.1.0 by b.lib; ^1.0 by lib (%s)", key,
+				strategyReasons[ResolutionLatest]),
+			fmt.Sprintf("2 %s: b.lib requires 1.1.0, resolved to 1.1.0 (selected in the previous pass)", key),
+			fmt.Sprintf("2 %s: lib requires ^1.0, resolved to 1.1.0 (selected in the previous pass)", key),
+			fmt.Sprintf("2 %s: selected 1.1.0 for 1.1.0 by b.lib; ^1.0 by lib (%s)", key,
+				strategyReasons[ResolutionLatest]),
+		}
+		if strings.Join(described, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("expected trace:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(described, "\n"))
+		}
+
+		var report strings.Builder
+		if err := trace.Report(&report, "text"); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(report.String(), "Resolution pass 1:\n  ") || !strings.Contains(report.String(),
+			"Resolution pass 2:\n") {
+			t.Fatalf("expected events grouped by pass, got:\n%s", report.String())
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalProjectInvalidResolution(t *testing.T) {
+	var project Project
+	err := yaml.Unmarshal([]byte("resolution: newest\n"), &project)
+	if err == nil || err.Error() != "invalid resolution 'newest'; expected latest, mvs, highest or fail" {
+		t.Fatalf("expected invalid resolution error, got %v", err)
+	}
+}
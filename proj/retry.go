@@ -0,0 +1,82 @@
+package proj
+
+import (
+	"context"
+	"errors"
+	"github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// transientGitMessages are fragments of git CLI error messages of network failures, which may not recur when retried.
+var transientGitMessages = []string{
+	"could not resolve host",
+	"connection timed out",
+	"connection refused",
+	"connection reset",
+	"early eof",
+	"rpc failed",
+	"remote end hung up unexpectedly",
+}
+
+// fetchWithRetries fetches the dependency from location into targetDir, retrying as configured if the fetch fails
+// with a transient error; waiting for the configured backoff before the first retry, and twice as long before every
+// further retry.
+func (d Dependency) fetchWithRetries(ctx *updateContext, location string, targetDir string) error {
+	retries := ctx.config.FetchRetries()
+	backoff := ctx.config.FetchBackoff()
+	for attempt := 0; ; attempt++ {
+		err := d.fetch(ctx, location, targetDir)
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return err
+		}
+		printer.Warn("failed to fetch dependency %s from %s, retrying in %s (%d/%d): %s", d.Name, location, backoff,
+			attempt+1, retries, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		clearDir(targetDir)
+		ctx.newLock.set(d.location(), LockedDependency{})
+	}
+}
+
+// isTransient returns true if the fetch error may not recur when retried: a timeout, a dropped connection, or a
+// server error or rate limit response.
+func isTransient(err error) bool {
+	if !errs.Is(err, errs.FetchFailed) {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var statusErr *utils.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Transient()
+	}
+	// go-git doesn't wrap the errors of unexpected HTTP responses
+	var unexpectedErr *plumbing.UnexpectedError
+	if errors.As(err, &unexpectedErr) {
+		var httpErr *githttp.Err
+		if errors.As(unexpectedErr.Err, &httpErr) {
+			return utils.IsTransientStatus(httpErr.Response.StatusCode)
+		}
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range transientGitMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package proj
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUpdateRetries(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "policy.rego"), []byte("package flaky"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var tarball bytes.Buffer
+	if err := utils.CreateTarGz(&tarball, srcDir, []string{srcDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		count := requests[r.URL.Path]
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/missing.tar.gz":
+			w.WriteHeader(http.StatusNotFound)
+		case count <= 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write(tarball.Bytes())
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		note             string
+		location         string
+		retries          int
+		expectedRequests int
+		expectedErr      string
+	}{
+		{
+			note:             "recovering after retries",
+			location:         "/flaky.tar.gz",
+			retries:          2,
+			expectedRequests: 3,
+		},
+		{
+			note:             "retries exhausted",
+			location:         "/unavailable.tar.gz",
+			retries:          1,
+			expectedRequests: 2,
+			expectedErr:      "unexpected status 503",
+		},
+		{
+			note:             "permanent failure not retried",
+			location:         "/missing.tar.gz",
+			retries:          2,
+			expectedRequests: 1,
+			expectedErr:      "unexpected status 404",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project": fmt.Sprintf(`fetch:
+  retries: %d
+  backoff: 10ms
+dependencies:
+  lib:
+    location: %s%s
+    namespace: false
+`, tc.retries, server.URL, tc.location),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadAndLoadProject(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = project.UpdateWithOptions(UpdateOptions{})
+				if tc.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected error containing %q, got %v", tc.expectedErr, err)
+					}
+					if code := errs.CodeOf(err); code != errs.FetchFailed {
+						t.Fatalf("expected error code %s, got %s", errs.FetchFailed.ID, code.ID)
+					}
+				} else if err != nil {
+					t.Fatal(err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if requests[tc.location] != tc.expectedRequests {
+					t.Fatalf("expected %d requests, got %d", tc.expectedRequests, requests[tc.location])
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
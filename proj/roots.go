@@ -0,0 +1,148 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// withinRoots reports whether the slash-separated path is located within one of the roots.
+func withinRoots(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRoots removes the Rego files whose package, and the data files whose directory, lie outside the given roots.
+func filterRoots(dir string, roots []string) error {
+	modules, err := scanModules(dir)
+	if err != nil {
+		return err
+	}
+	for _, m := range modules {
+		if path := strings.ReplaceAll(strings.TrimPrefix(m.pkg, "data."), ".", "/"); !withinRoots(path, roots) {
+			printer.Debug("Removing %s of package %s, outside the bundle roots", m.path, m.pkg)
+			if err := os.Remove(m.path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		switch info.Name() {
+		case "data.json", "data.yaml", "data.yml":
+		default:
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); !withinRoots(rel, roots) {
+			printer.Debug("Removing %s, outside the bundle roots", path)
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// applyBundleRoots restricts the content of a dependency shipping a bundle manifest with roots to those roots, honoring
+// the public surface declared by the library author.
+func applyBundleRoots(dir string) error {
+	roots, err := utils.ReadBundleRoots(dir)
+	if err != nil || roots == nil {
+		return err
+	}
+	printer.Debug("Restricting %s to bundle roots %s", dir, strings.Join(roots, ", "))
+	return filterRoots(dir, roots)
+}
+
+// effectiveRoots returns the bundle roots of the dependency as placed in the project's data tree; i.e. nested in the
+// dependency's namespace, if namespaced. Returns nil if the dependency doesn't declare roots.
+// Compiled bundle dependencies are never namespaced, and claim the entire data tree if they don't declare roots.
+func (d Dependency) effectiveRoots(namespacing bool) ([]string, error) {
+	if d.isBundle() {
+		roots, err := utils.ReadBundleRoots(d.bundleLocation())
+		if err == nil && roots == nil {
+			roots = []string{""}
+		}
+		return roots, err
+	}
+
+	roots, err := utils.ReadBundleRoots(d.dirPath)
+	if err != nil || roots == nil {
+		return nil, err
+	}
+
+	namespace := d.fullNamespace()
+	if !namespacing || namespace == "" {
+		return roots, nil
+	}
+	prefix := strings.ReplaceAll(namespace, ".", "/")
+	placed := make([]string, 0, len(roots))
+	for _, root := range roots {
+		placed = append(placed, prefix+"/"+root)
+	}
+	return placed, nil
+}
+
+// checkRoots verifies that the roots declared by dependencies don't overlap, as they would when placed in the same
+// namespace; in which case one dependency would override the content of the other. The roots of compiled bundle
+// dependencies must additionally not overlap any package of the rest of the project.
+func (p *Project) checkRoots() error {
+	type claim struct {
+		root string
+		dep  Dependency
+	}
+	var claims []claim
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		roots, err := dep.effectiveRoots(p.NamespacingEnabled())
+		if err != nil {
+			return err
+		}
+		for _, root := range roots {
+			claims = append(claims, claim{root, dep})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(claims, func(i, j int) bool {
+		return claims[i].root < claims[j].root
+	})
+	for i, a := range claims {
+		for _, b := range claims[i+1:] {
+			if a.dep.location() == b.dep.location() {
+				continue
+			}
+			if utils.RootsOverlap(a.root, b.root) {
+				return errs.New(errs.NamespaceCollision, "bundle root %s of dependency %s (%s) overlaps root %s of dependency %s (%s)",
+					displayRoot(a.root), a.dep.Name, a.dep.location(), displayRoot(b.root), b.dep.Name, b.dep.location())
+			}
+		}
+	}
+	return p.checkBundleRoots()
+}
+
+// displayRoot returns the root as presented in error messages, where the empty root is shown as the data root.
+func displayRoot(root string) string {
+	if root == "" {
+		return "/"
+	}
+	return root
+}
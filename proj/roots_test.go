@@ -0,0 +1,255 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdateBundleRoots(t *testing.T) {
+	files := map[string]string{
+		"opa.project": `dependencies:
+  lib:
+    location: file:///lib
+    namespace: false
+`,
+		"lib/.manifest":           `{"roots": ["lib/http"]}`,
+		"lib/http.rego":           "package lib.http\n\nallow := true",
+		"lib/http/headers.rego":   "package lib.http.headers\n\nnames := []",
+		"lib/internal.rego":       "package lib.internal\n\nsecret := true",
+		"lib/lib/http/data.json":  `{"timeout": 10}`,
+		"lib/lib/other/data.json": `{"internal": true}`,
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		dir := project.Dependencies["lib"].dir(dependenciesDir(root))
+		for path, expected := range map[string]bool{
+			"http.rego":           true,
+			"http/headers.rego":   true,
+			"internal.rego":       false,
+			"lib/http/data.json":  true,
+			"lib/other/data.json": false,
+		} {
+			if exists := utils.FileExists(filepath.Join(dir, path)); exists != expected {
+				t.Fatalf("expected %s to exist: %v, got %v", path, expected, exists)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateBundleRootsCollision(t *testing.T) {
+	tests := []struct {
+		note     string
+		project  string
+		expected bool
+	}{
+		{
+			note: "overlapping roots, not namespaced",
+			project: `dependencies:
+  a:
+    location: file:///a
+    namespace: false
+  b:
+    location: file:///b
+    namespace: false
+`,
+			expected: true,
+		},
+		{
+			note: "namespaced",
+			project: `dependencies:
+  a:
+    location: file:///a
+    namespace: false
+  b:
+    location: file:///b
+    namespace: b
+`,
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project":         tc.project,
+				"a/.manifest":         `{"roots": ["lib"]}`,
+				"a/lib.rego":          "package lib\n\nx := 1",
+				"b/.manifest":         `{"roots": ["lib/http"]}`,
+				"b/http.rego":         "package lib.http\n\nallow := true",
+				"b/http_helpers.rego": "package lib.http\n\nhelper := true",
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = project.Update()
+				if tc.expected {
+					if !errs.Is(err, errs.NamespaceCollision) {
+						t.Fatalf("expected namespace collision, got %v", err)
+					}
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateCompiledBundleDependency(t *testing.T) {
+	tests := []struct {
+		note     string
+		files    map[string]string
+		expected bool
+	}{
+		{
+			note: "distinct roots",
+			files: map[string]string{
+				"src/main.rego":  "package main\n\nallow := data.lib.allow",
+				"lib/.manifest":  `{"roots": ["lib"]}`,
+				"lib/lib/x.rego": "package lib\n\nallow := true",
+			},
+			expected: false,
+		},
+		{
+			note: "root overlapping project package",
+			files: map[string]string{
+				"src/main.rego":  "package lib.main\n\nallow := data.lib.allow",
+				"lib/.manifest":  `{"roots": ["lib"]}`,
+				"lib/lib/x.rego": "package lib\n\nallow := true",
+			},
+			expected: true,
+		},
+		{
+			note: "no declared roots",
+			files: map[string]string{
+				"src/main.rego":  "package main\n\nallow := data.lib.allow",
+				"lib/lib/x.rego": "package lib\n\nallow := true",
+			},
+			expected: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			tc.files["opa.project"] = `source: src
+dependencies:
+  lib:
+    location: file:///lib
+    bundle: true
+`
+			err := withTempFiles(tc.files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = project.Update()
+				if tc.expected {
+					if !errs.Is(err, errs.NamespaceCollision) {
+						t.Fatalf("expected namespace collision, got %v", err)
+					}
+					return
+				} else if err != nil {
+					t.Fatal(err)
+				}
+
+				// The bundle is used as-is, not refactored into the dependency's namespace
+				dir := project.Dependencies["lib"].dir(dependenciesDir(root))
+				if bs, err := os.ReadFile(filepath.Join(dir, "lib", "x.rego")); err != nil {
+					t.Fatal(err)
+				} else if !strings.HasPrefix(string(bs), "package lib\n") {
+					t.Fatalf("expected bundle to not be refactored, got:\n%s", bs)
+				}
+
+				dataLocations, err := project.DataLocations()
+				if err != nil {
+					t.Fatal(err)
+				}
+				bundleLocations, err := project.BundleLocations(true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected := []string{filepath.Join(root, "src")}; !reflect.DeepEqual(dataLocations, expected) {
+					t.Fatalf("expected data locations %v, got %v", expected, dataLocations)
+				}
+				if expected := []string{dir}; !reflect.DeepEqual(bundleLocations, expected) {
+					t.Fatalf("expected bundle locations %v, got %v", expected, bundleLocations)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDataLocationsWithoutSourceDirsAndCompiledBundleDependency(t *testing.T) {
+	// The bundle is kept outside the project, which would otherwise load it as its own source
+	bundleDir := t.TempDir()
+	for name, content := range map[string]string{".manifest": `{"roots": ["lib"]}`, "lib/x.rego": "package lib\n\nallow := true"} {
+		path := filepath.Join(bundleDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`dependencies:
+  lib:
+    location: file:/%s
+    bundle: true
+`, bundleDir),
+		"main.rego": "package main\n\nallow := data.lib.allow",
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		// ODM's own state files in .opa, such as the fetched bundle, must not be loaded as data
+		dataLocations, err := project.DataLocations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, location := range dataLocations {
+			if strings.HasPrefix(location, filepath.Join(root, dotOpaDir)) {
+				t.Fatalf("expected no data locations in %s, got %v", dotOpaDir, dataLocations)
+			}
+		}
+		if !utils.Contains(dataLocations, filepath.Join(root, "main.rego")) {
+			t.Fatalf("expected main.rego in data locations, got %v", dataLocations)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,94 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+)
+
+const sandboxesDir = "sandboxes"
+
+// TestSandbox is the isolated test environment of a dependency declared with isolated tests.
+type TestSandbox struct {
+	// Dependency is the name of the dependency
+	Dependency string
+	// Locations are the data and test locations to load for running the dependency's tests
+	Locations []string
+}
+
+// sandboxDir returns the directory of the dependency's test sandbox.
+func (d Dependency) sandboxDir(rootDir string) string {
+	return filepath.Join(rootDir, dotOpaDir, sandboxesDir, d.id())
+}
+
+// prepareSandbox copies the fetched content of the dependency, before namespacing, into its test sandbox; where the
+// dependency is updated as a project of its own, so that its tests run against its original package paths and those
+// of its own dependencies.
+func (d Dependency) prepareSandbox(ctx *updateContext, targetDir string) error {
+	dir := d.sandboxDir(ctx.rootDir)
+	printer.Debug("Preparing test sandbox of dependency %s in %s", d.Name, dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := utils.CopyAll(targetDir, dir, []string{".git", dotOpaDir}, false); err != nil {
+		return fmt.Errorf("failed to prepare test sandbox of dependency %s: %w", d.Name, err)
+	}
+
+	project, err := ReadProjectFromFile(dir, true)
+	if err != nil {
+		return err
+	}
+	lock, err := ReadLockFile(lockFilePath(project.filePath))
+	if err != nil {
+		return err
+	}
+	sandboxCtx := project.newUpdateContext(lock, ctx.config, newResolver(project.Resolution),
+		make(map[string]*oci.Metadata))
+	// Local locations stay relative to the root project, as in the project's dependency tree
+	sandboxCtx.rootDir = ctx.rootDir
+	sandboxCtx.strict = ctx.strict
+	sandboxCtx.refresh = ctx.refresh
+	sandboxCtx.recording = ctx.recording
+	if err := project.update(sandboxCtx); err != nil {
+		return fmt.Errorf("failed to update test sandbox of dependency %s: %w", d.Name, err)
+	}
+	return nil
+}
+
+// TestSandboxes returns the test sandboxes of all dependencies declared with isolated tests, as prepared by the last
+// update.
+func (p *Project) TestSandboxes() ([]TestSandbox, error) {
+	var sandboxes []TestSandbox
+	err := p.walkUniqueDependencies(func(dep Dependency) error {
+		if !dep.IsolatedTests || dep.isBundle() {
+			return nil
+		}
+		dir := dep.sandboxDir(p.Dir())
+		if !utils.FileExists(dir) {
+			return errs.New(errs.InvalidProject, "test sandbox of dependency %s is missing; run 'odm update'", dep.Name)
+		}
+
+		project, err := ReadAndLoadProject(dir, true)
+		if err != nil {
+			return err
+		}
+		dataLocations, err := project.DataLocations()
+		if err != nil {
+			return err
+		}
+		testLocations, err := project.TestLocations(false)
+		if err != nil {
+			return err
+		}
+		sandboxes = append(sandboxes, TestSandbox{
+			Dependency: dep.Name,
+			Locations:  append(dataLocations, testLocations...),
+		})
+		return nil
+	})
+	return sandboxes, err
+}
@@ -0,0 +1,73 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateIsolatedTests(t *testing.T) {
+	files := map[string]string{
+		"opa.project": `dependencies:
+  lib:
+    location: file:///lib
+    isolatedTests: true
+`,
+		"main.rego":              "package main\n\nallow := data.lib.lib.allow",
+		"lib/opa.project":        "dependencies:\n  util: file:///other/util\n",
+		"lib/lib.rego":           "package lib\n\nallow := data.util.util.ok",
+		"lib/lib_test.rego":      "package lib_test\n\ntest_allow { data[\"lib\"].allow }",
+		"other/util/util.rego":   "package util\n\nok := true",
+		"other/util/opa.project": "name: util\n",
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		sandboxes, err := project.TestSandboxes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sandboxes) != 1 || sandboxes[0].Dependency != "lib" {
+			t.Fatalf("expected sandbox of lib, got %v", sandboxes)
+		}
+		// The test references the dependency by its original path, which the namespaced copy doesn't have
+		if output, err := utils.NewOpa(sandboxes[0].Locations...).Test(); err != nil {
+			t.Fatalf("expected sandboxed tests to pass, got %v: %s", err, output)
+		}
+		sandboxDir := project.Dependencies["lib"].sandboxDir(root)
+		bs, err := os.ReadFile(filepath.Join(sandboxDir, "lib.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(bs), "package lib\n") {
+			t.Fatalf("expected un-namespaced package lib in sandbox, got %s", bs)
+		}
+
+		// The sandbox isn't part of the project's content, nor are the tests of isolated dependencies
+		dataLocations, err := project.DataLocations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLocations, err := project.TestLocations(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, location := range append(dataLocations, testLocations...) {
+			if strings.HasPrefix(location, filepath.Join(root, dotOpaDir, sandboxesDir)) || strings.HasSuffix(location, "_test.rego") {
+				t.Fatalf("unexpected location %s", location)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+)
+
+// selection limits an update to the named direct dependencies, and their transitive dependencies. The dependencies
+// reachable through the other direct dependencies, as fetched by the last update, are kept as they are.
+type selection struct {
+	names map[string]bool
+	// kept are the fetched dependencies left untouched by the update
+	kept []Dependency
+	// missing are the dependencies to keep that aren't fetched, and so are updated along with the named dependencies
+	missing []Dependency
+	// keptIds holds the ids of the kept dependencies
+	keptIds map[string]bool
+}
+
+// selectDependencies returns the selection of the named direct dependencies, walking the dependencies directory for
+// the dependencies to keep.
+func (p *Project) selectDependencies(names []string) (*selection, error) {
+	sel := &selection{names: make(map[string]bool), keptIds: make(map[string]bool)}
+	for _, name := range names {
+		if _, ok := p.Dependencies[name]; !ok {
+			return nil, errs.New(errs.InvalidUsage, "no dependency named %s", name)
+		}
+		sel.names[name] = true
+	}
+
+	depsDir := dependenciesDir(p.Dir())
+	var walk func(deps Dependencies, parent *Dependency) error
+	walk = func(deps Dependencies, parent *Dependency) error {
+		for _, name := range sortedDependencyNames(deps) {
+			dep := deps[name]
+			dep.ParentDependency = parent
+			if parent == nil && sel.names[name] {
+				continue
+			}
+			id := dep.id()
+			if sel.keptIds[id] {
+				continue
+			}
+			dir := dep.dir(depsDir)
+			if !utils.IsDir(dir) {
+				sel.missing = append(sel.missing, dep)
+				continue
+			}
+			sel.keptIds[id] = true
+			sel.kept = append(sel.kept, dep)
+
+			projectFile := projectFilePath(dir)
+			if !dep.isBundle() && utils.FileExists(projectFile) {
+				depProject, err := ReadProjectFromFile(projectFile, false)
+				if err != nil {
+					return err
+				}
+				dep.excludeDependencies(depProject)
+				if err := walk(depProject.Dependencies, &dep); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(p.Dependencies, nil); err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
+// prune removes the fetched directories, and test sandboxes, of all dependencies that aren't kept.
+func (sel *selection) prune(rootDir string) error {
+	for _, dir := range []string{dependenciesDir(rootDir), filepath.Join(rootDir, dotOpaDir, sandboxesDir)} {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && !sel.keptIds[entry.Name()] {
+				if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// update updates the named dependencies, and any dependencies to keep that aren't fetched, in a pass over the
+// project's dependency tree. Kept dependencies are marked as updated, for the named dependencies not to replace them,
+// and their lock entries carried over.
+func (sel *selection) update(ctx *updateContext, p *Project) error {
+	for _, dep := range sel.kept {
+		ctx.markUpdated(dep.id())
+		if locked, ok := ctx.lock.Get(dep.location()); ok {
+			ctx.newLock.set(dep.location(), locked)
+		}
+	}
+
+	selected := make(Dependencies, len(sel.names))
+	for name := range sel.names {
+		selected[name] = p.Dependencies[name]
+	}
+	if err := ctx.updateDependencies(selected, nil, true); err != nil {
+		return err
+	}
+	for name, dep := range selected {
+		p.Dependencies[name] = dep
+	}
+
+	for _, dep := range sel.missing {
+		printer.Info("Fetching missing dependency %s (%s)", dep.Name, dep.location())
+		if err := dep.update(ctx); err != nil {
+			return fmt.Errorf("failed to update dependency %s: %w", dep.Name, err)
+		}
+	}
+	return nil
+}
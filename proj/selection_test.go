@@ -0,0 +1,73 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/errs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateSelectedDependency(t *testing.T) {
+	files := map[string]string{
+		"opa.project": `dependencies:
+  a:
+    location: file:///a
+    namespace: false
+  b:
+    location: file:///b
+    namespace: false
+`,
+		"a/a.rego": "package a\n\nx := 1",
+		"b/b.rego": "package b\n\nx := 1",
+	}
+
+	err := withTempFiles(files, func(root string) {
+		project, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, dep := range []string{"a", "b"} {
+			if err := os.WriteFile(filepath.Join(root, dep, dep+".rego"), []byte("package "+dep+"\n\nx := 2"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		project, err = ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.UpdateWithOptions(UpdateOptions{Dependencies: []string{"a"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		depsDir := dependenciesDir(root)
+		for dep, expected := range map[string]string{"a": "package a\n\nx := 2", "b": "package b\n\nx := 1"} {
+			bs, err := os.ReadFile(filepath.Join(project.Dependencies[dep].dir(depsDir), dep+".rego"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(bs) != expected {
+				t.Fatalf("expected %s of dependency %s, got %s", expected, dep, bs)
+			}
+		}
+
+		// The sums recorded cover the kept dependency too
+		if modified, err := project.ModifiedDependencyFiles(); err != nil {
+			t.Fatal(err)
+		} else if len(modified) > 0 {
+			t.Fatalf("expected no modified dependency files, got %v", modified)
+		}
+
+		err = project.UpdateWithOptions(UpdateOptions{Dependencies: []string{"missing"}})
+		if !errs.Is(err, errs.InvalidUsage) {
+			t.Fatalf("expected usage error, got %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
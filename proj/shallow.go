@@ -0,0 +1,76 @@
+package proj
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+)
+
+// shallowClone returns true if the git dependency is to be cloned without history: as declared by the dependency, or
+// else as configured by the user-level config.
+func (d Dependency) shallowClone(ctx *updateContext) bool {
+	if d.ShallowClone != nil {
+		return *d.ShallowClone
+	}
+	return ctx.config.ShallowClone
+}
+
+// fullClone clones the git repository at url into targetDir, with its entire history.
+func fullClone(url string, targetDir string) (*git.Repository, error) {
+	cloneCtx, cancel := utils.FetchContext()
+	defer cancel()
+	repo, err := git.PlainCloneContext(cloneCtx, targetDir, false, &git.CloneOptions{
+		URL:      url,
+		Auth:     gitAuth(url),
+		Progress: printer.DebugPrinter(),
+	})
+	if err != nil {
+		return nil, errs.New(errs.FetchFailed, "failed to clone git repository %s: %w", url, err)
+	}
+	return repo, nil
+}
+
+// shallowClone clones the tip of the referenced tag or branch, or of HEAD, of the git repository at url into
+// targetDir, without history (a depth 1 clone). Commits may not be the tip of any reference, and nil is returned for
+// references to commits, to be cloned in full; as when replaying a recorded resolution, which requires the recorded
+// references to be cloned.
+func shallowClone(ctx *updateContext, url string, ref gitRef, targetDir string) (*git.Repository, error) {
+	if ref.isCommit() || ctx.recording.replaying() {
+		return nil, nil
+	}
+
+	var name plumbing.ReferenceName
+	if ref.name != "" {
+		refs, err := listRemoteGitRefs(ctx.recording, url)
+		if err != nil {
+			return nil, errs.New(errs.FetchFailed, "%w", err)
+		}
+		name = plumbing.NewBranchReferenceName(ref.name)
+		if tag := ref.tag(); tag != "" {
+			for _, r := range refs {
+				if r.Name() == plumbing.NewTagReferenceName(tag) {
+					name = r.Name()
+					break
+				}
+			}
+		}
+	}
+
+	printer.Debug("Shallow cloning %s", url)
+	cloneCtx, cancel := utils.FetchContext()
+	defer cancel()
+	repo, err := git.PlainCloneContext(cloneCtx, targetDir, false, &git.CloneOptions{
+		URL:           url,
+		Auth:          gitAuth(url),
+		ReferenceName: name,
+		SingleBranch:  true,
+		Depth:         1,
+		Progress:      printer.DebugPrinter(),
+	})
+	if err != nil {
+		return nil, errs.New(errs.FetchFailed, "failed to clone git repository %s: %w", url, err)
+	}
+	return repo, nil
+}
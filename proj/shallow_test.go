@@ -0,0 +1,82 @@
+package proj
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateGitDependencyShallowClone(t *testing.T) {
+	upstream := newGitUpstream(t, "policy.rego")
+	upstream.commit("package first")
+	upstream.tag("v1", "package tagged")
+	pinned := upstream.commit("package pinned")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("shallowClone: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ODM_CONFIG", configPath)
+
+	floating := fmt.Sprintf("git+file://%s", upstream.dir)
+	files := map[string]string{
+		"opa.project": fmt.Sprintf(`name: proj
+dependencies:
+  tagged:
+    location: git+file://%[1]s#v1
+    namespace: false
+  floating:
+    location: %[2]s
+    namespace: false
+  full:
+    location: git+file://%[1]s#branch=master
+    namespace: false
+    shallowClone: false
+`, upstream.dir, floating),
+	}
+
+	err := withTempFiles(files, func(root string) {
+		isShallow := func(dependency string) bool {
+			project, err := ReadProjectFromFile(root, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := project.Dependencies[dependency].dir(dependenciesDir(root))
+			_, err = os.Stat(filepath.Join(dir, ".git", "shallow"))
+			return err == nil
+		}
+
+		if policy := updateAndRead(t, root, "tagged", "policy.rego"); policy != "package tagged" {
+			t.Fatalf("expected package tagged, got %s", policy)
+		}
+		if policy := updateAndRead(t, root, "floating", "policy.rego"); policy != "package pinned" {
+			t.Fatalf("expected package pinned, got %s", policy)
+		}
+		if !isShallow("tagged") || !isShallow("floating") {
+			t.Fatalf("expected shallow clones")
+		}
+		if isShallow("full") {
+			t.Fatalf("expected full clone of dependency opting out")
+		}
+
+		// The commit pinned by the lock file is no longer the tip of the branch, and requires a full clone
+		upstream.commit("package latest")
+		if policy := updateAndRead(t, root, "floating", "policy.rego"); policy != "package pinned" {
+			t.Fatalf("expected package pinned, got %s", policy)
+		}
+		if isShallow("floating") {
+			t.Fatalf("expected full clone of pinned commit")
+		}
+		lock, err := ReadLockFile(filepath.Join(root, "opa.project.lock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lock.Get(floating); locked.Commit != pinned.String() {
+			t.Fatalf("expected locked commit %s, got %v", pinned, locked)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+package proj
+
+import (
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"os"
+	"path/filepath"
+)
+
+// keyringPath returns the keyring the git dependency must be signed by: the keyring declared for the dependency,
+// relative to the declaring project; or else the keyring of the user-level config. Empty if signatures aren't required.
+func (d Dependency) keyringPath(ctx *updateContext) (string, error) {
+	if d.Keyring != "" {
+		dir := ctx.rootDir
+		if d.ParentDependency != nil {
+			dir = d.ParentDependency.dirPath
+		}
+		return resolvePath(dir, d.Keyring), nil
+	}
+	if ctx.config.GitKeyring != "" {
+		path, err := config.FilePath()
+		if err != nil {
+			return "", err
+		}
+		return resolvePath(filepath.Dir(path), ctx.config.GitKeyring), nil
+	}
+	return "", nil
+}
+
+// verifySignature fails unless the checked-out commit of the git dependency is signed by a key in the required keyring,
+// if any. For tagged locations, a signed annotated tag pointing at the checked-out commit is accepted in place of a
+// signed commit.
+func (d Dependency) verifySignature(ctx *updateContext, repo *git.Repository, url string, tag string) error {
+	keyringPath, err := d.keyringPath(ctx)
+	if err != nil || keyringPath == "" {
+		return err
+	}
+	keyring, err := os.ReadFile(keyringPath)
+	if err != nil {
+		return errs.New(errs.SignatureInvalid, "failed to read keyring of dependency %s: %w", d.Name, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return errs.New(errs.SignatureInvalid, "failed to resolve checked-out commit of %s: %w", url, err)
+	}
+
+	if tag != "" {
+		if ref, err := repo.Tag(tag); err == nil {
+			tagObject, err := repo.TagObject(ref.Hash())
+			if err == nil && tagObject.Target == head.Hash() && tagObject.PGPSignature != "" {
+				entity, err := tagObject.Verify(string(keyring))
+				if err == nil {
+					printer.Debug("Tag '%s' of %s is signed by %s", tag, url, signer(entity))
+					return nil
+				}
+				printer.Debug("Signature of tag '%s' of %s not verified: %s", tag, url, err)
+			}
+		}
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return errs.New(errs.SignatureInvalid, "failed to read commit %s of %s: %w", head.Hash(), url, err)
+	}
+	if commit.PGPSignature == "" {
+		return errs.New(errs.SignatureInvalid, "commit %s of %s is not signed", commit.Hash, url)
+	}
+	entity, err := commit.Verify(string(keyring))
+	if err != nil {
+		return errs.New(errs.SignatureInvalid, "signature of commit %s of %s not verified by keyring %s: %w",
+			commit.Hash, url, keyringPath, err)
+	}
+	printer.Debug("Commit %s of %s is signed by %s", commit.Hash, url, signer(entity))
+	return nil
+}
+
+func signer(entity *openpgp.Entity) string {
+	for name := range entity.Identities {
+		return name
+	}
+	return entity.PrimaryKey.KeyIdString()
+}
@@ -0,0 +1,103 @@
+package proj
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/johanfylling/odm/errs"
+	"testing"
+)
+
+func TestUpdateGitDependencySignature(t *testing.T) {
+	trusted := newTestEntity(t, "trusted")
+	untrusted := newTestEntity(t, "untrusted")
+
+	tests := []struct {
+		note        string
+		signKey     *openpgp.Entity
+		keyring     string
+		expectedErr bool
+	}{
+		{
+			note:    "no keyring",
+			keyring: "",
+		},
+		{
+			note:    "signed by trusted key",
+			signKey: trusted,
+			keyring: "keys.asc",
+		},
+		{
+			note:        "unsigned",
+			keyring:     "keys.asc",
+			expectedErr: true,
+		},
+		{
+			note:        "signed by untrusted key",
+			signKey:     untrusted,
+			keyring:     "keys.asc",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			upstream := newGitUpstream(t, "policy.rego")
+			upstream.signKey = tc.signKey
+			upstream.commit("package signed")
+
+			dependency := fmt.Sprintf("location: git+file://%s\n    namespace: false", upstream.dir)
+			if tc.keyring != "" {
+				dependency += "\n    keyring: " + tc.keyring
+			}
+			files := map[string]string{
+				"opa.project": fmt.Sprintf("name: proj\ndependencies:\n  policy:\n    %s\n", dependency),
+				"keys.asc":    armoredPublicKey(t, trusted),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = project.Update()
+				if tc.expectedErr {
+					if !errs.Is(err, errs.SignatureInvalid) {
+						t.Fatalf("expected signature error, got %v", err)
+					}
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func newTestEntity(t *testing.T, name string) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
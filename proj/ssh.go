@@ -0,0 +1,154 @@
+package proj
+
+import (
+	"errors"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"golang.org/x/crypto/ssh"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitSSH is the SSH configuration git dependencies are fetched with, as set by configureFetching.
+var gitSSH config.SSH
+
+// defaultIdentityFiles are the private keys, in ~/.ssh, tried when neither an identity file is configured nor an SSH
+// agent is running; as by ssh.
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// sshConfig returns the SSH configuration of the project: the settings of its project file, taking precedence over
+// those of the user-level config; with paths resolved against the file declaring them.
+func (p *Project) sshConfig(cfg *config.Config) config.SSH {
+	var resolved config.SSH
+	if cfg.SSH != (config.SSH{}) {
+		if path, err := config.FilePath(); err == nil {
+			resolved = resolveSSHPaths(filepath.Dir(path), cfg.SSH)
+		}
+	}
+	if p != nil && p.SSH != nil {
+		project := resolveSSHPaths(p.Dir(), *p.SSH)
+		if project.IdentityFile != "" {
+			resolved.IdentityFile = project.IdentityFile
+		}
+		if project.KnownHosts != "" {
+			resolved.KnownHosts = project.KnownHosts
+		}
+	}
+	return resolved
+}
+
+func resolveSSHPaths(dir string, cfg config.SSH) config.SSH {
+	return config.SSH{IdentityFile: resolveUserPath(dir, cfg.IdentityFile), KnownHosts: resolveUserPath(dir, cfg.KnownHosts)}
+}
+
+// resolveUserPath resolves a path declared in a file in dir: relative to the user's home directory if prefixed with
+// '~/', or else to dir. An empty path is returned as-is.
+func resolveUserPath(dir string, path string) string {
+	if path == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return resolvePath(dir, path)
+}
+
+// isSSHUrl returns true if the git URL is accessed over SSH.
+func isSSHUrl(url string) bool {
+	return strings.HasPrefix(url, "ssh://") || strings.HasPrefix(url, "git+ssh://")
+}
+
+// sshUser returns the user of the SSH URL; git, if it doesn't declare any.
+func sshUser(location string) string {
+	if u, err := url.Parse(strings.TrimPrefix(location, "git+")); err == nil && u.User != nil && u.User.Username() != "" {
+		return u.User.Username()
+	}
+	return gitssh.DefaultUsername
+}
+
+// sshAuth returns the authentication for the SSH URL: the configured identity file, or else the keys of the running
+// SSH agent, or else the first default identity file found. Host keys are verified against the configured known_hosts
+// file, if any. Failures are logged, and nil returned, for go-git to fall back to the SSH agent.
+func sshAuth(url string) transport.AuthMethod {
+	user := sshUser(url)
+	identityFile := gitSSH.IdentityFile
+	if identityFile == "" && os.Getenv("SSH_AUTH_SOCK") == "" {
+		identityFile = defaultIdentityFile()
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	if gitSSH.KnownHosts != "" {
+		var err error
+		if hostKeyCallback, err = gitssh.NewKnownHostsCallback(gitSSH.KnownHosts); err != nil {
+			printer.Warn("Failed to read known hosts file %s: %s", gitSSH.KnownHosts, err)
+		}
+	}
+
+	if identityFile == "" {
+		if hostKeyCallback == nil {
+			return nil
+		}
+		agent, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			printer.Warn("Failed to connect to SSH agent for %s: %s", url, err)
+			return nil
+		}
+		agent.HostKeyCallback = hostKeyCallback
+		return agent
+	}
+
+	auth, err := gitssh.NewPublicKeysFromFile(user, identityFile, os.Getenv("ODM_SSH_PASSPHRASE"))
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			printer.Warn("SSH identity file %s is encrypted; set ODM_SSH_PASSPHRASE to decrypt it", identityFile)
+		} else {
+			printer.Warn("Failed to read SSH identity file %s: %s", identityFile, err)
+		}
+		return nil
+	}
+	printer.Debug("Authenticating with %s as %s, using %s", url, user, identityFile)
+	auth.HostKeyCallback = hostKeyCallback
+	return auth
+}
+
+// defaultIdentityFile returns the first of the default identity files that exists; empty if none does.
+func defaultIdentityFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range defaultIdentityFiles {
+		if path := filepath.Join(home, ".ssh", name); utils.FileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// sshCommand returns the ssh command the git CLI is to connect with, for the configured identity and known_hosts files;
+// empty if neither is configured.
+func sshCommand() string {
+	var args []string
+	if gitSSH.IdentityFile != "" {
+		args = append(args, "-i", shellQuote(gitSSH.IdentityFile), "-o", "IdentitiesOnly=yes")
+	}
+	if gitSSH.KnownHosts != "" {
+		args = append(args, "-o", shellQuote("UserKnownHostsFile="+gitSSH.KnownHosts))
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return "ssh " + strings.Join(args, " ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
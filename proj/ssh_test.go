@@ -0,0 +1,115 @@
+package proj
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/johanfylling/odm/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSSHConfig(t *testing.T) {
+	configDir := t.TempDir()
+	configPath := filepath.Join(configDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("ssh:\n  identityFile: keys/user\n  knownHosts: /etc/known_hosts\n"),
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ODM_CONFIG", configPath)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		note     string
+		project  string
+		expected func(root string) config.SSH
+	}{
+		{
+			note:    "user config",
+			project: "name: proj\n",
+			expected: func(string) config.SSH {
+				return config.SSH{IdentityFile: filepath.Join(configDir, "keys", "user"), KnownHosts: "/etc/known_hosts"}
+			},
+		},
+		{
+			note:    "project identity file",
+			project: "name: proj\nssh:\n  identityFile: keys/deploy\n",
+			expected: func(root string) config.SSH {
+				return config.SSH{IdentityFile: filepath.Join(root, "keys", "deploy"), KnownHosts: "/etc/known_hosts"}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			err := withTempFiles(map[string]string{"opa.project": tc.project}, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual, expected := project.sshConfig(cfg), tc.expected(root); actual != expected {
+					t.Fatalf("expected %v, got %v", expected, actual)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSSHAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(t.TempDir(), "id_rsa")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(identityFile, pemBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := gitSSH
+	t.Cleanup(func() { gitSSH = previous })
+
+	tests := []struct {
+		note         string
+		identityFile string
+		url          string
+		expectedUser string
+	}{
+		{note: "user in url", identityFile: identityFile, url: "ssh://deploy@example.com/org/repo.git",
+			expectedUser: "deploy"},
+		{note: "default user", identityFile: identityFile, url: "ssh://example.com:2222/org/repo.git",
+			expectedUser: "git"},
+		{note: "missing identity file", identityFile: filepath.Join(t.TempDir(), "missing"),
+			url: "ssh://git@example.com/org/repo.git"},
+		{note: "https", identityFile: identityFile, url: "https://example.com/org/repo.git"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			gitSSH = config.SSH{IdentityFile: tc.identityFile}
+			auth := gitAuth(tc.url)
+			if tc.expectedUser == "" {
+				if auth != nil {
+					t.Fatalf("expected no authentication, got %v", auth)
+				}
+				return
+			}
+			keys, ok := auth.(*gitssh.PublicKeys)
+			if !ok {
+				t.Fatalf("expected public key authentication, got %T", auth)
+			}
+			if keys.User != tc.expectedUser {
+				t.Fatalf("expected user %s, got %s", tc.expectedUser, keys.User)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/oci"
+	"sort"
+	"strings"
+)
+
+// DependencySnapshot is the state of a fetched dependency, as compared by DiffSnapshots.
+type DependencySnapshot struct {
+	// Version is the resolved tag of the dependency, if any; otherwise its abbreviated revision
+	Version   string
+	Namespace string
+	// sums are the content sums of the dependency's files, by path relative to the dependency directory
+	sums map[string]string
+}
+
+// Snapshot is the state of a loaded dependency tree, by dot-separated dependency path.
+type Snapshot map[string]DependencySnapshot
+
+// DependencyChange describes how a dependency changed between two snapshots.
+type DependencyChange struct {
+	// Path is the dot-separated path of the dependency in the dependency tree
+	Path string
+	// Kind is one of 'added', 'removed' or 'updated'
+	Kind       string
+	OldVersion string
+	NewVersion string
+	// OldNamespace and NewNamespace are only set if the namespace of an updated dependency changed
+	OldNamespace string
+	NewNamespace string
+	// ChangedFiles is the number of added, removed or modified files of an updated dependency
+	ChangedFiles int
+}
+
+// Snapshot records the version, namespace and content of each dependency in the loaded dependency tree.
+func (p *Project) Snapshot() (Snapshot, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	lock, err := ReadLockFile(lockFilePath(p.filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(Snapshot)
+	for _, path := range p.DependencyPaths() {
+		details, err := p.dependencyDetails(path, cfg, lock)
+		if err != nil {
+			return nil, err
+		}
+		dep := DependencySnapshot{
+			Version:   dependencyVersion(details, lock),
+			Namespace: details.Namespace,
+		}
+		if details.Dir != "" {
+			if dep.sums, err = contentSums(details.Dir); err != nil {
+				return nil, err
+			}
+		}
+		snapshot[path] = dep
+	}
+	return snapshot, nil
+}
+
+// dependencyVersion returns the tag the dependency resolved to, if any; otherwise its abbreviated revision.
+func dependencyVersion(details *DependencyDetails, lock *Lock) string {
+	locked, _ := lock.Get(details.Location)
+	if locked.Tag != "" {
+		return locked.Tag
+	}
+	switch {
+	case strings.HasPrefix(details.ResolvedLocation, "git+"):
+		// Branches and commits aren't versions
+		_, ref, err := parseGitUrl(details.ResolvedLocation)
+		if err == nil && ref.tag() != "" && locked.Branch == "" {
+			return ref.tag()
+		}
+	case strings.HasPrefix(details.ResolvedLocation, "oci://"):
+		ref, err := oci.ParseReference(strings.TrimPrefix(details.ResolvedLocation, "oci://"))
+		if err == nil && ref.Digest == "" && ref.Tag != "" {
+			return ref.Tag
+		}
+	}
+	return shortRevision(details.Revision)
+}
+
+func shortRevision(revision string) string {
+	algorithm, hex, ok := strings.Cut(revision, ":")
+	if !ok {
+		algorithm, hex = "", revision
+	}
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	if algorithm != "" {
+		return algorithm + ":" + hex
+	}
+	return hex
+}
+
+// DiffSnapshots returns the dependencies added, removed or updated between the before and after snapshots, sorted by
+// path. A dependency is updated if its version, namespace or content changed.
+func DiffSnapshots(before, after Snapshot) []DependencyChange {
+	var changes []DependencyChange
+	for path, dep := range after {
+		old, ok := before[path]
+		if !ok {
+			changes = append(changes, DependencyChange{Path: path, Kind: "added", NewVersion: dep.Version})
+			continue
+		}
+
+		change := DependencyChange{
+			Path:         path,
+			Kind:         "updated",
+			OldVersion:   old.Version,
+			NewVersion:   dep.Version,
+			ChangedFiles: len(diffSums(old.sums, dep.sums)),
+		}
+		if old.Namespace != dep.Namespace {
+			change.OldNamespace = old.Namespace
+			change.NewNamespace = dep.Namespace
+		}
+		if change.OldVersion != change.NewVersion || change.OldNamespace != change.NewNamespace ||
+			change.ChangedFiles > 0 {
+			changes = append(changes, change)
+		}
+	}
+	for path, dep := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, DependencyChange{Path: path, Kind: "removed", OldVersion: dep.Version})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
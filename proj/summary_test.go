@@ -0,0 +1,89 @@
+package proj
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	tests := []struct {
+		note     string
+		before   Snapshot
+		after    Snapshot
+		expected []DependencyChange
+	}{
+		{
+			note:   "unchanged",
+			before: Snapshot{"foo": {Version: "1.0.0", sums: map[string]string{"a.rego": "1"}}},
+			after:  Snapshot{"foo": {Version: "1.0.0", sums: map[string]string{"a.rego": "1"}}},
+		},
+		{
+			note:  "added",
+			after: Snapshot{"foo": {Version: "1.0.0"}},
+			expected: []DependencyChange{
+				{Path: "foo", Kind: "added", NewVersion: "1.0.0"},
+			},
+		},
+		{
+			note:   "removed",
+			before: Snapshot{"foo": {Version: "1.0.0"}, "foo.bar": {}},
+			expected: []DependencyChange{
+				{Path: "foo", Kind: "removed", OldVersion: "1.0.0"},
+				{Path: "foo.bar", Kind: "removed"},
+			},
+		},
+		{
+			note:   "new version",
+			before: Snapshot{"foo": {Version: "1.0.0", sums: map[string]string{"a.rego": "1", "b.rego": "2"}}},
+			after:  Snapshot{"foo": {Version: "1.1.0", sums: map[string]string{"a.rego": "3", "c.rego": "4"}}},
+			expected: []DependencyChange{
+				{Path: "foo", Kind: "updated", OldVersion: "1.0.0", NewVersion: "1.1.0", ChangedFiles: 3},
+			},
+		},
+		{
+			note:   "changed content",
+			before: Snapshot{"foo": {sums: map[string]string{"a.rego": "1"}}},
+			after:  Snapshot{"foo": {sums: map[string]string{"a.rego": "2"}}},
+			expected: []DependencyChange{
+				{Path: "foo", Kind: "updated", ChangedFiles: 1},
+			},
+		},
+		{
+			note:   "changed namespace",
+			before: Snapshot{"foo": {Version: "1.0.0", Namespace: "foo"}},
+			after:  Snapshot{"foo": {Version: "1.0.0", Namespace: "bar"}},
+			expected: []DependencyChange{
+				{Path: "foo", Kind: "updated", OldVersion: "1.0.0", NewVersion: "1.0.0", OldNamespace: "foo",
+					NewNamespace: "bar"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			actual := DiffSnapshots(tc.before, tc.after)
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected:\n\n%v\n\ngot:\n\n%v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestShortRevision(t *testing.T) {
+	tests := []struct {
+		revision string
+		expected string
+	}{
+		{"", ""},
+		{"0123456789abcdef0123", "0123456789ab"},
+		{"sha256:0123456789abcdef0123", "sha256:0123456789ab"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.revision, func(t *testing.T) {
+			if actual := shortRevision(tc.revision); actual != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
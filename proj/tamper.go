@@ -0,0 +1,77 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dependencySumsFile records the sums of all files in the dependencies directory, as left by the last update.
+const dependencySumsFile = "dependencies.sum"
+
+func dependencySumsPath(rootDir string) string {
+	return filepath.Join(rootDir, dotOpaDir, dependencySumsFile)
+}
+
+// recordDependencySums records the sums of the resolved content of all dependencies, after namespacing and renames,
+// for later detection of modifications.
+func (p *Project) recordDependencySums() error {
+	depsDir := dependenciesDir(p.Dir())
+	if !utils.IsDir(depsDir) {
+		return nil
+	}
+	sums, err := contentSums(depsDir)
+	if err != nil {
+		return err
+	}
+	path := dependencySumsPath(p.Dir())
+	if err := os.WriteFile(path, []byte(formatSums(sums)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ModifiedDependencyFiles lists the files of the dependencies directory added, modified or removed since the last
+// update; e.g. by hand-editing vendored dependencies. Files are listed by the path of their dependency in the
+// dependency tree, followed by their path within the dependency. Nothing is listed if no sums were recorded.
+func (p *Project) ModifiedDependencyFiles() ([]string, error) {
+	bs, err := os.ReadFile(dependencySumsPath(p.Dir()))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]string)
+	if depsDir := dependenciesDir(p.Dir()); utils.IsDir(depsDir) {
+		if sums, err = contentSums(depsDir); err != nil {
+			return nil, err
+		}
+	}
+	changes := diffSums(parseSums(string(bs)), sums)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	dirs, err := p.DependencyDirs()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(dirs))
+	for path, dir := range dirs {
+		if name, ok := names[filepath.Base(dir)]; !ok || path < name {
+			names[filepath.Base(dir)] = path
+		}
+	}
+	for i, change := range changes {
+		kind, path := change[:10], change[10:]
+		if id, rel, ok := strings.Cut(path, "/"); ok {
+			if name, ok := names[id]; ok {
+				changes[i] = kind + name + ": " + rel
+			}
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,74 @@
+package proj
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestModifiedDependencyFiles(t *testing.T) {
+	tests := []struct {
+		note     string
+		modify   func(dir string) error
+		expected []string
+	}{
+		{
+			note:   "unmodified",
+			modify: func(string) error { return nil },
+		},
+		{
+			note: "modified file",
+			modify: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "policy.rego"), []byte("package lib\n\nallow := false"), 0644)
+			},
+			expected: []string{"modified  lib: policy.rego"},
+		},
+		{
+			note: "added and removed files",
+			modify: func(dir string) error {
+				if err := os.Remove(filepath.Join(dir, "policy.rego")); err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join(dir, "extra.rego"), []byte("package lib.extra"), 0644)
+			},
+			expected: []string{"added     lib: extra.rego", "removed   lib: policy.rego"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project":     "dependencies:\n  lib: file:///lib\n",
+				"lib/policy.rego": "package lib\n\nallow := true",
+			}
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(dependenciesDir(root), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := tc.modify(project.Dependencies["lib"].dir(dependenciesDir(root))); err != nil {
+					t.Fatal(err)
+				}
+
+				changes, err := project.ModifiedDependencyFiles()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(changes, tc.expected) {
+					t.Fatalf("expected changes %v, got %v", tc.expected, changes)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package proj
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"io"
+	"os"
+	"strings"
+)
+
+// isTarballLocation returns true if the location is an HTTP(S) URL, an Azure Blob Storage location, a GitHub release
+// asset, or an OPA bundle service resource, of a gzipped tarball.
+func isTarballLocation(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") ||
+		strings.HasPrefix(location, "azblob://") || strings.HasPrefix(location, githubReleaseScheme) ||
+		strings.HasPrefix(location, bundleServiceScheme)
+}
+
+// checksum returns the declared sha256 digest of the dependency's tarball, as lower-case hex; empty if not declared.
+func (d Dependency) checksum() string {
+	return strings.ToLower(strings.TrimPrefix(d.Sha256, "sha256:"))
+}
+
+// updateTarball downloads the gzipped tarball at the HTTP(S), Azure Blob Storage, GitHub release or bundle service
+// location, and extracts it into targetDir. If the dependency declares a sha256 digest, the downloaded tarball must
+// match it, or the update fails without extracting anything. The digest of the downloaded tarball is recorded in the new
+// lock.
+func (d Dependency) updateTarball(ctx *updateContext, location string, targetDir string) error {
+	download := func(dst string) error {
+		return utils.DownloadObject(location, dst)
+	}
+	if strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") {
+		download = func(dst string) error {
+			return ctx.downloadHttp(location, nil, dst)
+		}
+	} else if strings.HasPrefix(location, bundleServiceScheme) {
+		u, err := bundleServiceUrl(location)
+		if err != nil {
+			return err
+		}
+		download = func(dst string) error {
+			return ctx.downloadHttp(u, bundleServiceHeader(u), dst)
+		}
+	} else if strings.HasPrefix(location, githubReleaseScheme) {
+		release, err := parseGithubRelease(location)
+		if err != nil {
+			return err
+		}
+		download = release.download
+	}
+
+	expected := d.checksum()
+	if expected != "" && ctx.cache.restore(tarballCacheKey(expected), targetDir) {
+		ctx.newLock.set(d.location(), LockedDependency{Digest: "sha256:" + expected})
+		return nil
+	}
+
+	tmp, err := os.CreateTemp("", "odm-tarball-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := download(tmp.Name()); err != nil {
+		return errs.New(errs.FetchFailed, "%w", err)
+	}
+	digest, err := fileSha256(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if expected != "" && digest != expected {
+		return errs.New(errs.ContentMismatch, "sha256 digest of %s is %s, expected %s", location, digest, expected)
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if err := utils.ExtractTarGz(f, targetDir); err != nil {
+		return errs.New(errs.FetchFailed, "failed to extract %s: %w", location, err)
+	}
+
+	if expected != "" {
+		ctx.cache.store(tarballCacheKey(expected), targetDir)
+	}
+	ctx.newLock.set(d.location(), LockedDependency{Digest: "sha256:" + digest})
+	return nil
+}
+
+// isArchive returns true if the file at path is a gzipped tarball or a zip archive, by its extension.
+func isArchive(path string) bool {
+	name := strings.ToLower(path)
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".zip")
+}
+
+// extractArchive extracts the gzipped tarball or zip archive at path into targetDir.
+func extractArchive(path string, targetDir string) error {
+	printer.Debug("Extracting %s to %s", path, targetDir)
+	var err error
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		err = utils.ExtractZip(path, targetDir)
+	} else {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		err = utils.ExtractTarGz(f, targetDir)
+	}
+	if err != nil {
+		return errs.New(errs.FetchFailed, "failed to extract %s: %w", path, err)
+	}
+	return nil
+}
+
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func tarballCacheKey(digest string) string {
+	return "tarball/" + digest
+}
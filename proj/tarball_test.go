@@ -0,0 +1,291 @@
+package proj
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateTarball(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "policy.rego"), []byte("package tarball"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var tarball bytes.Buffer
+	if err := utils.CreateTarGz(&tarball, srcDir, []string{srcDir}); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(tarball.Bytes())
+	digest := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bundle.tar.gz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(tarball.Bytes())
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		note            string
+		path            string
+		sha256          string
+		expectedErr     string
+		expectedErrCode errs.Code
+	}{
+		{
+			note: "no digest",
+			path: "/bundle.tar.gz",
+		},
+		{
+			note:   "matching digest",
+			path:   "/bundle.tar.gz",
+			sha256: digest,
+		},
+		{
+			note:   "matching prefixed, upper-case digest",
+			path:   "/bundle.tar.gz",
+			sha256: "sha256:" + strings.ToUpper(digest),
+		},
+		{
+			note:            "mismatching digest",
+			path:            "/bundle.tar.gz",
+			sha256:          strings.Repeat("ab", 32),
+			expectedErr:     fmt.Sprintf("sha256 digest of %s/bundle.tar.gz is %s, expected %s", server.URL, digest, strings.Repeat("ab", 32)),
+			expectedErrCode: errs.ContentMismatch,
+		},
+		{
+			note:            "not found",
+			path:            "/missing.tar.gz",
+			expectedErr:     "unexpected status 404",
+			expectedErrCode: errs.FetchFailed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			dep := fmt.Sprintf("    location: %s%s\n    namespace: false\n", server.URL, tc.path)
+			if tc.sha256 != "" {
+				dep += fmt.Sprintf("    sha256: %s\n", tc.sha256)
+			}
+			files := map[string]string{
+				"opa.project": "name: proj\ndependencies:\n  tarball:\n" + dep,
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadAndLoadProject(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = project.UpdateWithOptions(UpdateOptions{})
+				if tc.expectedErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+						t.Fatalf("expected error containing %q, got %v", tc.expectedErr, err)
+					}
+					if code := errs.CodeOf(err); code != tc.expectedErrCode {
+						t.Fatalf("expected error code %s, got %s", tc.expectedErrCode.ID, code.ID)
+					}
+					if utils.FileExists(filepath.Join(project.Dependencies["tarball"].dir(dependenciesDir(root)), "policy.rego")) {
+						t.Fatal("expected nothing to be extracted")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				bs, err := os.ReadFile(filepath.Join(project.Dependencies["tarball"].dir(dependenciesDir(root)), "policy.rego"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(bs) != "package tarball" {
+					t.Fatalf("expected extracted policy, got %s", bs)
+				}
+
+				lock, err := ReadLockFile(lockFilePath(project.filePath))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if locked, _ := lock.Get(server.URL + tc.path); locked.Digest != "sha256:"+digest || locked.Hash == "" {
+					t.Fatalf("expected locked digest sha256:%s and content hash, got %+v", digest, locked)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateLocalArchive(t *testing.T) {
+	content := map[string]string{
+		".manifest":     `{"roots": ["lib/http"]}`,
+		"http.rego":     "package lib.http\n\nallow := true",
+		"internal.rego": "package lib.internal\n\nsecret := true",
+	}
+
+	srcDir := t.TempDir()
+	for name, c := range content {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tarball bytes.Buffer
+	if err := utils.CreateTarGz(&tarball, srcDir, []string{srcDir}); err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, c := range content {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		note    string
+		name    string
+		archive []byte
+	}{
+		{
+			note:    "gzipped tarball",
+			name:    "bundle.tar.gz",
+			archive: tarball.Bytes(),
+		},
+		{
+			note:    "zip archive",
+			name:    "bundle.zip",
+			archive: archive.Bytes(),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			files := map[string]string{
+				"opa.project": fmt.Sprintf("dependencies:\n  lib:\n    location: file:///%s\n    namespace: false\n", tc.name),
+				tc.name:       string(tc.archive),
+			}
+
+			err := withTempFiles(files, func(root string) {
+				project, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := project.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				dir := project.Dependencies["lib"].dir(dependenciesDir(root))
+				for path, expected := range map[string]bool{
+					".manifest":     true,
+					"http.rego":     true,
+					"internal.rego": false,
+					tc.name:         false,
+				} {
+					if exists := utils.FileExists(filepath.Join(dir, path)); exists != expected {
+						t.Fatalf("expected %s to exist: %v, got %v", path, expected, exists)
+					}
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateTarballNotModified(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "policy.rego"), []byte("package tarball"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var tarball bytes.Buffer
+	if err := utils.CreateTarGz(&tarball, srcDir, []string{srcDir}); err != nil {
+		t.Fatal(err)
+	}
+	lastModified := "Wed, 21 Oct 2015 07:28:00 GMT"
+
+	tests := []struct {
+		note   string
+		header string
+		value  string
+		check  string
+	}{
+		{
+			note:   "etag",
+			header: "ETag",
+			value:  `"v1"`,
+			check:  "If-None-Match",
+		},
+		{
+			note:   "last modified",
+			header: "Last-Modified",
+			value:  lastModified,
+			check:  "If-Modified-Since",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			downloads := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(tc.check) == tc.value {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				downloads++
+				w.Header().Set(tc.header, tc.value)
+				_, _ = w.Write(tarball.Bytes())
+			}))
+			defer server.Close()
+
+			files := map[string]string{
+				"opa.project": fmt.Sprintf("dependencies:\n  tarball:\n    location: %s/bundle.tar.gz\n    namespace: false\n",
+					server.URL),
+			}
+			err := withTempFiles(files, func(root string) {
+				for i := 0; i < 2; i++ {
+					project, err := ReadAndLoadProject(root, false)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := project.UpdateWithOptions(UpdateOptions{}); err != nil {
+						t.Fatal(err)
+					}
+					bs, err := os.ReadFile(filepath.Join(project.Dependencies["tarball"].dir(dependenciesDir(root)), "policy.rego"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if string(bs) != "package tarball" {
+						t.Fatalf("expected extracted policy, got %s", bs)
+					}
+				}
+				if downloads != 1 {
+					t.Fatalf("expected 1 download, got %d", downloads)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// gitTLS is the TLS configuration of the user-level config, as set by configureFetching; passed on to the git CLI.
+var gitTLS config.TLS
+
+// configureTLS applies the TLS settings of the config to all subsequent HTTPS requests, with the CA file resolved
+// against the config file.
+func configureTLS(cfg *config.Config) error {
+	gitTLS = cfg.TLS
+	if cfg.TLS.CAFile != "" {
+		path, err := config.FilePath()
+		if err != nil {
+			return err
+		}
+		gitTLS.CAFile = resolveUserPath(filepath.Dir(path), cfg.TLS.CAFile)
+	}
+	for _, host := range gitTLS.InsecureHosts() {
+		printer.Warn("TLS certificates of %s are not verified", host)
+	}
+	if err := utils.ConfigureTLS(gitTLS.CAFile, gitTLS.InsecureHosts()); err != nil {
+		return errs.New(errs.InvalidConfig, "invalid TLS config: %w", err)
+	}
+	return nil
+}
+
+// gitTLSConfig returns the git config settings, as key=value, applying the TLS settings to the git repository at the
+// HTTPS url; for passing to the git CLI.
+func gitTLSConfig(gitUrl string) []string {
+	if !strings.HasPrefix(gitUrl, "https://") {
+		return nil
+	}
+	var settings []string
+	if gitTLS.CAFile != "" {
+		settings = append(settings, "http.sslCAInfo="+gitTLS.CAFile)
+	}
+	if u, err := url.Parse(gitUrl); err == nil {
+		for _, host := range gitTLS.InsecureHosts() {
+			if strings.EqualFold(u.Hostname(), host) || strings.EqualFold(u.Host, host) {
+				settings = append(settings, "http.sslVerify=false")
+				break
+			}
+		}
+	}
+	return settings
+}
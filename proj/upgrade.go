@@ -0,0 +1,171 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	"github.com/johanfylling/odm/config"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/utils"
+	"strings"
+)
+
+// Scopes of upgrades, bounding how far the version of a dependency may be bumped.
+const (
+	UpgradeMajor = "major"
+	UpgradeMinor = "minor"
+	UpgradePatch = "patch"
+)
+
+// UpgradedDependency is a dependency whose version was bumped by Upgrade.
+type UpgradedDependency struct {
+	Name     string
+	Location string
+	Upgraded string
+}
+
+// Upgrade bumps the versions of the project's dependencies to the newest tags within scope: of the same major and
+// minor version for UpgradePatch, of the same major version for UpgradeMinor, and of any version for UpgradeMajor.
+// Git and OCI dependencies referencing an exact semver tag have their location rewritten to the newest tag; those
+// referencing a version range, tag pattern or 'latest' keep their location, and are re-resolved by the next update.
+// Only the named dependencies are upgraded, or all direct dependencies if none are named; transitive dependencies are
+// declared by their own projects, and so aren't upgraded.
+// The lock entries of upgraded dependencies are removed, and the lock file written; writing the project file, and
+// updating the project, is left to the caller.
+func (p *Project) Upgrade(names []string, scope string) ([]UpgradedDependency, error) {
+	switch scope {
+	case UpgradeMajor, UpgradeMinor, UpgradePatch:
+	default:
+		return nil, errs.New(errs.InvalidUsage, "invalid upgrade scope '%s'", scope)
+	}
+
+	lock, err := ReadLockFile(lockFilePath(p.filePath))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := configureFetching(cfg, p); err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		names = sortedDependencyNames(p.Dependencies)
+	}
+
+	ctx := p.newUpdateContext(lock, cfg, newResolver(p.Resolution), make(map[string]*oci.Metadata))
+	var upgraded []UpgradedDependency
+	for _, name := range names {
+		dep, ok := p.Dependencies[name]
+		if !ok {
+			return nil, errs.New(errs.InvalidUsage, "no dependency named %s", name)
+		}
+		location := dep.location()
+		source, err := dep.source(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		current, listTags, err := upgradableVersion(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		if current == "" {
+			continue
+		}
+		if !isExactVersion(current) {
+			printer.Debug("Re-resolving %s of dependency %s", current, name)
+			lock.remove(location)
+			continue
+		}
+
+		tags, err := listTags()
+		if err != nil {
+			return nil, errs.New(errs.FetchFailed, "failed to list versions of dependency %s: %w", name, err)
+		}
+		newest, err := newestVersionInScope(current, tags, scope)
+		if err != nil {
+			return nil, errs.New(errs.InvalidLocation, "invalid version of dependency %s: %w", name, err)
+		}
+		if newest == current {
+			continue
+		}
+		// Versions declared through variables can't be rewritten without rewriting the variables themselves
+		if !strings.HasSuffix(dep.Location, current) {
+			printer.Warn("not upgrading dependency %s to %s: its version isn't declared in its location", name, newest)
+			continue
+		}
+
+		lock.remove(location)
+		dep.Location = strings.TrimSuffix(dep.Location, current) + newest
+		p.Dependencies[name] = dep
+		upgraded = append(upgraded, UpgradedDependency{Name: name, Location: location, Upgraded: dep.location()})
+	}
+
+	return upgraded, lock.WriteToFile()
+}
+
+// upgradableVersion returns the version the source references, along with a function listing the available versions:
+// the tag, version range, tag pattern or 'latest' of a git location, or the tag or version range of an OCI location
+// without digest. Other locations have no upgradable version.
+func upgradableVersion(ctx *updateContext, source string) (string, func() ([]string, error), error) {
+	switch {
+	case strings.HasPrefix(source, "git+"):
+		url, ref, err := parseGitUrl(source)
+		if err != nil {
+			return "", nil, err
+		}
+		if !ref.resolvable() && !isVersioned(ref.tag()) {
+			return "", nil, nil
+		}
+		return ref.name, func() ([]string, error) {
+			return remoteGitTags(ctx.recording, url)
+		}, nil
+	case strings.HasPrefix(source, "oci://"):
+		ref, err := oci.ParseReference(strings.TrimPrefix(source, "oci://"))
+		if err != nil {
+			return "", nil, errs.New(errs.InvalidLocation, "invalid OCI location %s: %w", source, err)
+		}
+		if ref.Digest != "" || !isVersioned(ref.Tag) {
+			return "", nil, nil
+		}
+		return ref.Tag, func() ([]string, error) {
+			return unyankedTags(ctx, oci.NewClient(), ref)
+		}, nil
+	default:
+		return "", nil, nil
+	}
+}
+
+// isExactVersion returns true if the version is a single semver version, rather than a range, tag pattern or 'latest'.
+func isExactVersion(version string) bool {
+	return isVersioned(version) && !utils.IsVersionRange(version)
+}
+
+// newestVersionInScope returns the tag holding the highest version the current version may be upgraded to within
+// scope; or the current version, if none is higher.
+func newestVersionInScope(current string, tags []string, scope string) (string, error) {
+	v, err := semver.NewVersion(current)
+	if err != nil {
+		return "", err
+	}
+	bound := fmt.Sprintf(">=%s", v)
+	switch scope {
+	case UpgradePatch:
+		bound += fmt.Sprintf(", <%d.%d.0", v.Major(), v.Minor()+1)
+	case UpgradeMinor:
+		bound += fmt.Sprintf(", <%d.0.0", v.Major()+1)
+	}
+	newest, err := utils.SelectVersion([]string{bound}, tags, false)
+	if err != nil {
+		// No tag holds the current version, or any higher one
+		return current, nil
+	}
+	if newestVersion, err := semver.NewVersion(newest); err == nil && newestVersion.Equal(v) {
+		return current, nil
+	}
+	return newest, nil
+}
@@ -0,0 +1,160 @@
+package proj
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/errs"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpgrade(t *testing.T) {
+	t.Setenv("ODM_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+
+	newUpstream := func() string {
+		upstream := newGitUpstream(t, "policy.rego")
+		for _, tag := range []string{"v1.0.0", "v1.0.1", "v1.1.0", "v2.0.0-rc.1", "v2.0.0", "latest"} {
+			upstream.tag(tag, "package git")
+		}
+		return upstream.dir
+	}
+
+	registry := ocitest.NewRegistry()
+	defer registry.Close()
+	for _, tag := range []string{"1.0.0", "1.0.5", "1.2.0", "3.0.0"} {
+		registry.Push("org/policy", tag, map[string]string{"/policy.rego": "package oci"})
+	}
+
+	gitLocation := fmt.Sprintf("git+file://%s#", newUpstream())
+	ociLocation := fmt.Sprintf("oci://%s/org/policy:", registry.Host())
+	rangeLocation := fmt.Sprintf("git+file://%s#^1.0.0", newUpstream())
+	project := fmt.Sprintf(`name: proj
+dependencies:
+  git:
+    location: %sv1.0.0
+  oci:
+    location: %s1.0.0
+  range:
+    location: %s
+`, gitLocation, ociLocation, rangeLocation)
+
+	tests := []struct {
+		note     string
+		names    []string
+		scope    string
+		expected map[string]string
+		relocked []string
+	}{
+		{
+			note:  "patch",
+			scope: UpgradePatch,
+			expected: map[string]string{
+				"git": gitLocation + "v1.0.1",
+				"oci": ociLocation + "1.0.5",
+			},
+			relocked: []string{"range"},
+		},
+		{
+			note:  "minor",
+			scope: UpgradeMinor,
+			expected: map[string]string{
+				"git": gitLocation + "v1.1.0",
+				"oci": ociLocation + "1.2.0",
+			},
+			relocked: []string{"range"},
+		},
+		{
+			note:  "major",
+			scope: UpgradeMajor,
+			expected: map[string]string{
+				"git": gitLocation + "v2.0.0",
+				"oci": ociLocation + "3.0.0",
+			},
+			relocked: []string{"range"},
+		},
+		{
+			note:  "named dependency",
+			names: []string{"oci"},
+			scope: UpgradeMinor,
+			expected: map[string]string{
+				"oci": ociLocation + "1.2.0",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			err := withTempFiles(map[string]string{"opa.project": project}, func(root string) {
+				p, err := ReadProjectFromFile(root, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := p.Update(); err != nil {
+					t.Fatal(err)
+				}
+
+				upgraded, err := p.Upgrade(tc.names, tc.scope)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(upgraded) != len(tc.expected) {
+					t.Fatalf("expected %d upgraded dependencies, got %v", len(tc.expected), upgraded)
+				}
+				for _, dep := range upgraded {
+					if dep.Upgraded != tc.expected[dep.Name] {
+						t.Fatalf("expected %s upgraded to %s, got %s", dep.Name, tc.expected[dep.Name], dep.Upgraded)
+					}
+					if p.Dependencies[dep.Name].Location != dep.Upgraded {
+						t.Fatalf("expected location of %s rewritten, got %s", dep.Name, p.Dependencies[dep.Name].Location)
+					}
+				}
+
+				lock, err := ReadLockFile(lockFilePath(p.filePath))
+				if err != nil {
+					t.Fatal(err)
+				}
+				relocked := map[string]bool{}
+				for _, name := range tc.relocked {
+					relocked[name] = true
+				}
+				for name, dep := range p.Dependencies {
+					_, locked := lock.Get(dep.location())
+					if _, upgraded := tc.expected[name]; upgraded || relocked[name] {
+						if locked {
+							t.Fatalf("expected lock entry of %s removed", name)
+						}
+					} else if !locked {
+						t.Fatalf("expected lock entry of %s kept", name)
+					}
+				}
+
+				if err := p.Update(); err != nil {
+					t.Fatal(err)
+				}
+				lock, err = ReadLockFile(lockFilePath(p.filePath))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if locked, _ := lock.Get(rangeLocation); locked.Tag != "v1.1.0" {
+					t.Fatalf("expected range locked to v1.1.0, got %v", locked)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	err := withTempFiles(map[string]string{"opa.project": project}, func(root string) {
+		p, err := ReadProjectFromFile(root, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Upgrade([]string{"missing"}, UpgradeMinor); !errs.Is(err, errs.InvalidUsage) {
+			t.Fatalf("expected usage error, got %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,51 @@
+package proj
+
+import (
+	"github.com/Masterminds/semver/v3"
+	"github.com/johanfylling/odm/errs"
+	"strings"
+)
+
+// BumpVersion sets the project's version: to the next major, minor or patch version of the current version, for a bump
+// of 'major', 'minor' or 'patch'; or else to the bump itself, which must be a semantic version. A project without a
+// version is bumped from 0.0.0. A 'v' prefix of the current version, or given version, is kept. The new version is returned; writing the
+// project file is left to the caller.
+func (p *Project) BumpVersion(bump string) (string, error) {
+	var next semver.Version
+	prefixed := strings.HasPrefix(p.Version, "v")
+	switch bump {
+	case "major", "minor", "patch":
+		current := semver.MustParse("0.0.0")
+		if p.Version != "" {
+			v, err := semver.StrictNewVersion(strings.TrimPrefix(p.Version, "v"))
+			if err != nil {
+				return "", errs.New(errs.InvalidProject, "version %s of project %s is not a semantic version: %w",
+					p.Version, p.filePath, err)
+			}
+			current = v
+		}
+		switch bump {
+		case "major":
+			next = current.IncMajor()
+		case "minor":
+			next = current.IncMinor()
+		default:
+			next = current.IncPatch()
+		}
+	default:
+		v, err := semver.StrictNewVersion(strings.TrimPrefix(bump, "v"))
+		if err != nil {
+			return "", errs.New(errs.InvalidUsage, "version %s is not a semantic version, nor one of major, minor or patch: %w",
+				bump, err)
+		}
+		next = *v
+		prefixed = strings.HasPrefix(bump, "v")
+	}
+
+	version := next.String()
+	if prefixed {
+		version = "v" + version
+	}
+	p.Version = version
+	return version, nil
+}
@@ -0,0 +1,49 @@
+package proj
+
+import (
+	"github.com/johanfylling/odm/errs"
+	"testing"
+)
+
+func TestBumpVersion(t *testing.T) {
+	tests := []struct {
+		note     string
+		version  string
+		bump     string
+		expected string
+		err      errs.Code
+	}{
+		{note: "major", version: "1.2.3", bump: "major", expected: "2.0.0"},
+		{note: "minor", version: "1.2.3", bump: "minor", expected: "1.3.0"},
+		{note: "patch", version: "1.2.3", bump: "patch", expected: "1.2.4"},
+		{note: "prefixed", version: "v1.2.3", bump: "minor", expected: "v1.3.0"},
+		{note: "pre-release", version: "1.3.0-rc.1", bump: "patch", expected: "1.3.0"},
+		{note: "no version", bump: "patch", expected: "0.0.1"},
+		{note: "explicit", version: "1.2.3", bump: "2.0.0-rc.1", expected: "2.0.0-rc.1"},
+		{note: "explicit prefixed", version: "1.2.3", bump: "v2.0.0", expected: "v2.0.0"},
+		{note: "explicit invalid", version: "1.2.3", bump: "2.0", err: errs.InvalidUsage},
+		{note: "invalid current", version: "latest", bump: "minor", err: errs.InvalidProject},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			project := &Project{Version: tc.version}
+			actual, err := project.BumpVersion(tc.bump)
+			if tc.err != (errs.Code{}) {
+				if !errs.Is(err, tc.err) {
+					t.Fatalf("expected error %s, got %v", tc.err.ID, err)
+				}
+				if project.Version != tc.version {
+					t.Fatalf("expected version to be unchanged, got %s", project.Version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected || project.Version != tc.expected {
+				t.Fatalf("expected version %s, got %s (project: %s)", tc.expected, actual, project.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/johanfylling/odm/printer"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// serveGit serves the upload-pack service of the git smart HTTP protocol from a mirror of the upstream repository.
+// The mirror is updated from upstream on every ref advertisement; i.e. once per clone or fetch.
+func (s *Server) serveGit(w http.ResponseWriter, r *http.Request, path string) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/info/refs"):
+		if r.URL.Query().Get("service") != transport.UploadPackServiceName {
+			httpError(w, http.StatusForbidden, fmt.Errorf("only %s is supported", transport.UploadPackServiceName))
+			return
+		}
+		s.serveGitRefs(w, r, strings.TrimSuffix(path, "/info/refs"))
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/"+transport.UploadPackServiceName):
+		s.serveGitUploadPack(w, r, strings.TrimSuffix(path, "/"+transport.UploadPackServiceName))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveGitRefs(w http.ResponseWriter, r *http.Request, repo string) {
+	if err := s.updateMirror(repo); err != nil {
+		httpError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	session, err := s.uploadPackSession(repo)
+	if err != nil {
+		httpError(w, http.StatusNotFound, err)
+		return
+	}
+	defer func() { _ = session.Close() }()
+
+	refs, err := session.AdvertisedReferencesContext(r.Context())
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	refs.Prefix = [][]byte{[]byte("# service=" + transport.UploadPackServiceName), pktline.Flush}
+
+	w.Header().Set("Content-Type", "application/x-"+transport.UploadPackServiceName+"-advertisement")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := refs.Encode(w); err != nil {
+		printer.Debug("Failed to advertise refs of %s: %s", repo, err)
+	}
+}
+
+func (s *Server) serveGitUploadPack(w http.ResponseWriter, r *http.Request, repo string) {
+	session, err := s.uploadPackSession(repo)
+	if err != nil {
+		httpError(w, http.StatusNotFound, err)
+		return
+	}
+	defer func() { _ = session.Close() }()
+
+	req := packp.NewUploadPackRequest()
+	if err := req.Decode(r.Body); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := session.UploadPack(r.Context(), req)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() { _ = resp.Close() }()
+
+	w.Header().Set("Content-Type", "application/x-"+transport.UploadPackServiceName+"-result")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := resp.Encode(w); err != nil {
+		printer.Debug("Failed to upload pack of %s: %s", repo, err)
+	}
+}
+
+func (s *Server) uploadPackSession(repo string) (transport.UploadPackSession, error) {
+	if _, err := s.cachePath("git", repo); err != nil {
+		return nil, err
+	}
+	ep, err := transport.NewEndpoint("/git/" + repo)
+	if err != nil {
+		return nil, err
+	}
+	srv := server.NewServer(server.NewFilesystemLoader(osfs.New(s.cacheDir)))
+	return srv.NewUploadPackSession(ep, nil)
+}
+
+// updateMirror clones or fetches the upstream repository into its bare mirror. If upstream is unavailable, an existing
+// mirror is served as-is.
+func (s *Server) updateMirror(repo string) error {
+	dir, err := s.cachePath("git", repo)
+	if err != nil {
+		return err
+	}
+	defer s.lock(dir)()
+
+	upstream := s.gitUpstream(repo)
+	mirror, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		printer.Debug("Cloning mirror of %s", upstream)
+		_, err := git.PlainClone(dir, true, &git.CloneOptions{URL: upstream, Mirror: true})
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return fmt.Errorf("failed to clone %s: %w", upstream, err)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	printer.Debug("Updating mirror of %s", upstream)
+	err = mirror.Fetch(&git.FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/*:refs/*"},
+		Force:    true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		printer.Info("Failed to update mirror of %s, serving cached mirror: %s", upstream, err)
+	}
+	return nil
+}
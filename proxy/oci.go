@@ -0,0 +1,250 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/printer"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// serveOci serves the read-only subset of the OCI distribution API needed for pulling, for repositories named
+// '<upstream registry>/<upstream repository>'.
+func (s *Server) serveOci(w http.ResponseWriter, r *http.Request, path string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var repository, kind, reference string
+	for _, k := range []string{"/manifests/", "/blobs/", "/tags/list"} {
+		if i := strings.LastIndex(path, k); i > 0 {
+			repository, kind, reference = path[:i], strings.Trim(k, "/"), path[i+len(k):]
+			break
+		}
+	}
+
+	registry, upstreamRepository, ok := strings.Cut(repository, "/")
+	if !ok || upstreamRepository == "" {
+		http.NotFound(w, r)
+		return
+	}
+	ref := oci.Reference{Registry: registry, Repository: upstreamRepository}
+
+	switch kind {
+	case "manifests":
+		s.serveManifest(w, ref, reference)
+	case "blobs":
+		s.serveBlob(w, ref, reference)
+	case "tags/list":
+		s.serveTags(w, ref, repository)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveManifest(w http.ResponseWriter, ref oci.Reference, reference string) {
+	tagPath, err := s.cachePath("oci", "tags", ref.Registry, ref.Repository, reference)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	digest := reference
+	if strings.HasPrefix(reference, "sha256:") {
+		ref.Digest = reference
+	} else {
+		ref.Tag = reference
+		// Tags are mutable, so are always resolved upstream first
+		body, mediaType, upstreamDigest, err := oci.NewAnonymousClient().FetchManifest(ref)
+		if err == nil {
+			if err := s.storeManifest(upstreamDigest, mediaType, body); err != nil {
+				httpError(w, http.StatusInternalServerError, err)
+				return
+			}
+			if err := writeFile(tagPath, []byte(upstreamDigest)); err != nil {
+				httpError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeManifest(w, upstreamDigest, mediaType, body)
+			return
+		}
+		if oci.IsNotFound(err) {
+			httpError(w, http.StatusNotFound, err)
+			return
+		}
+
+		printer.Info("Failed to resolve %s upstream, falling back to cache: %s", ref, err)
+		bs, cacheErr := os.ReadFile(tagPath)
+		if cacheErr != nil {
+			httpError(w, http.StatusBadGateway, err)
+			return
+		}
+		digest = string(bs)
+	}
+
+	body, mediaType, err := s.cachedManifest(digest)
+	if err != nil {
+		if body, mediaType, digest, err = oci.NewAnonymousClient().FetchManifest(ref); err != nil {
+			httpError(w, upstreamStatus(err), err)
+			return
+		}
+		if err := s.storeManifest(digest, mediaType, body); err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	writeManifest(w, digest, mediaType, body)
+}
+
+func writeManifest(w http.ResponseWriter, digest string, mediaType string, body []byte) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	_, _ = w.Write(body)
+}
+
+func (s *Server) cachedManifest(digest string) ([]byte, string, error) {
+	path, err := s.digestPath("manifests", digest)
+	if err != nil {
+		return nil, "", err
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	mediaType, err := os.ReadFile(path + ".type")
+	if err != nil {
+		return nil, "", err
+	}
+	return body, string(mediaType), nil
+}
+
+func (s *Server) storeManifest(digest string, mediaType string, body []byte) error {
+	path, err := s.digestPath("manifests", digest)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(path, body); err != nil {
+		return err
+	}
+	return writeFile(path+".type", []byte(mediaType))
+}
+
+// serveBlob serves a blob from the cache, fetching it from upstream on first request. Blobs are verified against their
+// digest before being cached.
+func (s *Server) serveBlob(w http.ResponseWriter, ref oci.Reference, digest string) {
+	path, err := s.digestPath("blobs", digest)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if err := s.fetchBlob(ref, digest, path); err != nil {
+			httpError(w, upstreamStatus(err), err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", digest)
+	f, err := os.Open(path)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	_, _ = io.Copy(w, f)
+}
+
+func (s *Server) fetchBlob(ref oci.Reference, digest string, path string) error {
+	printer.Debug("Fetching blob %s from %s/%s", digest, ref.Registry, ref.Repository)
+
+	body, err := oci.NewAnonymousClient().FetchBlob(ref, digest)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch blob %s: %w", digest, err)
+	}
+	if actual := fmt.Sprintf("sha256:%x", h.Sum(nil)); actual != digest {
+		return fmt.Errorf("digest mismatch for blob %s: got %s", digest, actual)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// serveTags lists the repository's tags upstream, falling back to the last listing when upstream is unavailable.
+func (s *Server) serveTags(w http.ResponseWriter, ref oci.Reference, repository string) {
+	path, err := s.cachePath("oci", "tags", ref.Registry, ref.Repository+".list")
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tags, err := oci.NewAnonymousClient().Tags(ref)
+	if err != nil && !oci.IsNotFound(err) {
+		printer.Info("Failed to list tags of %s/%s upstream, falling back to cache: %s", ref.Registry, ref.Repository, err)
+		bs, cacheErr := os.ReadFile(path)
+		if cacheErr != nil {
+			httpError(w, http.StatusBadGateway, err)
+			return
+		}
+		tags, err = strings.Split(strings.TrimSpace(string(bs)), "\n"), nil
+	}
+	if err != nil {
+		httpError(w, http.StatusNotFound, err)
+		return
+	}
+	if err := writeFile(path, []byte(strings.Join(tags, "\n"))); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repository, "tags": tags})
+}
+
+func (s *Server) digestPath(kind string, digest string) (string, error) {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" || len(hex) != 64 || strings.ContainsAny(hex, "/\\.") {
+		return "", fmt.Errorf("invalid digest %s", digest)
+	}
+	return s.cachePath("oci", kind, algorithm, hex)
+}
+
+func upstreamStatus(err error) int {
+	if oci.IsNotFound(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
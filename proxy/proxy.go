@@ -0,0 +1,82 @@
+// Package proxy implements a caching pull-through proxy for OCI and git dependencies, shared by ODM clients through the
+// 'proxy' setting of the user-level config.
+//
+// OCI repositories are proxied under the upstream registry's host, e.g. 'ghcr.io/org/policy' is served as repository
+// 'ghcr.io/org/policy' of the proxy registry. Git repositories are proxied over the smart HTTP protocol under '/git/',
+// e.g. 'https://github.com/org/policy.git' is served at '/git/github.com/org/policy.git'.
+package proxy
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Server is a caching pull-through proxy. Immutable content, such as OCI blobs and manifests by digest, is served from
+// the cache once fetched; mutable content, such as tags and git refs, is refreshed from upstream, falling back to the
+// cache when upstream is unavailable.
+type Server struct {
+	cacheDir string
+	// gitUpstream returns the upstream URL of a proxied git repository path; e.g. 'github.com/org/policy.git'
+	gitUpstream func(path string) string
+	// locks serializes mirror updates per git repository
+	locks sync.Map
+}
+
+func NewServer(cacheDir string) *Server {
+	return &Server{
+		cacheDir: cacheDir,
+		gitUpstream: func(path string) string {
+			return "https://" + path
+		},
+	}
+}
+
+// DefaultCacheDir returns the default cache directory of the proxy, within the user cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine cache directory: %w", err)
+	}
+	return filepath.Join(dir, "odm", "proxy"), nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	printer.Debug("%s %s", r.Method, r.URL)
+
+	switch {
+	case r.URL.Path == "/v2/" || r.URL.Path == "/v2":
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	case strings.HasPrefix(r.URL.Path, "/v2/"):
+		s.serveOci(w, r, strings.TrimPrefix(r.URL.Path, "/v2/"))
+	case strings.HasPrefix(r.URL.Path, "/git/"):
+		s.serveGit(w, r, strings.TrimPrefix(r.URL.Path, "/git/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) lock(key string) func() {
+	mtx, _ := s.locks.LoadOrStore(key, &sync.Mutex{})
+	mtx.(*sync.Mutex).Lock()
+	return mtx.(*sync.Mutex).Unlock
+}
+
+// cachePath returns the location in the cache of the given slash-separated path, rejecting paths escaping the cache.
+func (s *Server) cachePath(parts ...string) (string, error) {
+	path := filepath.Join(append([]string{s.cacheDir}, parts...)...)
+	if !strings.HasPrefix(path, filepath.Clean(s.cacheDir)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal path %s", filepath.Join(parts...))
+	}
+	return path, nil
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	printer.Debug("%s", err)
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/johanfylling/odm/oci"
+	"github.com/johanfylling/odm/oci/ocitest"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyOci(t *testing.T) {
+	upstream := ocitest.NewRegistry()
+	digest := upstream.Push("org/policy", "1.0.0", map[string]string{"/policy.rego": "package v1"})
+
+	server := httptest.NewServer(NewServer(t.TempDir()))
+	defer server.Close()
+
+	ref, err := oci.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/" + upstream.Host() + "/org/policy:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pull := func() {
+		dir := t.TempDir()
+		pulled, err := oci.NewClient().Pull(ref, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pulled != digest {
+			t.Fatalf("expected digest %s, got %s", digest, pulled)
+		}
+		bs, err := os.ReadFile(filepath.Join(dir, "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package v1" {
+			t.Fatalf("expected package v1, got %s", string(bs))
+		}
+	}
+
+	pull()
+	tags, err := oci.NewClient().Tags(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0] != "1.0.0" {
+		t.Fatalf("expected tags [1.0.0], got %v", tags)
+	}
+
+	// Once cached, content is served while upstream is unavailable
+	upstream.Close()
+	pull()
+	if tags, err := oci.NewClient().Tags(ref); err != nil || len(tags) != 1 {
+		t.Fatalf("expected cached tags, got %v (%v)", tags, err)
+	}
+
+	unknown := ref
+	unknown.Tag = "2.0.0"
+	if _, err := oci.NewClient().Pull(unknown, t.TempDir()); err == nil {
+		t.Fatal("expected error for uncached tag")
+	}
+}
+
+func TestProxyGit(t *testing.T) {
+	upstreamDir := t.TempDir()
+	repo, err := git.PlainInit(upstreamDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(upstreamDir, "policy.rego"), []byte("package git"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add("policy.rego"); err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := worktree.Commit("initial", &git.CommitOptions{Author: signature}); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := NewServer(t.TempDir())
+	proxy.gitUpstream = func(path string) string {
+		if path != "example.com/org/policy.git" {
+			t.Fatalf("unexpected upstream path %s", path)
+		}
+		return upstreamDir
+	}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	clone := func() {
+		dir := t.TempDir()
+		_, err := git.PlainClone(dir, false, &git.CloneOptions{URL: server.URL + "/git/example.com/org/policy.git"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, err := os.ReadFile(filepath.Join(dir, "policy.rego"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != "package git" {
+			t.Fatalf("expected package git, got %s", string(bs))
+		}
+	}
+
+	clone()
+
+	// Once mirrored, the repository is served while upstream is unavailable
+	if err := os.RemoveAll(upstreamDir); err != nil {
+		t.Fatal(err)
+	}
+	clone()
+}
+
+func TestProxyRejectsEscapingPaths(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir()))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/v2/registry/org/policy/blobs/sha256:..%2F..%2Fsecret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected status 400, got %d", resp.StatusCode)
+	}
+}
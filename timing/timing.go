@@ -0,0 +1,189 @@
+// Package timing records how long the phases of a command take, such as resolving versions, fetching and refactoring
+// dependencies, and running OPA; for finding out what makes large updates slow.
+package timing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Phases of a command, in the order they're reported.
+const (
+	Resolve  = "resolve"
+	Fetch    = "fetch"
+	Copy     = "copy"
+	Refactor = "refactor"
+	Opa      = "opa"
+)
+
+var phases = []string{Resolve, Fetch, Copy, Refactor, Opa}
+
+// Event is a single timed occurrence of a phase.
+type Event struct {
+	Phase string `json:"phase"`
+	// Subject is what the phase was applied to, e.g. the location of a fetched dependency, or the OPA command run
+	Subject string `json:"subject,omitempty"`
+	// Start is the offset from when recording started
+	Start    time.Duration `json:"-"`
+	Duration time.Duration `json:"-"`
+}
+
+// PhaseSummary sums up the events of a phase. As dependencies are fetched concurrently, and some phases run within
+// others, e.g. OPA within refactoring, the sum of all phases may exceed the total.
+type PhaseSummary struct {
+	Phase   string        `json:"phase"`
+	Count   int           `json:"count"`
+	Total   time.Duration `json:"-"`
+	Max     time.Duration `json:"-"`
+	Slowest string        `json:"slowest,omitempty"`
+}
+
+var (
+	mu      sync.Mutex
+	enabled bool
+	started time.Time
+	events  []Event
+)
+
+// Enable starts recording; phases are only timed once enabled.
+func Enable() {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = true
+	started = time.Now()
+	events = nil
+}
+
+// Enabled reports whether phases are being timed.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return enabled
+}
+
+// Start starts timing a phase applied to subject, returning the function stopping it; e.g.
+// 'defer timing.Start(timing.Fetch, location)()'. A no-op unless recording is enabled.
+func Start(phase string, subject string) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	if !enabled {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, Event{Phase: phase, Subject: subject, Start: start.Sub(started), Duration: time.Since(start)})
+	}
+}
+
+// Events returns the recorded events, in the order they were stopped.
+func Events() []Event {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Event{}, events...)
+}
+
+// Summarize returns the summary of every phase with recorded events, in reporting order.
+func Summarize(events []Event) []PhaseSummary {
+	var summaries []PhaseSummary
+	for _, phase := range phases {
+		summary := PhaseSummary{Phase: phase}
+		for _, event := range events {
+			if event.Phase != phase {
+				continue
+			}
+			summary.Count++
+			summary.Total += event.Duration
+			if event.Duration > summary.Max || summary.Count == 1 {
+				summary.Max = event.Duration
+				summary.Slowest = event.Subject
+			}
+		}
+		if summary.Count > 0 {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries
+}
+
+// Report writes the recorded timings to w, in table or json format; total being the duration of the whole command.
+// The table lists the summary of every phase, followed by the slowest events; the json object holds every event.
+func Report(w io.Writer, format string, total time.Duration) error {
+	recorded := Events()
+	summaries := Summarize(recorded)
+
+	if format == "json" {
+		type jsonSummary struct {
+			PhaseSummary
+			TotalMs float64 `json:"totalMs"`
+			MaxMs   float64 `json:"maxMs"`
+		}
+		type jsonEvent struct {
+			Event
+			StartMs    float64 `json:"startMs"`
+			DurationMs float64 `json:"durationMs"`
+		}
+		report := struct {
+			TotalMs float64       `json:"totalMs"`
+			Phases  []jsonSummary `json:"phases"`
+			Events  []jsonEvent   `json:"events"`
+		}{TotalMs: millis(total), Phases: []jsonSummary{}, Events: []jsonEvent{}}
+		for _, s := range summaries {
+			report.Phases = append(report.Phases, jsonSummary{s, millis(s.Total), millis(s.Max)})
+		}
+		for _, e := range recorded {
+			report.Events = append(report.Events, jsonEvent{e, millis(e.Start), millis(e.Duration)})
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PHASE\tCOUNT\tTOTAL\tMAX\tSLOWEST")
+	for _, s := range summaries {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", s.Phase, s.Count, round(s.Total), round(s.Max), s.Slowest)
+	}
+	_, _ = fmt.Fprintf(tw, "total\t\t%s\t\t\n", round(total))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	slowest := append([]Event{}, recorded...)
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].Duration > slowest[j].Duration })
+	if len(slowest) > maxSlowestEvents {
+		slowest = slowest[:maxSlowestEvents]
+	}
+	if len(slowest) == 0 {
+		return nil
+	}
+	_, _ = fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SLOWEST\tPHASE\tDURATION")
+	for _, e := range slowest {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.TrimSpace(e.Subject), e.Phase, round(e.Duration))
+	}
+	return tw.Flush()
+}
+
+// maxSlowestEvents is the number of slowest events listed in the table.
+const maxSlowestEvents = 10
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// round rounds durations to milliseconds; or to microseconds, if shorter than a millisecond.
+func round(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,64 @@
+package timing
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	events := []Event{
+		{Phase: Opa, Subject: "refactor", Duration: 30 * time.Millisecond},
+		{Phase: Fetch, Subject: "oci://ghcr.io/org/a:1.0.0", Duration: time.Second},
+		{Phase: Fetch, Subject: "git+https://github.com/org/b.git", Duration: 2 * time.Second},
+		{Phase: Refactor, Subject: "b", Duration: 40 * time.Millisecond},
+	}
+
+	expected := []PhaseSummary{
+		{Phase: Fetch, Count: 2, Total: 3 * time.Second, Max: 2 * time.Second, Slowest: "git+https://github.com/org/b.git"},
+		{Phase: Refactor, Count: 1, Total: 40 * time.Millisecond, Max: 40 * time.Millisecond, Slowest: "b"},
+		{Phase: Opa, Count: 1, Total: 30 * time.Millisecond, Max: 30 * time.Millisecond, Slowest: "refactor"},
+	}
+	if actual := Summarize(events); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected:\n%+v\ngot:\n%+v", expected, actual)
+	}
+}
+
+func TestStart(t *testing.T) {
+	defer func() {
+		mu.Lock()
+		enabled, events = false, nil
+		mu.Unlock()
+	}()
+
+	Start(Fetch, "before")()
+	if len(Events()) != 0 {
+		t.Fatalf("expected no events before enabling, got %v", Events())
+	}
+
+	Enable()
+	Start(Fetch, "oci://ghcr.io/org/a:1.0.0")()
+	stop := Start(Opa, "test")
+	stop()
+
+	var actual []string
+	for _, event := range Events() {
+		actual = append(actual, event.Phase+" "+event.Subject)
+	}
+	expected := []string{"fetch oci://ghcr.io/org/a:1.0.0", "opa test"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+
+	for _, format := range []string{"table", "json"} {
+		var buf bytes.Buffer
+		if err := Report(&buf, format, time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "oci://ghcr.io/org/a:1.0.0") {
+			t.Fatalf("expected %s report to list fetch, got:\n%s", format, buf.String())
+		}
+	}
+}
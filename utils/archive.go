@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExtractTarGz extracts the gzipped tarball read from r into dstDir.
+// Entries resolving to locations outside dstDir are rejected.
+func ExtractTarGz(r io.Reader, dstDir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip stream: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar stream: %w", err)
+		}
+
+		target, err := archiveEntryPath(dstDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr); err != nil {
+				return err
+			}
+		default:
+			// Links and special files have no place in a policy bundle
+			continue
+		}
+	}
+}
+
+// ExtractZip extracts the zip archive at path into dstDir.
+// Entries resolving to locations outside dstDir are rejected.
+func ExtractZip(path string, dstDir string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive %s: %w", path, err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	for _, entry := range zr.File {
+		target, err := archiveEntryPath(dstDir, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		switch mode := entry.Mode(); {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			r, err := entry.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s of zip archive %s: %w", entry.Name, path, err)
+			}
+			err = writeFile(target, r)
+			_ = r.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			// Links and special files have no place in a policy bundle
+			continue
+		}
+	}
+	return nil
+}
+
+func archiveEntryPath(dstDir string, name string) (string, error) {
+	target := filepath.Join(dstDir, filepath.FromSlash(strings.TrimPrefix(name, "/")))
+	if target != filepath.Clean(dstDir) && !strings.HasPrefix(target, filepath.Clean(dstDir)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal file path in archive: %s", name)
+	}
+	return target, nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return nil
+}
+
+// CreateTarGz writes a gzipped tarball of the given files and directories to w. Entry names are relative to rootDir,
+// which all paths must be located within.
+func CreateTarGz(w io.Writer, rootDir string, paths []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				return nil
+			}
+
+			name, err := filepath.Rel(rootDir, file)
+			if err != nil {
+				return err
+			}
+			if name == "." {
+				return nil
+			}
+			if name == ".." || strings.HasPrefix(name, ".."+string(os.PathSeparator)) {
+				return fmt.Errorf("%s is not located within %s", file, rootDir)
+			}
+
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(name)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// AddToTarGz adds files to the gzipped tarball at archivePath, keeping its other entries. files maps entry names to the
+// paths of the files to add, replacing any existing entries of the same names.
+func AddToTarGz(archivePath string, files map[string]string) error {
+	src, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %w", archivePath, err)
+	}
+	defer func() { _ = src.Close() }()
+
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), filepath.Base(archivePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := copyTarGz(src, tmp, files); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to add files to archive %s: %w", archivePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	_ = src.Close()
+
+	return os.Rename(tmp.Name(), archivePath)
+}
+
+func copyTarGz(r io.Reader, w io.Writer, files map[string]string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gr.Close() }()
+	tr := tar.NewReader(gr)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := files[header.Name]; ok {
+			continue
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := addTarFile(tw, name, files[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func addTarFile(tw *tar.Writer, name string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     info.Size(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
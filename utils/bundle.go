@@ -0,0 +1,326 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const bundleManifestFile = ".manifest"
+
+var regoPackagePattern = regexp.MustCompile(`(?m)^\s*package\s+([^\s#]+)`)
+
+// bundleManifest is the part of an OPA bundle manifest declaring the bundle's roots and wasm modules.
+type bundleManifest struct {
+	Roots *[]string      `json:"roots"`
+	Wasm  []WasmResolver `json:"wasm"`
+}
+
+// WasmResolver maps an entrypoint of a bundle to the wasm module evaluating it.
+type WasmResolver struct {
+	Entrypoint string `json:"entrypoint"`
+	Module     string `json:"module"`
+}
+
+// readBundleFile returns the content of the named file in the bundle at path, a directory or a gzipped tarball; or nil
+// if there is no such file.
+func readBundleFile(bundlePath string, name string) ([]byte, error) {
+	name = strings.TrimPrefix(name, "/")
+	if IsDir(bundlePath) {
+		bs, err := os.ReadFile(filepath.Join(bundlePath, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return bs, err
+	}
+
+	var content []byte
+	err := walkTarGz(bundlePath, func(entry string, r io.Reader) error {
+		if strings.TrimPrefix(entry, "/") == name {
+			bs, err := io.ReadAll(r)
+			content = bs
+			return err
+		}
+		return nil
+	})
+	return content, err
+}
+
+// readBundleManifest returns the manifest of the bundle at path; or an empty manifest if there is none.
+func readBundleManifest(bundlePath string) (*bundleManifest, error) {
+	bs, err := readBundleFile(bundlePath, bundleManifestFile)
+	if err != nil || bs == nil {
+		return &bundleManifest{}, err
+	}
+	var m bundleManifest
+	if err := json.Unmarshal(bs, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest of bundle %s: %w", bundlePath, err)
+	}
+	return &m, nil
+}
+
+// ReadBundleRoots returns the roots declared by the manifest of the bundle at path, a directory or a gzipped tarball,
+// as slash-separated paths. Returns nil if there is no manifest, or if it doesn't declare roots; in which case the
+// bundle claims the entire data tree.
+func ReadBundleRoots(bundlePath string) ([]string, error) {
+	m, err := readBundleManifest(bundlePath)
+	if err != nil || m.Roots == nil {
+		return nil, err
+	}
+	roots := make([]string, 0, len(*m.Roots))
+	for _, root := range *m.Roots {
+		root = strings.Trim(root, "/")
+		if root == "" {
+			return nil, nil
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+// ReadWasmResolvers returns the wasm modules declared by the manifest of the bundle at path, by entrypoint.
+func ReadWasmResolvers(bundlePath string) ([]WasmResolver, error) {
+	m, err := readBundleManifest(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	return m.Wasm, nil
+}
+
+// IsCompiledBundle reports whether the gzipped tarball bundle at path holds a compiled plan or wasm modules, rather than
+// Rego source.
+func IsCompiledBundle(bundlePath string) (bool, error) {
+	compiled := false
+	err := walkTarGz(bundlePath, func(name string, _ io.Reader) error {
+		name = strings.TrimPrefix(name, "/")
+		compiled = compiled || name == "plan.json" || strings.HasSuffix(name, ".wasm")
+		return nil
+	})
+	return compiled, err
+}
+
+// MergeWasmModules adds the wasm modules of the compiled bundle at bundlePath to the bundle at archivePath, under the
+// slash-separated prefix, and declares their entrypoints and the bundle's roots in the manifest of the latter.
+// OPA's own bundle merging keeps the declared module paths while moving the modules, so can't be used for wasm modules.
+func MergeWasmModules(archivePath string, bundlePath string, prefix string) error {
+	wasm, err := ReadWasmResolvers(bundlePath)
+	if err != nil || len(wasm) == 0 {
+		return err
+	}
+	roots, err := ReadBundleRoots(bundlePath)
+	if err != nil {
+		return err
+	}
+	if roots == nil {
+		return fmt.Errorf("wasm bundle %s doesn't declare roots", bundlePath)
+	}
+
+	manifest := make(map[string]interface{})
+	if bs, err := readBundleFile(archivePath, bundleManifestFile); err != nil {
+		return err
+	} else if bs != nil {
+		if err := json.Unmarshal(bs, &manifest); err != nil {
+			return fmt.Errorf("failed to parse manifest of bundle %s: %w", archivePath, err)
+		}
+	}
+
+	// Bundles built without roots, or with the empty root, claim the entire data tree, and so must be narrowed down
+	var existingRoots []string
+	if declared, ok := manifest["roots"].([]interface{}); ok {
+		for _, root := range declared {
+			if root := strings.Trim(fmt.Sprint(root), "/"); root != "" {
+				existingRoots = append(existingRoots, root)
+			} else {
+				existingRoots = nil
+				break
+			}
+		}
+	}
+	if existingRoots == nil {
+		if existingRoots, err = contentRoots(archivePath); err != nil {
+			return err
+		}
+	}
+	for _, root := range roots {
+		for _, existing := range existingRoots {
+			if RootsOverlap(root, existing) {
+				return fmt.Errorf("root %s of wasm bundle %s overlaps root %s of bundle %s", root, bundlePath, existing, archivePath)
+			}
+		}
+	}
+	manifest["roots"] = append(existingRoots, roots...)
+
+	tmpDir, err := os.MkdirTemp("", "odm-wasm-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	files := make(map[string]string)
+	resolvers, _ := manifest["wasm"].([]interface{})
+	for i, resolver := range wasm {
+		name := path.Join("/", prefix, strings.TrimPrefix(resolver.Module, "/"))
+		if _, ok := files[name]; !ok {
+			bs, err := readBundleFile(bundlePath, resolver.Module)
+			if err != nil {
+				return err
+			}
+			if bs == nil {
+				return fmt.Errorf("wasm module %s of bundle %s not found", resolver.Module, bundlePath)
+			}
+			modulePath := filepath.Join(tmpDir, fmt.Sprintf("%d.wasm", i))
+			if err := os.WriteFile(modulePath, bs, 0644); err != nil {
+				return err
+			}
+			files[name] = modulePath
+		}
+		resolvers = append(resolvers, WasmResolver{Entrypoint: resolver.Entrypoint, Module: name})
+	}
+	manifest["wasm"] = resolvers
+
+	bs, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(tmpDir, bundleManifestFile)
+	if err := os.WriteFile(manifestPath, bs, 0644); err != nil {
+		return err
+	}
+	files["/"+bundleManifestFile] = manifestPath
+
+	return AddToTarGz(archivePath, files)
+}
+
+// RootsOverlap reports whether two slash-separated bundle roots overlap; i.e. if they are equal, or one is nested in the
+// other. The empty root overlaps all roots.
+func RootsOverlap(a string, b string) bool {
+	return a == "" || b == "" || a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// contentRoots returns the smallest set of roots covering the packages and data of the gzipped tarball bundle at path.
+func contentRoots(bundlePath string) ([]string, error) {
+	var roots []string
+	err := walkTarGz(bundlePath, func(name string, r io.Reader) error {
+		name = strings.TrimPrefix(name, "/")
+		switch {
+		case strings.HasSuffix(name, ".rego"):
+			bs, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if match := regoPackagePattern.FindSubmatch(bs); match != nil {
+				roots = append(roots, strings.ReplaceAll(string(match[1]), ".", "/"))
+			}
+		case path.Base(name) == "data.json":
+			var data map[string]interface{}
+			if err := json.NewDecoder(r).Decode(&data); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			dir := path.Dir(name)
+			for key := range data {
+				if dir == "." {
+					roots = append(roots, key)
+				} else {
+					roots = append(roots, dir+"/"+key)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop roots nested in other roots, as OPA rejects manifests with overlapping roots
+	sort.Strings(roots)
+	var distinct []string
+	for _, root := range roots {
+		if n := len(distinct); n > 0 && RootsOverlap(distinct[n-1], root) {
+			continue
+		}
+		distinct = append(distinct, root)
+	}
+	return distinct, nil
+}
+
+// TarGzSizes returns the uncompressed sizes of all regular files in the gzipped tarball at path, by entry name.
+func TarGzSizes(archivePath string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	err := walkTarGz(archivePath, func(name string, r io.Reader) error {
+		n, err := io.Copy(io.Discard, r)
+		sizes[name] = n
+		return err
+	})
+	return sizes, err
+}
+
+// ReadBundlePolicies returns the Rego files of the bundle at path, a gzipped tarball, by slash-separated path relative to
+// the bundle root.
+func ReadBundlePolicies(bundlePath string) (map[string]string, error) {
+	policies := make(map[string]string)
+	err := walkTarGz(bundlePath, func(name string, r io.Reader) error {
+		if path.Ext(name) != ".rego" {
+			return nil
+		}
+		bs, err := io.ReadAll(r)
+		policies[strings.TrimPrefix(name, "/")] = string(bs)
+		return err
+	})
+	return policies, err
+}
+
+// ReadBundleData returns the data files of the bundle at path, a gzipped tarball, named data.json, data.yaml or
+// data.yml; by slash-separated path relative to the bundle root.
+func ReadBundleData(bundlePath string) (map[string][]byte, error) {
+	data := make(map[string][]byte)
+	err := walkTarGz(bundlePath, func(name string, r io.Reader) error {
+		switch path.Base(name) {
+		case "data.json", "data.yaml", "data.yml":
+			bs, err := io.ReadAll(r)
+			data[strings.TrimPrefix(name, "/")] = bs
+			return err
+		}
+		return nil
+	})
+	return data, err
+}
+
+// walkTarGz calls f for every regular file in the gzipped tarball at path.
+func walkTarGz(archivePath string, f func(name string, r io.Reader) error) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", archivePath, err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := f(header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBundleRoots(t *testing.T) {
+	tests := []struct {
+		note          string
+		files         map[string]string
+		expectedRoots []string
+		expectedSrc   []string
+	}{
+		{
+			note: "declared roots",
+			files: map[string]string{
+				"/.manifest":          `{"roots": ["lib/http", "/partial/"]}`,
+				"/optimized/lib.rego": "package lib.http\n\nallow := true",
+			},
+			expectedRoots: []string{"lib/http", "partial"},
+			expectedSrc:   []string{"lib/http"},
+		},
+		{
+			note: "no manifest",
+			files: map[string]string{
+				"/main.rego":           "package main\n\nallow := true",
+				"/main/nested.rego":    "package main.nested\n\nallow := true",
+				"/config/data.json":    `{"timeout": 10, "retries": 3}`,
+				"/lib/http/utils.rego": "# comment\npackage lib.http.utils\n\nx := 1",
+			},
+			expectedRoots: nil,
+			expectedSrc:   []string{"config/retries", "config/timeout", "lib/http/utils", "main"},
+		},
+		{
+			note: "empty root",
+			files: map[string]string{
+				"/.manifest": `{"roots": ["lib", ""]}`,
+			},
+			expectedRoots: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+			writeTestTarGz(t, path, tc.files)
+
+			roots, err := ReadBundleRoots(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(roots, tc.expectedRoots) {
+				t.Fatalf("expected roots %v, got %v", tc.expectedRoots, roots)
+			}
+
+			content, err := contentRoots(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(content, tc.expectedSrc) {
+				t.Fatalf("expected content roots %v, got %v", tc.expectedSrc, content)
+			}
+		})
+	}
+}
+
+func TestRootsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"lib", "lib", true},
+		{"lib", "lib/http", true},
+		{"lib/http", "lib", true},
+		{"", "lib", true},
+		{"lib", "libs", false},
+		{"lib/http", "lib/jwt", false},
+	}
+
+	for _, tc := range tests {
+		if actual := RootsOverlap(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("expected RootsOverlap(%q, %q) to be %v, got %v", tc.a, tc.b, tc.expected, actual)
+		}
+	}
+}
+
+func writeTestTarGz(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeWasmModules(t *testing.T) {
+	tests := []struct {
+		note             string
+		files            map[string]string
+		expectedManifest string
+		expectedErr      bool
+	}{
+		{
+			note: "no manifest",
+			files: map[string]string{
+				"/src/main.rego": "package main\n\nallow := true",
+			},
+			expectedManifest: `{"roots":["main","authz"],"wasm":[{"entrypoint":"authz/allow","module":"/wasm/authz/policy.wasm"},{"entrypoint":"authz/deny","module":"/wasm/authz/policy.wasm"}]}`,
+		},
+		{
+			note: "manifest with wasm modules",
+			files: map[string]string{
+				"/.manifest":     `{"revision":"abc","roots":[""],"wasm":[{"entrypoint":"main/allow","module":"/policy.wasm"}]}`,
+				"/src/main.rego": "package main\n\nallow := true",
+				"/policy.wasm":   "\x00asm\x01\x00\x00\x00",
+			},
+			expectedManifest: `{"revision":"abc","roots":["main","authz"],"wasm":[{"entrypoint":"main/allow","module":"/policy.wasm"},{"entrypoint":"authz/allow","module":"/wasm/authz/policy.wasm"},{"entrypoint":"authz/deny","module":"/wasm/authz/policy.wasm"}]}`,
+		},
+		{
+			note: "overlapping roots",
+			files: map[string]string{
+				"/.manifest":      `{"roots":["authz/internal"]}`,
+				"/src/authz.rego": "package authz.internal\n\nallow := true",
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			dir := t.TempDir()
+			bundleDir := filepath.Join(dir, "authz")
+			if err := os.MkdirAll(bundleDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			manifest := `{"roots":["authz"],"wasm":[{"entrypoint":"authz/allow","module":"/policy.wasm"},{"entrypoint":"authz/deny","module":"/policy.wasm"}]}`
+			if err := os.WriteFile(filepath.Join(bundleDir, ".manifest"), []byte(manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(bundleDir, "policy.wasm"), []byte("\x00asm\x01\x00\x00\x00"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			archivePath := filepath.Join(dir, "bundle.tar.gz")
+			writeTestTarGz(t, archivePath, tc.files)
+
+			err := MergeWasmModules(archivePath, bundleDir, "wasm/authz")
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual, err := readBundleFile(archivePath, ".manifest"); err != nil {
+				t.Fatal(err)
+			} else if string(actual) != tc.expectedManifest {
+				t.Fatalf("expected manifest:\n%s\n\ngot:\n%s", tc.expectedManifest, actual)
+			}
+			if module, err := readBundleFile(archivePath, "/wasm/authz/policy.wasm"); err != nil {
+				t.Fatal(err)
+			} else if module == nil {
+				t.Fatal("expected wasm module in bundle")
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gitPath returns the location of the git CLI, used for operations go-git doesn't support.
+func gitPath() string {
+	return toolPath("GIT_PATH", "git")
+}
+
+// GitAvailable returns true if the git CLI can be found.
+func GitAvailable() bool {
+	_, err := exec.LookPath(gitPath())
+	return err == nil
+}
+
+// RunGit runs the git CLI with the given args in dir, or the working directory if empty, returning its output. Git
+// never prompts for credentials, as ODM may run non-interactively, and is killed if exceeding the configured timeout.
+func RunGit(dir string, args ...string) (string, error) {
+	printer.Debug("Executing git in '%s' with args: %s", dir, args)
+	ctx, cancel := FetchContext()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, gitPath(), args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("git %s: %w", args[0], ctx.Err())
+		}
+		if msg := strings.TrimSpace(errb.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return outb.String(), nil
+}
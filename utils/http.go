@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"io"
+	"net/http"
+	"os"
+)
+
+// DownloadFile downloads the resource at url to the file at dst.
+func DownloadFile(url string, dst string) error {
+	_, _, err := DownloadFileIfModified(url, dst, Validators{}, nil)
+	return err
+}
+
+// Validators are the validators of a downloaded HTTP resource, for conditionally downloading it again.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// IsZero reports whether no validators were returned for the resource.
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// DownloadFileIfModified downloads the resource at url to the file at dst, unless the server reports it unchanged since
+// the download that returned validators; in which case false is returned, and dst isn't written. The validators of the
+// downloaded resource are returned. The request carries the given header, if any; without an Authorization header, it
+// is authenticated with the netrc credential of the host.
+func DownloadFileIfModified(url string, dst string, validators Validators, header http.Header) (Validators, bool, error) {
+	printer.Debug("Downloading %s to %s", url, dst)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return validators, false, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	authorize(req)
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return validators, false, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotModified && !validators.IsZero() {
+		printer.Debug("%s not modified", url)
+		return validators, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return validators, false, fmt.Errorf("failed to download %s: %w", url, NewStatusError(resp))
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return validators, false, fmt.Errorf("failed to create file %s: %w", dst, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return validators, false, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, true, nil
+}
+
+// DownloadBytes downloads the resource at url into memory.
+func DownloadBytes(url string) ([]byte, error) {
+	printer.Debug("Downloading %s", url)
+
+	resp, err := httpGet(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %w", url, NewStatusError(resp))
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// StatusError is the error of an HTTP request answered with an unexpected status.
+type StatusError struct {
+	Status     string
+	StatusCode int
+}
+
+// NewStatusError returns the error of the unexpected status of resp.
+func NewStatusError(resp *http.Response) *StatusError {
+	return &StatusError{Status: resp.Status, StatusCode: resp.StatusCode}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+// Transient reports whether the status may not recur when retrying the request: server errors, timeouts and rate
+// limits.
+func (e *StatusError) Transient() bool {
+	return IsTransientStatus(e.StatusCode)
+}
+
+// IsTransientStatus reports whether the HTTP status code may not recur when retrying the request.
+func IsTransientStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// httpGet issues a GET request to url, authenticated with the netrc credential of its host, if any.
+func httpGet(url string) (*http.Response, error) {
+	return httpDo(http.MethodGet, url, nil)
+}
+
+func httpDo(method string, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	authorize(req)
+	return http.DefaultClient.Do(req)
+}
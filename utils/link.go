@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LinkFile materializes the file src at dst, sharing storage with src where the filesystem allows it: as a
+// copy-on-write clone (reflink) where supported, else as a hardlink, else as a plain copy. An existing dst is replaced.
+// Hardlinked files share their content and permissions with src, and must not be written in place; see UnshareFiles.
+func LinkFile(src string, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := reflink(src, dst); err == nil {
+		return nil
+	}
+	if hardlinks {
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		}
+	}
+	return copyFile(src, dst)
+}
+
+// UnshareFiles gives the files within paths that share storage with other files, or are read-only, a private, writable
+// copy of their content; so that they can be rewritten in place without affecting the files they were linked from.
+func UnshareFiles(paths ...string) error {
+	for _, path := range paths {
+		err := filepath.Walk(path, func(path string, info fs.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			if linkCount(info) <= 1 && info.Mode().Perm()&0200 != 0 {
+				return nil
+			}
+			tmp := path + ".odm-unshare"
+			if err := copyFile(path, tmp); err != nil {
+				return err
+			}
+			if err := os.Rename(tmp, path); err != nil {
+				_ = os.Remove(tmp)
+				return fmt.Errorf("failed to unshare file %s: %w", path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", src, err)
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to write file %s: %w", dst, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// reflink creates dst as a copy-on-write clone of src, failing on filesystems without support for it.
+func reflink(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package utils
+
+import "errors"
+
+func reflink(string, string) error {
+	return errors.New("reflinks not supported")
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package utils
+
+import "io/fs"
+
+// Hardlinks aren't used where link counts can't be read, as shared files couldn't be told apart to unshare them
+const hardlinks = false
+
+func linkCount(fs.FileInfo) uint64 {
+	return 1
+}
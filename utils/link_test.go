@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinkFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.rego")
+	dst := filepath.Join(dir, "dst.rego")
+	if err := os.WriteFile(src, []byte("package src"), 0444); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("package stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LinkFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := os.ReadFile(dst); err != nil || string(bs) != "package src" {
+		t.Fatalf("expected linked content, got %s (%v)", bs, err)
+	}
+
+	if err := UnshareFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("package dst"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := os.ReadFile(src); err != nil || string(bs) != "package src" {
+		t.Fatalf("expected source to be untouched, got %s (%v)", bs, err)
+	}
+}
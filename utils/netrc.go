@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"github.com/johanfylling/odm/keyring"
+	"github.com/johanfylling/odm/printer"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// NetrcCredential returns the login and password of the host in the user's netrc file: the file named by the NETRC
+// environment variable, or else ~/.netrc (or ~/_netrc on Windows). Entries for the host take precedence over the
+// default entry, if any; as for curl.
+func NetrcCredential(host string) (login string, password string, ok bool) {
+	path := netrcPath()
+	if path == "" {
+		return "", "", false
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false
+	}
+	login, password, ok = parseNetrc(string(bs), host)
+	if ok {
+		printer.Debug("Using credential for %s from %s", host, path)
+	}
+	return
+}
+
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(home, ".netrc")
+	if runtime.GOOS == "windows" && !FileExists(path) {
+		path = filepath.Join(home, "_netrc")
+	}
+	return path
+}
+
+// parseNetrc returns the login and password of the machine entry of host, or else of the default entry.
+func parseNetrc(content string, host string) (string, string, bool) {
+	type entry struct {
+		machine, login, password string
+	}
+	var entries []*entry
+	var current *entry
+
+	fields := netrcFields(content)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				current = &entry{machine: strings.ToLower(fields[i])}
+				entries = append(entries, current)
+			}
+		case "default":
+			current = &entry{}
+			entries = append(entries, current)
+		case "login", "password", "account":
+			if i+1 >= len(fields) {
+				continue
+			}
+			i++
+			if current == nil {
+				continue
+			}
+			if fields[i-1] == "login" {
+				current.login = fields[i]
+			} else if fields[i-1] == "password" {
+				current.password = fields[i]
+			}
+		}
+	}
+
+	var fallback *entry
+	for _, e := range entries {
+		if e.machine == strings.ToLower(host) {
+			return e.login, e.password, true
+		}
+		if e.machine == "" && fallback == nil {
+			fallback = e
+		}
+	}
+	if fallback != nil {
+		return fallback.login, fallback.password, true
+	}
+	return "", "", false
+}
+
+// netrcFields splits the netrc content into its tokens, dropping comments and macro definitions, which run until the
+// next empty line.
+func netrcFields(content string) []string {
+	var fields []string
+	inMacro := false
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		lineFields := strings.Fields(line)
+		for i, field := range lineFields {
+			if field == "macdef" {
+				inMacro = true
+				lineFields = lineFields[:i]
+				break
+			}
+		}
+		fields = append(fields, lineFields...)
+	}
+	return fields
+}
+
+// authorize sets the basic authentication of the request to the credential of the credential helper configured for its
+// host, or else to the netrc credential of its host; unless it already carries credentials.
+func authorize(req *http.Request) {
+	if req.URL.User != nil || req.Header.Get("Authorization") != "" {
+		return
+	}
+	if credential, ok := keyring.HelperCredential(req.URL.Host); ok {
+		req.SetBasicAuth(credential.Username, credential.Secret)
+	} else if login, password, ok := NetrcCredential(req.URL.Hostname()); ok {
+		req.SetBasicAuth(login, password)
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	content := `# credentials
+machine git.example.com
+  login alice
+  password s3cr#t
+
+machine files.example.com login bob password hunter2 macdef init
+cd /pub
+machine ignored.example.com login mallory password macro
+
+default login anonymous password guest
+`
+	tests := []struct {
+		note             string
+		host             string
+		expectedLogin    string
+		expectedPassword string
+	}{
+		{note: "multi-line entry", host: "git.example.com", expectedLogin: "alice", expectedPassword: "s3cr#t"},
+		{note: "single-line entry", host: "FILES.example.com", expectedLogin: "bob", expectedPassword: "hunter2"},
+		{note: "macro skipped", host: "ignored.example.com", expectedLogin: "anonymous", expectedPassword: "guest"},
+		{note: "default", host: "other.example.com", expectedLogin: "anonymous", expectedPassword: "guest"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			login, password, ok := parseNetrc(content, tc.host)
+			if !ok || login != tc.expectedLogin || password != tc.expectedPassword {
+				t.Fatalf("expected %s:%s, got %s:%s (%v)", tc.expectedLogin, tc.expectedPassword, login, password, ok)
+			}
+		})
+	}
+
+	if _, _, ok := parseNetrc("machine git.example.com login alice password secret", "other.example.com"); ok {
+		t.Fatalf("expected no credential without default entry")
+	}
+}
+
+func TestDownloadFileNetrc(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "alice" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	netrc := filepath.Join(dir, "netrc")
+	if err := os.WriteFile(netrc, []byte("machine 127.0.0.1 login alice password secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+
+	dst := filepath.Join(dir, "downloaded")
+	if err := DownloadFile(server.URL+"/bundle.tar.gz", dst); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := os.ReadFile(dst); err != nil || string(bs) != "content" {
+		t.Fatalf("expected content, got %s (%v)", bs, err)
+	}
+}
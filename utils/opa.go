@@ -1,23 +1,37 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/johanfylling/odm/printer"
+	"github.com/johanfylling/odm/timing"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
 )
 
 type Opa struct {
 	location      string
+	dir           string
 	dataLocations []string
+	bundles       []string
 	entrypoints   []string
 	target        string
+	schema        string
+	profile       bool
+	profileSort   string
+	profileLimit  int
+	metrics       bool
 }
 
 func NewOpa(dataLocations ...string) *Opa {
-	location, ok := os.LookupEnv("OPA_PATH")
-	if !ok {
-		location = "opa"
+	location := "opa"
+	if current, ok := currentOpaPath(); ok {
+		location = current
 	}
+	location = toolPath("OPA_PATH", location)
 
 	printer.Debug("Creating OPA instance\nlocation: %s\ndata: %v", location, dataLocations)
 
@@ -27,44 +41,85 @@ func NewOpa(dataLocations ...string) *Opa {
 	}
 }
 
+// WithDir runs OPA in dir, against which relative data locations, bundles and output paths are resolved.
+func (o *Opa) WithDir(dir string) *Opa {
+	o.dir = dir
+	return o
+}
+
+// WithBundles loads the given compiled bundles alongside the data locations, which are then merged by OPA, as opposed to
+// being loaded as plain files.
+func (o *Opa) WithBundles(bundles []string) *Opa {
+	cpy := *o
+	cpy.bundles = bundles
+	return &cpy
+}
+
 func (o *Opa) WithEntrypoints(entrypoints []string) *Opa {
 	cpy := *o
 	cpy.entrypoints = entrypoints
 	return &cpy
 }
 
+// WithSchema type-checks the input and data documents against the JSON schema file, or directory of schema files, at
+// the given path; unless empty.
+func (o *Opa) WithSchema(path string) *Opa {
+	cpy := *o
+	cpy.schema = path
+	return &cpy
+}
+
 func (o *Opa) WithTarget(target string) *Opa {
 	cpy := *o
 	cpy.target = target
 	return &cpy
 }
 
+// WithProfile enables query profiling, sorting the profile by the given criteria and limiting it to the given
+// number of entries. The output format defaults to 'pretty', for the profile to be rendered as a table.
+func (o *Opa) WithProfile(sort string, limit int) *Opa {
+	cpy := *o
+	cpy.profile = true
+	cpy.profileSort = sort
+	cpy.profileLimit = limit
+	return &cpy
+}
+
+// WithMetrics enables reporting of query performance metrics.
+func (o *Opa) WithMetrics() *Opa {
+	cpy := *o
+	cpy.metrics = true
+	return &cpy
+}
+
 func (o *Opa) Eval(passThroughArgs ...string) (string, error) {
 	printer.Info("Running OPA eval")
 
-	opaArgs := make([]string, 0, 1+2*len(o.dataLocations)+len(passThroughArgs))
-	opaArgs = append(opaArgs, "eval")
+	opaArgs := make([]string, 0, 2*len(o.dataLocations)+len(passThroughArgs))
 
 	for _, location := range o.dataLocations {
 		opaArgs = append(opaArgs, "-d", location)
 	}
-	opaArgs = append(opaArgs, passThroughArgs...)
+	for _, bundle := range o.bundles {
+		opaArgs = append(opaArgs, "-b", bundle)
+	}
+	opaArgs = append(opaArgs, o.performanceFlags(passThroughArgs)...)
+	opaArgs = append(opaArgs, prefixSchema(o.schema, passThroughArgs)...)
 
-	return RunCommand(o.location, opaArgs...)
+	return runOpaCommand(o.dir, o.location, "eval", opaArgs...)
 }
 
 func (o *Opa) Test(passThroughArgs ...string) (string, error) {
 	printer.Info("Running OPA test")
 
-	opaArgs := make([]string, 0, 1+len(o.dataLocations)+len(passThroughArgs))
-	opaArgs = append(opaArgs, "test")
+	opaArgs := make([]string, 0, len(o.dataLocations)+len(passThroughArgs))
 
 	for _, location := range o.dataLocations {
 		opaArgs = append(opaArgs, location)
 	}
-	opaArgs = append(opaArgs, passThroughArgs...)
+	opaArgs = append(opaArgs, prefixSchema(o.schema, passThroughArgs)...)
 
-	return RunCommand(o.location, opaArgs...)
+	return runOpaCommand(o.dir, o.location, "test", opaArgs...)
 }
 
 func (o *Opa) Build(outputPath string, passThroughFlags ...string) (string, error) {
@@ -74,17 +129,109 @@ func (o *Opa) Build(outputPath string, passThroughFlags ...string) (string, erro
 	opaArgs := prefixEntrypoints(o.entrypoints, passThroughFlags)
 	opaArgs = prefixOutput(outputPath, opaArgs)
 	opaArgs = prefixTarget(o.target, opaArgs)
+	if len(o.bundles) > 0 {
+		return o.buildWithBundles(opaArgs)
+	}
 	// locations must be first in the list of arguments, so prefixed last
 	opaArgs = prefixDataLocations(o.dataLocations, opaArgs, false)
 
-	return runOpaCommand(o.location, "build", opaArgs...)
+	return runOpaCommand(o.dir, o.location, "build", opaArgs...)
+}
+
+// buildWithBundles builds the data locations into an intermediate bundle, which is then merged with the compiled
+// bundles. OPA only merges bundles declaring non-overlapping roots, so the intermediate bundle is given a manifest with
+// roots covering its packages and data.
+func (o *Opa) buildWithBundles(flags []string) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "odm-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary build directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	bundles := o.bundles
+	if len(o.dataLocations) > 0 {
+		sourcePath := filepath.Join(tmpDir, "source.tar.gz")
+		printer.Debug("Building source bundle %s", sourcePath)
+		if _, err := runOpaCommand(o.dir, o.location, "build", prefixDataLocations(o.dataLocations, []string{"-o", sourcePath}, false)...); err != nil {
+			return "", err
+		}
+
+		roots, err := contentRoots(sourcePath)
+		if err != nil {
+			return "", err
+		}
+		if len(roots) == 0 {
+			printer.Debug("Source bundle is empty, building from compiled bundles only")
+			return runOpaCommand(o.dir, o.location, "build", append([]string{"-b"}, prefixDataLocations(bundles, flags, false)...)...)
+		}
+		manifest, err := json.Marshal(map[string][]string{"roots": roots})
+		if err != nil {
+			return "", err
+		}
+		manifestPath := filepath.Join(tmpDir, bundleManifestFile)
+		if err := os.WriteFile(manifestPath, manifest, 0644); err != nil {
+			return "", err
+		}
+		if err := AddToTarGz(sourcePath, map[string]string{"/" + bundleManifestFile: manifestPath}); err != nil {
+			return "", err
+		}
+		bundles = append([]string{sourcePath}, bundles...)
+	}
+
+	opaArgs := append([]string{"-b"}, prefixDataLocations(bundles, flags, false)...)
+	return runOpaCommand(o.dir, o.location, "build", opaArgs...)
 }
 
+// Inspect runs 'opa inspect' on the bundle at bundlePath.
+func (o *Opa) Inspect(bundlePath string, passThroughFlags ...string) (string, error) {
+	printer.Info("Running OPA inspect")
+
+	opaArgs := append([]string{bundlePath}, passThroughFlags...)
+	return runOpaCommand(o.dir, o.location, "inspect", opaArgs...)
+}
+
+// Exec runs 'opa exec' for the given decision over the given input files, with the provided bundle loaded.
+func (o *Opa) Exec(bundlePath string, decision string, inputs []string, passThroughFlags ...string) (string, error) {
+	printer.Info("Running OPA exec")
+
+	opaArgs := make([]string, 0, 4+len(inputs)+len(passThroughFlags))
+	opaArgs = append(opaArgs, "--decision", decision, "--bundle", bundlePath)
+	opaArgs = append(opaArgs, passThroughFlags...)
+	opaArgs = append(opaArgs, inputs...)
+
+	return runOpaCommand(o.dir, o.location, "exec", opaArgs...)
+}
+
+// Run starts 'opa run' with the data locations loaded, returning the running process.
+// The process' output is forwarded to the output of ODM.
+func (o *Opa) Run(passThroughArgs ...string) (*exec.Cmd, error) {
+	printer.Info("Running OPA run")
+
+	opaArgs := prefixDataLocations(o.dataLocations, passThroughArgs, false)
+	opaArgs = append([]string{"run"}, opaArgs...)
+
+	printer.Debug("Executing '%s' with args: %s", o.location, opaArgs)
+	cmd := exec.Command(o.location, opaArgs...)
+	cmd.Dir = o.dir
+	cmd.Stdout = printer.PrintWriter
+	cmd.Stderr = printer.LogWriter
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start opa: %w", err)
+	}
+	return cmd, nil
+}
+
+// Refactor moves fromPackage to toPackage, rewriting the files of the data locations in place. Files sharing storage
+// with others, such as those linked from the ODM cache, are unshared first.
 func (o *Opa) Refactor(fromPackage, toPackage string) error {
 	printer.Info("Running OPA refactor")
 	printer.Debug("From package: %s", fromPackage)
 	printer.Debug("To package: %s", toPackage)
 
+	if err := UnshareFiles(o.dataLocations...); err != nil {
+		return err
+	}
+
 	mapping := fmt.Sprintf("%s:%s", fromPackage, toPackage)
 
 	opaArgs := make([]string, 0, 4+len(o.dataLocations))
@@ -92,16 +239,80 @@ func (o *Opa) Refactor(fromPackage, toPackage string) error {
 	opaArgs = append(opaArgs, o.dataLocations...)
 	opaArgs = append(opaArgs, "-w", "-p", mapping)
 
-	_, err := runOpaCommand(o.location, "refactor", opaArgs...)
+	_, err := runOpaCommand(o.dir, o.location, "refactor", opaArgs...)
 	return err
 }
 
-func runOpaCommand(opaLocation string, command string, flags ...string) (string, error) {
+var (
+	versionsMu sync.Mutex
+	versions   = make(map[string]string)
+)
+
+// Version returns the version of the OPA binary, as reported by 'opa version'. Versions are looked up once per binary.
+func (o *Opa) Version() (string, error) {
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+	if version, ok := versions[o.location]; ok {
+		return version, nil
+	}
+
+	output, err := runOpaCommand(o.dir, o.location, "version")
+	if err != nil {
+		return "", fmt.Errorf("failed to determine OPA version: %w", err)
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if version, ok := strings.CutPrefix(line, "Version:"); ok {
+			versions[o.location] = strings.TrimSpace(version)
+			return versions[o.location], nil
+		}
+	}
+	return "", fmt.Errorf("failed to determine OPA version: unexpected output %q", output)
+}
+
+func (o *Opa) performanceFlags(passThroughArgs []string) []string {
+	var flags []string
+	if o.profile {
+		flags = append(flags, "--profile")
+		if o.profileSort != "" && !hasFlag(passThroughArgs, "--profile-sort") {
+			flags = append(flags, "--profile-sort", o.profileSort)
+		}
+		if o.profileLimit > 0 && !hasFlag(passThroughArgs, "--profile-limit") {
+			flags = append(flags, "--profile-limit", fmt.Sprintf("%d", o.profileLimit))
+		}
+	}
+	if o.metrics {
+		flags = append(flags, "--metrics")
+	}
+	if (o.profile || o.metrics) && !hasFlag(passThroughArgs, "-f", "--format") {
+		flags = append(flags, "--format", "pretty")
+	}
+	return flags
+}
+
+// hasFlag reports whether any of the named flags is set in args: as a separate argument, as in '--format json';
+// joined by '=', as in '--format=json'; or, for single-letter flags, joined with the value, as in '-fjson'.
+func hasFlag(args []string, names ...string) bool {
+	for _, arg := range args {
+		for _, name := range names {
+			if arg == name || strings.HasPrefix(arg, name+"=") {
+				return true
+			}
+			if len(name) == 2 && name[0] == '-' && strings.HasPrefix(arg, name) && !strings.HasPrefix(arg, "--") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func runOpaCommand(dir string, opaLocation string, command string, flags ...string) (string, error) {
+	defer timing.Start(timing.Opa, command)()
+
 	opaArgs := make([]string, 0, 1+len(flags))
 	opaArgs = append(opaArgs, command)
 	opaArgs = append(opaArgs, flags...)
 
-	return RunCommand(opaLocation, opaArgs...)
+	return RunCommandIn(dir, opaLocation, opaArgs...)
 }
 
 func prefixDataLocations(dataLocations []string, flags []string, namedFlag bool) []string {
@@ -155,3 +366,14 @@ func prefixTarget(target string, flags []string) []string {
 
 	return append(newFlags, flags...)
 }
+
+func prefixSchema(schema string, flags []string) []string {
+	if schema == "" {
+		return flags
+	}
+	if Contains(flags, "-s") || Contains(flags, "--schema") {
+		printer.Debug("Schema present on pass-through flags to OPA, ignoring configured schema")
+		return flags
+	}
+	return append([]string{"--schema", schema}, flags...)
+}
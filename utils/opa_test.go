@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPerformanceFlags(t *testing.T) {
+	tests := []struct {
+		note     string
+		opa      *Opa
+		args     []string
+		expected []string
+	}{
+		{
+			note: "no profiling",
+			opa:  NewOpa(),
+		},
+		{
+			note:     "profile",
+			opa:      NewOpa().WithProfile("total_time_ns", 10),
+			expected: []string{"--profile", "--profile-sort", "total_time_ns", "--profile-limit", "10", "--format", "pretty"},
+		},
+		{
+			note:     "profile, sort and format on pass-through args",
+			opa:      NewOpa().WithProfile("total_time_ns", 0),
+			args:     []string{"--profile-sort", "num_eval", "--format", "json"},
+			expected: []string{"--profile"},
+		},
+		{
+			note:     "sort, limit and format joined on pass-through args",
+			opa:      NewOpa().WithProfile("total_time_ns", 10).WithMetrics(),
+			args:     []string{"--profile-sort=num_eval", "--profile-limit=5", "--format=json"},
+			expected: []string{"--profile", "--metrics"},
+		},
+		{
+			note:     "short format with value on pass-through args",
+			opa:      NewOpa().WithMetrics(),
+			args:     []string{"-fjson"},
+			expected: []string{"--metrics"},
+		},
+		{
+			note:     "metrics",
+			opa:      NewOpa().WithMetrics(),
+			expected: []string{"--metrics", "--format", "pretty"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			flags := tc.opa.performanceFlags(tc.args)
+			if !reflect.DeepEqual(flags, tc.expected) {
+				t.Fatalf("expected flags:\n\n%v\n\ngot:\n\n%v", tc.expected, flags)
+			}
+		})
+	}
+}
+
+func TestPrefixSchema(t *testing.T) {
+	tests := []struct {
+		note     string
+		schema   string
+		args     []string
+		expected []string
+	}{
+		{
+			note:     "no schema",
+			args:     []string{"data.main.allow"},
+			expected: []string{"data.main.allow"},
+		},
+		{
+			note:     "schema",
+			schema:   "/proj/schemas",
+			args:     []string{"data.main.allow"},
+			expected: []string{"--schema", "/proj/schemas", "data.main.allow"},
+		},
+		{
+			note:     "schema on pass-through args",
+			schema:   "/proj/schemas",
+			args:     []string{"-s", "other.json", "data.main.allow"},
+			expected: []string{"-s", "other.json", "data.main.allow"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			if args := prefixSchema(tc.schema, tc.args); !reflect.DeepEqual(args, tc.expected) {
+				t.Fatalf("expected args:\n\n%v\n\ngot:\n\n%v", tc.expected, args)
+			}
+		})
+	}
+}
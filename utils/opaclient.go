@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OpaClient is a minimal client for the OPA REST API.
+type OpaClient struct {
+	baseUrl string
+	token   string
+	client  *http.Client
+}
+
+func NewOpaClient(baseUrl string, token string) *OpaClient {
+	return &OpaClient{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		token:   token,
+		client:  http.DefaultClient,
+	}
+}
+
+// PutPolicy creates or updates the policy module with the given id.
+func (c *OpaClient) PutPolicy(id string, module []byte) error {
+	return c.do(http.MethodPut, "/v1/policies/"+escapePath(id), "text/plain", module, nil)
+}
+
+// DeletePolicy deletes the policy module with the given id.
+func (c *OpaClient) DeletePolicy(id string) error {
+	return c.do(http.MethodDelete, "/v1/policies/"+escapePath(id), "", nil, nil)
+}
+
+// ListPolicies returns the ids of all policy modules loaded into OPA.
+func (c *OpaClient) ListPolicies() ([]string, error) {
+	var resp struct {
+		Result []struct {
+			Id string `json:"id"`
+		} `json:"result"`
+	}
+	if err := c.do(http.MethodGet, "/v1/policies", "", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(resp.Result))
+	for _, p := range resp.Result {
+		ids = append(ids, p.Id)
+	}
+	return ids, nil
+}
+
+// PutData creates or overwrites the document at the given path, e.g. "foo/bar" for data.foo.bar.
+func (c *OpaClient) PutData(path string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data for %s: %w", path, err)
+	}
+	return c.do(http.MethodPut, "/v1/data/"+escapePath(path), "application/json", body, nil)
+}
+
+func (c *OpaClient) do(method string, path string, contentType string, body []byte, result interface{}) error {
+	printer.Debug("%s %s%s", method, c.baseUrl, path)
+
+	req, err := http.NewRequest(method, c.baseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: unexpected status %s: %s", method, path, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("%s %s: failed to decode response: %w", method, path, err)
+		}
+	}
+
+	return nil
+}
+
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
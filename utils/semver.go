@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	"strings"
+)
+
+// IsVersionRange reports whether s is a semver range, such as '^1.2', '~1.2.3', '>=1.0 <2.0' or '1.x', rather than a
+// plain tag or branch name.
+func IsVersionRange(s string) bool {
+	if strings.ContainsAny(s, "^~<>=*|, ") {
+		return true
+	}
+	for _, part := range strings.Split(s, ".") {
+		if part == "x" || part == "X" {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectVersion returns the tag holding the highest semver version satisfying all the given ranges, or the lowest if
+// lowest is true. Tags that aren't valid semver versions are ignored; pre-release versions are only considered if the
+// ranges themselves include a pre-release.
+func SelectVersion(ranges []string, tags []string, lowest bool) (string, error) {
+	var constraints []*semver.Constraints
+	for _, r := range ranges {
+		constraint, err := semver.NewConstraint(r)
+		if err != nil {
+			return "", fmt.Errorf("invalid version range '%s': %w", r, err)
+		}
+		constraints = append(constraints, constraint)
+	}
+
+	var best string
+	var bestVersion *semver.Version
+	for _, tag := range tags {
+		version, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+		if !satisfiesAll(version, constraints) {
+			continue
+		}
+		if bestVersion == nil || (lowest && version.LessThan(bestVersion)) || (!lowest && version.GreaterThan(bestVersion)) {
+			best = tag
+			bestVersion = version
+		}
+	}
+
+	if bestVersion == nil {
+		return "", fmt.Errorf("no version matching '%s'", strings.Join(ranges, "', '"))
+	}
+	return best, nil
+}
+
+func satisfiesAll(version *semver.Version, constraints []*semver.Constraints) bool {
+	for _, constraint := range constraints {
+		if !constraint.Check(version) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package utils
+
+import "testing"
+
+func TestIsVersionRange(t *testing.T) {
+	tests := map[string]bool{
+		"1.2.0":      false,
+		"v1.2.0":     false,
+		"latest":     false,
+		"main":       false,
+		"^1.2":       true,
+		"~1.2.3":     true,
+		">=1.0 <2.0": true,
+		"1.x":        true,
+		"*":          true,
+	}
+
+	for s, expected := range tests {
+		if actual := IsVersionRange(s); actual != expected {
+			t.Fatalf("expected IsVersionRange(%s) to be %v", s, expected)
+		}
+	}
+}
+
+func TestSelectVersion(t *testing.T) {
+	tags := []string{"latest", "v1.0.0", "1.2.0", "1.2.5", "1.3.0-rc.1", "2.0.0", "foo"}
+
+	tests := []struct {
+		note        string
+		ranges      []string
+		lowest      bool
+		expected    string
+		expectedErr bool
+	}{
+		{
+			note:     "caret",
+			ranges:   []string{"^1.0"},
+			expected: "1.2.5",
+		},
+		{
+			note:     "tilde",
+			ranges:   []string{"~1.0"},
+			expected: "v1.0.0",
+		},
+		{
+			note:     "comparison",
+			ranges:   []string{">=1.0 <3.0"},
+			expected: "2.0.0",
+		},
+		{
+			note:     "wildcard",
+			ranges:   []string{"1.x"},
+			expected: "1.2.5",
+		},
+		{
+			note:     "pre-release",
+			ranges:   []string{"~1.3.0-rc.0"},
+			expected: "1.3.0-rc.1",
+		},
+		{
+			note:     "lowest",
+			ranges:   []string{"^1.1"},
+			lowest:   true,
+			expected: "1.2.0",
+		},
+		{
+			note:     "multiple ranges",
+			ranges:   []string{"^1.0", "<1.2.5"},
+			expected: "1.2.0",
+		},
+		{
+			note:        "no match",
+			ranges:      []string{"^3.0"},
+			expectedErr: true,
+		},
+		{
+			note:        "no match for all ranges",
+			ranges:      []string{"^1.0", "^2.0"},
+			expectedErr: true,
+		},
+		{
+			note:        "invalid range",
+			ranges:      []string{">>1"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			actual, err := SelectVersion(tc.ranges, tags, tc.lowest)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
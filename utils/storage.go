@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ObjectMetadata holds the headers and custom metadata set on objects uploaded to object storage.
+type ObjectMetadata struct {
+	CacheControl string
+	ContentType  string
+	Metadata     map[string]string
+}
+
+// UploadObject uploads the file at src to an object storage location, such as s3://bucket/path, gs://bucket/path or
+// azblob://account/container/path. Uploads are delegated to the provider's CLI (aws, gsutil, az), which must be
+// installed and authenticated.
+// HTTP(S) locations are uploaded to with a PUT request.
+func UploadObject(src string, dst string, meta ObjectMetadata) error {
+	if !FileExists(src) {
+		return fmt.Errorf("file %s does not exist", src)
+	}
+
+	if strings.HasPrefix(dst, "http://") || strings.HasPrefix(dst, "https://") {
+		return uploadHTTP(src, dst, meta)
+	}
+
+	command, args, err := uploadCommand(src, dst, meta)
+	if err != nil {
+		return err
+	}
+
+	if _, err := RunCommand(command, args...); err != nil {
+		return fmt.Errorf("failed to upload %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
+
+func uploadCommand(src string, dst string, meta ObjectMetadata) (string, []string, error) {
+	u, err := url.Parse(dst)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid destination %s: %w", dst, err)
+	}
+	if u.Host == "" {
+		return "", nil, fmt.Errorf("invalid destination %s: missing bucket", dst)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		args := []string{"s3", "cp", src, dst}
+		if meta.CacheControl != "" {
+			args = append(args, "--cache-control", meta.CacheControl)
+		}
+		if meta.ContentType != "" {
+			args = append(args, "--content-type", meta.ContentType)
+		}
+		if len(meta.Metadata) > 0 {
+			pairs := make([]string, 0, len(meta.Metadata))
+			for _, k := range sortedKeys(meta.Metadata) {
+				pairs = append(pairs, fmt.Sprintf("%s=%s", k, meta.Metadata[k]))
+			}
+			args = append(args, "--metadata", strings.Join(pairs, ","))
+		}
+		return toolPath("AWS_CLI_PATH", "aws"), args, nil
+	case "gs":
+		var args []string
+		if meta.CacheControl != "" {
+			args = append(args, "-h", fmt.Sprintf("Cache-Control:%s", meta.CacheControl))
+		}
+		if meta.ContentType != "" {
+			args = append(args, "-h", fmt.Sprintf("Content-Type:%s", meta.ContentType))
+		}
+		for _, k := range sortedKeys(meta.Metadata) {
+			args = append(args, "-h", fmt.Sprintf("x-goog-meta-%s:%s", k, meta.Metadata[k]))
+		}
+		args = append(args, "cp", src, dst)
+		return toolPath("GSUTIL_PATH", "gsutil"), args, nil
+	case "azblob":
+		blob, err := azureBlobArgs(u)
+		if err != nil {
+			return "", nil, err
+		}
+		args := append([]string{"storage", "blob", "upload", "--file", src, "--overwrite"}, blob...)
+		if meta.CacheControl != "" {
+			args = append(args, "--content-cache-control", meta.CacheControl)
+		}
+		if meta.ContentType != "" {
+			args = append(args, "--content-type", meta.ContentType)
+		}
+		if len(meta.Metadata) > 0 {
+			args = append(args, "--metadata")
+			for _, k := range sortedKeys(meta.Metadata) {
+				args = append(args, fmt.Sprintf("%s=%s", k, meta.Metadata[k]))
+			}
+		}
+		return azPath(), args, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported object storage scheme '%s'; expected s3://, gs:// or azblob://", u.Scheme)
+	}
+}
+
+// azureBlobArgs returns the az CLI arguments addressing the blob of an azblob://account/container/path location,
+// authenticating as the identity az is logged in with; e.g. a user, service principal, or managed identity.
+func azureBlobArgs(u *url.URL) ([]string, error) {
+	container, name, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Host == "" || container == "" || name == "" {
+		return nil, fmt.Errorf("invalid Azure Blob Storage location %s: expected azblob://<account>/<container>/<path>",
+			u.String())
+	}
+	return []string{"--account-name", u.Host, "--container-name", container, "--name", name, "--auth-mode", "login"}, nil
+}
+
+func azPath() string {
+	return toolPath("AZ_CLI_PATH", "az")
+}
+
+// DownloadObject downloads the object at an object storage location, such as s3://bucket/path, gs://bucket/path or
+// azblob://account/container/path, or an HTTP(S) location, to the file at dst.
+func DownloadObject(src string, dst string) error {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		return DownloadFile(src, dst)
+	}
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return fmt.Errorf("invalid location %s: %w", src, err)
+	}
+
+	var command string
+	var args []string
+	switch u.Scheme {
+	case "s3":
+		command, args = toolPath("AWS_CLI_PATH", "aws"), []string{"s3", "cp", src, dst}
+	case "gs":
+		command, args = toolPath("GSUTIL_PATH", "gsutil"), []string{"cp", src, dst}
+	case "azblob":
+		blob, err := azureBlobArgs(u)
+		if err != nil {
+			return err
+		}
+		command, args = azPath(), append([]string{"storage", "blob", "download", "--file", dst, "--no-progress"}, blob...)
+	default:
+		return fmt.Errorf("unsupported object storage scheme '%s'; expected s3://, gs://, azblob:// or http(s)://",
+			u.Scheme)
+	}
+
+	if _, err := RunCommand(command, args...); err != nil {
+		return fmt.Errorf("failed to download %s: %w", src, err)
+	}
+	return nil
+}
+
+// ObjectExists reports whether an object exists at an object storage location, or an HTTP(S) location, without
+// downloading it.
+func ObjectExists(src string) (bool, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := httpDo(http.MethodHead, src, nil)
+		if err != nil {
+			return false, fmt.Errorf("failed to check %s: %w", src, err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode >= 200 && resp.StatusCode <= 299, nil
+	}
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return false, fmt.Errorf("invalid location %s: %w", src, err)
+	}
+
+	var command string
+	var args []string
+	switch u.Scheme {
+	case "s3":
+		command, args = toolPath("AWS_CLI_PATH", "aws"), []string{"s3", "ls", src}
+	case "gs":
+		command, args = toolPath("GSUTIL_PATH", "gsutil"), []string{"-q", "stat", src}
+	case "azblob":
+		blob, err := azureBlobArgs(u)
+		if err != nil {
+			return false, err
+		}
+		// az reports whether the blob exists, rather than failing for missing blobs
+		out, err := RunCommand(azPath(), append([]string{"storage", "blob", "exists", "--query", "exists", "--output",
+			"tsv"}, blob...)...)
+		return err == nil && strings.TrimSpace(out) == "true", nil
+	default:
+		return false, fmt.Errorf("unsupported object storage scheme '%s'; expected s3://, gs://, azblob:// or http(s)://",
+			u.Scheme)
+	}
+
+	// Both CLIs exit with a non-zero status for missing objects
+	_, err = RunCommand(command, args...)
+	return err == nil, nil
+}
+
+func uploadHTTP(src string, dst string, meta ObjectMetadata) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	req, err := http.NewRequest(http.MethodPut, dst, f)
+	if err != nil {
+		return fmt.Errorf("invalid destination %s: %w", dst, err)
+	}
+	if meta.CacheControl != "" {
+		req.Header.Set("Cache-Control", meta.CacheControl)
+	}
+	if meta.ContentType != "" {
+		req.Header.Set("Content-Type", meta.ContentType)
+	}
+	authorize(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to %s: %w", src, dst, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to upload %s to %s: unexpected status %s", src, dst, resp.Status)
+	}
+	return nil
+}
+
+// toolPath returns the location of an external executable, overridable through the given environment variable.
+func toolPath(envVar string, defaultPath string) string {
+	if location, ok := os.LookupEnv(envVar); ok {
+		return location
+	}
+	return defaultPath
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUploadCommand(t *testing.T) {
+	tests := []struct {
+		note            string
+		dst             string
+		meta            ObjectMetadata
+		expectedCommand string
+		expectedArgs    []string
+		expectedErr     bool
+	}{
+		{
+			note:            "s3, no metadata",
+			dst:             "s3://bucket/path/bundle.tar.gz",
+			expectedCommand: "aws",
+			expectedArgs:    []string{"s3", "cp", "bundle.tar.gz", "s3://bucket/path/bundle.tar.gz"},
+		},
+		{
+			note: "s3, with metadata",
+			dst:  "s3://bucket/bundle.tar.gz",
+			meta: ObjectMetadata{
+				CacheControl: "max-age=60",
+				ContentType:  "application/gzip",
+				Metadata:     map[string]string{"revision": "abc", "env": "dev"},
+			},
+			expectedCommand: "aws",
+			expectedArgs: []string{"s3", "cp", "bundle.tar.gz", "s3://bucket/bundle.tar.gz",
+				"--cache-control", "max-age=60",
+				"--content-type", "application/gzip",
+				"--metadata", "env=dev,revision=abc"},
+		},
+		{
+			note: "gs, with metadata",
+			dst:  "gs://bucket/bundle.tar.gz",
+			meta: ObjectMetadata{
+				CacheControl: "no-cache",
+				Metadata:     map[string]string{"revision": "abc"},
+			},
+			expectedCommand: "gsutil",
+			expectedArgs: []string{
+				"-h", "Cache-Control:no-cache",
+				"-h", "x-goog-meta-revision:abc",
+				"cp", "bundle.tar.gz", "gs://bucket/bundle.tar.gz"},
+		},
+		{
+			note: "azblob, with metadata",
+			dst:  "azblob://account/container/path/bundle.tar.gz",
+			meta: ObjectMetadata{
+				ContentType: "application/gzip",
+				Metadata:    map[string]string{"revision": "abc", "env": "dev"},
+			},
+			expectedCommand: "az",
+			expectedArgs: []string{"storage", "blob", "upload", "--file", "bundle.tar.gz", "--overwrite",
+				"--account-name", "account", "--container-name", "container", "--name", "path/bundle.tar.gz",
+				"--auth-mode", "login",
+				"--content-type", "application/gzip",
+				"--metadata", "env=dev", "revision=abc"},
+		},
+		{
+			note:        "azblob, missing container",
+			dst:         "azblob://account/bundle.tar.gz",
+			expectedErr: true,
+		},
+		{
+			note:        "unsupported scheme",
+			dst:         "https://example.com/bundle.tar.gz",
+			expectedErr: true,
+		},
+		{
+			note:        "missing bucket",
+			dst:         "s3:///bundle.tar.gz",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			command, args, err := uploadCommand("bundle.tar.gz", tc.dst, tc.meta)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if command != tc.expectedCommand {
+				t.Fatalf("expected command %s, got %s", tc.expectedCommand, command)
+			}
+			if !reflect.DeepEqual(args, tc.expectedArgs) {
+				t.Fatalf("expected args:\n\n%v\n\ngot:\n\n%v", tc.expectedArgs, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxThrottledRead bounds the size of single reads of throttled bodies, so that delays are spread evenly.
+const maxThrottledRead = 32 * 1024
+
+var (
+	throttleMu    sync.Mutex
+	baseTransport http.RoundTripper
+	// throttleLimit is the configured rate limit, in bytes per second; 0 if unlimited
+	throttleLimit int64
+	// tlsConfig is the configured TLS config of HTTPS requests; nil for the default
+	tlsConfig *tls.Config
+	// insecureHosts are the host names whose certificates aren't verified
+	insecureHosts map[string]bool
+)
+
+// ThrottleHTTP limits the combined rate at which the bodies of all responses received through http.DefaultTransport
+// are read, to bytesPerSecond; covering all HTTP(S) downloads of OCI artifacts, git repositories and cache entries.
+// A rate of 0 removes the limit.
+func ThrottleHTTP(bytesPerSecond int64) {
+	throttleMu.Lock()
+	defer throttleMu.Unlock()
+
+	throttleLimit = bytesPerSecond
+	installTransport()
+}
+
+// installTransport replaces http.DefaultTransport with the original default transport, configured with the TLS config,
+// bounded by the timeout, and throttled to the rate limit, if set. Must be called with throttleMu held.
+func installTransport() {
+	if baseTransport == nil {
+		baseTransport = http.DefaultTransport
+	}
+	transport := baseTransport
+	if base, ok := baseTransport.(*http.Transport); ok && tlsConfig != nil {
+		secure := base.Clone()
+		secure.TLSClientConfig = tlsConfig
+		transport = secure
+		if len(insecureHosts) > 0 {
+			insecure := base.Clone()
+			insecure.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			transport = &hostTransport{secure: secure, insecure: insecure, insecureHosts: insecureHosts}
+		}
+	}
+	if fetchTimeout > 0 {
+		transport = &timeoutTransport{base: transport, timeout: fetchTimeout}
+	}
+	if throttleLimit > 0 {
+		transport = &throttledTransport{
+			base:    transport,
+			limiter: &rateLimiter{rate: throttleLimit},
+		}
+	}
+	http.DefaultTransport = transport
+}
+
+type throttledTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &throttledBody{ReadCloser: resp.Body, limiter: t.limiter}
+	return resp, nil
+}
+
+type throttledBody struct {
+	io.ReadCloser
+	limiter *rateLimiter
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > maxThrottledRead {
+		p = p[:maxThrottledRead]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.limiter.wait(n)
+	return n, err
+}
+
+// rateLimiter delays consumers of bytes, so that their combined rate doesn't exceed the limit.
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate int64
+	// next is the time at which the bytes consumed so far are paid for
+	next time.Time
+}
+
+func (l *rateLimiter) wait(n int) {
+	if n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottleHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 4000)))
+	}))
+	defer server.Close()
+
+	ThrottleHTTP(10000)
+	defer ThrottleHTTP(0)
+
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 4000 {
+		t.Fatalf("expected 4000 bytes, got %d", len(body))
+	}
+	// 4000 bytes at 10000 bytes/s take 400ms
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("expected throttled download, took %s", elapsed)
+	}
+
+	ThrottleHTTP(0)
+	if _, ok := http.DefaultTransport.(*throttledTransport); ok {
+		t.Fatal("expected throttling to be removed")
+	}
+}
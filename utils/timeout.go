@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// fetchTimeout is the configured time limit of network operations; 0 if unlimited
+var fetchTimeout time.Duration
+
+// ConfigureTimeout limits the duration of every request made through http.DefaultTransport, including the reading of
+// its response, and of every git CLI invocation, to timeout. A timeout of 0 removes the limit.
+func ConfigureTimeout(timeout time.Duration) {
+	throttleMu.Lock()
+	defer throttleMu.Unlock()
+	fetchTimeout = timeout
+	installTransport()
+}
+
+// FetchContext returns a context bounded by the configured timeout of network operations, if any.
+func FetchContext() (context.Context, context.CancelFunc) {
+	throttleMu.Lock()
+	timeout := fetchTimeout
+	throttleMu.Unlock()
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+type timeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody cancels the context of its request when closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConfigureTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	t.Cleanup(func() { ConfigureTimeout(0) })
+
+	ConfigureTimeout(50 * time.Millisecond)
+	_, err := DownloadBytes(server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected request to time out, got %v", err)
+	}
+
+	ctx, cancel := FetchContext()
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("expected fetch context with deadline")
+	}
+}
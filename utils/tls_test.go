@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigureTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(block), 0644); err != nil {
+		t.Fatal(err)
+	}
+	otherCaFile := filepath.Join(t.TempDir(), "other.pem")
+	if err := os.WriteFile(otherCaFile, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ConfigureTLS("", nil) })
+
+	tests := []struct {
+		note          string
+		caFile        string
+		insecureHosts []string
+		expectedErr   bool
+	}{
+		{
+			note:        "untrusted certificate",
+			expectedErr: true,
+		},
+		{
+			note:   "CA file",
+			caFile: caFile,
+		},
+		{
+			note:          "insecure host",
+			insecureHosts: []string{u.Host},
+		},
+		{
+			note:          "other insecure host",
+			insecureHosts: []string{"git.example.com"},
+			expectedErr:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			if err := ConfigureTLS(tc.caFile, tc.insecureHosts); err != nil {
+				t.Fatal(err)
+			}
+			http.DefaultClient.CloseIdleConnections()
+
+			_, err := DownloadBytes(server.URL)
+			if tc.expectedErr && err == nil {
+				t.Fatal("expected certificate verification to fail")
+			} else if !tc.expectedErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	if err := ConfigureTLS(otherCaFile, nil); err == nil {
+		t.Fatal("expected error for CA file without certificates")
+	}
+}
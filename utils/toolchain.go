@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/johanfylling/odm/printer"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	toolchainsDir       = "toolchains"
+	currentToolchain    = "current"
+	defaultOpaDownloads = "https://openpolicyagent.org/downloads"
+)
+
+// CacheDir returns the directory where ODM caches data shared between projects.
+// Defaults to 'odm' in the user cache directory, and can be overridden through the ODM_CACHE_DIR environment variable.
+func CacheDir() (string, error) {
+	if dir, ok := os.LookupEnv("ODM_CACHE_DIR"); ok {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine cache directory: %w", err)
+	}
+	return filepath.Join(dir, "odm"), nil
+}
+
+// Toolchains manages locally cached OPA binaries.
+type Toolchains struct {
+	dir          string
+	downloadBase string
+}
+
+// NewToolchains returns a manager for the OPA binaries cached in the ODM cache directory.
+// Binaries are downloaded from the official OPA download site, which can be overridden through the
+// ODM_OPA_DOWNLOAD_URL environment variable, e.g. for using a mirror.
+func NewToolchains() (*Toolchains, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Toolchains{
+		dir:          filepath.Join(cacheDir, toolchainsDir),
+		downloadBase: strings.TrimSuffix(toolPath("ODM_OPA_DOWNLOAD_URL", defaultOpaDownloads), "/"),
+	}, nil
+}
+
+// List returns the versions of all installed OPA binaries.
+func (t *Toolchains) List() ([]string, error) {
+	if !FileExists(t.dir) {
+		return nil, nil
+	}
+
+	children, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read toolchain directory %s: %w", t.dir, err)
+	}
+
+	var versions []string
+	for _, child := range children {
+		if child.IsDir() && FileExists(t.binaryPath(child.Name())) {
+			versions = append(versions, child.Name())
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Install downloads the OPA binary of the given version, verifying its checksum against the one published
+// alongside it. Installing an already installed version is a no-op.
+func (t *Toolchains) Install(version string) error {
+	version = normalizeOpaVersion(version)
+	if err := checkOpaVersion(version); err != nil {
+		return err
+	}
+	binaryPath := t.binaryPath(version)
+	if FileExists(binaryPath) {
+		printer.Info("OPA %s already installed", version)
+		return nil
+	}
+
+	name := opaBinaryName(runtime.GOOS, runtime.GOARCH)
+	url := fmt.Sprintf("%s/%s/%s", t.downloadBase, version, name)
+
+	printer.Info("Installing OPA %s from %s", version, url)
+
+	checksumFile, err := DownloadBytes(url + ".sha256")
+	if err != nil {
+		return fmt.Errorf("failed to download checksum for OPA %s: %w", version, err)
+	}
+	fields := strings.Fields(string(checksumFile))
+	if len(fields) == 0 {
+		return fmt.Errorf("invalid checksum file for OPA %s", version)
+	}
+	expectedChecksum := fields[0]
+
+	versionDir := filepath.Dir(binaryPath)
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		return fmt.Errorf("failed to create toolchain directory %s: %w", versionDir, err)
+	}
+
+	tmpPath := binaryPath + ".download"
+	defer func() { _ = os.Remove(tmpPath) }()
+	if err := DownloadFile(url, tmpPath); err != nil {
+		_ = os.RemoveAll(versionDir)
+		return err
+	}
+
+	if checksum, err := fileChecksum(tmpPath); err != nil {
+		_ = os.RemoveAll(versionDir)
+		return err
+	} else if checksum != expectedChecksum {
+		_ = os.RemoveAll(versionDir)
+		return fmt.Errorf("checksum mismatch for OPA %s: expected %s, got %s", version, expectedChecksum, checksum)
+	}
+
+	if err := os.Chmod(tmpPath, 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, binaryPath)
+}
+
+// Remove deletes the installed OPA binary of the given version.
+func (t *Toolchains) Remove(version string) error {
+	version = normalizeOpaVersion(version)
+	if err := checkOpaVersion(version); err != nil {
+		return err
+	}
+	if !FileExists(t.binaryPath(version)) {
+		return fmt.Errorf("OPA %s is not installed", version)
+	}
+
+	if current, _ := t.Current(); current == version {
+		if err := os.Remove(filepath.Join(t.dir, currentToolchain)); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(filepath.Join(t.dir, version))
+}
+
+// Use sets the given installed version as the default OPA binary used by ODM.
+func (t *Toolchains) Use(version string) error {
+	version = normalizeOpaVersion(version)
+	if err := checkOpaVersion(version); err != nil {
+		return err
+	}
+	if !FileExists(t.binaryPath(version)) {
+		return fmt.Errorf("OPA %s is not installed", version)
+	}
+	return os.WriteFile(filepath.Join(t.dir, currentToolchain), []byte(version), 0644)
+}
+
+// Current returns the version of the default OPA binary, or an empty string if none is set.
+func (t *Toolchains) Current() (string, error) {
+	bs, err := os.ReadFile(filepath.Join(t.dir, currentToolchain))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
+
+// Path returns the location of the installed OPA binary of the given version.
+func (t *Toolchains) Path(version string) (string, error) {
+	normalized := normalizeOpaVersion(version)
+	if err := checkOpaVersion(normalized); err != nil {
+		return "", err
+	}
+	path := t.binaryPath(normalized)
+	if !FileExists(path) {
+		return "", fmt.Errorf("OPA %s is not installed", version)
+	}
+	return path, nil
+}
+
+func (t *Toolchains) binaryPath(version string) string {
+	name := "opa"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(t.dir, version, name)
+}
+
+// currentOpaPath returns the location of the default toolchain OPA binary, or false if none is set.
+func currentOpaPath() (string, bool) {
+	toolchains, err := NewToolchains()
+	if err != nil {
+		return "", false
+	}
+	version, err := toolchains.Current()
+	if err != nil || version == "" {
+		return "", false
+	}
+	path, err := toolchains.Path(version)
+	if err != nil {
+		printer.Debug("Default OPA toolchain %s not found: %s", version, err)
+		return "", false
+	}
+	return path, true
+}
+
+// normalizeOpaVersion prefixes numeric versions with 'v', as OPA releases are tagged; other versions, such as 'latest',
+// are left as-is.
+func normalizeOpaVersion(version string) string {
+	if version == "" || version[0] < '0' || version[0] > '9' {
+		return version
+	}
+	return "v" + version
+}
+
+// checkOpaVersion returns an error if the version isn't usable as the name of a directory within the toolchain
+// directory, such as a version containing path separators or '..', which could address files outside of it.
+func checkOpaVersion(version string) error {
+	if version == "" || version == "." || strings.ContainsAny(version, `/\`) || strings.Contains(version, "..") {
+		return fmt.Errorf("invalid OPA version %q", version)
+	}
+	return nil
+}
+
+func opaBinaryName(goos string, goarch string) string {
+	name := fmt.Sprintf("opa_%s_%s", goos, goarch)
+	switch {
+	case goos == "windows":
+		name += ".exe"
+	case goos == "linux", goos == "darwin" && goarch == "arm64":
+		name += "_static"
+	}
+	return name
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
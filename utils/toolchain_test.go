@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestToolchains(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho opa\n")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(binary))
+	name := opaBinaryName(runtime.GOOS, runtime.GOARCH)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v0.55.0/" + name:
+			_, _ = w.Write(binary)
+		case "/v0.55.0/" + name + ".sha256":
+			_, _ = w.Write([]byte(checksum + "  " + name + "\n"))
+		case "/v0.56.0/" + name:
+			_, _ = w.Write([]byte("tampered"))
+		case "/v0.56.0/" + name + ".sha256":
+			_, _ = w.Write([]byte(checksum + "  " + name + "\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("ODM_CACHE_DIR", t.TempDir())
+	t.Setenv("ODM_OPA_DOWNLOAD_URL", server.URL)
+
+	toolchains, err := NewToolchains()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := toolchains.Install("0.55.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = toolchains.Install("v0.56.0")
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch error, got %v", err)
+	}
+
+	if err := toolchains.Install("v0.57.0"); err == nil {
+		t.Fatal("expected error for missing version")
+	}
+
+	versions, err := toolchains.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(versions, []string{"v0.55.0"}) {
+		t.Fatalf("expected only v0.55.0 to be installed, got %v", versions)
+	}
+
+	if err := toolchains.Use("v0.56.0"); err == nil {
+		t.Fatal("expected error using uninstalled version")
+	}
+	if err := toolchains.Use("v0.55.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	path, ok := currentOpaPath()
+	if !ok || path != toolchains.binaryPath("v0.55.0") {
+		t.Fatalf("expected current OPA path to be %s, got %s", toolchains.binaryPath("v0.55.0"), path)
+	}
+
+	if err := toolchains.Remove("0.55.0"); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := toolchains.Current(); current != "" {
+		t.Fatalf("expected no current version after removal, got %s", current)
+	}
+	if _, ok := currentOpaPath(); ok {
+		t.Fatal("expected no current OPA path after removal")
+	}
+}
+
+func TestNormalizeOpaVersion(t *testing.T) {
+	tests := []struct {
+		note     string
+		version  string
+		expected string
+	}{
+		{
+			note:     "numeric",
+			version:  "0.55.0",
+			expected: "v0.55.0",
+		},
+		{
+			note:     "prefixed",
+			version:  "v0.55.0",
+			expected: "v0.55.0",
+		},
+		{
+			note:     "latest",
+			version:  "latest",
+			expected: "latest",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			if actual := normalizeOpaVersion(tc.version); actual != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestToolchainsInvalidVersion(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ODM_CACHE_DIR", dir)
+	t.Setenv("ODM_OPA_DOWNLOAD_URL", "http://localhost:0")
+
+	toolchains, err := NewToolchains()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{"", ".", "..", "../../x", "v0.55.0/../../x", `..\x`, "/tmp/x"} {
+		t.Run(version, func(t *testing.T) {
+			if err := toolchains.Install(version); err == nil || !strings.Contains(err.Error(), "invalid OPA version") {
+				t.Fatalf("expected invalid version error installing, got %v", err)
+			}
+			if err := toolchains.Remove(version); err == nil || !strings.Contains(err.Error(), "invalid OPA version") {
+				t.Fatalf("expected invalid version error removing, got %v", err)
+			}
+			if err := toolchains.Use(version); err == nil || !strings.Contains(err.Error(), "invalid OPA version") {
+				t.Fatalf("expected invalid version error using, got %v", err)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/johanfylling/odm/printer"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
@@ -121,23 +122,95 @@ func CopyAll(src string, dstDir string, exclude []string, ignoreEmptyFiles bool)
 		}
 	} else {
 		dstFile := dstDir + "/" + info.Name()
-		printer.Debug("Copying file %s to %s", src, dstFile)
-		data, err := os.ReadFile(src)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", src, err)
-		}
-		if ignoreEmptyFiles && len(data) == 0 {
+		if ignoreEmptyFiles && info.Size() == 0 {
 			printer.Debug("Skipping empty file", src)
 			return nil
 		}
-		if err := os.WriteFile(dstFile, data, 0644); err != nil {
-			return fmt.Errorf("failed to write file %s: %w", dstFile, err)
+		printer.Debug("Copying file %s to %s", src, dstFile)
+		if err := streamFile(src, dstFile, info); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// streamFile copies the file src, described by info, to dst without reading it into memory, as dependencies may carry
+// large data files. The permissions and modification time of src are preserved. An existing dst is replaced rather than
+// written through, as it may be read-only, or linked from the cache.
+func streamFile(src string, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", src, err)
+	}
+	defer func() { _ = in.Close() }()
+
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace file %s: %w", dst, err)
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to write file %s: %w", dst, err)
+	}
+	// Permissions are set explicitly, as those given on creation are subject to the umask
+	if err := out.Chmod(info.Mode().Perm()); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to set permissions of file %s: %w", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", dst, err)
+	}
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to set modification time of file %s: %w", dst, err)
+	}
+	return nil
+}
+
+// ExcludeFiles returns the smallest set of paths covering all files in root, except those for which exclude returns true.
+// An excluded directory excludes its entire content. Root itself is returned if nothing in it is excluded; otherwise, its
+// remaining entries are covered individually.
+func ExcludeFiles(root string, exclude func(path string, info os.FileInfo) bool) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if exclude(root, info) {
+		return nil, nil
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", root, err)
+	}
+
+	var paths []string
+	complete := true
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		covered, err := ExcludeFiles(path, exclude)
+		if err != nil {
+			return nil, err
+		}
+		if len(covered) != 1 || covered[0] != path {
+			complete = false
+		}
+		paths = append(paths, covered...)
+	}
+
+	if complete {
+		return []string{root}, nil
+	}
+	return paths, nil
+}
+
 func contains(arr []string, str string) bool {
 	for _, item := range arr {
 		if item == str {
@@ -148,8 +221,15 @@ func contains(arr []string, str string) bool {
 }
 
 func RunCommand(command string, args ...string) (string, error) {
+	return RunCommandIn("", command, args...)
+}
+
+// RunCommandIn runs the command like RunCommand, with dir as its working directory; or the current working directory, if
+// dir is empty.
+func RunCommandIn(dir string, command string, args ...string) (string, error) {
 	printer.Debug("Executing '%s' with args: %s", command, args)
 	cmd := exec.Command(command, args...)
+	cmd.Dir = dir
 	var outb, errb bytes.Buffer
 	cmd.Stdout = &outb
 	cmd.Stderr = &errb
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyAll(t *testing.T) {
+	modTime := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		note             string
+		content          string
+		mode             os.FileMode
+		ignoreEmptyFiles bool
+		expectedCopy     bool
+	}{
+		{
+			note:         "data file",
+			content:      `{"users": ["alice", "bob"]}`,
+			mode:         0644,
+			expectedCopy: true,
+		},
+		{
+			note:         "read-only file",
+			content:      "package lib",
+			mode:         0444,
+			expectedCopy: true,
+		},
+		{
+			note:         "executable file",
+			content:      "#!/bin/sh",
+			mode:         0755,
+			expectedCopy: true,
+		},
+		{
+			note:         "empty file",
+			mode:         0644,
+			expectedCopy: true,
+		},
+		{
+			note:             "empty file, ignored",
+			mode:             0644,
+			ignoreEmptyFiles: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.note, func(t *testing.T) {
+			srcDir := t.TempDir()
+			dstDir := t.TempDir()
+			src := filepath.Join(srcDir, "sub", "file")
+			if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(src, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(src, tc.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(src, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := CopyAll(srcDir, dstDir, nil, tc.ignoreEmptyFiles); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := filepath.Join(dstDir, "sub", "file")
+			info, err := os.Stat(dst)
+			if !tc.expectedCopy {
+				if !os.IsNotExist(err) {
+					t.Fatalf("expected %s not to be copied", dst)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tc.mode {
+				t.Fatalf("expected mode %v, got %v", tc.mode, info.Mode().Perm())
+			}
+			if !info.ModTime().Equal(modTime) {
+				t.Fatalf("expected modification time %v, got %v", modTime, info.ModTime())
+			}
+			if bs, err := os.ReadFile(dst); err != nil || string(bs) != tc.content {
+				t.Fatalf("expected content %q, got %q (%v)", tc.content, bs, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// Watcher detects changes to files under a set of paths by comparing their size and modification time between polls.
+type Watcher struct {
+	paths   []string
+	exclude []string
+	last    string
+}
+
+// NewWatcher creates a watcher for the given files and directories.
+// Files and directories with a name in exclude are ignored.
+func NewWatcher(paths []string, exclude []string) *Watcher {
+	w := &Watcher{
+		paths:   paths,
+		exclude: exclude,
+	}
+	w.last = w.fingerprint()
+	return w
+}
+
+// Changed reports whether any watched file has been added, removed, or modified since the last call.
+func (w *Watcher) Changed() bool {
+	current := w.fingerprint()
+	if current == w.last {
+		return false
+	}
+	w.last = current
+	return true
+}
+
+func (w *Watcher) fingerprint() string {
+	var entries []string
+	for _, path := range w.paths {
+		_ = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Files may come and go while walking; missing files are part of the fingerprint by their absence
+				return nil
+			}
+			if contains(w.exclude, d.Name()) && file != path {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info, err := d.Info(); err == nil && !d.IsDir() {
+				entries = append(entries, fmt.Sprintf("%s:%d:%d", file, info.Size(), info.ModTime().UnixNano()))
+			}
+			return nil
+		})
+	}
+	sort.Strings(entries)
+
+	h := sha256.New()
+	for _, e := range entries {
+		h.Write([]byte(e))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}